@@ -1,8 +1,9 @@
 package main
 
 import (
+	"errors"
 	"flag"
-	"sync"
+	"net/http"
 	"time"
 
 	scrapers "github.com/diadata-org/diadata/internal/pkg/exchange-scrapers"
@@ -10,7 +11,6 @@ import (
 	"github.com/diadata-org/diadata/pkg/dia/helpers/configCollectors"
 	"github.com/diadata-org/diadata/pkg/dia/helpers/kafkaHelper"
 	models "github.com/diadata-org/diadata/pkg/model"
-	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,33 +20,19 @@ func init() {
 	log = logrus.New()
 }
 
-func handleTrades(c chan *dia.Trade, wg *sync.WaitGroup, w *kafka.Writer, exchange string) {
-	lastTradeTime := time.Now()
-	watchdogDelay := scrapers.Exchanges[exchange].WatchdogDelay
-	t := time.NewTicker(time.Duration(watchdogDelay) * time.Second)
-	for {
-		select {
-		case <-t.C:
-			duration := time.Since(lastTradeTime)
-			if duration > time.Duration(watchdogDelay)*time.Second {
-				log.Error(duration)
-				panic("frozen? ")
-			}
-		case t, ok := <-c:
-			if !ok {
-				wg.Done()
-				log.Error("handleTrades")
-				return
-			}
-			lastTradeTime = time.Now()
-			kafkaHelper.WriteMessage(w, t)
-		}
-	}
-}
-
 var (
-	exchange         = flag.String("exchange", "", "which exchange")
-	onePairPerSymbol = flag.Bool("onePairPerSymbol", false, "one Pair max Per Symbol ?")
+	exchange           = flag.String("exchange", "", "which exchange")
+	onePairPerSymbol   = flag.Bool("onePairPerSymbol", false, "one Pair max Per Symbol ?")
+	pairTimeout        = flag.Duration("pairTimeout", 0, "time without trades after which a pair is restarted, defaults to the watchdog delay of the exchange")
+	scraperTimeout     = flag.Duration("scraperTimeout", 0, "time without trades of any pair after which the scraper is restarted, defaults to the watchdog delay of the exchange")
+	maxPairRestarts    = flag.Int("maxPairRestarts", 3, "restarts of a pair without trades before it is marked as failed")
+	maxScraperRestarts = flag.Int("maxScraperRestarts", 3, "restarts of the scraper without trades before the collector exits")
+	healthAddr         = flag.String("healthAddr", ":8080", "address serving the health of the pairs, empty to disable")
+	reloadInterval     = flag.Duration("reloadInterval", 5*time.Minute, "interval of reloading the available pairs of the exchange")
+	backfillFrom       = flag.String("backfillFrom", "", "backfill the trades since this RFC3339 time and exit instead of scraping")
+	backfillTo         = flag.String("backfillTo", "", "backfill the trades until this RFC3339 time, defaults to now")
+	backfillTarget     = flag.String("backfillTarget", "kafka", "where backfilled trades are written to: kafka or influx")
+	backfillChunk      = flag.Duration("backfillChunk", 24*time.Hour, "interval of trades fetched and written at once while backfilling")
 )
 
 func init() {
@@ -61,6 +47,76 @@ func init() {
 	}
 }
 
+// reloadPairs scrapes the pairs added to the available pairs of the exchange, e.g. by the
+// pairDiscoveryService, and stops scraping removed pairs.
+func reloadPairs(ds models.Datastore, supervisor *scrapers.Supervisor) {
+	ticker := time.NewTicker(*reloadInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pairsExchange, err := ds.GetAvailablePairsForExchange(*exchange)
+		// the scraped pairs are kept if the available pairs are missing
+		if err != nil || len(pairsExchange) == 0 {
+			continue
+		}
+		added, removed := supervisor.SyncPairs(pairsExchange, *onePairPerSymbol)
+		if len(added) > 0 || len(removed) > 0 {
+			log.Infof("reloaded pairs: %d added, %d removed", len(added), len(removed))
+		}
+	}
+}
+
+// backfill writes the past trades of @pairsExchange to the trades topic or to influx.
+func backfill(apiScraper scrapers.APIScraper, pairsExchange []dia.Pair) error {
+	historicalScraper, ok := apiScraper.(scrapers.HistoricalScraper)
+	if !ok {
+		return errors.New("exchange " + *exchange + " does not support backfilling")
+	}
+	from, err := time.Parse(time.RFC3339, *backfillFrom)
+	if err != nil {
+		return err
+	}
+	to := time.Now()
+	if *backfillTo != "" {
+		to, err = time.Parse(time.RFC3339, *backfillTo)
+		if err != nil {
+			return err
+		}
+	}
+
+	var handle func([]*dia.Trade) error
+	switch *backfillTarget {
+	case "kafka":
+		w := kafkaHelper.NewWriter(kafkaHelper.TopicTrades)
+		defer w.Close()
+		handle = func(trades []*dia.Trade) error {
+			for _, t := range trades {
+				if err := kafkaHelper.WriteMessage(w, t); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case "influx":
+		ds, err := models.NewDataStore()
+		if err != nil {
+			return err
+		}
+		handle = func(trades []*dia.Trade) error {
+			for _, t := range trades {
+				if err := ds.SaveTradeInflux(t); err != nil {
+					return err
+				}
+			}
+			return ds.Flush()
+		}
+	default:
+		return errors.New("unknown backfill target " + *backfillTarget)
+	}
+
+	log.Infof("backfilling trades of %s from %v to %v", *exchange, from, to)
+	return scrapers.Backfill(historicalScraper, pairsExchange, *onePairPerSymbol, from, to, *backfillChunk, handle)
+}
+
 // main manages all PairScrapers and handles incoming trade information
 func main() {
 
@@ -82,35 +138,38 @@ func main() {
 	if err != nil {
 		log.Warning("no config for exchange's api ", err)
 	}
-	es := scrapers.NewAPIScraper(*exchange, configApi.ApiKey, configApi.SecretKey)
+	if *backfillFrom != "" {
+		err = backfill(scrapers.NewAPIScraper(*exchange, configApi.ApiKey, configApi.SecretKey), pairsExchange)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	supervisor := scrapers.NewSupervisor(*exchange, scrapers.SupervisorPolicy{
+		PairTimeout:        *pairTimeout,
+		ScraperTimeout:     *scraperTimeout,
+		MaxPairRestarts:    *maxPairRestarts,
+		MaxScraperRestarts: *maxScraperRestarts,
+	}, func() scrapers.APIScraper {
+		return scrapers.NewAPIScraper(*exchange, configApi.ApiKey, configApi.SecretKey)
+	}, ds)
 
 	w := kafkaHelper.NewWriter(kafkaHelper.TopicTrades)
 	defer w.Close()
 
-	wg := sync.WaitGroup{}
-
-	pairs := make(map[string]string)
+	supervisor.SyncPairs(pairsExchange, *onePairPerSymbol)
+	go reloadPairs(ds, supervisor)
 
-	for _, configPair := range pairsExchange {
-		dontAddPair := false
-		if *onePairPerSymbol {
-			_, dontAddPair = pairs[configPair.Symbol]
-			pairs[configPair.Symbol] = configPair.Symbol
-		}
-		if dontAddPair {
-			log.Println("Skipping pair:", configPair.Symbol, configPair.ForeignName, "on exchange", *exchange)
-		} else {
-			log.Println("Adding pair:", configPair.Symbol, configPair.ForeignName, "on exchange", *exchange)
-			_, err := es.ScrapePair(dia.Pair{
-				Symbol:      configPair.Symbol,
-				ForeignName: configPair.ForeignName})
-			if err != nil {
-				log.Println(err)
-			} else {
-				wg.Add(1)
-			}
-		}
-		defer wg.Wait()
+	if *healthAddr != "" {
+		go func() {
+			http.Handle("/health", supervisor)
+			log.Errorln("health", http.ListenAndServe(*healthAddr, nil))
+		}()
 	}
-	go handleTrades(es.Channel(), &wg, w, *exchange)
+
+	err = supervisor.Run(func(t *dia.Trade) {
+		kafkaHelper.WriteMessage(w, t)
+	})
+	log.Fatal(err)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	scrapers "github.com/diadata-org/diadata/internal/pkg/exchange-scrapers"
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/configCollectors"
+	models "github.com/diadata-org/diadata/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+var log *logrus.Logger
+
+func init() {
+	log = logrus.New()
+}
+
+var (
+	exchange = flag.String("exchange", "", "which exchange")
+	interval = flag.Duration("interval", time.Minute, "interval of storing the metrics of the order books")
+)
+
+func init() {
+	flag.Parse()
+	if *exchange == "" {
+		flag.Usage()
+		log.Fatal("exchange is required")
+	}
+}
+
+// saveMetrics stores the metrics of the synced order books of @pairs.
+func saveMetrics(ds models.Datastore, scraper scrapers.OrderBookScraper, pairs []dia.Pair) {
+	for _, pair := range pairs {
+		book, err := scraper.OrderBook(pair.ForeignName)
+		if err != nil {
+			log.Warnln("metrics of", pair.ForeignName, err)
+			continue
+		}
+		price, err := ds.GetPriceUSD(pair.Symbol)
+		if err != nil || price == 0 {
+			log.Warnln("no price of", pair.Symbol, err)
+			continue
+		}
+		if err := ds.SaveOrderBookMetricsInflux(book.Metrics(price)); err != nil {
+			log.Errorln("SaveOrderBookMetricsInflux", err)
+		}
+	}
+	if err := ds.Flush(); err != nil {
+		log.Errorln("Flush", err)
+	}
+}
+
+// main maintains the order books of all pairs of the exchange and periodically stores their
+// spread and depth
+func main() {
+	ds, err := models.NewDataStore()
+	if err != nil {
+		log.Fatal("NewDataStore:", err)
+	}
+
+	scraper := scrapers.NewOrderBookScraper(*exchange)
+	if scraper == nil {
+		log.Fatal("order books of " + *exchange + " are not scraped")
+	}
+	defer scraper.Close()
+
+	pairsExchange, err := ds.GetAvailablePairsForExchange(*exchange)
+	if err != nil || len(pairsExchange) == 0 {
+		log.Error("error on GetAvailablePairsForExchange", err)
+		cc := configCollectors.NewConfigCollectors(*exchange, ".json")
+		pairsExchange = cc.AllPairs()
+	}
+
+	var pairs []dia.Pair
+	for _, pair := range pairsExchange {
+		if pair.Ignore {
+			continue
+		}
+		if err := scraper.ScrapeOrderBook(pair); err != nil {
+			log.Errorln("ScrapeOrderBook", pair.ForeignName, err)
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+	log.Infof("scraping the order books of %d pairs", len(pairs))
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		saveMetrics(ds, scraper, pairs)
+	}
+}
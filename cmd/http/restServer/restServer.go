@@ -9,8 +9,11 @@ import (
 	_ "github.com/diadata-org/diadata/api/docs"
 	"github.com/diadata-org/diadata/pkg/dia"
 	"github.com/diadata-org/diadata/pkg/dia/helpers/kafkaHelper"
+	"github.com/diadata-org/diadata/pkg/http/restServer/authApi"
 	"github.com/diadata-org/diadata/pkg/http/restServer/diaApi"
+	"github.com/diadata-org/diadata/pkg/http/restServer/graphqlApi"
 	"github.com/diadata-org/diadata/pkg/http/restServer/kafkaApi"
+	"github.com/diadata-org/diadata/pkg/http/restServer/streamApi"
 	models "github.com/diadata-org/diadata/pkg/model"
 	"github.com/gin-contrib/cache"
 	"github.com/gin-contrib/cache/persistence"
@@ -71,9 +74,13 @@ const (
 	cachingTimeShort  = time.Minute * 2
 	cachingTimeMedium = time.Minute * 10
 	cachingTimeLong   = time.Minute * 100
+	// streamBufferSize is the number of events kept for resuming streams.
+	streamBufferSize = 10000
+	// streamQueueSize is the number of events queued for a stream client before it is dropped.
+	streamQueueSize = 1000
 )
 
-var identityKey = "id"
+var identityKey = authApi.IdentityKey
 
 func helloHandler(c *gin.Context) {
 	claims := jwt.ExtractClaims(c)
@@ -91,6 +98,13 @@ func main() {
 
 	config := dia.GetConfigApi()
 
+	relStore, err := models.NewRelDataStore()
+	if err != nil {
+		log.Errorln("NewRelDataStore", err)
+	}
+	// the key of the config is the admin key creating the keys of clients
+	authEnv := authApi.NewEnv(relStore, config.ApiKey, config.SecretKey)
+
 	// the jwt middleware
 	authMiddleware, err := jwt.New(&jwt.GinJWTMiddleware{
 		Realm:       "party zone",
@@ -105,33 +119,29 @@ func main() {
 			}
 			return jwt.MapClaims{}
 		},
+		// the identity is the API key, whose scopes are checked per route
 		IdentityHandler: func(c *gin.Context) interface{} {
 			claims := jwt.ExtractClaims(c)
-			return &User{
-				UserName: claims["id"].(string),
-			}
+			key, _ := claims[identityKey].(string)
+			return key
 		},
 		Authenticator: func(c *gin.Context) (interface{}, error) {
 			var loginVals login
 			if err := c.ShouldBind(&loginVals); err != nil {
 				return "", jwt.ErrMissingLoginValues
 			}
-			userID := loginVals.Username
-			password := loginVals.Password
-
-			if userID == config.ApiKey && password == config.SecretKey { // Temporary: only 1 valid key so far.
-				return &User{
-					UserName:  userID,
-					LastName:  "Bo-Yi",
-					FirstName: "Wu",
-				}, nil
+			apiKey, err := authEnv.Authenticate(loginVals.Username, loginVals.Password)
+			if err != nil {
+				log.Warning("Authenticator ErrFailedAuthentication")
+				return nil, jwt.ErrFailedAuthentication
 			}
-
-			log.Warning("Authenticator ErrFailedAuthentication")
-			return nil, jwt.ErrFailedAuthentication
+			return &User{
+				UserName:  apiKey.Key,
+				FirstName: apiKey.Name,
+			}, nil
 		},
 		Authorizator: func(data interface{}, c *gin.Context) bool {
-			if v, ok := data.(*User); ok && v.UserName == config.ApiKey {
+			if key, ok := data.(string); ok && key != "" {
 				return true
 			}
 			log.Warning("Authorizator rejected")
@@ -173,39 +183,70 @@ func main() {
 		auth.GET("/refresh_token", authMiddleware.RefreshHandler)
 	}
 
+	// requests to the read endpoints with a token are limited by the scope and rate limit of
+	// their API key, requests without token are served anonymously
+	readAuth := []gin.HandlerFunc{withToken(authMiddleware.MiddlewareFunc()), authEnv.LimitScope(dia.ScopeRead)}
+
 	kafka := r.Group("/kafka")
+	kafka.Use(readAuth...)
 	{
 		kafka.GET("/tradesBlock", GetTradesBlock)
 		kafka.GET("/filtersBlock", GetFiltersBlock)
 		kafka.GET("/trades", GetTrades)
 	}
 
+	hub := streamApi.NewHub(streamBufferSize, streamQueueSize)
+	for _, topic := range []int{kafkaHelper.TopicTrades, kafkaHelper.TopicFiltersBlock} {
+		if err := hub.ConsumeTopic(topic); err != nil {
+			log.Errorln("stream: ConsumeTopic", err)
+		}
+	}
+	streamApiEnv := &streamApi.Env{Hub: hub}
+	stream := r.Group("/stream")
+	stream.Use(readAuth...)
+	{
+		stream.GET("/ws", streamApiEnv.Websocket)
+		stream.GET("/sse", streamApiEnv.SSE)
+	}
+
 	memoryStore := persistence.NewInMemoryStore(time.Second)
 
-	store, err := models.NewDataStore()
+	store, err := models.NewDataStoreFromConfig()
 	if err != nil {
 		log.Errorln("NewDataStore", err)
 	}
-	relStore, err := models.NewRelDataStore()
-	if err != nil {
-		log.Errorln("NewRelDataStore", err)
-	}
 	diaApiEnv := &diaApi.Env{
 		DataStore: store,
 		RelDB:     *relStore,
 	}
+	graphqlApiEnv, err := graphqlApi.NewEnv(store, relStore)
+	if err != nil {
+		log.Fatal("graphqlApi.NewEnv: ", err)
+	}
 
 	diaAuth := r.Group("/v1")
 	diaAuth.Use(authMiddleware.MiddlewareFunc())
 	{
-		diaAuth.POST("/supply", diaApiEnv.PostSupply)
-		diaAuth.POST("/indexRebalance/:symbol", diaApiEnv.PostIndexRebalance)
+		diaAuth.POST("/supply", authEnv.RequireScope(dia.ScopeWriteSupply), diaApiEnv.PostSupply)
+		diaAuth.POST("/indexRebalance/:symbol", authEnv.RequireScope(dia.ScopeIndexRebalance), diaApiEnv.PostIndexRebalance)
+	}
+
+	admin := r.Group("/v1/admin")
+	admin.Use(authMiddleware.MiddlewareFunc(), authEnv.RequireScope(dia.ScopeAdmin))
+	{
+		admin.POST("/apiKeys", authEnv.CreateAPIKey)
+		admin.GET("/apiKeys", authEnv.GetAPIKeys)
+		admin.POST("/apiKeys/:key/rotate", authEnv.RotateAPIKey)
+		admin.DELETE("/apiKeys/:key", authEnv.RevokeAPIKey)
+		admin.GET("/apiKeys/:key/usage", authEnv.GetAPIKeyUsage)
 	}
 
 	dia := r.Group("/v1")
+	dia.Use(readAuth...)
 	{
 		// Endpoints for cryptocurrencies/exchanges
 		dia.GET("/quotation/:symbol", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetQuotation))
+		dia.GET("/quotations", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetQuotations))
 		dia.GET("/lastTrades/:symbol", diaApiEnv.GetLastTrades)
 		dia.GET("/lastPriceBefore/:filter/:exchange/:symbol/:timestamp", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetLastPriceBefore))
 		dia.GET("/lastPriceBeforeAllExchanges/:filter/:symbol/:timestamp", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetLastPriceBeforeAllExchanges))
@@ -215,12 +256,15 @@ func main() {
 		dia.GET("/symbols", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetAllSymbols))
 		dia.GET("/volume/:symbol", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetVolume))
 		dia.GET("/volume24/:exchange", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.Get24hVolume))
+		dia.GET("/orderBookDepth/:exchange/:symbol", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetOrderBookDepth))
 		dia.GET("/coins", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetCoins))
 		dia.GET("/pairs", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetPairs))
 		dia.GET("/exchanges", cache.CachePage(memoryStore, cachingTimeLong, diaApiEnv.GetExchanges))
 		dia.GET("/defiLendingProtocols", cache.CachePage(memoryStore, cachingTimeLong, diaApiEnv.GetLendingProtocols))
 		dia.GET("/chartPoints/:filter/:exchange/:symbol", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetChartPoints))
 		dia.GET("/chartPointsAllExchanges/:filter/:symbol", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetChartPointsAllExchanges))
+		dia.GET("/candles/:exchange/:symbol", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetCandles))
+		dia.GET("/candlesAllExchanges/:symbol", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetCandlesAllExchanges))
 		dia.GET("/cviIndex", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetCviIndex))
 		dia.GET("/defiLendingRate/:protocol/:asset", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetDefiRate))
 		dia.GET("/defiLendingRate/:protocol/:asset/:time", cache.CachePage(memoryStore, cachingTimeShort, diaApiEnv.GetDefiRate))
@@ -267,6 +311,9 @@ func main() {
 		dia.GET("/NFT/:blockchain/:address/:id", cache.CachePage(memoryStore, cachingTimeLong, diaApiEnv.GetNFT))
 		dia.GET("/NFTTrades/:blockchain/:address/:id", cache.CachePage(memoryStore, cachingTimeLong, diaApiEnv.GetNFTTrades))
 		dia.GET("/NFTPrice30Days/:blockchain/:address", cache.CachePage(memoryStore, cachingTimeLong, diaApiEnv.GetNFTPrice30Days))
+		// Endpoint for GraphQL queries
+		dia.GET("/graphql", graphqlApiEnv.Query)
+		dia.POST("/graphql", graphqlApiEnv.Query)
 	}
 
 	r.Use(static.Serve("/v1/chart", static.LocalFile("/charts", true)))
@@ -281,3 +328,19 @@ func main() {
 	}
 
 }
+
+// withToken returns a middleware running @authenticate for requests sending a JWT token in one
+// of the places of TokenLookup. Other requests are passed on unauthenticated.
+func withToken(authenticate gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" || c.Query("token") != "" {
+			authenticate(c)
+			return
+		}
+		if cookie, err := c.Cookie("jwt"); err == nil && cookie != "" {
+			authenticate(c)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"os"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/kafkaHelper"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/signingHelper"
+	log "github.com/sirupsen/logrus"
+)
+
+// blockVerifier checks that a range of trades or filters blocks is correctly signed and chained.
+//
+//  blockVerifier -publicKey <hex> -blocks filtersBlock -from 1000 -count 500
+//  blockVerifier -publicKey <hex> -blocks tradesBlock -file tradesBlocks.json
+
+var (
+	publicKey = flag.String("publicKey", "", "hex encoded ed25519 public key of the producing service")
+	blocks    = flag.String("blocks", "tradesBlock", "type of the blocks, tradesBlock or filtersBlock")
+	from      = flag.Int64("from", 0, "kafka offset of the first block")
+	count     = flag.Int64("count", 0, "number of blocks to verify, all blocks up to the last offset if 0")
+	file      = flag.String("file", "", "file with one json encoded block per line, read instead of kafka")
+)
+
+func init() {
+	flag.Parse()
+}
+
+// verify checks the block encoded in @data with @verifier.
+func verify(verifier *signingHelper.ChainVerifier, data []byte) error {
+	if *blocks == "filtersBlock" {
+		var b dia.FiltersBlock
+		if err := kafkaHelper.Decode(data, &b); err != nil {
+			return err
+		}
+		return verifier.AddFiltersBlock(&b)
+	}
+	var b dia.TradesBlock
+	if err := kafkaHelper.Decode(data, &b); err != nil {
+		return err
+	}
+	return verifier.AddTradesBlock(&b)
+}
+
+func verifyFile(verifier *signingHelper.ChainVerifier) {
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if err := verify(verifier, scanner.Bytes()); err != nil {
+			log.Fatalf("line %d: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func verifyKafka(verifier *signingHelper.ChainVerifier) {
+	topic := kafkaHelper.TopicTradesBlock
+	if *blocks == "filtersBlock" {
+		topic = kafkaHelper.TopicFiltersBlock
+	}
+	last, err := kafkaHelper.ReadOffset(topic)
+	if err != nil {
+		log.Fatal("ReadOffset: ", err)
+	}
+	end := last
+	if *count > 0 && *from+*count < last {
+		end = *from + *count
+	}
+
+	r := kafkaHelper.NewReaderAtOffset(topic, *from)
+	defer r.Close()
+	for offset := *from; offset < end; offset++ {
+		m, err := r.ReadMessage(context.Background())
+		if err != nil {
+			log.Fatal("ReadMessage: ", err)
+		}
+		if err := verify(verifier, m.Value); err != nil {
+			log.Fatalf("offset %d: %v", m.Offset, err)
+		}
+	}
+}
+
+func main() {
+	key, err := signingHelper.ParsePublicKey(*publicKey)
+	if err != nil {
+		log.Fatal("publicKey: ", err)
+	}
+	if *blocks != "tradesBlock" && *blocks != "filtersBlock" {
+		log.Fatal("unknown block type ", *blocks)
+	}
+	verifier := signingHelper.NewChainVerifier(key)
+	if *file != "" {
+		verifyFile(verifier)
+	} else {
+		verifyKafka(verifier)
+	}
+	log.Infof("verified %d %s blocks", verifier.NumBlocks, *blocks)
+}
@@ -3,24 +3,48 @@ package main
 import (
 	"context"
 	"flag"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	filters "github.com/diadata-org/diadata/internal/pkg/filtersBlockService"
 	"github.com/diadata-org/diadata/pkg/dia"
 	"github.com/diadata-org/diadata/pkg/dia/helpers/kafkaHelper"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/signingHelper"
 	models "github.com/diadata-org/diadata/pkg/model"
 	"github.com/segmentio/kafka-go"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	replayInflux = flag.Bool("replayInflux", false, "replayInflux ?")
+	replayInflux  = flag.Bool("replayInflux", false, "recompute filters from the trades in influx instead of consuming trades blocks")
+	replayStart   = flag.Int64("replayStart", 1539475200, "unix time of the first trade to replay")
+	replayEnd     = flag.Int64("replayEnd", 0, "unix time after the last trade to replay, now if 0")
+	replayVersion = flag.Int("replayVersion", 1, "version of the measurement the replayed filters are written to")
+	replayDiff    = flag.Bool("replayDiff", false, "log replayed filter values which differ from the live filters")
+	filtersConfig = flag.String("filtersConfig", "", "json file with the filters to compute, defaults are used if empty")
+	start         = flag.String("start", "latest", "first trades block read without stored filters state: latest, earliest or timestamp")
+	startTime     = flag.Int64("startTime", 0, "unix time of the first trades block read with -start timestamp")
+	checkpoint    = flag.Duration("checkpointInterval", 5*time.Minute, "minimal time between two saved filters states")
 )
 
 func init() {
 	flag.Parse()
 	log.Println("replayInflux=", *replayInflux)
+	log.Println("filtersConfig=", *filtersConfig)
+}
+
+func loadFiltersConfig() filters.FiltersConfig {
+	if *filtersConfig == "" {
+		return filters.DefaultFiltersConfig()
+	}
+	config, err := filters.LoadFiltersConfig(*filtersConfig)
+	if err != nil {
+		log.Fatal("loading filters config: ", err)
+	}
+	return config
 }
 
 func handler(channel chan *dia.FiltersBlock, wg *sync.WaitGroup, w *kafka.Writer) {
@@ -41,76 +65,18 @@ func handler(channel chan *dia.FiltersBlock, wg *sync.WaitGroup, w *kafka.Writer
 	}
 }
 
-func loadFilterPointsFromPreviousBlock() []dia.FilterPoint {
+// loadPreviousBlock returns the filter points and the hash of the last published filters block.
+func loadPreviousBlock() ([]dia.FilterPoint, string) {
 	// load the previous block points so that we have a value even if
 	// there is no trades
 	lastFilterPoints := []dia.FilterPoint{}
+	var lastBlockHash string
 	lastFilterBlock, err := kafkaHelper.GetLastElement(kafkaHelper.TopicFiltersBlock)
 	if err == nil {
 		lastFilterPoints = lastFilterBlock.(dia.FiltersBlock).FiltersBlockData.FilterPoints
+		lastBlockHash = lastFilterBlock.(dia.FiltersBlock).BlockHash
 	}
-	return lastFilterPoints
-}
-
-//  docker exec -it <cointainer> filtersBlockService -replayInflux
-
-func createTradeBlockFromInflux(d models.Datastore, f *filters.FiltersBlockService) {
-	//now := time.Now()
-	//then := now.AddDate(0, -1, 0)
-	then := time.Unix(1539475200, 0)
-	//"1405544146"
-
-	log.Info("createTradeBlockFromInflux")
-	var currentBlock int64
-	trades := []dia.Trade{}
-	for {
-		log.Info("sleeping")
-		time.Sleep(1 * time.Second)
-		r, err := d.GetAllTrades(then, 1000)
-		if err != nil {
-			log.Errorln("createTradeBlockFromInflux", r)
-			continue
-		}
-		if len(r) == 0 {
-			log.Info("no new trades...")
-			break
-		} else {
-			then = r[len(r)-1].Time
-			log.Infoln("x got", len(r), "trades", then)
-			for _, v := range r {
-				if v.Source == "Simex" {
-					continue
-				}
-				block := (v.Time.Unix() / dia.BlockSizeSeconds)
-				if block != currentBlock {
-					var t1 time.Time
-					var t2 time.Time
-					currentBlock = block
-					if len(trades) > 0 {
-						t1 = trades[0].Time
-						t2 = trades[len(trades)-1].Time
-					}
-					b := &dia.TradesBlock{
-						TradesBlockData: dia.TradesBlockData{
-							Trades:    trades,
-							BeginTime: t1,
-							EndTime:   t2,
-						},
-					}
-					if len(trades) > 5 {
-						log.Infoln("calling ProcessTradesBlock", len(trades), "trades blocknumber:", currentBlock, t1, t2)
-						f.ProcessTradesBlock(b)
-						log.Infoln("bang", currentBlock)
-					} else {
-						log.Info("not enough trades in block ignoring...", len(trades), currentBlock, t1, t2)
-					}
-					trades = []dia.Trade{}
-				} else {
-					trades = append(trades, v)
-				}
-			}
-		}
-	}
+	return lastFilterPoints, lastBlockHash
 }
 
 func main() {
@@ -118,18 +84,39 @@ func main() {
 	if *replayInflux {
 		s, err := models.NewInfluxDataStore()
 		if err != nil {
-			log.Errorln("NewDataStore", err)
+			log.Fatal("NewInfluxDataStore", err)
 		}
-		f := filters.NewFiltersBlockService(nil, s, nil)
-		createTradeBlockFromInflux(s, f)
+		replayTrades(s)
 	} else {
-		s, err := models.NewDataStore()
+		s, err := models.NewDataStoreFromConfig()
 		if err != nil {
 			log.Errorln("NewDataStore", err)
 		}
 		channel := make(chan *dia.FiltersBlock)
 
-		f := filters.NewFiltersBlockService(loadFilterPointsFromPreviousBlock(), s, channel)
+		signer, err := signingHelper.NewSignerFromEnv()
+		if err != nil {
+			log.Fatal("NewSignerFromEnv: ", err)
+		}
+		if signer == nil {
+			log.Warn("no signing key set, filters blocks are published unsigned")
+		}
+
+		lastFilterPoints, lastBlockHash := loadPreviousBlock()
+		f, offset := filters.NewPersistentFiltersBlockService(lastFilterPoints, s, channel, loadFiltersConfig(), *checkpoint)
+		f.ChainTo(lastBlockHash, signer)
+
+		// Closing the service saves the filters state of the trades blocks processed since the last checkpoint
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-stop
+			log.Info("Received stop signal.")
+			if err := f.Close(); err != nil {
+				log.Errorln("closing filters block service", err)
+			}
+			os.Exit(0)
+		}()
 
 		w := kafkaHelper.NewSyncWriter(kafkaHelper.TopicFiltersBlock)
 
@@ -139,22 +126,37 @@ func main() {
 
 		go handler(channel, &wg, w)
 
-		r := kafkaHelper.NewReaderNextMessage(kafkaHelper.TopicTradesBlock)
-		defer r.Close()
+		// The offset stored along with the filters state is the committed progress of the service.
+		var options kafkaHelper.ConsumerOptions
+		if offset >= 0 {
+			// continue right after the trades block of the restored filters state
+			options = kafkaHelper.ConsumerOptions{Start: kafkaHelper.StartOffset, Offset: offset + 1}
+		} else {
+			startPosition, err := kafkaHelper.ParseStartPosition(*start)
+			if err != nil || startPosition == kafkaHelper.StartOffset {
+				log.Fatal("invalid start position ", *start)
+			}
+			options = kafkaHelper.ConsumerOptions{Start: startPosition, Timestamp: time.Unix(*startTime, 0)}
+		}
+		c, err := kafkaHelper.NewConsumer(kafkaHelper.TopicTradesBlock, options)
+		if err != nil {
+			log.Fatal("NewConsumer: ", err)
+		}
+		defer c.Close()
 
 		for {
-			m, err := r.ReadMessage(context.Background())
+			m, err := c.Fetch(context.Background())
 			if err != nil {
 				log.Printf(err.Error())
 			} else {
 				log.Info("get block from tradesBlock")
 				var tb dia.TradesBlock
-				err := tb.UnmarshalBinary(m.Value)
+				err := kafkaHelper.Decode(m.Value, &tb)
 				if err != nil {
 					log.Error("error unmarshalling trades block")
 				}
 				if err == nil {
-					f.ProcessTradesBlock(&tb)
+					f.ProcessTradesBlockAtOffset(&tb, m.Offset)
 				}
 			}
 		}
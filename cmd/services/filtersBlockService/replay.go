@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	filters "github.com/diadata-org/diadata/internal/pkg/filtersBlockService"
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+//  docker exec -it <cointainer> filtersBlockService -replayInflux -replayStart 1539475200 -replayEnd 1539561600 -replayVersion 2 -replayDiff
+
+const (
+	replayBatchSize = 1000
+	// replayDiffTolerance is the relative difference up to which filter values are considered equal.
+	replayDiffTolerance = 1e-9
+)
+
+// replayDatastore writes filter values into a versioned replay measurement and leaves
+// the live prices and filters untouched. Reruns overwrite the points of previous runs,
+// as these have the same tags and timestamps.
+type replayDatastore struct {
+	*models.DB
+	table string
+}
+
+func (ds *replayDatastore) SetFilter(filter string, symbol string, exchange string, value float64, t time.Time) error {
+	return ds.SaveFilterInfluxTable(ds.table, filter, symbol, exchange, value, t)
+}
+
+func (ds *replayDatastore) SetPriceUSD(symbol string, price float64) error {
+	return nil
+}
+
+// SetPriceZSET writes the price of the FilterKing into the replay measurement like DB.SetPriceZSET
+// does into the live one.
+func (ds *replayDatastore) SetPriceZSET(symbol string, exchange string, price float64, t time.Time) error {
+	return ds.SaveFilterInfluxTable(ds.table, dia.FilterKing, symbol, exchange, price, t)
+}
+
+func (ds *replayDatastore) SetLastTradeTimeForExchange(symbol string, exchange string, t time.Time) error {
+	return nil
+}
+
+// replayTrades recomputes the filters from the trades in influx between replayStart and replayEnd.
+func replayTrades(db *models.DB) {
+	start := time.Unix(*replayStart, 0)
+	end := time.Now()
+	if *replayEnd != 0 {
+		end = time.Unix(*replayEnd, 0)
+	}
+	ds := &replayDatastore{DB: db, table: models.FiltersReplayTable(*replayVersion)}
+	log.Infoln("replaying trades from", start, "to", end, "into", ds.table)
+
+	replay := filters.NewReplay(ds, loadFiltersConfig())
+	// GetAllTrades returns trades at or after its argument, so each page starts with the
+	// trades at the last timestamp of the previous page, which are skipped if already replayed.
+	then := start
+	replayed := make(map[string]bool)
+	batchSize := replayBatchSize
+	numTrades := 0
+	for then.Before(end) {
+		trades, err := db.GetAllTrades(then, batchSize)
+		if err != nil {
+			log.Fatal("GetAllTrades: ", err)
+		}
+		if len(trades) == 0 {
+			break
+		}
+		last := trades[len(trades)-1].Time
+		if last.Equal(then) && len(trades) == batchSize {
+			// the page holds only trades at @then, fetch more of them at once
+			batchSize *= 2
+			continue
+		}
+		atLast := make(map[string]bool)
+		for _, t := range trades {
+			if !t.Time.Before(end) {
+				break
+			}
+			key := replayTradeKey(t)
+			if t.Time.Equal(last) {
+				atLast[key] = true
+			}
+			if (t.Time.Equal(then) && replayed[key]) || t.Source == dia.SimexExchange {
+				continue
+			}
+			replay.AddTrade(t)
+			numTrades++
+		}
+		if len(trades) < batchSize {
+			break
+		}
+		then = last
+		replayed = atLast
+		batchSize = replayBatchSize
+	}
+	replay.Flush()
+	db.Flush()
+	log.Infoln("replayed", numTrades, "trades in", len(replay.FiltersBlocks), "blocks")
+
+	if *replayDiff {
+		diffReplay(db, ds.table, start, end)
+	}
+}
+
+// replayTradeKey identifies @t among the trades with the same timestamp.
+func replayTradeKey(t dia.Trade) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%v/%v", t.Source, t.Pair, t.Symbol, t.ForeignTradeID, t.Price, t.Volume)
+}
+
+// diffReplay logs the filter values of @table which differ from the live filters.
+func diffReplay(db *models.DB, table string, start time.Time, end time.Time) {
+	current, err := db.GetFilterPointsTable(models.FiltersTable(), start, end)
+	if err != nil {
+		log.Fatal("GetFilterPointsTable: ", err)
+	}
+	replayed, err := db.GetFilterPointsTable(table, start, end)
+	if err != nil {
+		log.Fatal("GetFilterPointsTable: ", err)
+	}
+	diffs := filters.DiffFilterPoints(current, replayed, replayDiffTolerance)
+	for _, d := range diffs {
+		log.Infof("%s %s %s %v: current %v replayed %v", d.Name, d.Symbol, d.Exchange, d.Time, d.Current, d.Replayed)
+	}
+	log.Infof("%d of %d replayed filter values differ from %s", len(diffs), len(replayed), models.FiltersTable())
+}
@@ -2,16 +2,91 @@ package main
 
 import (
 	"context"
+	"flag"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/diadata-org/diadata/internal/pkg/tradesBlockService"
 	"github.com/diadata-org/diadata/pkg/dia"
 	"github.com/diadata-org/diadata/pkg/dia/helpers/kafkaHelper"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/signingHelper"
 	"github.com/diadata-org/diadata/pkg/model"
 	"github.com/segmentio/kafka-go"
 	log "github.com/sirupsen/logrus"
-	"sync"
 )
 
-func handleBlocks(blockMaker *tradesBlockService.TradesBlockService, wg *sync.WaitGroup, w *kafka.Writer) {
+var (
+	gracePeriod     = flag.Duration("gracePeriod", 10*time.Second, "time blocks are kept open for late trades")
+	finaliseOnTick  = flag.Bool("finaliseOnTick", false, "finalise blocks by wall clock time, including blocks without trades")
+	maxDeviation    = flag.Float64("maxDeviation", 0, "maximal relative deviation of trades from reference prices, 0 disables the check")
+	maxReferenceAge = flag.Duration("maxReferenceAge", time.Hour, "maximal age of reference prices")
+	foreignSources  = flag.String("foreignSources", "Coingecko,CoinMarketCap", "comma separated sources of foreign quotations used as reference prices")
+	statsInterval   = flag.Duration("statsInterval", 5*time.Minute, "interval for logging late trade statistics")
+	consumerGroup   = flag.String("consumerGroup", "tradesBlockService", "kafka consumer group committing the offsets of processed trades, none if empty")
+	start           = flag.String("start", "latest", "first trade read without committed offset: latest, earliest or timestamp")
+	startTime       = flag.Int64("startTime", 0, "unix time of the first trade read with -start timestamp")
+	shard           = flag.Int("shard", 0, "index of this instance among the instances of a sharded service")
+	shards          = flag.Int("shards", 1, "number of instances sharing the partitions of the trades topic")
+	merge           = flag.Bool("merge", false, "merge the trades block shards published by -shards instances")
+	mergeTimeout    = flag.Duration("mergeTimeout", time.Minute, "time a block waits for missing shards before it is merged")
+	metricsAddr     = flag.String("metricsAddr", ":8080", "address serving the late trades metrics at /debug/vars, empty to disable")
+)
+
+func init() {
+	flag.Parse()
+	log.Println("gracePeriod=", *gracePeriod)
+	log.Println("finaliseOnTick=", *finaliseOnTick)
+	log.Println("maxDeviation=", *maxDeviation)
+	log.Println("consumerGroup=", *consumerGroup)
+	log.Println("start=", *start)
+	log.Println("shard=", *shard, "shards=", *shards, "merge=", *merge)
+}
+
+// offsetTracker tracks which trades have been published in a trades block, so that their
+// offsets can be committed.
+type offsetTracker struct {
+	mu sync.Mutex
+	// pending are the trades not yet published per partition, in ascending order of offset.
+	pending map[int][]pendingTrade
+}
+
+type pendingTrade struct {
+	offset int64
+	time   time.Time
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{pending: make(map[int][]pendingTrade)}
+}
+
+// add records the trade at @offset of @partition with time @t. Messages which are not trades
+// are added with zero time, so that they are committed along with the next block.
+func (o *offsetTracker) add(partition int, offset int64, t time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending[partition] = append(o.pending[partition], pendingTrade{offset: offset, time: t})
+}
+
+// published removes the trades before @endTime and returns per partition the highest offset up
+// to which all trades have been published.
+func (o *offsetTracker) published(endTime time.Time) map[int]int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	offsets := make(map[int]int64)
+	for partition, pending := range o.pending {
+		n := 0
+		for n < len(pending) && pending[n].time.Before(endTime) {
+			offsets[partition] = pending[n].offset
+			n++
+		}
+		o.pending[partition] = pending[n:]
+	}
+	return offsets
+}
+
+func handleBlocks(blockMaker *tradesBlockService.TradesBlockService, wg *sync.WaitGroup, w *kafka.Writer, c *kafkaHelper.Consumer, tracker *offsetTracker) {
 	for {
 		t, ok := <-blockMaker.Channel()
 		if !ok {
@@ -19,43 +94,164 @@ func handleBlocks(blockMaker *tradesBlockService.TradesBlockService, wg *sync.Wa
 			wg.Done()
 			return
 		}
-		err := kafkaHelper.WriteMessage(w, t)
+		var err error
+		if *shards > 1 {
+			err = kafkaHelper.WriteMessage(w, &dia.TradesBlockShard{
+				Shard:     *shard,
+				NumShards: *shards,
+				BeginTime: t.TradesBlockData.BeginTime,
+				EndTime:   t.TradesBlockData.EndTime,
+				Trades:    t.TradesBlockData.Trades,
+			})
+		} else {
+			err = kafkaHelper.WriteMessage(w, t)
+		}
 		if err != nil {
+			// the trades are read again after a restart
 			log.Errorln("handleBlocks", err)
+			continue
+		}
+		for partition, offset := range tracker.published(t.TradesBlockData.EndTime) {
+			if err := c.Commit(context.Background(), partition, offset); err != nil {
+				log.Errorln("handleBlocks: commit", err)
+			}
 		}
 	}
 }
 
+func handleLateTrades(blockMaker *tradesBlockService.TradesBlockService, w *kafka.Writer) {
+	for {
+		t, ok := <-blockMaker.LateTradesChannel()
+		if !ok {
+			log.Printf("handleLateTrades: finishing channel")
+			return
+		}
+		err := kafkaHelper.WriteMessage(w, t)
+		if err != nil {
+			log.Errorln("handleLateTrades", err)
+		}
+	}
+}
+
+func logLateTradeStats(blockMaker *tradesBlockService.TradesBlockService) {
+	ticker := time.NewTicker(*statsInterval)
+	for range ticker.C {
+		for source, stat := range blockMaker.LateTradeStats() {
+			log.Infof("late trades on %s: %d trades, %.2f USD volume, max delay %v", source, stat.NumTrades, stat.VolumeUSD, stat.MaxDelay)
+		}
+	}
+}
+
+// chainToLastBlock chains the blocks of @chainer to the last published trades block and signs
+// them with the key set in the environment.
+func chainToLastBlock(chainer interface {
+	ChainTo(string, *signingHelper.Signer)
+}) {
+	signer, err := signingHelper.NewSignerFromEnv()
+	if err != nil {
+		log.Fatal("NewSignerFromEnv: ", err)
+	}
+	if signer == nil {
+		log.Warn("no signing key set, trades blocks are published unsigned")
+	}
+	var lastBlockHash string
+	lastBlock, err := kafkaHelper.GetLastElement(kafkaHelper.TopicTradesBlock)
+	if err == nil {
+		lastBlockHash = lastBlock.(dia.TradesBlock).BlockHash
+	}
+	chainer.ChainTo(lastBlockHash, signer)
+}
+
 func main() {
 
-	w := kafkaHelper.NewSyncWriter(kafkaHelper.TopicTradesBlock)
+	if *merge {
+		mergeShards()
+		return
+	}
+
+	// Sharded instances each consume the partitions assigned to them within the consumer
+	// group, and publish one shard per interval, which are merged into the trades block.
+	topic := kafkaHelper.TopicTradesBlock
+	if *shards > 1 {
+		if *consumerGroup == "" || *shard < 0 || *shard >= *shards {
+			log.Fatal("sharded instances need a consumer group and a shard index below -shards")
+		}
+		topic = kafkaHelper.TopicTradesBlockShards
+		*finaliseOnTick = true
+	}
+	w := kafkaHelper.NewSyncWriter(topic)
 	defer w.Close()
 
-	r := kafkaHelper.NewReaderNextMessage(kafkaHelper.TopicTrades)
-	defer r.Close()
+	wLate := kafkaHelper.NewWriter(kafkaHelper.TopicTradesLate)
+	defer wLate.Close()
 
-	s, err := models.NewDataStore()
+	startPosition, err := kafkaHelper.ParseStartPosition(*start)
+	if err != nil {
+		log.Fatal("start: ", err)
+	}
+	options := kafkaHelper.ConsumerOptions{
+		GroupID:   *consumerGroup,
+		Start:     startPosition,
+		Timestamp: time.Unix(*startTime, 0),
+	}
+	if startPosition == kafkaHelper.StartTimestamp && options.GroupID != "" && *shards == 1 {
+		log.Warn("consumer groups can not start at a timestamp, offsets are not committed")
+		options.GroupID = ""
+	}
+	c, err := kafkaHelper.NewConsumer(kafkaHelper.TopicTrades, options)
+	if err != nil {
+		log.Fatal("NewConsumer: ", err)
+	}
+	defer c.Close()
+
+	s, err := models.NewDataStoreFromConfig()
 	if err != nil {
 		log.Errorln("NewDataStore", err)
 	}
 
-	tradesBlockService := tradesBlockService.NewTradesBlockService(s, dia.BlockSizeSeconds)
+	var priceGuard *tradesBlockService.PriceGuard
+	if *maxDeviation > 0 {
+		priceGuard = tradesBlockService.NewPriceGuard(s, *maxDeviation, *maxReferenceAge, strings.Split(*foreignSources, ","))
+	}
+
+	var blockService *tradesBlockService.TradesBlockService
+	if *finaliseOnTick {
+		blockService = tradesBlockService.NewTradesBlockServiceWithTicker(s, dia.BlockSizeSeconds, *gracePeriod, priceGuard)
+	} else {
+		blockService = tradesBlockService.NewTradesBlockService(s, dia.BlockSizeSeconds, *gracePeriod, priceGuard)
+	}
 
+	if *shards == 1 {
+		// shards are chained and signed by the merger
+		chainToLastBlock(blockService)
+	}
+
+	tracker := newOffsetTracker()
 	wg := sync.WaitGroup{}
-	go handleBlocks(tradesBlockService, &wg, w)
+	go handleBlocks(blockService, &wg, w, c, tracker)
+	go handleLateTrades(blockService, wLate)
+	go logLateTradeStats(blockService)
+	if *metricsAddr != "" {
+		// expvar serves the metrics of the service on the default mux
+		go func() {
+			log.Errorln("metrics", http.ListenAndServe(*metricsAddr, nil))
+		}()
+	}
 
 	log.Printf("starting...")
 
 	for {
-		m, err := r.ReadMessage(context.Background())
+		m, err := c.Fetch(context.Background())
 		if err != nil {
 			log.Printf(err.Error())
 		} else {
 			var t dia.Trade
-			err := t.UnmarshalBinary(m.Value)
+			err := kafkaHelper.Decode(m.Value, &t)
 			if err == nil {
-				tradesBlockService.ProcessTrade(&t)
+				tracker.add(m.Partition, m.Offset, t.Time)
+				blockService.ProcessTrade(&t)
 			} else {
+				tracker.add(m.Partition, m.Offset, time.Time{})
 				log.Printf("ignored message at offset %d: %s = %s\n", m.Offset, string(m.Key), string(m.Value))
 			}
 		}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/diadata-org/diadata/internal/pkg/tradesBlockService"
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/kafkaHelper"
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// mergeShards merges the shards published by the instances of a sharded tradesBlockService
+// into trades blocks.
+func mergeShards() {
+	w := kafkaHelper.NewSyncWriter(kafkaHelper.TopicTradesBlock)
+	defer w.Close()
+
+	c, err := kafkaHelper.NewConsumer(kafkaHelper.TopicTradesBlockShards, kafkaHelper.ConsumerOptions{
+		GroupID: *consumerGroup + "Merger",
+		Start:   kafkaHelper.StartLatest,
+	})
+	if err != nil {
+		log.Fatal("NewConsumer: ", err)
+	}
+	defer c.Close()
+
+	merger := tradesBlockService.NewBlockMerger(*shards, *mergeTimeout)
+	chainToLastBlock(merger)
+
+	messages := make(chan kafka.Message)
+	go func() {
+		for {
+			m, err := c.Fetch(context.Background())
+			if err != nil {
+				log.Printf(err.Error())
+				continue
+			}
+			messages <- m
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	log.Printf("merging %d shards...", *shards)
+
+	var last *kafka.Message
+	for {
+		var merged []*dia.TradesBlock
+		select {
+		case m := <-messages:
+			var b dia.TradesBlockShard
+			if err := kafkaHelper.Decode(m.Value, &b); err != nil {
+				log.Printf("ignored message at offset %d: %v", m.Offset, err)
+			} else {
+				merged = merger.Add(&b, time.Now())
+			}
+			last = &m
+		case <-ticker.C:
+			merged = merger.Expire(time.Now())
+		}
+
+		published := true
+		for _, b := range merged {
+			if err := kafkaHelper.WriteMessage(w, b); err != nil {
+				log.Errorln("mergeShards", err)
+				published = false
+			}
+		}
+		// the shards read so far are only committed once all of them have been merged
+		if published && len(merged) > 0 && merger.Pending() == 0 && last != nil {
+			if err := c.Commit(context.Background(), last.Partition, last.Offset); err != nil {
+				log.Errorln("mergeShards: commit", err)
+			}
+			last = nil
+		}
+	}
+}
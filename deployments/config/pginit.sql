@@ -163,3 +163,21 @@ CREATE TABLE blockdata (
     UNIQUE(blockchain, block_number),
     UNIQUE(blockdata_id)
 );
+
+-- apikey holds the keys of the clients of the REST API. Secrets are only
+-- stored as SHA-256 hashes, scopes are a subset of
+-- read, write-supply, index-rebalance and admin.
+CREATE TABLE apikey (
+    apikey_id UUID DEFAULT gen_random_uuid(),
+    key text not null,
+    secret_hash text not null,
+    name text not null,
+    scopes text[] not null,
+    -- requests per minute, 0 for no limit
+    rate_limit integer default 0,
+    created_at timestamp not null,
+    rotated_at timestamp,
+    revoked_at timestamp,
+    UNIQUE(key),
+    UNIQUE(apikey_id)
+);
@@ -13,6 +13,7 @@ import (
 	pool "github.com/diadata-org/diadata/internal/pkg/exchange-scrapers/balancer/balancerpool"
 	"github.com/diadata-org/diadata/internal/pkg/exchange-scrapers/balancer/balancertoken"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -29,8 +30,13 @@ const (
 	factoryContract        = "0x9424B1412450D0f8Fc2255FAf6046b98213B76Bd"
 	balancerRestDial       = "http://159.69.120.42:8545/"
 	balancerWsDial         = "ws://159.69.120.42:8546/"
+	// block the factory contract was created in
+	balancerFactoryStartBlock = 9562480
 )
 
+// balancerLogSwapTopic is the topic of LOG_SWAP events of pools.
+var balancerLogSwapTopic = common.HexToHash("0x908fb5ee8f16c6bc9bc3690973819f32a4d4b10188134543c88706e0e1d43378")
+
 type BalancerSwap struct {
 	SellToken  string
 	BuyToken   string
@@ -67,6 +73,13 @@ type BalancerScraper struct {
 	RestClient  *ethclient.Client
 	resubscribe chan string
 	pools       map[string]struct{}
+
+	// historyLock guards the pools and tokens of FetchTrades, which are kept between calls.
+	historyLock   sync.Mutex
+	historyPools  []common.Address
+	historyTokens map[common.Address]*BalancerToken
+	// historyBlock is the block pools are listed from by the next call of FetchTrades.
+	historyBlock uint64
 }
 
 func NewBalancerScraper(exchange dia.Exchange) *BalancerScraper {
@@ -81,6 +94,8 @@ func NewBalancerScraper(exchange dia.Exchange) *BalancerScraper {
 		balancerTokensMap: make(map[string]*BalancerToken),
 		resubscribe:       make(chan string),
 		pools:             make(map[string]struct{}),
+		historyTokens:     make(map[common.Address]*BalancerToken),
+		historyBlock:      balancerFactoryStartBlock,
 	}
 
 	wsClient, err := ethclient.Dial(balancerWsDial)
@@ -470,6 +485,154 @@ func (scraper *BalancerScraper) getNewPoolLogChannel() (chan *factory.Balancerfa
 	return sink, sub, nil
 }
 
+// FetchTrades returns the swaps of @pair in [@from, @to) filtered from the logs of all pools
+// created by the factory before @to. The swaps of all pools are filtered with one query per
+// range of blocks.
+func (scraper *BalancerScraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	start, end, err := blockRange(scraper.RestClient, from, to)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := scraper.fetchPools(end)
+	if err != nil {
+		return nil, err
+	}
+	if len(pools) == 0 {
+		return nil, nil
+	}
+	// the address of the filterer is not used to parse logs
+	poolFiltererContract, err := pool.NewBalancerpoolFilterer(common.Address{}, scraper.RestClient)
+	if err != nil {
+		return nil, err
+	}
+
+	times := newBlockTimes(scraper.RestClient)
+	var trades []*dia.Trade
+	err = forBlockRanges(start, end, func(start uint64, end uint64) error {
+		logs, err := scraper.RestClient.FilterLogs(context.Background(), ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: pools,
+			Topics:    [][]common.Hash{{balancerLogSwapTopic}},
+		})
+		if err != nil {
+			return err
+		}
+		for _, l := range logs {
+			if l.Removed {
+				continue
+			}
+			vLog, err := poolFiltererContract.ParseLOGSWAP(l)
+			if err != nil {
+				return err
+			}
+			tokenIn, err := scraper.getHistoryToken(vLog.TokenIn)
+			if err != nil {
+				return err
+			}
+			tokenOut, err := scraper.getHistoryToken(vLog.TokenOut)
+			if err != nil {
+				return err
+			}
+			amountIn, _ := new(big.Float).Quo(big.NewFloat(0).SetInt(vLog.TokenAmountIn), new(big.Float).SetFloat64(math.Pow10(int(tokenIn.Decimals)))).Float64()
+			amountOut, _ := new(big.Float).Quo(big.NewFloat(0).SetInt(vLog.TokenAmountOut), new(big.Float).SetFloat64(math.Pow10(int(tokenOut.Decimals)))).Float64()
+			swap := BalancerSwap{
+				SellToken:  tokenIn.Symbol,
+				BuyToken:   tokenOut.Symbol,
+				SellVolume: amountIn,
+				BuyVolume:  amountOut,
+				ID:         vLog.Raw.TxHash.String() + "-" + fmt.Sprint(vLog.Raw.Index),
+			}
+			swap.normalizeETH()
+			foreignName, volume, price, err := getSwapDataBalancer(swap)
+			if err != nil || foreignName != pair.ForeignName {
+				continue
+			}
+			timestamp, err := times.at(vLog.Raw.BlockNumber)
+			if err != nil {
+				return err
+			}
+			trades = append(trades, &dia.Trade{
+				Symbol:         pair.Symbol,
+				Pair:           foreignName,
+				Price:          price,
+				Volume:         volume,
+				Time:           timestamp,
+				ForeignTradeID: swap.ID,
+				Source:         scraper.exchangeName,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTrades(trades)
+	return trades, nil
+}
+
+// fetchPools returns the addresses of the pools created by the factory up to block @end. Pools
+// are kept, so that later calls only list the pools created since.
+func (scraper *BalancerScraper) fetchPools(end uint64) ([]common.Address, error) {
+	scraper.historyLock.Lock()
+	defer scraper.historyLock.Unlock()
+	if end >= scraper.historyBlock {
+		factoryFiltererContract, err := factory.NewBalancerfactoryFilterer(common.HexToAddress(factoryContract), scraper.RestClient)
+		if err != nil {
+			return nil, err
+		}
+		iter, err := factoryFiltererContract.FilterLOGNEWPOOL(&bind.FilterOpts{Start: scraper.historyBlock, End: &end}, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer iter.Close()
+		var pools []common.Address
+		for iter.Next() {
+			pools = append(pools, iter.Event.Pool)
+		}
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+		scraper.historyPools = append(scraper.historyPools, pools...)
+		scraper.historyBlock = end + 1
+	}
+	pools := make([]common.Address, len(scraper.historyPools))
+	copy(pools, scraper.historyPools)
+	return pools, nil
+}
+
+// getHistoryToken returns the token with @address for FetchTrades, which are kept between calls.
+func (scraper *BalancerScraper) getHistoryToken(address common.Address) (*BalancerToken, error) {
+	scraper.historyLock.Lock()
+	defer scraper.historyLock.Unlock()
+	return scraper.getBalancerToken(address, scraper.historyTokens)
+}
+
+// getBalancerToken returns the token with @address from @tokens, querying it on a miss.
+func (scraper *BalancerScraper) getBalancerToken(address common.Address, tokens map[common.Address]*BalancerToken) (*BalancerToken, error) {
+	if token, ok := tokens[address]; ok {
+		return token, nil
+	}
+	tokenCaller, err := balancertoken.NewBalancertokenCaller(address, scraper.RestClient)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := tokenCaller.Symbol(&bind.CallOpts{})
+	if err != nil {
+		return nil, err
+	}
+	decimals, err := tokenCaller.Decimals(&bind.CallOpts{})
+	if err != nil {
+		return nil, err
+	}
+	token := &BalancerToken{
+		Symbol:   symbol,
+		Decimals: uint8(decimals.Uint64()),
+	}
+	tokens[address] = token
+	return token, nil
+}
+
 func (bs *BalancerSwap) normalizeETH() {
 	if bs.SellToken == "WETH" {
 		bs.SellToken = "ETH"
@@ -1,10 +1,11 @@
 package scrapers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/adshao/go-binance"
@@ -13,48 +14,49 @@ import (
 	utils "github.com/diadata-org/diadata/pkg/utils"
 )
 
-type binancePairScraperSet map[*BinancePairScraper]nothing
+// binanceTradesLimit is the maximal number of aggregated trades returned per request.
+const binanceTradesLimit = 1000
 
-// BinanceScraper is a Scraper for collecting trades from the Binance websocket API
+var _binanceSocketURL = "wss://stream.binance.com:9443/ws"
+
+type binanceStreamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int64    `json:"id"`
+}
+
+// binanceRequest returns the request @method, e.g. SUBSCRIBE, for the streams of @pairs
+// named by @stream, e.g. @aggTrade.
+func binanceRequest(method string, pairs []dia.Pair, stream string) binanceStreamRequest {
+	var streams []string
+	for _, pair := range pairs {
+		streams = append(streams, strings.ToLower(pair.ForeignName)+stream)
+	}
+	return binanceStreamRequest{Method: method, Params: streams, ID: time.Now().UnixNano()}
+}
+
+// BinanceScraper scrapes the aggregated trades of Binance with a WebsocketScraper
 type BinanceScraper struct {
-	client *binance.Client
-	// signaling channels for session initialization and finishing
-	initDone     chan nothing
-	shutdown     chan nothing
-	shutdownDone chan nothing
-	// error handling; to read error or closed, first acquire read lock
-	// only cleanup method should hold write lock
-	errorLock sync.RWMutex
-	error     error
-	closed    bool
-	// used to keep track of trading pairs that we subscribed to
-	// use sync.Maps to concurrently handle multiple pairs
-	pairScrapers      sync.Map // dia.Pair -> binancePairScraperSet
-	pairSubscriptions sync.Map // dia.Pair -> string (subscription ID)
-	pairLocks         sync.Map // dia.Pair -> sync.Mutex
-	exchangeName      string
-	chanTrades        chan *dia.Trade
+	*WebsocketScraper
+	client       *binance.Client
+	exchangeName string
 }
 
 // NewBinanceScraper returns a new BinanceScraper for the given pair
 func NewBinanceScraper(apiKey string, secretKey string, exchange dia.Exchange) *BinanceScraper {
-
 	s := &BinanceScraper{
 		client:       binance.NewClient(apiKey, secretKey),
-		initDone:     make(chan nothing),
-		shutdown:     make(chan nothing),
-		shutdownDone: make(chan nothing),
 		exchangeName: exchange.Name,
-		error:        nil,
-		chanTrades:   make(chan *dia.Trade),
 	}
-
-	// establish connection in the background
-	go s.mainLoop()
+	s.WebsocketScraper = NewWebsocketScraper(exchange, binanceProtocol{}, WebsocketOptions{})
 	return s
 }
 
 func (up *BinanceScraper) NormalizePair(pair dia.Pair) (dia.Pair, error) {
+	return normalizeBinancePair(pair), nil
+}
+
+func normalizeBinancePair(pair dia.Pair) dia.Pair {
 	if pair.Symbol == "MIOTA" {
 		pair.ForeignName = "M" + pair.ForeignName
 	}
@@ -64,102 +66,71 @@ func (up *BinanceScraper) NormalizePair(pair dia.Pair) (dia.Pair, error) {
 	if pair.Symbol == "ETHOS" {
 		pair.ForeignName = "ETHOS" + pair.ForeignName[3:]
 	}
-	return pair, nil
+	return pair
 }
 
-// runs in a goroutine until s is closed
-func (s *BinanceScraper) mainLoop() {
-	close(s.initDone)
-	for {
-		select {
-		case <-s.shutdown: // user requested shutdown
-			log.Println("BinanceScraper shutting down")
-			s.cleanup(nil)
-			return
-		}
-	}
+// binanceProtocol implements WebsocketProtocol for the aggregated trade streams of Binance
+type binanceProtocol struct{}
+
+type binanceAggTrade struct {
+	Event        string `json:"e"`
+	Symbol       string `json:"s"`
+	AggTradeID   int64  `json:"a"`
+	Price        string `json:"p"`
+	Quantity     string `json:"q"`
+	TradeTime    int64  `json:"T"`
+	IsBuyerMaker bool   `json:"m"`
 }
 
-// closes all connected PairScrapers
-// must only be called from mainLoop
-func (s *BinanceScraper) cleanup(err error) {
-	s.errorLock.Lock()
-	defer s.errorLock.Unlock()
-	// close all channels of PairScraper children
-	s.pairScrapers.Range(func(k, v interface{}) bool {
-		for ps := range v.(binancePairScraperSet) {
-			ps.closed = true
-		}
-		s.pairScrapers.Delete(k)
-		return true
-	})
-
-	s.closed = true
-	close(s.shutdownDone) // signal that shutdown is complete
+func (p binanceProtocol) URL() (string, error) {
+	return _binanceSocketURL, nil
 }
 
-// Close closes any existing API connections, as well as channels of
-// PairScrapers from calls to ScrapePair
-func (s *BinanceScraper) Close() error {
-	if s.closed {
-		return errors.New("BinanceScraper: Already closed")
-	}
-	close(s.shutdown)
-	<-s.shutdownDone
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
+func (p binanceProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	return []interface{}{binanceRequest("SUBSCRIBE", pairs, "@aggTrade")}, nil
 }
 
-// ScrapePair returns a PairScraper that can be used to get trades for a single pair from
-// this APIScraper
-func (s *BinanceScraper) ScrapePair(pair dia.Pair) (PairScraper, error) {
-	<-s.initDone // wait until client is connected
+func (p binanceProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return []interface{}{binanceRequest("UNSUBSCRIBE", []dia.Pair{pair}, "@aggTrade")}, nil
+}
 
-	if s.closed {
-		return nil, errors.New("BinanceScraper: Call ScrapePair on closed scraper")
+func (p binanceProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	var event binanceAggTrade
+	if err := json.Unmarshal(data, &event); err != nil {
+		return WebsocketMessage{}, err
 	}
-
-	ps := &BinancePairScraper{
-		parent: s,
-		pair:   pair,
+	// replies to requests have no event type
+	if event.Event != "aggTrade" {
+		return WebsocketMessage{}, nil
 	}
-
-	wsAggTradeHandler := func(event *binance.WsAggTradeEvent) {
-
-		volume, err := strconv.ParseFloat(event.Quantity, 64)
-		price, err2 := strconv.ParseFloat(event.Price, 64)
-
-		if err == nil && err2 == nil && event.Event == "aggTrade" {
-			if !event.IsBuyerMaker {
-				volume = -volume
-			}
-			pairNormalized, _ := s.NormalizePair(pair)
-			t := &dia.Trade{
-				Symbol:         pairNormalized.Symbol,
-				Pair:           pairNormalized.ForeignName,
-				Price:          price,
-				Volume:         volume,
-				Time:           time.Unix(event.TradeTime/1000, (event.TradeTime%1000)*int64(time.Millisecond)),
-				ForeignTradeID: strconv.FormatInt(event.AggTradeID, 16),
-				Source:         s.exchangeName,
-			}
-			ps.parent.chanTrades <- t
-			// log.Info("got trade: ", t)
-		} else {
-			log.Println("ignoring event ", event, err, err2)
-		}
-	}
-	errHandler := func(err error) {
-		log.Error(err)
+	volume, err := strconv.ParseFloat(event.Quantity, 64)
+	if err != nil {
+		return WebsocketMessage{}, err
 	}
-
-	_, _, err := binance.WsAggTradeServe(pair.ForeignName, wsAggTradeHandler, errHandler)
+	price, err := strconv.ParseFloat(event.Price, 64)
 	if err != nil {
-		log.Errorf("serving pair %s", pair.ForeignName)
+		return WebsocketMessage{}, err
+	}
+	if !event.IsBuyerMaker {
+		volume = -volume
+	}
+	t := &dia.Trade{
+		Pair:           event.Symbol,
+		Price:          price,
+		Volume:         volume,
+		Time:           time.Unix(event.TradeTime/1000, (event.TradeTime%1000)*int64(time.Millisecond)),
+		ForeignTradeID: strconv.FormatInt(event.AggTradeID, 16),
 	}
+	return WebsocketMessage{Trades: []*dia.Trade{t}}, nil
+}
+
+func (p binanceProtocol) Heartbeat() interface{} {
+	return nil
+}
 
-	return ps, err
+// tradePair returns the foreign name of @pair as normalized by NormalizePair.
+func (p binanceProtocol) tradePair(pair dia.Pair) string {
+	return normalizeBinancePair(pair).ForeignName
 }
 
 func (s *BinanceScraper) normalizeSymbol(p dia.Pair, foreignName string, params ...string) (pair dia.Pair, err error) {
@@ -215,48 +186,167 @@ func (s *BinanceScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
 	return
 }
 
-// BinancePairScraper implements PairScraper for Binance
-type BinancePairScraper struct {
-	parent *BinanceScraper
-	pair   dia.Pair
-	closed bool
-}
-
-// Close stops listening for trades of the pair associated with s
-func (ps *BinancePairScraper) Close() error {
-	var err error
-	s := ps.parent
-	// if parent already errored, return early
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	if s.error != nil {
-		return s.error
+// FetchTrades returns the aggregated trades of @pair in [@from, @to). The first trade is
+// searched in windows of an hour, the only windows accepted by the API, then the trades are
+// paged by id.
+func (s *BinanceScraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	pairNormalized, _ := s.NormalizePair(pair)
+	toMs := to.UnixNano() / int64(time.Millisecond)
+
+	fromID := int64(-1)
+	for start := from; fromID < 0 && start.Before(to); start = start.Add(time.Hour) {
+		end := start.Add(time.Hour)
+		if end.After(to) {
+			end = to
+		}
+		aggTrades, err := s.client.NewAggTradesService().
+			Symbol(pair.ForeignName).
+			StartTime(start.UnixNano() / int64(time.Millisecond)).
+			EndTime(end.UnixNano()/int64(time.Millisecond) - 1).
+			Limit(1).
+			Do(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if len(aggTrades) > 0 {
+			fromID = aggTrades[0].AggTradeID
+		}
 	}
-	if ps.closed {
-		return errors.New("BinancePairScraper: Already closed")
+	if fromID < 0 {
+		return nil, nil
+	}
+
+	var trades []*dia.Trade
+	for {
+		aggTrades, err := s.client.NewAggTradesService().
+			Symbol(pair.ForeignName).
+			FromID(fromID).
+			Limit(binanceTradesLimit).
+			Do(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, aggTrade := range aggTrades {
+			if aggTrade.Timestamp >= toMs {
+				return trades, nil
+			}
+			volume, err := strconv.ParseFloat(aggTrade.Quantity, 64)
+			if err != nil {
+				return nil, err
+			}
+			price, err := strconv.ParseFloat(aggTrade.Price, 64)
+			if err != nil {
+				return nil, err
+			}
+			if !aggTrade.IsBuyerMaker {
+				volume = -volume
+			}
+			trades = append(trades, &dia.Trade{
+				Symbol:         pairNormalized.Symbol,
+				Pair:           pairNormalized.ForeignName,
+				Price:          price,
+				Volume:         volume,
+				Time:           time.Unix(aggTrade.Timestamp/1000, (aggTrade.Timestamp%1000)*int64(time.Millisecond)),
+				ForeignTradeID: strconv.FormatInt(aggTrade.AggTradeID, 16),
+				Source:         s.exchangeName,
+			})
+		}
+		if len(aggTrades) < binanceTradesLimit {
+			return trades, nil
+		}
+		fromID = aggTrades[len(aggTrades)-1].AggTradeID + 1
 	}
+}
 
-	// TODO stop collection for the pair
+// binanceOrderBookProtocol implements WebsocketProtocol and OrderBookSnapshotter for the diff
+// depth streams of Binance. Diffs carry the range of update ids they cover.
+type binanceOrderBookProtocol struct{}
+
+type binanceDepthUpdate struct {
+	Event         string     `json:"e"`
+	EventTime     int64      `json:"E"`
+	Symbol        string     `json:"s"`
+	FirstUpdateID int64      `json:"U"`
+	FinalUpdateID int64      `json:"u"`
+	Bids          [][]string `json:"b"`
+	Asks          [][]string `json:"a"`
+}
+
+type binanceDepthSnapshot struct {
+	LastUpdateID int64      `json:"lastUpdateId"`
+	Bids         [][]string `json:"bids"`
+	Asks         [][]string `json:"asks"`
+}
+
+func (p binanceOrderBookProtocol) URL() (string, error) {
+	return _binanceSocketURL, nil
+}
 
-	ps.closed = true
-	return err
+func (p binanceOrderBookProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	return []interface{}{binanceRequest("SUBSCRIBE", pairs, "@depth@100ms")}, nil
 }
 
-// Channel returns a channel that can be used to receive trades
-func (ps *BinanceScraper) Channel() chan *dia.Trade {
-	return ps.chanTrades
+func (p binanceOrderBookProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return []interface{}{binanceRequest("UNSUBSCRIBE", []dia.Pair{pair}, "@depth@100ms")}, nil
+}
+
+func (p binanceOrderBookProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	update := binanceDepthUpdate{}
+	if err := json.Unmarshal(data, &update); err != nil {
+		return WebsocketMessage{}, err
+	}
+	// replies to requests have no event type
+	if update.Event != "depthUpdate" {
+		return WebsocketMessage{}, nil
+	}
+	bids, err := parseOrderBookLevels(update.Bids)
+	if err != nil {
+		return WebsocketMessage{}, err
+	}
+	asks, err := parseOrderBookLevels(update.Asks)
+	if err != nil {
+		return WebsocketMessage{}, err
+	}
+	return WebsocketMessage{
+		Diffs: []OrderBookDiff{{
+			Pair:          update.Symbol,
+			Bids:          bids,
+			Asks:          asks,
+			FirstSequence: update.FirstUpdateID,
+			Sequence:      update.FinalUpdateID,
+			Time:          time.Unix(0, update.EventTime*int64(time.Millisecond)),
+		}},
+	}, nil
 }
 
-// Error returns an error when the channel Channel() is closed
-// and nil otherwise
-func (ps *BinancePairScraper) Error() error {
-	s := ps.parent
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
+func (p binanceOrderBookProtocol) Heartbeat() interface{} {
+	return nil
 }
 
-// Pair returns the pair this scraper is subscribed to
-func (ps *BinancePairScraper) Pair() dia.Pair {
-	return ps.pair
+// Snapshot fetches the 1000 best levels of each side of the order book of @foreignName.
+func (p binanceOrderBookProtocol) Snapshot(foreignName string) (OrderBookDiff, error) {
+	data, err := utils.GetRequest("https://api.binance.com/api/v3/depth?symbol=" + foreignName + "&limit=1000")
+	if err != nil {
+		return OrderBookDiff{}, err
+	}
+	snapshot := binanceDepthSnapshot{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return OrderBookDiff{}, err
+	}
+	bids, err := parseOrderBookLevels(snapshot.Bids)
+	if err != nil {
+		return OrderBookDiff{}, err
+	}
+	asks, err := parseOrderBookLevels(snapshot.Asks)
+	if err != nil {
+		return OrderBookDiff{}, err
+	}
+	return OrderBookDiff{
+		Pair:     foreignName,
+		Snapshot: true,
+		Bids:     bids,
+		Asks:     asks,
+		Sequence: snapshot.LastUpdateID,
+		Time:     time.Now(),
+	}, nil
 }
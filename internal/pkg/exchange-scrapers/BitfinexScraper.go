@@ -2,7 +2,9 @@ package scrapers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,6 +17,12 @@ import (
 	utils "github.com/diadata-org/diadata/pkg/utils"
 )
 
+const (
+	bitfinexTradesLimit = 10000
+	// public endpoints are limited to 30 requests per minute
+	bitfinexRequestDelay = 2 * time.Second
+)
+
 type pairScraperSet map[*BitfinexPairScraper]nothing
 
 // BitfinexScraper is a Scraper for collecting trades from the Bitfinex websocket API
@@ -307,3 +315,51 @@ func (ps *BitfinexPairScraper) Error() error {
 func (ps *BitfinexPairScraper) Pair() dia.Pair {
 	return ps.pair
 }
+
+// FetchTrades returns the trades of @pair in [@from, @to). Pages are requested by the time of
+// the last trade, as trades of the same millisecond can be split across pages.
+func (s *BitfinexScraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	pairNormalized, _ := s.NormalizePair(pair)
+	start := from.UnixNano() / int64(time.Millisecond)
+	end := to.UnixNano()/int64(time.Millisecond) - 1
+	seen := make(map[int64]bool)
+	var trades []*dia.Trade
+	for start <= end {
+		data, err := utils.GetRequest(fmt.Sprintf("https://api-pub.bitfinex.com/v2/trades/t%s/hist?start=%d&end=%d&limit=%d&sort=1", pair.ForeignName, start, end, bitfinexTradesLimit))
+		if err != nil {
+			return nil, err
+		}
+		// [ID, MTS, AMOUNT, PRICE], the amount of sells is negative
+		var page [][]float64
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, trade := range page {
+			if len(trade) < 4 {
+				continue
+			}
+			id, mts := int64(trade[0]), int64(trade[1])
+			start = mts
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			added++
+			trades = append(trades, &dia.Trade{
+				Symbol:         pairNormalized.Symbol,
+				Pair:           pair.ForeignName,
+				Price:          trade[3],
+				Volume:         trade[2],
+				Time:           time.Unix(mts/1000, (mts%1000)*int64(time.Millisecond)),
+				ForeignTradeID: strconv.FormatInt(id, 16),
+				Source:         s.exchangeName,
+			})
+		}
+		if len(page) < bitfinexTradesLimit || added == 0 {
+			break
+		}
+		time.Sleep(bitfinexRequestDelay)
+	}
+	return trades, nil
+}
@@ -3,30 +3,22 @@ package scrapers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/diadata-org/diadata/pkg/dia"
 	"github.com/diadata-org/diadata/pkg/dia/helpers"
 	"github.com/diadata-org/diadata/pkg/utils"
-	ws "github.com/gorilla/websocket"
 	gdax "github.com/preichenberger/go-coinbasepro/v2"
 )
 
+// CoinBaseScraper scrapes the trades of CoinBase with a WebsocketScraper
 type CoinBaseScraper struct {
-	// signaling channels
-	shutdown     chan nothing
-	shutdownDone chan nothing
-	// error handling; to read error or closed, first acquire read lock
-	// only cleanup method should hold write lock
-	errorLock    sync.RWMutex
-	error        error
-	closed       bool
-	pairScrapers map[string]*CoinBasePairScraper // pc.Pair -> pairScraperSet
-	wsConn       *ws.Conn
+	*WebsocketScraper
 	exchangeName string
-	chanTrades   chan *dia.Trade
 }
 
 const (
@@ -38,98 +30,167 @@ const (
 	ChannelFull      = "full"
 )
 
+const (
+	coinBaseTradesLimit = 1000
+	// public endpoints are limited to 3 requests per second
+	coinBaseRequestDelay = 400 * time.Millisecond
+)
+
 // NewCoinBaseScraper returns a new CoinBaseScraper initialized with default values.
 // The instance is asynchronously scraping as soon as it is created.
 func NewCoinBaseScraper(exchange dia.Exchange) *CoinBaseScraper {
 	s := &CoinBaseScraper{
-		shutdown:     make(chan nothing),
-		shutdownDone: make(chan nothing),
-		pairScrapers: make(map[string]*CoinBasePairScraper),
 		exchangeName: exchange.Name,
-		error:        nil,
-		chanTrades:   make(chan *dia.Trade),
 	}
-	var wsDialer ws.Dialer
-	SwConn, _, err := wsDialer.Dial("wss://ws-feed.pro.coinbase.com", nil)
+	s.WebsocketScraper = NewWebsocketScraper(exchange, coinBaseProtocol{}, WebsocketOptions{})
+	return s
+}
+
+// coinBaseProtocol implements WebsocketProtocol for the matches channel of CoinBase. Trade
+// ids increase by one per trade of a product, which reveals missed trades.
+type coinBaseProtocol struct{}
+
+func (p coinBaseProtocol) URL() (string, error) {
+	return "wss://ws-feed.pro.coinbase.com", nil
+}
+
+func (p coinBaseProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	return []interface{}{coinBaseMessage("subscribe", ChannelMatches, pairs)}, nil
+}
+
+func (p coinBaseProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return []interface{}{coinBaseMessage("unsubscribe", ChannelMatches, []dia.Pair{pair})}, nil
+}
+
+// coinBaseMessage returns the message (un)subscribing from @channel of @pairs.
+func coinBaseMessage(messageType string, channel string, pairs []dia.Pair) gdax.Message {
+	var productIds []string
+	for _, pair := range pairs {
+		productIds = append(productIds, pair.ForeignName)
+	}
+	return gdax.Message{
+		Type: messageType,
+		Channels: []gdax.MessageChannel{
+			{
+				Name:       channel,
+				ProductIds: productIds,
+			},
+		},
+	}
+}
+
+func (p coinBaseProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	message := gdax.Message{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return WebsocketMessage{}, err
+	}
+	// last_match is the latest trade before subscribing
+	if message.Type != "match" && message.Type != "last_match" {
+		return WebsocketMessage{}, nil
+	}
+
+	f64Price, err := strconv.ParseFloat(message.Price, 64)
 	if err != nil {
-		println(err.Error())
+		return WebsocketMessage{}, errors.New("error parsing price " + message.Price)
 	}
-	s.wsConn = SwConn
-	go s.mainLoop()
-	return s
+	f64Volume, err := strconv.ParseFloat(message.Size, 64)
+	if err != nil {
+		return WebsocketMessage{}, errors.New("error parsing size " + message.Size)
+	}
+	// side is the side of the maker order, takers sell into buy orders
+	if message.Side == "buy" {
+		f64Volume = -f64Volume
+	}
+
+	t := &dia.Trade{
+		Pair:           message.ProductID,
+		Price:          f64Price,
+		Volume:         f64Volume,
+		Time:           message.Time.Time(),
+		ForeignTradeID: strconv.FormatInt(int64(message.TradeID), 16),
+	}
+	return WebsocketMessage{
+		Trades:   []*dia.Trade{t},
+		Stream:   message.ProductID,
+		Sequence: int64(message.TradeID),
+	}, nil
+}
+
+func (p coinBaseProtocol) Heartbeat() interface{} {
+	return nil
 }
 
-// mainLoop runs in a goroutine until channel s is closed.
-func (s *CoinBaseScraper) mainLoop() {
+// coinBaseOrderBookProtocol implements WebsocketProtocol for the level2 channel of CoinBase,
+// which sends a snapshot of the order book on subscribing and diffs afterwards.
+type coinBaseOrderBookProtocol struct{}
+
+type coinBaseLevel2Message struct {
+	Type      string     `json:"type"`
+	ProductID string     `json:"product_id"`
+	Time      string     `json:"time"`
+	Bids      [][]string `json:"bids"`
+	Asks      [][]string `json:"asks"`
+	// Changes are triples of side, price and size.
+	Changes [][]string `json:"changes"`
+}
+
+func (p coinBaseOrderBookProtocol) URL() (string, error) {
+	return "wss://ws-feed.pro.coinbase.com", nil
+}
+
+func (p coinBaseOrderBookProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	return []interface{}{coinBaseMessage("subscribe", ChannelLevel2, pairs)}, nil
+}
+
+func (p coinBaseOrderBookProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return []interface{}{coinBaseMessage("unsubscribe", ChannelLevel2, []dia.Pair{pair})}, nil
+}
+
+func (p coinBaseOrderBookProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	message := coinBaseLevel2Message{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return WebsocketMessage{}, err
+	}
+	diff := OrderBookDiff{Pair: message.ProductID}
 	var err error
-	for true {
-		message := gdax.Message{}
-		if err = s.wsConn.ReadJSON(&message); err != nil {
-			println(err.Error())
-			break
+	switch message.Type {
+	case "snapshot":
+		diff.Snapshot = true
+		diff.Time = time.Now()
+		if diff.Bids, err = parseOrderBookLevels(message.Bids); err != nil {
+			return WebsocketMessage{}, err
+		}
+		if diff.Asks, err = parseOrderBookLevels(message.Asks); err != nil {
+			return WebsocketMessage{}, err
+		}
+	case "l2update":
+		if diff.Time, err = time.Parse(time.RFC3339Nano, message.Time); err != nil {
+			return WebsocketMessage{}, err
 		}
-		if message.Type == ChannelTicker {
-			ps, ok := s.pairScrapers[message.ProductID]
-			if ok {
-				f64Price, err := strconv.ParseFloat(message.Price, 64)
-				if err == nil {
-					f64Volume, err := strconv.ParseFloat(message.LastSize, 64)
-					if err == nil {
-						if message.TradeID != 0 {
-							if message.Side == "sell" {
-								f64Volume = -f64Volume
-							}
-							t := &dia.Trade{
-								Symbol:         ps.pair.Symbol,
-								Pair:           message.ProductID,
-								Price:          f64Price,
-								Volume:         f64Volume,
-								Time:           message.Time.Time(),
-								ForeignTradeID: strconv.FormatInt(int64(message.TradeID), 16),
-								Source:         s.exchangeName,
-							}
-							log.Info("go trade: ", t)
-							ps.parent.chanTrades <- t
-						}
-					} else {
-						log.Error("error parsing LastSize " + message.LastSize)
-					}
-				} else {
-					log.Error("error parsing price " + message.Price)
-				}
+		for _, change := range message.Changes {
+			if len(change) < 3 {
+				return WebsocketMessage{}, errors.New("invalid change " + strings.Join(change, ","))
+			}
+			levels, err := parseOrderBookLevels([][]string{change[1:]})
+			if err != nil {
+				return WebsocketMessage{}, err
+			}
+			if change[0] == "buy" {
+				diff.Bids = append(diff.Bids, levels...)
 			} else {
-				log.Error("unknown productError" + message.ProductID)
+				diff.Asks = append(diff.Asks, levels...)
 			}
 		}
+	default:
+		return WebsocketMessage{}, nil
 	}
-	s.cleanup(err)
+	return WebsocketMessage{Diffs: []OrderBookDiff{diff}}, nil
 }
 
-// closes all connected PairScrapers
-// must only be called from mainLoop
-func (s *CoinBaseScraper) cleanup(err error) {
-	s.errorLock.Lock()
-	defer s.errorLock.Unlock()
-	if err != nil {
-		s.error = err
-	}
-	s.closed = true
-	close(s.shutdownDone) // signal that shutdown is complete
+func (p coinBaseOrderBookProtocol) Heartbeat() interface{} {
+	return nil
 }
 
-// Close closes any existing API connections, as well as channels of
-// PairScrapers from calls to ScrapePair
-func (s *CoinBaseScraper) Close() error {
-	if s.closed {
-		return errors.New("CoinBaseScraper: Already closed")
-	}
-	s.wsConn.Close()
-	close(s.shutdown)
-	<-s.shutdownDone
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
-}
 func (s *CoinBaseScraper) normalizeSymbol(foreignName string) (symbol string, err error) {
 	str := strings.Split(foreignName, "-")
 	symbol = str[0]
@@ -183,77 +244,101 @@ func (s *CoinBaseScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
 	return
 }
 
-// NewCoinBaseScraper implements PairScraper for GDax
-type CoinBasePairScraper struct {
-	parent     *CoinBaseScraper
-	pair       dia.Pair
-	closed     bool
-	lastRecord int64
+// coinBaseTrade is a trade of the trades endpoint of CoinBase.
+type coinBaseTrade struct {
+	TradeID int64     `json:"trade_id"`
+	Time    time.Time `json:"time"`
+	Price   string    `json:"price"`
+	Size    string    `json:"size"`
+	Side    string    `json:"side"`
 }
 
-// ScrapePair returns a PairScraper that can be used to get trades for a single pair from
-// this APIScraper
-func (s *CoinBaseScraper) ScrapePair(pair dia.Pair) (PairScraper, error) {
-
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	if s.error != nil {
-		return nil, s.error
+// fetchTradesBefore returns at most @limit trades of @productID with ids lower than @before,
+// newest first. The latest trades are returned if @before is 0.
+func (s *CoinBaseScraper) fetchTradesBefore(productID string, before int64, limit int) ([]coinBaseTrade, error) {
+	url := fmt.Sprintf("https://api.pro.coinbase.com/products/%s/trades?limit=%d", productID, limit)
+	if before > 0 {
+		url += "&after=" + strconv.FormatInt(before, 10)
 	}
-	if s.closed {
-		return nil, errors.New("CoinBaseScraper: Call ScrapePair on closed scraper")
-	}
-	ps := &CoinBasePairScraper{
-		parent:     s,
-		pair:       pair,
-		lastRecord: 0, //TODO FIX to figure out the last we got...
-	}
-
-	s.pairScrapers[pair.ForeignName] = ps
-
-	subscribe := gdax.Message{
-		Type: "subscribe",
-		Channels: []gdax.MessageChannel{
-			gdax.MessageChannel{
-				Name: ChannelHeartbeat,
-				ProductIds: []string{
-					pair.ForeignName,
-				},
-			},
-			gdax.MessageChannel{
-				Name: ChannelTicker,
-				ProductIds: []string{
-					pair.ForeignName,
-				},
-			},
-		},
-	}
-	if err := s.wsConn.WriteJSON(subscribe); err != nil {
-		println(err.Error())
+	time.Sleep(coinBaseRequestDelay)
+	data, err := utils.GetRequest(url)
+	if err != nil {
+		return nil, err
 	}
-
-	return ps, nil
-}
-
-// Channel returns a channel that can be used to receive trades/pricing information
-func (ps *CoinBaseScraper) Channel() chan *dia.Trade {
-	return ps.chanTrades
+	var trades []coinBaseTrade
+	err = json.Unmarshal(data, &trades)
+	return trades, err
 }
 
-func (ps *CoinBasePairScraper) Close() error {
-	return nil
-}
+// FetchTrades returns the trades of @pair in [@from, @to). Trades are only paged backwards by
+// id, so the id of the first trade at @to is searched for before.
+func (s *CoinBaseScraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	pairNormalized, _ := s.NormalizePair(pair)
+	latest, err := s.fetchTradesBefore(pair.ForeignName, 0, 1)
+	if err != nil || len(latest) == 0 {
+		return nil, err
+	}
 
-// Error returns an error when the channel Channel() is closed
-// and nil otherwise
-func (ps *CoinBasePairScraper) Error() error {
-	s := ps.parent
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
-}
+	// ids are consecutive, trade i is the only trade before id i+1
+	var searchErr error
+	before := int64(sort.Search(int(latest[0].TradeID)+1, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		trades, err := s.fetchTradesBefore(pair.ForeignName, int64(i)+1, 1)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return len(trades) > 0 && !trades[0].Time.Before(to)
+	}))
+	if searchErr != nil {
+		return nil, searchErr
+	}
 
-// Pair returns the pair this scraper is subscribed to
-func (ps *CoinBasePairScraper) Pair() dia.Pair {
-	return ps.pair
+	var trades []*dia.Trade
+	for before > 1 {
+		page, err := s.fetchTradesBefore(pair.ForeignName, before, coinBaseTradesLimit)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, trade := range page {
+			if trade.Time.Before(from) {
+				before = 0
+				break
+			}
+			if !trade.Time.Before(to) {
+				continue
+			}
+			price, err := strconv.ParseFloat(trade.Price, 64)
+			if err != nil {
+				return nil, err
+			}
+			volume, err := strconv.ParseFloat(trade.Size, 64)
+			if err != nil {
+				return nil, err
+			}
+			// side is the side of the maker order
+			if trade.Side == "buy" {
+				volume = -volume
+			}
+			trades = append(trades, &dia.Trade{
+				Symbol:         pairNormalized.Symbol,
+				Pair:           pair.ForeignName,
+				Price:          price,
+				Volume:         volume,
+				Time:           trade.Time,
+				ForeignTradeID: strconv.FormatInt(trade.TradeID, 16),
+				Source:         s.exchangeName,
+			})
+		}
+		if before > 0 {
+			before = page[len(page)-1].TradeID
+		}
+	}
+	sortTrades(trades)
+	return trades, nil
 }
@@ -379,6 +379,62 @@ func (scraper *CurveFIScraper) getSwapDataCurve(pool string, s *curvepool.Curvep
 	return
 }
 
+// FetchTrades returns the swaps of @pair in [@from, @to) filtered from the logs of all pools
+// of the registry.
+func (scraper *CurveFIScraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	start, end, err := blockRange(scraper.RestClient, from, to)
+	if err != nil {
+		return nil, err
+	}
+	times := newBlockTimes(scraper.RestClient)
+
+	var trades []*dia.Trade
+	for _, pool := range scraper.pools.poolsAddressNoLock() {
+		filterer, err := curvepool.NewCurvepoolFilterer(common.HexToAddress(pool), scraper.RestClient)
+		if err != nil {
+			return nil, err
+		}
+		err = forBlockRanges(start, end, func(start uint64, end uint64) error {
+			iter, err := filterer.FilterTokenExchange(&bind.FilterOpts{Start: start, End: &end}, nil)
+			if err != nil {
+				return err
+			}
+			defer iter.Close()
+			for iter.Next() {
+				swp := iter.Event
+				_, okSold := scraper.pools.getPoolCoin(pool, int(swp.SoldId.Int64()))
+				_, okBought := scraper.pools.getPoolCoin(pool, int(swp.BoughtId.Int64()))
+				if !okSold || !okBought {
+					continue
+				}
+				foreignName, volume, price, err := scraper.getSwapDataCurve(pool, swp)
+				if err != nil || foreignName != pair.ForeignName {
+					continue
+				}
+				timestamp, err := times.at(swp.Raw.BlockNumber)
+				if err != nil {
+					return err
+				}
+				trades = append(trades, &dia.Trade{
+					Symbol:         pair.Symbol,
+					Pair:           foreignName,
+					Price:          price,
+					Volume:         volume,
+					Time:           timestamp,
+					ForeignTradeID: swp.Raw.TxHash.Hex() + "-" + fmt.Sprint(swp.Raw.Index),
+					Source:         scraper.exchangeName,
+				})
+			}
+			return iter.Error()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortTrades(trades)
+	return trades, nil
+}
+
 func (scraper *CurveFIScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
 
 	pairSet := make(map[string]struct{})
@@ -5,13 +5,11 @@ import (
 	"errors"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/diadata-org/diadata/pkg/dia"
 	"github.com/diadata-org/diadata/pkg/dia/helpers"
 	utils "github.com/diadata-org/diadata/pkg/utils"
-	ws "github.com/gorilla/websocket"
 )
 
 var _GateIOsocketurl string = "wss://api.gateio.ws/ws/v4/"
@@ -29,44 +27,18 @@ type SubscribeGate struct {
 	Payload []string `json:"payload"`
 }
 
+// GateIOScraper scrapes the trades of GateIO with a WebsocketScraper
 type GateIOScraper struct {
-	wsClient *ws.Conn
-	// signaling channels for session initialization and finishing
-	//initDone     chan nothing
-	shutdown     chan nothing
-	shutdownDone chan nothing
-	// error handling; to read error or closed, first acquire read lock
-	// only cleanup method should hold write lock
-	errorLock sync.RWMutex
-	error     error
-	closed    bool
-	// used to keep track of trading pairs that we subscribed to
-	pairScrapers map[string]*GateIOPairScraper
+	*WebsocketScraper
 	exchangeName string
-	chanTrades   chan *dia.Trade
 }
 
 // NewGateIOScraper returns a new GateIOScraper for the given pair
 func NewGateIOScraper(exchange dia.Exchange) *GateIOScraper {
-
 	s := &GateIOScraper{
-		shutdown:     make(chan nothing),
-		shutdownDone: make(chan nothing),
-		pairScrapers: make(map[string]*GateIOPairScraper),
 		exchangeName: exchange.Name,
-		error:        nil,
-		chanTrades:   make(chan *dia.Trade),
 	}
-
-	var wsDialer ws.Dialer
-	SwConn, _, err := wsDialer.Dial(_GateIOsocketurl, nil)
-
-	if err != nil {
-		println(err.Error())
-	}
-
-	s.wsClient = SwConn
-	go s.mainLoop()
+	s.WebsocketScraper = NewWebsocketScraper(exchange, gateIOProtocol{}, WebsocketOptions{})
 	return s
 }
 
@@ -100,131 +72,73 @@ type GateIOResponseTrade struct {
 	} `json:"result"`
 }
 
-// runs in a goroutine until s is closed
-func (s *GateIOScraper) mainLoop() {
-	var (
-		gresponse GateIPPairResponse
-	)
-	var err error
-	var f64Price float64
-	var f64Volume float64
+// gateIOProtocol implements WebsocketProtocol for the spot.trades channel of GateIO
+type gateIOProtocol struct{}
 
-	b, _ := utils.GetRequest("https://api.gateio.ws/api/v4/spot/currency_pairs")
-	err = json.Unmarshal(b, &gresponse)
-	if err != nil {
-		log.Error("deconding currency pairs: ", err)
-	}
+func (p gateIOProtocol) URL() (string, error) {
+	return _GateIOsocketurl, nil
+}
 
-	for _, v := range gresponse {
+func (p gateIOProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	return p.messages("subscribe", pairs), nil
+}
 
-		a := &SubscribeGate{
-			Event:   "subscribe",
+func (p gateIOProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return p.messages("unsubscribe", []dia.Pair{pair}), nil
+}
+
+func (p gateIOProtocol) messages(event string, pairs []dia.Pair) []interface{} {
+	var messages []interface{}
+	for _, pair := range pairs {
+		messages = append(messages, &SubscribeGate{
+			Event:   event,
 			Time:    time.Now().Unix(),
 			Channel: "spot.trades",
-			Payload: []string{v.ID},
-		}
-		log.Infof("Subscribed for Pair %v", v.ID)
-		if err = s.wsClient.WriteJSON(a); err != nil {
-			log.Error(err.Error())
-		}
+			Payload: []string{pair.ForeignName},
+		})
 	}
-
-	for {
-
-		var message GateIOResponseTrade
-		if err = s.wsClient.ReadJSON(&message); err != nil {
-			log.Error(err.Error())
-			break
-		}
-
-		ps, ok := s.pairScrapers[message.Result.CurrencyPair]
-		if ok {
-
-			f64Price, err = strconv.ParseFloat(message.Result.Price, 64)
-			if err != nil {
-				log.Errorln("error parsing float Price", err)
-				continue
-			}
-
-			f64Volume, err = strconv.ParseFloat(message.Result.Amount, 64)
-			if err != nil {
-				log.Errorln("error parsing float Price", err)
-				continue
-			}
-
-			if message.Result.Side == "sell" {
-				f64Volume = -f64Volume
-			}
-
-			t := &dia.Trade{
-				Symbol:         ps.pair.Symbol,
-				Pair:           message.Result.CurrencyPair,
-				Price:          f64Price,
-				Volume:         f64Volume,
-				Time:           time.Unix(int64(message.Result.CreateTime), 0),
-				ForeignTradeID: strconv.FormatInt(int64(message.Result.ID), 16),
-				Source:         s.exchangeName,
-			}
-			ps.parent.chanTrades <- t
-			log.Infoln("got trade", t)
-
-		}
-
-	}
-	s.cleanup(err)
+	return messages
 }
 
-func (s *GateIOScraper) cleanup(err error) {
-	s.errorLock.Lock()
-	defer s.errorLock.Unlock()
+func (p gateIOProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	var message GateIOResponseTrade
+	if err := json.Unmarshal(data, &message); err != nil {
+		return WebsocketMessage{}, err
+	}
+	// subscription results and pongs carry no trades
+	if message.Channel != "spot.trades" || message.Event != "update" {
+		return WebsocketMessage{}, nil
+	}
 
+	f64Price, err := strconv.ParseFloat(message.Result.Price, 64)
 	if err != nil {
-		s.error = err
+		return WebsocketMessage{}, err
 	}
-	s.closed = true
-
-	close(s.shutdownDone)
-}
-
-// Close closes any existing API connections, as well as channels of
-// PairScrapers from calls to ScrapePair
-func (s *GateIOScraper) Close() error {
-
-	if s.closed {
-		return errors.New("GateIOScraper: Already closed")
+	f64Volume, err := strconv.ParseFloat(message.Result.Amount, 64)
+	if err != nil {
+		return WebsocketMessage{}, err
 	}
-	s.wsClient.Close()
-	close(s.shutdown)
-	<-s.shutdownDone
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
-}
-
-// ScrapePair returns a PairScraper that can be used to get trades for a single pair from
-// this APIScraper
-func (s *GateIOScraper) ScrapePair(pair dia.Pair) (PairScraper, error) {
-
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-
-	if s.error != nil {
-		return nil, s.error
+	if message.Result.Side == "sell" {
+		f64Volume = -f64Volume
 	}
 
-	if s.closed {
-		return nil, errors.New("GateIOScraper: Call ScrapePair on closed scraper")
+	t := &dia.Trade{
+		Pair:           message.Result.CurrencyPair,
+		Price:          f64Price,
+		Volume:         f64Volume,
+		Time:           time.Unix(int64(message.Result.CreateTime), 0),
+		ForeignTradeID: strconv.FormatInt(int64(message.Result.ID), 16),
 	}
+	return WebsocketMessage{Trades: []*dia.Trade{t}}, nil
+}
 
-	ps := &GateIOPairScraper{
-		parent: s,
-		pair:   pair,
+func (p gateIOProtocol) Heartbeat() interface{} {
+	return &SubscribeGate{
+		Time:    time.Now().Unix(),
+		Channel: "spot.ping",
 	}
-
-	s.pairScrapers[pair.ForeignName] = ps
-
-	return ps, nil
 }
+
 func (s *GateIOScraper) normalizeSymbol(foreignName string, params ...interface{}) (symbol string, err error) {
 	str := strings.Split(foreignName, "_")
 	symbol = strings.ToUpper(str[0])
@@ -282,34 +196,3 @@ func (s *GateIOScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
 	}
 	return
 }
-
-// GateIOPairScraper implements PairScraper for GateIO
-type GateIOPairScraper struct {
-	parent *GateIOScraper
-	pair   dia.Pair
-	closed bool
-}
-
-// Close stops listening for trades of the pair associated with s
-func (ps *GateIOPairScraper) Close() error {
-	return nil
-}
-
-// Channel returns a channel that can be used to receive trades
-func (ps *GateIOScraper) Channel() chan *dia.Trade {
-	return ps.chanTrades
-}
-
-// Error returns an error when the channel Channel() is closed
-// and nil otherwise
-func (ps *GateIOPairScraper) Error() error {
-	s := ps.parent
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
-}
-
-// Pair returns the pair this scraper is subscribed to
-func (ps *GateIOPairScraper) Pair() dia.Pair {
-	return ps.pair
-}
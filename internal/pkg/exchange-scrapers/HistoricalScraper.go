@@ -0,0 +1,162 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// filterBlockRange is the number of blocks of which logs are filtered at once.
+const filterBlockRange = 2000
+
+// HistoricalScraper is implemented by APIScrapers which can fetch past trades, e.g. for
+// backfilling a new exchange or a gap after an outage.
+type HistoricalScraper interface {
+	// FetchTrades returns the trades of @pair in [@from, @to) ordered by time.
+	FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error)
+}
+
+// Backfill fetches the trades of the pairs selected from @pairs in [@from, @to) and passes
+// them to @handle in chunks of at most @chunk, so that long intervals are not held in memory.
+// @onePairPerSymbol selects the pairs like the Supervisor does.
+func Backfill(scraper HistoricalScraper, pairs []dia.Pair, onePairPerSymbol bool, from time.Time, to time.Time, chunk time.Duration, handle func([]*dia.Trade) error) error {
+	selected := selectPairs(pairs, nil, onePairPerSymbol)
+	foreignNames := make([]string, 0, len(selected))
+	for foreignName := range selected {
+		foreignNames = append(foreignNames, foreignName)
+	}
+	sort.Strings(foreignNames)
+
+	for _, foreignName := range foreignNames {
+		pair := selected[foreignName]
+		for start := from; start.Before(to); start = start.Add(chunk) {
+			end := start.Add(chunk)
+			if end.After(to) {
+				end = to
+			}
+			trades, err := scraper.FetchTrades(pair, start, end)
+			if err != nil {
+				return fmt.Errorf("fetching trades of %s in [%v, %v): %v", foreignName, start, end, err)
+			}
+			log.Infof("backfilled %d trades of %s in [%v, %v)", len(trades), foreignName, start, end)
+			if err := handle(trades); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// headerReader is implemented by ethclient.Client.
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// blockRange returns the first and the last block mined in [@from, @to). The first block is
+// after the last one if there is no block in the interval.
+func blockRange(client headerReader, from time.Time, to time.Time) (start uint64, end uint64, err error) {
+	latest, err := client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return
+	}
+	start, err = blockAt(client, latest, from)
+	if err != nil {
+		return
+	}
+	end, err = blockAt(client, latest, to)
+	if err != nil {
+		return
+	}
+	if end == 0 {
+		return 1, 0, nil
+	}
+	return start, end - 1, nil
+}
+
+// blockAt returns the number of the first block mined at or after @t. It is the number after
+// @latest if @latest was mined before @t.
+func blockAt(client headerReader, latest *types.Header, t time.Time) (uint64, error) {
+	if latest.Time < uint64(t.Unix()) {
+		return latest.Number.Uint64() + 1, nil
+	}
+	var searchErr error
+	n := sort.Search(int(latest.Number.Int64()), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		header, err := client.HeaderByNumber(context.Background(), big.NewInt(int64(i)))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return header.Time >= uint64(t.Unix())
+	})
+	return uint64(n), searchErr
+}
+
+// forBlockRanges calls @f for consecutive ranges of at most filterBlockRange blocks covering
+// [@start, @end].
+func forBlockRanges(start uint64, end uint64, f func(start uint64, end uint64) error) error {
+	for ; start <= end; start += filterBlockRange {
+		last := start + filterBlockRange - 1
+		if last > end {
+			last = end
+		}
+		if err := f(start, last); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockTimes caches the times blocks were mined at.
+type blockTimes struct {
+	client headerReader
+	times  map[uint64]time.Time
+}
+
+func newBlockTimes(client headerReader) *blockTimes {
+	return &blockTimes{client: client, times: make(map[uint64]time.Time)}
+}
+
+func (bt *blockTimes) at(number uint64) (time.Time, error) {
+	if t, ok := bt.times[number]; ok {
+		return t, nil
+	}
+	header, err := bt.client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(number))
+	if err != nil {
+		return time.Time{}, err
+	}
+	t := time.Unix(int64(header.Time), 0)
+	bt.times[number] = t
+	return t, nil
+}
+
+// pairAddresses maps foreign names to the addresses of their pair contracts. It is loaded once
+// on first use, as loading all pairs of a DEX takes long.
+type pairAddresses struct {
+	once      sync.Once
+	addresses map[string][]common.Address
+	err       error
+}
+
+func (pa *pairAddresses) get(foreignName string, load func() (map[string][]common.Address, error)) ([]common.Address, error) {
+	pa.once.Do(func() {
+		pa.addresses, pa.err = load()
+	})
+	return pa.addresses[foreignName], pa.err
+}
+
+// sortTrades orders @trades by time.
+func sortTrades(trades []*dia.Trade) {
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Time.Before(trades[j].Time)
+	})
+}
@@ -0,0 +1,114 @@
+package scrapers
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeChain mines block i at 1000+10*i seconds, the latest block is 100.
+type fakeChain struct{}
+
+func (c fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = big.NewInt(100)
+	}
+	return &types.Header{Number: number, Time: 1000 + 10*number.Uint64()}, nil
+}
+
+// fakeHistoricalScraper returns one trade per fetched interval at its start.
+type fakeHistoricalScraper struct{}
+
+func (s fakeHistoricalScraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	return []*dia.Trade{{Symbol: pair.Symbol, Pair: pair.ForeignName, Time: from}}, nil
+}
+
+func TestBackfill(t *testing.T) {
+	pairs := []dia.Pair{
+		{Symbol: "ETH", ForeignName: "ETHUSDT"},
+		{Symbol: "BTC", ForeignName: "BTCUSDT"},
+		{Symbol: "BTC", ForeignName: "BTCEUR"},
+		{Symbol: "XRP", ForeignName: "XRPUSDT", Ignore: true},
+	}
+	from := time.Unix(1600000000, 0)
+	var trades []*dia.Trade
+	err := Backfill(fakeHistoricalScraper{}, pairs, true, from, from.Add(150*time.Minute), time.Hour, func(chunk []*dia.Trade) error {
+		trades = append(trades, chunk...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		pair  string
+		after time.Duration
+	}{
+		{"BTCUSDT", 0},
+		{"BTCUSDT", time.Hour},
+		{"BTCUSDT", 2 * time.Hour},
+		{"ETHUSDT", 0},
+		{"ETHUSDT", time.Hour},
+		{"ETHUSDT", 2 * time.Hour},
+	}
+	if len(trades) != len(want) {
+		t.Fatalf("Number of trades was incorrect, got: %d, want: %d.", len(trades), len(want))
+	}
+	for i, w := range want {
+		if trades[i].Pair != w.pair || !trades[i].Time.Equal(from.Add(w.after)) {
+			t.Errorf("Trade %d was incorrect, got: %s at %v, want: %s at %v.", i, trades[i].Pair, trades[i].Time, w.pair, from.Add(w.after))
+		}
+	}
+}
+
+func TestBlockRange(t *testing.T) {
+	tables := []struct {
+		from  int64
+		to    int64
+		start uint64
+		end   uint64
+	}{
+		{1000, 1030, 0, 2},
+		{1005, 1031, 1, 3},
+		{1500, 1600, 50, 59},
+		{1995, 3000, 100, 100},
+		{2500, 3000, 101, 100},
+		{0, 1000, 1, 0},
+	}
+	for _, table := range tables {
+		start, end, err := blockRange(fakeChain{}, time.Unix(table.from, 0), time.Unix(table.to, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start != table.start || end != table.end {
+			t.Errorf("Blocks of [%d, %d) were incorrect, got: [%d, %d], want: [%d, %d].", table.from, table.to, start, end, table.start, table.end)
+		}
+	}
+}
+
+func TestForBlockRanges(t *testing.T) {
+	var ranges [][2]uint64
+	err := forBlockRanges(10, 2*filterBlockRange+10, func(start uint64, end uint64) error {
+		ranges = append(ranges, [2]uint64{start, end})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]uint64{
+		{10, filterBlockRange + 9},
+		{filterBlockRange + 10, 2*filterBlockRange + 9},
+		{2*filterBlockRange + 10, 2*filterBlockRange + 10},
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("Ranges were incorrect, got: %v, want: %v.", ranges, want)
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("Range %d was incorrect, got: %v, want: %v.", i, ranges[i], want[i])
+		}
+	}
+}
@@ -1,51 +1,40 @@
 package scrapers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	krakenapi "github.com/beldur/kraken-go-api-client"
 	"github.com/diadata-org/diadata/pkg/dia"
+	utils "github.com/diadata-org/diadata/pkg/utils"
 )
 
-const (
-	krakenRefreshDelay = time.Second * 30 * 1
+var (
+	_KrakenSocketURL     = "wss://ws.kraken.com"
+	_KrakenAssetPairsURL = "https://api.kraken.com/0/public/AssetPairs"
 )
 
+// KrakenScraper scrapes the trades of Kraken with a WebsocketScraper
 type KrakenScraper struct {
-	// signaling channels
-	shutdown     chan nothing
-	shutdownDone chan nothing
-	// error handling; to read error or closed, first acquire read lock
-	// only cleanup method should hold write lock
-	errorLock    sync.RWMutex
-	error        error
-	closed       bool
-	pairScrapers map[string]*KrakenPairScraper // pc.Pair -> pairScraperSet
+	*WebsocketScraper
 	api          *krakenapi.KrakenApi
-	ticker       *time.Ticker
 	exchangeName string
-	chanTrades   chan *dia.Trade
 }
 
 // NewKrakenScraper returns a new KrakenScraper initialized with default values.
 // The instance is asynchronously scraping as soon as it is created.
 func NewKrakenScraper(key string, secret string, exchange dia.Exchange) *KrakenScraper {
 	s := &KrakenScraper{
-		shutdown:     make(chan nothing),
-		shutdownDone: make(chan nothing),
-		pairScrapers: make(map[string]*KrakenPairScraper),
 		api:          krakenapi.New(key, secret),
-		ticker:       time.NewTicker(krakenRefreshDelay),
 		exchangeName: exchange.Name,
-		error:        nil,
-		chanTrades:   make(chan *dia.Trade),
 	}
-	go s.mainLoop()
+	s.WebsocketScraper = NewWebsocketScraper(exchange, &krakenProtocol{}, WebsocketOptions{})
 	return s
 }
 
@@ -65,79 +54,6 @@ func FloatToString(input_num float64) string {
 	return strconv.FormatFloat(input_num, 'f', -1, 64)
 }
 
-// mainLoop runs in a goroutine until channel s is closed.
-func (s *KrakenScraper) mainLoop() {
-	for {
-		select {
-		case <-s.ticker.C:
-			s.Update()
-		case <-s.shutdown: // user requested shutdown
-			log.Printf("KrakenScraper shutting down")
-			s.cleanup(nil)
-			return
-		}
-	}
-}
-
-// closes all connected PairScrapers
-// must only be called from mainLoop
-func (s *KrakenScraper) cleanup(err error) {
-
-	s.errorLock.Lock()
-	defer s.errorLock.Unlock()
-
-	if err != nil {
-		s.error = err
-	}
-	s.closed = true
-
-	close(s.shutdownDone) // signal that shutdown is complete
-}
-
-// Close closes any existing API connections, as well as channels of
-// PairScrapers from calls to ScrapePair
-func (s *KrakenScraper) Close() error {
-	if s.closed {
-		return errors.New("KrakenScraper: Already closed")
-	}
-	close(s.shutdown)
-	<-s.shutdownDone
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
-}
-
-// KrakenPairScraper implements PairScraper for Kraken
-type KrakenPairScraper struct {
-	parent     *KrakenScraper
-	pair       dia.Pair
-	closed     bool
-	lastRecord int64
-}
-
-// ScrapePair returns a PairScraper that can be used to get trades for a single pair from
-// this APIScraper
-func (s *KrakenScraper) ScrapePair(pair dia.Pair) (PairScraper, error) {
-
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	if s.error != nil {
-		return nil, s.error
-	}
-	if s.closed {
-		return nil, errors.New("KrakenScraper: Call ScrapePair on closed scraper")
-	}
-	ps := &KrakenPairScraper{
-		parent:     s,
-		pair:       pair,
-		lastRecord: 0, //TODO FIX to figure out the last we got...
-	}
-
-	s.pairScrapers[pair.Symbol] = ps
-
-	return ps, nil
-}
-
 // FetchAvailablePairs returns a list with all available trade pairs
 func (s *KrakenScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
 	return []dia.Pair{}, errors.New("FetchAvailablePairs() not implemented")
@@ -145,10 +61,14 @@ func (s *KrakenScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
 
 // NormalizePair accounts for the par
 func (ps *KrakenScraper) NormalizePair(pair dia.Pair) (dia.Pair, error) {
+	return normalizeKrakenPair(pair), nil
+}
+
+func normalizeKrakenPair(pair dia.Pair) dia.Pair {
 	if len(pair.ForeignName) == 7 {
 		if pair.ForeignName[4:5] == "Z" || pair.ForeignName[4:5] == "X" {
 			pair.ForeignName = pair.ForeignName[:4] + pair.ForeignName[5:]
-			return pair, nil
+			return pair
 		}
 		if pair.ForeignName[:1] == "Z" || pair.ForeignName[:1] == "X" {
 			pair.ForeignName = pair.ForeignName[1:]
@@ -168,30 +88,7 @@ func (ps *KrakenScraper) NormalizePair(pair dia.Pair) (dia.Pair, error) {
 	if pair.ForeignName[:3] == "XBT" {
 		pair.ForeignName = "BTC" + pair.ForeignName[len(pair.ForeignName)-3:]
 	}
-	return pair, nil
-}
-
-// Channel returns a channel that can be used to receive trades/pricing information
-func (ps *KrakenScraper) Channel() chan *dia.Trade {
-	return ps.chanTrades
-}
-
-func (ps *KrakenPairScraper) Close() error {
-	return nil
-}
-
-// Error returns an error when the channel Channel() is closed
-// and nil otherwise
-func (ps *KrakenPairScraper) Error() error {
-	s := ps.parent
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
-}
-
-// Pair returns the pair this scraper is subscribed to
-func (ps *KrakenPairScraper) Pair() dia.Pair {
-	return ps.pair
+	return pair
 }
 
 func NewTrade(pair dia.Pair, info krakenapi.TradeInfo, foreignTradeID string) *dia.Trade {
@@ -211,27 +108,194 @@ func NewTrade(pair dia.Pair, info krakenapi.TradeInfo, foreignTradeID string) *d
 	return t
 }
 
-func (s *KrakenScraper) Update() {
+// krakenTradeID returns the ID of the trade @info. Kraken trades have no IDs, so that it is
+// derived from their content, which is the same for trades fetched and scraped live.
+func krakenTradeID(info krakenapi.TradeInfo) string {
+	side, orderType := "b", "m"
+	if info.Sell {
+		side = "s"
+	}
+	if info.Limit {
+		orderType = "l"
+	}
+	return strconv.FormatInt(info.Time, 10) + "-" + strconv.FormatFloat(info.PriceFloat, 'f', -1, 64) + "-" +
+		strconv.FormatFloat(info.VolumeFloat, 'f', -1, 64) + "-" + side + orderType
+}
 
-	for _, ps := range s.pairScrapers {
+// FetchTrades returns the trades of @pair in [@from, @to). The API returns the trades since a
+// cursor in nanoseconds, with the cursor of the next page.
+func (s *KrakenScraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	p, _ := s.NormalizePair(pair)
+	var trades []*dia.Trade
+	since := from.UnixNano()
+	for {
+		r, err := s.api.Trades(pair.ForeignName, since)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil || len(r.Trades) == 0 {
+			return trades, nil
+		}
+		for _, ti := range r.Trades {
+			if ti.Time >= to.Unix() {
+				return trades, nil
+			}
+			trades = append(trades, NewTrade(p, ti, krakenTradeID(ti)))
+		}
+		if r.Last <= since {
+			return trades, nil
+		}
+		since = r.Last
+		// public endpoints are limited to about one call per second
+		time.Sleep(time.Second)
+	}
+}
+
+// krakenProtocol implements WebsocketProtocol for the trade channel of Kraken. The channel
+// names pairs by their websocket names, e.g. XBT/USD, which are looked up on connecting.
+type krakenProtocol struct {
+	lock sync.RWMutex
+	// wsNames maps the names and alternative names of pairs to their websocket names.
+	wsNames map[string]string
+	// foreignNames maps websocket names to the foreign names subscribed with.
+	foreignNames map[string]string
+}
+
+type krakenSubscription struct {
+	Event        string   `json:"event"`
+	Pair         []string `json:"pair"`
+	Subscription struct {
+		Name string `json:"name"`
+	} `json:"subscription"`
+}
+
+type krakenAssetPairs struct {
+	Error  []string `json:"error"`
+	Result map[string]struct {
+		Altname string `json:"altname"`
+		WsName  string `json:"wsname"`
+	} `json:"result"`
+}
+
+func (p *krakenProtocol) URL() (string, error) {
+	data, err := utils.GetRequest(_KrakenAssetPairsURL)
+	if err != nil {
+		return "", err
+	}
+	var assetPairs krakenAssetPairs
+	if err := json.Unmarshal(data, &assetPairs); err != nil {
+		return "", err
+	}
+	if len(assetPairs.Error) > 0 {
+		return "", errors.New(strings.Join(assetPairs.Error, ", "))
+	}
+	wsNames := make(map[string]string)
+	for name, info := range assetPairs.Result {
+		wsNames[name] = info.WsName
+		wsNames[info.Altname] = info.WsName
+	}
+	p.lock.Lock()
+	p.wsNames = wsNames
+	p.foreignNames = make(map[string]string)
+	p.lock.Unlock()
+	return _KrakenSocketURL, nil
+}
 
-		r, err := s.api.Trades(ps.pair.ForeignName, ps.lastRecord)
+func (p *krakenProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	return p.message("subscribe", pairs)
+}
+
+func (p *krakenProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return p.message("unsubscribe", []dia.Pair{pair})
+}
 
+func (p *krakenProtocol) message(event string, pairs []dia.Pair) ([]interface{}, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	message := &krakenSubscription{Event: event}
+	message.Subscription.Name = "trade"
+	for _, pair := range pairs {
+		wsName, ok := p.wsNames[pair.ForeignName]
+		if !ok {
+			log.Errorf("Kraken: no websocket name for pair %s", pair.ForeignName)
+			continue
+		}
+		p.foreignNames[wsName] = pair.ForeignName
+		message.Pair = append(message.Pair, wsName)
+	}
+	return []interface{}{message}, nil
+}
+
+// Parse parses trade messages of the form [channelID, [[price, volume, time, side, orderType,
+// misc], ...], "trade", pair]. Events such as heartbeats and subscription statuses are objects.
+func (p *krakenProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	if len(data) == 0 || data[0] != '[' {
+		return WebsocketMessage{}, nil
+	}
+	var message []json.RawMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return WebsocketMessage{}, err
+	}
+	if len(message) != 4 {
+		return WebsocketMessage{}, errors.New("unexpected number of fields in Kraken message")
+	}
+	var channel, wsName string
+	if err := json.Unmarshal(message[2], &channel); err != nil {
+		return WebsocketMessage{}, err
+	}
+	if channel != "trade" {
+		return WebsocketMessage{}, nil
+	}
+	if err := json.Unmarshal(message[3], &wsName); err != nil {
+		return WebsocketMessage{}, err
+	}
+	var rows [][]string
+	if err := json.Unmarshal(message[1], &rows); err != nil {
+		return WebsocketMessage{}, err
+	}
+
+	p.lock.RLock()
+	foreignName := p.foreignNames[wsName]
+	p.lock.RUnlock()
+	var trades []*dia.Trade
+	for _, row := range rows {
+		if len(row) < 5 {
+			return WebsocketMessage{}, errors.New("unexpected number of fields in Kraken trade")
+		}
+		price, err := strconv.ParseFloat(row[0], 64)
 		if err != nil {
-			log.Printf("err on collect trades %v %v", err, ps.pair.ForeignName)
-			time.Sleep(1 * time.Minute)
-		} else {
-			if r != nil {
-				ps.lastRecord = r.Last
-				for _, ti := range r.Trades {
-					p, _ := s.NormalizePair(ps.pair)
-					t := NewTrade(p, ti, strconv.FormatInt(r.Last, 16))
-					ps.parent.chanTrades <- t
-					log.Info("got trade: ", t)
-				}
-			} else {
-				log.Printf("r nil")
-			}
+			return WebsocketMessage{}, err
+		}
+		volume, err := strconv.ParseFloat(row[1], 64)
+		if err != nil {
+			return WebsocketMessage{}, err
+		}
+		seconds, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return WebsocketMessage{}, err
 		}
+		info := krakenapi.TradeInfo{
+			Price:       row[0],
+			PriceFloat:  price,
+			Volume:      row[1],
+			VolumeFloat: volume,
+			Time:        int64(seconds),
+			Buy:         row[3] == "b",
+			Sell:        row[3] == "s",
+			Market:      row[4] == "m",
+			Limit:       row[4] == "l",
+		}
+		// Symbol, Pair and Source are set by the scraper
+		trades = append(trades, NewTrade(dia.Pair{ForeignName: foreignName}, info, krakenTradeID(info)))
 	}
+	return WebsocketMessage{Trades: trades}, nil
+}
+
+func (p *krakenProtocol) Heartbeat() interface{} {
+	return map[string]string{"event": "ping"}
+}
+
+// tradePair returns the foreign name of @pair as normalized by NormalizePair.
+func (p *krakenProtocol) tradePair(pair dia.Pair) string {
+	return normalizeKrakenPair(pair).ForeignName
 }
@@ -0,0 +1,50 @@
+package scrapers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+func TestKrakenProtocolParse(t *testing.T) {
+	p := &krakenProtocol{
+		wsNames:      map[string]string{"XXBTZEUR": "XBT/EUR"},
+		foreignNames: make(map[string]string),
+	}
+	if _, err := p.Subscribe([]dia.Pair{{Symbol: "BTC", ForeignName: "XXBTZEUR"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := p.Parse(0, []byte(`[0,[["5541.2","0.15","1534614057.321597","s","l",""],["5542.0","0.5","1534614058.0","b","m",""]],"trade","XBT/EUR"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := []struct {
+		price  float64
+		volume float64
+		time   time.Time
+		id     string
+	}{
+		{5541.2, -0.15, time.Unix(1534614057, 0), "1534614057-5541.2-0.15-sl"},
+		{5542, 0.5, time.Unix(1534614058, 0), "1534614058-5542-0.5-bm"},
+	}
+	if len(message.Trades) != len(tables) {
+		t.Fatalf("Number of trades was incorrect, got: %d, want: %d.", len(message.Trades), len(tables))
+	}
+	for i, table := range tables {
+		trade := message.Trades[i]
+		if trade.Pair != "XXBTZEUR" || trade.Price != table.price || trade.Volume != table.volume || !trade.Time.Equal(table.time) || trade.ForeignTradeID != table.id {
+			t.Errorf("Trade %d was incorrect, got: %v.", i, trade)
+		}
+	}
+	if pair := p.tradePair(dia.Pair{ForeignName: "XXBTZEUR"}); pair != "BTCEUR" {
+		t.Errorf("Trade pair was incorrect, got: %s, want: %s.", pair, "BTCEUR")
+	}
+
+	for _, data := range []string{`{"event":"heartbeat"}`, `[42,{"a":[]},"book-10","XBT/EUR"]`} {
+		if message, err := p.Parse(0, []byte(data)); err != nil || len(message.Trades) != 0 {
+			t.Errorf("Message %s was parsed incorrectly, got: %v, %v.", data, message, err)
+		}
+	}
+}
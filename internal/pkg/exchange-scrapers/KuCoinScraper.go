@@ -1,6 +1,7 @@
 package scrapers
 
 import (
+	"encoding/json"
 	"errors"
 	"strconv"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/Kucoin/kucoin-go-sdk"
 	"github.com/diadata-org/diadata/pkg/dia"
+	utils "github.com/diadata-org/diadata/pkg/utils"
 )
 
 type KuExchangePairs []KuExchangePair
@@ -44,225 +46,217 @@ type KuExchangePair struct {
 	EnableTrading   bool   `json:"enableTrading"`
 }
 
+const (
+	// kucoinMaxPairs is the maximal number of pairs subscribed on one connection.
+	kucoinMaxPairs = 300
+	// kucoinMaxTopicPairs is the maximal number of pairs subscribed with one message.
+	kucoinMaxTopicPairs = 100
+	// kucoinPingInterval is below the ping interval of 18s announced by the exchange.
+	kucoinPingInterval = 15 * time.Second
+)
+
+var _KuCoinTokenURL = "https://api.kucoin.com/api/v1/bullet-public"
+
+// KuCoinScraper scrapes the trades of KuCoin. The exchange limits the pairs per connection,
+// so pairs are distributed over several WebsocketScrapers.
 type KuCoinScraper struct {
-	// signaling channels for session initialization and finishing
-	initDone     chan nothing
+	exchange dia.Exchange
+	// lock guards shards and closed
+	lock   sync.Mutex
+	shards []*WebsocketScraper
+	closed bool
+	// signaling channel for forwarding the trades of the shards
 	shutdown     chan nothing
-	shutdownDone chan nothing
-	// error handling; to read error or closed, first acquire read lock
-	// only cleanup method should hold write lock
-	errorLock sync.RWMutex
-	error     error
-	closed    bool
-	// used to keep track of trading pairs that we subscribed to
-	// use sync.Maps to concurrently handle multiple pairs
-	pairScrapers      map[string]*KuCoinPairScraper // dia.Pair -> KuCoinPairScraper
-	pairSubscriptions sync.Map                      // dia.Pair -> string (subscription ID)
-	pairLocks         sync.Map                      // dia.Pair -> sync.Mutex
-	exchangeName      string
-	chanTrades        chan *dia.Trade
-	apiService        *kucoin.ApiService
+	exchangeName string
+	chanTrades   chan *dia.Trade
+	apiService   *kucoin.ApiService
 }
 
 func NewKuCoinScraper(apiKey string, secretKey string, exchange dia.Exchange) *KuCoinScraper {
-	apiService := kucoin.NewApiService()
-
-	s := &KuCoinScraper{
-		initDone:     make(chan nothing),
+	return &KuCoinScraper{
+		exchange:     exchange,
 		shutdown:     make(chan nothing),
-		shutdownDone: make(chan nothing),
 		exchangeName: exchange.Name,
-		pairScrapers: make(map[string]*KuCoinPairScraper),
-		error:        nil,
 		chanTrades:   make(chan *dia.Trade),
-		apiService:   apiService,
+		apiService:   kucoin.NewApiService(),
 	}
+}
 
-	// establish connection in the background
-	go s.mainLoop()
-	return s
+// kucoinProtocol implements WebsocketProtocol for the match channel of KuCoin. Connections
+// are authorized by a token, which is requested before each connection.
+type kucoinProtocol struct{}
+
+type kucoinToken struct {
+	Code string `json:"code"`
+	Data struct {
+		Token           string `json:"token"`
+		InstanceServers []struct {
+			Endpoint string `json:"endpoint"`
+		} `json:"instanceServers"`
+	} `json:"data"`
 }
 
-// runs in a goroutine until s is closed
-func (s *KuCoinScraper) mainLoop() {
-	var channelsForClient1, channelsForClient2 []*kucoin.WebSocketSubscribeMessage
+type kucoinRequest struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	Topic          string `json:"topic,omitempty"`
+	PrivateChannel bool   `json:"privateChannel"`
+	Response       bool   `json:"response"`
+}
 
-	close(s.initDone)
+type kucoinMessage struct {
+	Type    string            `json:"type"`
+	Topic   string            `json:"topic"`
+	Subject string            `json:"subject"`
+	Data    KucoinMarketMatch `json:"data"`
+}
 
-	rsp, err := s.apiService.WebSocketPublicToken()
+func (p kucoinProtocol) URL() (string, error) {
+	data, err := utils.PostRequest(_KuCoinTokenURL, nil)
 	if err != nil {
-		// Handle error
-		log.Error("Error WebSocketPublicToken", err)
+		return "", err
 	}
-
-	tk := &kucoin.WebSocketTokenModel{}
-	if err := rsp.ReadData(tk); err != nil {
-		log.Error("Error Reading data", err)
+	var token kucoinToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", err
 	}
+	if len(token.Data.InstanceServers) == 0 {
+		return "", errors.New("KuCoin: no instance servers, code " + token.Code)
+	}
+	return token.Data.InstanceServers[0].Endpoint + "?token=" + token.Data.Token + "&connectId=" + strconv.FormatInt(time.Now().UnixNano(), 10), nil
+}
 
-	client1 := s.apiService.NewWebSocketClient(tk)
-	client2 := s.apiService.NewWebSocketClient(tk)
+func (p kucoinProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	return p.requests("subscribe", pairs), nil
+}
+
+func (p kucoinProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return p.requests("unsubscribe", []dia.Pair{pair}), nil
+}
+
+func (p kucoinProtocol) requests(requestType string, pairs []dia.Pair) []interface{} {
+	var requests []interface{}
+	for i := 0; i < len(pairs); i += kucoinMaxTopicPairs {
+		var names []string
+		for j := i; j < len(pairs) && j < i+kucoinMaxTopicPairs; j++ {
+			names = append(names, pairs[j].ForeignName)
+		}
+		requests = append(requests, &kucoinRequest{
+			ID:       strconv.FormatInt(time.Now().UnixNano(), 10),
+			Type:     requestType,
+			Topic:    "/market/match:" + strings.Join(names, ","),
+			Response: true,
+		})
+	}
+	return requests
+}
 
-	client1DownStream, _, err := client1.Connect()
+func (p kucoinProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	var message kucoinMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return WebsocketMessage{}, err
+	}
+	// welcome, ack and pong messages carry no trades
+	if message.Type == "error" {
+		log.Errorf("KuCoin: %s", data)
+	}
+	if message.Type != "message" || message.Subject != "trade.l3match" {
+		return WebsocketMessage{}, nil
+	}
+	t := message.Data
+	f64Price, err := strconv.ParseFloat(t.Price, 64)
 	if err != nil {
-		// Handle error
-		log.Error("Error Reading data", err)
+		return WebsocketMessage{}, err
 	}
-	client2DownStream, _, err := client2.Connect()
+	f64Volume, err := strconv.ParseFloat(t.Size, 64)
 	if err != nil {
-		// Handle error
-		log.Error("Error Reading data", err)
+		return WebsocketMessage{}, err
 	}
-
-	count := 0
-	for pair := range s.pairScrapers {
-		ch := kucoin.NewSubscribeMessage("/market/match:"+pair, false)
-		if count >= 299 {
-			channelsForClient2 = append(channelsForClient2, ch)
-			count++
-			continue
-		} else {
-			channelsForClient1 = append(channelsForClient1, ch)
-			count++
-		}
+	timeOrder, err := strconv.ParseInt(t.Time, 10, 64)
+	if err != nil {
+		return WebsocketMessage{}, err
 	}
-
-	log.Info("number of pairs: ", count)
-
-	if err := client1.Subscribe(channelsForClient1...); err != nil {
-		// Handle error
-		log.Fatal("Error while subscribing client1 ", err)
+	if t.Side == "sell" {
+		f64Volume = -f64Volume
 	}
-	if err := client2.Subscribe(channelsForClient2...); err != nil {
-		// Handle error
-		log.Fatal("Error while subscribing client2 ", err)
+	trade := &dia.Trade{
+		Symbol:         strings.Split(t.Symbol, "-")[0],
+		Pair:           t.Symbol,
+		Price:          f64Price,
+		Time:           time.Unix(0, timeOrder),
+		Volume:         f64Volume,
+		ForeignTradeID: t.TradeID,
 	}
+	return WebsocketMessage{Trades: []*dia.Trade{trade}}, nil
+}
 
-	go func() {
-		var msg *kucoin.WebSocketDownstreamMessage
-		for {
-			select {
-
-			case msg = <-client1DownStream:
-				if msg == nil {
-					continue
-				}
-				t := &KucoinMarketMatch{}
-				if err := msg.ReadData(t); err != nil {
-					log.Printf("Failure to read: %s", err.Error())
-					return
-				}
-				asset := strings.Split(t.Symbol, "-")
-				f64Price, _ := strconv.ParseFloat(t.Price, 64)
-				f64Volume, _ := strconv.ParseFloat(t.Size, 64)
-				timeOrder, _ := strconv.ParseInt(t.Time, 10, 64)
-
-				if t.Side == "sell" {
-					f64Volume = -f64Volume
-				}
-				trade := &dia.Trade{
-					Symbol: asset[0],
-					Pair:   t.Symbol,
-					Price:  f64Price,
-					Time:   time.Unix(0, timeOrder),
-					Volume: f64Volume,
-					Source: s.exchangeName,
-				}
-				s.chanTrades <- trade
-				log.Println("Got trade stream1: ", trade)
-
-			case msg = <-client2DownStream:
-				if msg == nil {
-					continue
-				}
-				t := &KucoinMarketMatch{}
-				if err := msg.ReadData(t); err != nil {
-					log.Printf("Failure to read: %s", err.Error())
-					return
-				}
-				asset := strings.Split(t.Symbol, "-")
-				f64Price, _ := strconv.ParseFloat(t.Price, 64)
-				f64Volume, _ := strconv.ParseFloat(t.Size, 64)
-				timeOrder, _ := strconv.ParseInt(t.Time, 10, 64)
-
-				if t.Side == "sell" {
-					f64Volume = -f64Volume
-				}
-				trade := &dia.Trade{
-					Symbol: asset[0],
-					Pair:   t.Symbol,
-					Price:  f64Price,
-					Time:   time.Unix(0, timeOrder),
-					Volume: f64Volume,
-					Source: s.exchangeName,
-				}
-				s.chanTrades <- trade
-				log.Println("Got trade stream2: ", trade)
-
-			case <-s.shutdown: // user requested shutdown
-				log.Println("KuCoin shutting down")
-				s.cleanup(nil)
-				return
-			}
-		}
-
-	}()
-
+func (p kucoinProtocol) Heartbeat() interface{} {
+	return &kucoinRequest{
+		ID:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Type: "ping",
+	}
 }
 
 func (s *KuCoinScraper) NormalizePair(pair dia.Pair) (dia.Pair, error) {
 	return dia.Pair{}, nil
 }
 
-// closes all connected PairScrapers
-// must only be called from mainLoop
-func (s *KuCoinScraper) cleanup(err error) {
-	s.errorLock.Lock()
-	defer s.errorLock.Unlock()
-
-	if err != nil {
-		s.error = err
-	}
-	s.closed = true
-
-	close(s.shutdownDone)
-}
-
-// Close closes any existing API connections, as well as channels of
-// PairScrapers from calls to ScrapePair
+// Close closes the connections of all shards and stops forwarding their trades
 func (s *KuCoinScraper) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	if s.closed {
 		return errors.New("KuCoinScraper: Already closed")
 	}
+	s.closed = true
 	close(s.shutdown)
-	<-s.shutdownDone
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
+	var err error
+	for _, shard := range s.shards {
+		if shardErr := shard.Close(); shardErr != nil {
+			err = shardErr
+		}
+	}
+	return err
 }
 
 // ScrapePair returns a PairScraper that can be used to get trades for a single pair from
-// this APIScraper
+// this APIScraper. The pair is scraped on the first shard with less than kucoinMaxPairs pairs.
 func (s *KuCoinScraper) ScrapePair(pair dia.Pair) (PairScraper, error) {
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-
-	if s.error != nil {
-		return nil, s.error
-	}
-
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	if s.closed {
-		return nil, errors.New("KucoinScraper: Call ScrapePair on closed scraper")
+		return nil, errors.New("KuCoinScraper: Call ScrapePair on closed scraper")
 	}
-
-	ps := &KuCoinPairScraper{
-		parent: s,
-		pair:   pair,
+	for _, shard := range s.shards {
+		if shard.scrapes(pair.ForeignName) {
+			return shard.ScrapePair(pair)
+		}
 	}
+	for _, shard := range s.shards {
+		if shard.pairCount() < kucoinMaxPairs {
+			return shard.ScrapePair(pair)
+		}
+	}
+	shard := NewWebsocketScraper(s.exchange, kucoinProtocol{}, WebsocketOptions{PingInterval: kucoinPingInterval})
+	s.shards = append(s.shards, shard)
+	go s.forward(shard)
+	return shard.ScrapePair(pair)
+}
 
-	s.pairScrapers[pair.ForeignName] = ps
-
-	return ps, nil
+// forward sends the trades of @shard to the trades channel until s is closed.
+func (s *KuCoinScraper) forward(shard *WebsocketScraper) {
+	for {
+		select {
+		case t := <-shard.Channel():
+			select {
+			case s.chanTrades <- t:
+			case <-s.shutdown:
+				return
+			}
+		case <-s.shutdown:
+			return
+		}
+	}
 }
 
 func (s *KuCoinScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
@@ -286,48 +280,7 @@ func (s *KuCoinScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
 	return
 }
 
-// KuCoinPairScraper implements PairScraper for kuCoin
-type KuCoinPairScraper struct {
-	parent *KuCoinScraper
-	pair   dia.Pair
-	closed bool
-}
-
-// Close stops listening for trades of the pair associated with s
-func (ps *KuCoinPairScraper) Close() error {
-	var err error
-	s := ps.parent
-	// if parent already errored, return early
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	if s.error != nil {
-		return s.error
-	}
-	if ps.closed {
-		return errors.New("KuCoinPairScraper: Already closed")
-	}
-
-	// TODO stop collection for the pair
-
-	ps.closed = true
-	return err
-}
-
 // Channel returns a channel that can be used to receive trades
 func (ps *KuCoinScraper) Channel() chan *dia.Trade {
 	return ps.chanTrades
 }
-
-// Error returns an error when the channel Channel() is closed
-// and nil otherwise
-func (ps *KuCoinPairScraper) Error() error {
-	s := ps.parent
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
-}
-
-// Pair returns the pair this scraper is subscribed to
-func (ps *KuCoinPairScraper) Pair() dia.Pair {
-	return ps.pair
-}
@@ -8,13 +8,11 @@ import (
 	"io/ioutil"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/diadata-org/diadata/pkg/dia"
 	"github.com/diadata-org/diadata/pkg/dia/helpers"
 	utils "github.com/diadata-org/diadata/pkg/utils"
-	ws "github.com/gorilla/websocket"
 )
 
 var _OKExSocketURL = "wss://ws.okex.com:8443/ws/v5/public"
@@ -39,59 +37,21 @@ type OKEXArgs struct {
 	InstID  string `json:"instId"`
 }
 
+// OKExScraper scrapes the trades of OKEx with a WebsocketScraper
 type OKExScraper struct {
-	wsClient *ws.Conn
-	// signaling channels for session initialization and finishing
-	run          bool
-	shutdown     chan nothing
-	shutdownDone chan nothing
-	// error handling; to read error or closed, first acquire read lock
-	// only cleanup method should hold write lock
-	errorLock sync.RWMutex
-	error     error
-	closed    bool
-	// used to keep track of trading pairs that we subscribed to
-	pairScrapers map[string]*OKExPairScraper
+	*WebsocketScraper
 	exchangeName string
-	chanTrades   chan *dia.Trade
 }
 
 // NewOKExScraper returns a new OKExScraper for the given pair
 func NewOKExScraper(exchange dia.Exchange) *OKExScraper {
-
 	s := &OKExScraper{
-		shutdown:     make(chan nothing),
-		shutdownDone: make(chan nothing),
-		pairScrapers: make(map[string]*OKExPairScraper),
 		exchangeName: exchange.Name,
-		error:        nil,
-		chanTrades:   make(chan *dia.Trade),
 	}
-
-	var wsDialer ws.Dialer
-	SwConn, _, err := wsDialer.Dial(_OKExSocketURL, nil)
-	if err != nil {
-		log.Error("dial:", err)
-	}
-
-	s.wsClient = SwConn
-	go s.mainLoop()
+	s.WebsocketScraper = NewWebsocketScraper(exchange, okexProtocol{}, WebsocketOptions{})
 	return s
 }
 
-// Useful to reconnect to ws when the connection is down
-func (s *OKExScraper) reconnectToWS() {
-
-	var wsDialer ws.Dialer
-	SwConn, _, err := wsDialer.Dial(_OKExSocketURL, nil)
-
-	if err != nil {
-		log.Error("dial:", err)
-	}
-
-	s.wsClient = SwConn
-}
-
 type OKEXMarket struct {
 	Alias     string `json:"alias"`
 	BaseCcy   string `json:"baseCcy"`
@@ -122,39 +82,6 @@ type AllOKEXMarketResponse struct {
 	Msg  string       `json:"msg"`
 }
 
-// Subscribe again to all channels
-func (s *OKExScraper) subscribeToALL() {
-
-	var (
-		resp     AllOKEXMarketResponse
-		allPairs []OKEXArgs
-	)
-
-	b, err := utils.GetRequest("https://aws.okex.com/api/v5/public/instruments?instType=SPOT")
-	if err != nil {
-		log.Errorln("Error getting OKex market", err)
-	}
-
-	err = json.Unmarshal(b, &resp)
-	if err != nil {
-		log.Errorln("Error Unmarshalling OKex market json", err)
-	}
-
-	for _, v := range resp.Data {
-		allPairs = append(allPairs, OKEXArgs{Channel: "trades", InstID: v.InstID})
-	}
-
-	a := &Subscribe{
-		OP:   "subscribe",
-		Args: allPairs,
-	}
-
-	if err := s.wsClient.WriteJSON(a); err != nil {
-		log.Errorln(err.Error())
-	}
-
-}
-
 type OKEXWSResponse struct {
 	Arg struct {
 		Channel string `json:"channel"`
@@ -170,146 +97,75 @@ type OKEXWSResponse struct {
 	} `json:"data"`
 }
 
-// runs in a goroutine until s is closed
-func (s *OKExScraper) mainLoop() {
-
-	s.run = true
-	for s.run {
-		var message OKEXWSResponse
-		messageType, messageTemp, err := s.wsClient.ReadMessage()
-		if err != nil {
-			log.Warning("reconnect the scraping to ws, ", err, ":", message)
-			s.reconnectToWS()
-			s.subscribeToALL()
-		} else {
-			switch messageType {
-			case ws.TextMessage:
-				// no need uncompressed
-				err := json.Unmarshal(messageTemp, &message)
-				if err != nil {
-					log.Errorln("Error parsing response")
-				}
-				ps, ok := s.pairScrapers[message.Arg.InstID]
-
-				if ok && len(message.Data) > 0 {
-
-					f64PriceString := message.Data[0].Px
-					f64Price, err := strconv.ParseFloat(f64PriceString, 64)
-
-					if err == nil {
-
-						f64VolumeString := message.Data[0].Sz
-						f64Volume, err := strconv.ParseFloat(f64VolumeString, 64)
-
-						if err == nil {
+// okexProtocol implements WebsocketProtocol for the trades channel of OKEx
+type okexProtocol struct{}
 
-							ts, _ := strconv.ParseInt(message.Data[0].Ts, 10, 64)
-							timeStamp := time.Unix(int64(ts)/1e3, 0)
-							if message.Data[0].Side == "sell" {
-								f64Volume = -f64Volume
-							}
-
-							t := &dia.Trade{
-								Symbol:         ps.pair.Symbol,
-								Pair:           message.Arg.InstID,
-								Price:          f64Price,
-								Volume:         f64Volume,
-								Time:           timeStamp,
-								ForeignTradeID: message.Data[0].TradeID,
-								Source:         s.exchangeName,
-							}
-							ps.parent.chanTrades <- t
-							log.Infoln("Got trade", t)
-
-						} else {
-							log.Errorf("parsing volume %v", f64VolumeString)
-						}
-
-					} else {
-						log.Errorf("parsing price %v", f64PriceString)
-					}
-				}
-
-			}
-		}
-	}
-	s.cleanup(errors.New("main loop terminated by Close()"))
+func (p okexProtocol) URL() (string, error) {
+	return _OKExSocketURL, nil
 }
 
-func GzipDecode(in []byte) ([]byte, error) {
-	reader := flate.NewReader(bytes.NewReader(in))
-	defer reader.Close()
-
-	return ioutil.ReadAll(reader)
+func (p okexProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	return []interface{}{p.message("subscribe", pairs)}, nil
 }
 
-func (s *OKExScraper) cleanup(err error) {
-	s.errorLock.Lock()
-	defer s.errorLock.Unlock()
-
-	if err != nil {
-		s.error = err
-	}
-	s.closed = true
-
-	close(s.shutdownDone)
+func (p okexProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return []interface{}{p.message("unsubscribe", []dia.Pair{pair})}, nil
 }
 
-// Close closes any existing API connections, as well as channels of
-// PairScrapers from calls to ScrapePair
-func (s *OKExScraper) Close() error {
-
-	if s.closed {
-		return errors.New("OKExScraper: Already closed")
-	}
-
-	close(s.shutdown)
-	// Set false first to prevent reconnect
-	s.run = false
-	err := s.wsClient.Close()
-	if err != nil {
-		return err
+func (p okexProtocol) message(op string, pairs []dia.Pair) *Subscribe {
+	a := &Subscribe{OP: op}
+	for _, pair := range pairs {
+		a.Args = append(a.Args, OKEXArgs{Channel: "trades", InstID: pair.ForeignName})
 	}
-	<-s.shutdownDone
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
+	return a
 }
 
-// ScrapePair returns a PairScraper that can be used to get trades for a single pair from
-// this APIScraper
-func (s *OKExScraper) ScrapePair(pair dia.Pair) (PairScraper, error) {
-
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-
-	if s.error != nil {
-		return nil, s.error
+func (p okexProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	// answer of the heartbeat
+	if string(data) == "pong" {
+		return WebsocketMessage{}, nil
 	}
-
-	if s.closed {
-		return nil, errors.New("OKExScraper: Call ScrapePair on closed scraper")
+	var message OKEXWSResponse
+	if err := json.Unmarshal(data, &message); err != nil {
+		return WebsocketMessage{}, err
 	}
 
-	ps := &OKExPairScraper{
-		parent: s,
-		pair:   pair,
+	var trades []*dia.Trade
+	for _, d := range message.Data {
+		f64Price, err := strconv.ParseFloat(d.Px, 64)
+		if err != nil {
+			return WebsocketMessage{}, err
+		}
+		f64Volume, err := strconv.ParseFloat(d.Sz, 64)
+		if err != nil {
+			return WebsocketMessage{}, err
+		}
+		ts, _ := strconv.ParseInt(d.Ts, 10, 64)
+		if d.Side == "sell" {
+			f64Volume = -f64Volume
+		}
+		trades = append(trades, &dia.Trade{
+			Pair:           message.Arg.InstID,
+			Price:          f64Price,
+			Volume:         f64Volume,
+			Time:           time.Unix(ts/1e3, 0),
+			ForeignTradeID: d.TradeID,
+		})
 	}
+	return WebsocketMessage{Trades: trades}, nil
+}
 
-	s.pairScrapers[pair.ForeignName] = ps
+func (p okexProtocol) Heartbeat() interface{} {
+	return []byte("ping")
+}
 
-	//a := &Subscribe{
-	//	OP:      "addChannel",
-	//	Args: "ok_sub_spot_" + strings.ToLower(pair.ForeignName) + "_deals",
-	//}
-	//
-	//subByteString := `{"channel":` + `"` + a.Channel + `"` + `,"event":` + `"` + a.Event + `"}`
-	//if err := s.wsClient.WriteMessage(ws.TextMessage, []byte(subByteString)); err != nil {
-	//	fmt.Println(err.Error())
-	//}
+func GzipDecode(in []byte) ([]byte, error) {
+	reader := flate.NewReader(bytes.NewReader(in))
+	defer reader.Close()
 
-	return ps, nil
+	return ioutil.ReadAll(reader)
 }
+
 func (s *OKExScraper) normalizeSymbol(foreignName string, baseCurrency string) (symbol string, err error) {
 	symbol = strings.ToUpper(baseCurrency)
 	if helpers.NameForSymbol(symbol) == symbol {
@@ -383,34 +239,3 @@ func (s *OKExScraper) FetchAvailablePairs() (pairs []dia.Pair, err error) {
 	}
 	return
 }
-
-// OKExPairScraper implements PairScraper for OKEx exchange
-type OKExPairScraper struct {
-	parent *OKExScraper
-	pair   dia.Pair
-	closed bool
-}
-
-// Close stops listening for trades of the pair associated with s
-func (ps *OKExPairScraper) Close() error {
-	return nil
-}
-
-// Channel returns a channel that can be used to receive trades
-func (s *OKExScraper) Channel() chan *dia.Trade {
-	return s.chanTrades
-}
-
-// Error returns an error when the channel Channel() is closed
-// and nil otherwise
-func (ps *OKExPairScraper) Error() error {
-	s := ps.parent
-	s.errorLock.RLock()
-	defer s.errorLock.RUnlock()
-	return s.error
-}
-
-// Pair returns the pair this scraper is subscribed to
-func (ps *OKExPairScraper) Pair() dia.Pair {
-	return ps.pair
-}
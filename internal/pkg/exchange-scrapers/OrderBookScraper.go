@@ -0,0 +1,272 @@
+package scrapers
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+const (
+	// maxBufferedDiffs bounds the diffs of a pair buffered while waiting for its snapshot.
+	maxBufferedDiffs = 1000
+	// snapshotRetryDelay is the minimal time between snapshot requests of a pair.
+	snapshotRetryDelay = 5 * time.Second
+)
+
+// OrderBookScraper maintains the L2 order books of pairs of an exchange from a snapshot and
+// the diffs published by the exchange.
+type OrderBookScraper interface {
+	io.Closer
+	// ScrapeOrderBook starts maintaining the order book of @pair.
+	ScrapeOrderBook(pair dia.Pair) error
+	// OrderBook returns a copy of the order book of the pair with @foreignName. An error is
+	// returned while the book is not in sync with the exchange.
+	OrderBook(foreignName string) (dia.OrderBook, error)
+}
+
+// OrderBookDiff is a change of the order book of a pair received from an exchange.
+type OrderBookDiff struct {
+	// Pair is the foreign name of the pair.
+	Pair string
+	// Snapshot diffs replace all levels of the book.
+	Snapshot bool
+	// Levels with a volume of 0 are removed from the book.
+	Bids []dia.OrderBookLevel
+	Asks []dia.OrderBookLevel
+	// FirstSequence and Sequence are the first and last update ids of the exchange covered by
+	// the diff. Both are 0 if the exchange does not number its updates.
+	FirstSequence int64
+	Sequence      int64
+	Time          time.Time
+}
+
+// OrderBookSnapshotter is implemented by the WebsocketProtocols of exchanges which publish the
+// snapshots of order books separately from their diffs.
+type OrderBookSnapshotter interface {
+	// Snapshot fetches the current order book of the pair with @foreignName.
+	Snapshot(foreignName string) (OrderBookDiff, error)
+}
+
+// NewOrderBookScraper returns the OrderBookScraper of @exchange, nil if the order books of the
+// exchange are not scraped.
+func NewOrderBookScraper(exchange string) OrderBookScraper {
+	switch exchange {
+	case dia.BinanceExchange:
+		return NewWebsocketOrderBookScraper(Exchanges[dia.BinanceExchange], binanceOrderBookProtocol{}, WebsocketOptions{})
+	case dia.CoinBaseExchange:
+		return NewWebsocketOrderBookScraper(Exchanges[dia.CoinBaseExchange], coinBaseOrderBookProtocol{}, WebsocketOptions{})
+	default:
+		return nil
+	}
+}
+
+// WebsocketOrderBookScraper implements OrderBookScraper with a WebsocketScraper whose protocol
+// subscribes to order book diffs instead of trades.
+type WebsocketOrderBookScraper struct {
+	scraper *WebsocketScraper
+}
+
+// NewWebsocketOrderBookScraper returns a WebsocketOrderBookScraper for @exchange speaking
+// @protocol. The instance connects in the background as soon as it is created.
+func NewWebsocketOrderBookScraper(exchange dia.Exchange, protocol WebsocketProtocol, options WebsocketOptions) *WebsocketOrderBookScraper {
+	books := &orderBooks{
+		exchangeName: exchange.Name,
+		books:        make(map[string]*orderBookState),
+	}
+	return &WebsocketOrderBookScraper{scraper: newWebsocketScraper(exchange, protocol, options, books)}
+}
+
+// ScrapeOrderBook starts maintaining the order book of @pair. Its diffs are subscribed again
+// after each reconnect.
+func (s *WebsocketOrderBookScraper) ScrapeOrderBook(pair dia.Pair) error {
+	s.scraper.books.add(pair)
+	_, err := s.scraper.ScrapePair(pair)
+	return err
+}
+
+// OrderBook returns a copy of the order book of the pair with @foreignName.
+func (s *WebsocketOrderBookScraper) OrderBook(foreignName string) (dia.OrderBook, error) {
+	return s.scraper.books.get(foreignName)
+}
+
+// Close closes the connection to the exchange.
+func (s *WebsocketOrderBookScraper) Close() error {
+	return s.scraper.Close()
+}
+
+// orderBookState is an order book and whether it is in sync with the exchange.
+type orderBookState struct {
+	book   dia.OrderBook
+	synced bool
+	// buffered are the diffs received while waiting for a snapshot
+	buffered      []OrderBookDiff
+	fetching      bool
+	lastFetchTime time.Time
+}
+
+// orderBooks keeps the order books of the pairs of an exchange.
+type orderBooks struct {
+	exchangeName string
+	lock         sync.Mutex
+	books        map[string]*orderBookState
+}
+
+func (obs *orderBooks) add(pair dia.Pair) {
+	obs.lock.Lock()
+	defer obs.lock.Unlock()
+	if _, ok := obs.books[pair.ForeignName]; ok {
+		return
+	}
+	obs.books[pair.ForeignName] = &orderBookState{
+		book: dia.OrderBook{
+			Symbol:   pair.Symbol,
+			Pair:     pair.ForeignName,
+			Exchange: obs.exchangeName,
+		},
+	}
+}
+
+func (obs *orderBooks) get(foreignName string) (dia.OrderBook, error) {
+	obs.lock.Lock()
+	defer obs.lock.Unlock()
+	state, ok := obs.books[foreignName]
+	if !ok {
+		return dia.OrderBook{}, errors.New(obs.exchangeName + ": order book of " + foreignName + " is not scraped")
+	}
+	if !state.synced {
+		return dia.OrderBook{}, errors.New(obs.exchangeName + ": order book of " + foreignName + " is not synced")
+	}
+	return state.book.Copy(), nil
+}
+
+// reset marks all books as out of sync, as diffs are missed while reconnecting.
+func (obs *orderBooks) reset() {
+	obs.lock.Lock()
+	defer obs.lock.Unlock()
+	for _, state := range obs.books {
+		state.unsync()
+	}
+}
+
+// apply applies @diff to the book of its pair. It returns true if the book needs a snapshot.
+func (obs *orderBooks) apply(diff OrderBookDiff) bool {
+	obs.lock.Lock()
+	defer obs.lock.Unlock()
+	state, ok := obs.books[diff.Pair]
+	if !ok {
+		return false
+	}
+
+	if diff.Snapshot {
+		state.book.Bids, state.book.Asks = nil, nil
+		state.setLevels(diff)
+		state.synced = true
+		buffered := state.buffered
+		state.buffered = nil
+		for _, d := range buffered {
+			if !state.applyDiff(d) {
+				log.Warnf("%s: order book of %s missed updates after the snapshot", obs.exchangeName, diff.Pair)
+				return true
+			}
+		}
+		return false
+	}
+
+	if !state.synced {
+		if len(state.buffered) == maxBufferedDiffs {
+			state.buffered = state.buffered[1:]
+		}
+		state.buffered = append(state.buffered, diff)
+		return true
+	}
+	if !state.applyDiff(diff) {
+		log.Warnf("%s: order book of %s missed updates before sequence %d", obs.exchangeName, diff.Pair, diff.FirstSequence)
+		return true
+	}
+	return false
+}
+
+// startFetch returns true if a snapshot of the book of @foreignName should be fetched now, in
+// which case finishFetch must be called once it is fetched.
+func (obs *orderBooks) startFetch(foreignName string) bool {
+	obs.lock.Lock()
+	defer obs.lock.Unlock()
+	state, ok := obs.books[foreignName]
+	if !ok || state.fetching || time.Since(state.lastFetchTime) < snapshotRetryDelay {
+		return false
+	}
+	state.fetching = true
+	state.lastFetchTime = time.Now()
+	return true
+}
+
+func (obs *orderBooks) finishFetch(foreignName string) {
+	obs.lock.Lock()
+	defer obs.lock.Unlock()
+	if state, ok := obs.books[foreignName]; ok {
+		state.fetching = false
+	}
+}
+
+// applyDiff applies @diff to the synced book. Outdated diffs are ignored. It returns false and
+// marks the book out of sync if updates are missing before @diff.
+func (state *orderBookState) applyDiff(diff OrderBookDiff) bool {
+	if diff.Sequence != 0 && state.book.Sequence != 0 {
+		if diff.Sequence <= state.book.Sequence {
+			return true
+		}
+		if diff.FirstSequence > state.book.Sequence+1 {
+			state.unsync()
+			state.buffered = append(state.buffered, diff)
+			return false
+		}
+	}
+	state.setLevels(diff)
+	return true
+}
+
+func (state *orderBookState) setLevels(diff OrderBookDiff) {
+	for _, level := range diff.Bids {
+		state.book.SetBid(level.Price, level.Volume)
+	}
+	for _, level := range diff.Asks {
+		state.book.SetAsk(level.Price, level.Volume)
+	}
+	if diff.Sequence != 0 {
+		state.book.Sequence = diff.Sequence
+	}
+	if !diff.Time.IsZero() {
+		state.book.Time = diff.Time
+	}
+}
+
+func (state *orderBookState) unsync() {
+	state.synced = false
+	state.buffered = nil
+	state.book.Bids, state.book.Asks = nil, nil
+	state.book.Sequence = 0
+}
+
+// parseOrderBookLevels parses levels sent by exchanges as pairs of price and volume strings.
+func parseOrderBookLevels(levels [][]string) ([]dia.OrderBookLevel, error) {
+	parsed := make([]dia.OrderBookLevel, 0, len(levels))
+	for _, level := range levels {
+		if len(level) < 2 {
+			return nil, errors.New("invalid order book level")
+		}
+		price, err := strconv.ParseFloat(level[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		volume, err := strconv.ParseFloat(level[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, dia.OrderBookLevel{Price: price, Volume: volume})
+	}
+	return parsed, nil
+}
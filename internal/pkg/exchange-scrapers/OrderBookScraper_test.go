@@ -0,0 +1,55 @@
+package scrapers
+
+import (
+	"testing"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+func TestOrderBooks(t *testing.T) {
+	obs := &orderBooks{exchangeName: "test", books: make(map[string]*orderBookState)}
+	obs.add(dia.Pair{Symbol: "BTC", ForeignName: "BTCUSDT"})
+
+	level := func(price float64, volume float64) []dia.OrderBookLevel {
+		return []dia.OrderBookLevel{{Price: price, Volume: volume}}
+	}
+	tables := []struct {
+		diff          OrderBookDiff
+		needsSnapshot bool
+		synced        bool
+		bestBid       float64
+	}{
+		// diffs are buffered until the snapshot
+		{OrderBookDiff{Pair: "BTCUSDT", Bids: level(100, 1), FirstSequence: 9, Sequence: 10}, true, false, 0},
+		{OrderBookDiff{Pair: "BTCUSDT", Bids: level(101, 1), FirstSequence: 11, Sequence: 12}, true, false, 0},
+		// the first buffered diff is older than the snapshot
+		{OrderBookDiff{Pair: "BTCUSDT", Snapshot: true, Bids: level(99, 1), Sequence: 10}, false, true, 101},
+		{OrderBookDiff{Pair: "BTCUSDT", Bids: level(101, 0), FirstSequence: 13, Sequence: 13}, false, true, 99},
+		// outdated diffs are ignored
+		{OrderBookDiff{Pair: "BTCUSDT", Bids: level(105, 1), FirstSequence: 12, Sequence: 13}, false, true, 99},
+		// updates 14 and 15 are missing
+		{OrderBookDiff{Pair: "BTCUSDT", Bids: level(102, 1), FirstSequence: 16, Sequence: 16}, true, false, 0},
+		{OrderBookDiff{Pair: "BTCUSDT", Snapshot: true, Bids: level(103, 1), Sequence: 15}, false, true, 103},
+		// diffs of pairs without book are dropped
+		{OrderBookDiff{Pair: "ETHUSDT", Bids: level(1, 1), FirstSequence: 1, Sequence: 1}, false, true, 103},
+	}
+	for i, table := range tables {
+		needsSnapshot := obs.apply(table.diff)
+		if needsSnapshot != table.needsSnapshot {
+			t.Errorf("Snapshot need of diff %d was incorrect, got: %v, want: %v.", i, needsSnapshot, table.needsSnapshot)
+		}
+		book, err := obs.get("BTCUSDT")
+		if (err == nil) != table.synced {
+			t.Errorf("Sync of diff %d was incorrect, got: %v, want: %v.", i, err == nil, table.synced)
+			continue
+		}
+		if table.synced && book.Bids[0].Price != table.bestBid {
+			t.Errorf("Best bid of diff %d was incorrect, got: %v, want: %v.", i, book.Bids[0].Price, table.bestBid)
+		}
+	}
+
+	obs.reset()
+	if _, err := obs.get("BTCUSDT"); err == nil {
+		t.Errorf("Order book was synced after reset.")
+	}
+}
@@ -0,0 +1,495 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+// Health states of supervised pairs
+const (
+	// PairHealthy pairs had trades within the pair timeout.
+	PairHealthy = "healthy"
+	// PairRestarted pairs have been restarted and had no trades since.
+	PairRestarted = "restarted"
+	// PairFailed pairs had no trades after all restarts allowed by the policy. They are kept
+	// subscribed and become healthy again with their next trade.
+	PairFailed = "failed"
+)
+
+const (
+	superviseInterval   = 30 * time.Second
+	scraperCloseTimeout = 10 * time.Second
+)
+
+// SupervisorPolicy configures when a Supervisor restarts pairs and scrapers. Zero timeouts
+// are replaced by the watchdog delay of the exchange.
+type SupervisorPolicy struct {
+	// PairTimeout is the time without trades or errors after which a pair is restarted.
+	PairTimeout time.Duration
+	// ScraperTimeout is the time without trades of any pair after which the APIScraper is
+	// restarted.
+	ScraperTimeout time.Duration
+	// MaxPairRestarts is the number of restarts of a pair without trades before it is failed.
+	MaxPairRestarts int
+	// MaxScraperRestarts is the number of restarts of the APIScraper without trades before
+	// the Supervisor gives up.
+	MaxScraperRestarts int
+}
+
+// PairHealth is the health of a supervised pair.
+type PairHealth struct {
+	Pair      dia.Pair  `json:"pair"`
+	Status    string    `json:"status"`
+	LastTrade time.Time `json:"lastTrade"`
+	// Restarts is the number of restarts since the last trade of the pair.
+	Restarts int    `json:"restarts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// LastTradeTimeStore stores the time of the last trade of a symbol on an exchange.
+type LastTradeTimeStore interface {
+	SetLastTradeTimeForExchange(symbol string, exchange string, t time.Time) error
+}
+
+// pairStopper is implemented by PairScrapers whose Close stops scraping their pair, such as
+// the PairScrapers of WebsocketScrapers. The pairs of other PairScrapers are scraped until
+// their APIScraper is closed.
+type pairStopper interface {
+	stopsPair()
+}
+
+// stopsPair returns true if closing @ps stops scraping its pair.
+func stopsPair(ps PairScraper) bool {
+	_, ok := ps.(pairStopper)
+	return ok
+}
+
+type supervisedPair struct {
+	scraper PairScraper
+	health  PairHealth
+	// tradePair is the pair of the trades of the pair, its foreign name as normalized by
+	// the scraper.
+	tradePair string
+	// active is the time of the last trade or restart of the pair
+	active time.Time
+	saved  time.Time
+}
+
+// Supervisor tracks the liveness of the pairs of an exchange. Pairs without trades or with
+// errors are restarted, and the whole APIScraper if no pair has trades anymore.
+type Supervisor struct {
+	exchange   string
+	policy     SupervisorPolicy
+	newScraper func() APIScraper
+	datastore  LastTradeTimeStore
+
+	// restartLock serializes replacements of the scraper, which happen without holding lock
+	restartLock     sync.Mutex
+	lock            sync.RWMutex
+	scraper         APIScraper
+	pairs           map[string]*supervisedPair
+	started         time.Time
+	lastTrade       time.Time
+	scraperRestarts int
+	// tradePairs are the supervised pairs by the pair of their trades.
+	tradePairs map[string]*supervisedPair
+}
+
+// NewSupervisor returns a Supervisor of the APIScrapers of @exchange created by @newScraper.
+// Last trade times are stored in @datastore if it is not nil.
+func NewSupervisor(exchange string, policy SupervisorPolicy, newScraper func() APIScraper, datastore LastTradeTimeStore) *Supervisor {
+	watchdogDelay := time.Duration(Exchanges[exchange].WatchdogDelay) * time.Second
+	if policy.PairTimeout == 0 {
+		policy.PairTimeout = watchdogDelay
+	}
+	if policy.ScraperTimeout == 0 {
+		policy.ScraperTimeout = watchdogDelay
+	}
+	return &Supervisor{
+		exchange:   exchange,
+		policy:     policy,
+		newScraper: newScraper,
+		datastore:  datastore,
+		scraper:    newScraper(),
+		pairs:      make(map[string]*supervisedPair),
+		tradePairs: make(map[string]*supervisedPair),
+		started:    time.Now(),
+	}
+}
+
+// AddPair starts scraping @pair.
+func (s *Supervisor) AddPair(pair dia.Pair) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.addPair(pair)
+}
+
+func (s *Supervisor) addPair(pair dia.Pair) error {
+	if _, ok := s.pairs[pair.ForeignName]; ok {
+		return errors.New("pair already supervised: " + pair.ForeignName)
+	}
+	ps, err := s.scraper.ScrapePair(pair)
+	if err != nil {
+		return err
+	}
+	p := &supervisedPair{
+		scraper:   ps,
+		health:    PairHealth{Pair: pair, Status: PairHealthy},
+		tradePair: pair.ForeignName,
+		active:    time.Now(),
+	}
+	// scrapers may store trades with their normalized pair, e.g. Kraken XXBTZEUR as BTCEUR
+	if normalized, err := s.scraper.NormalizePair(pair); err == nil && normalized.ForeignName != "" {
+		p.tradePair = normalized.ForeignName
+	}
+	s.pairs[pair.ForeignName] = p
+	s.tradePairs[p.tradePair] = p
+	return nil
+}
+
+// RemovePair stops scraping the pair with the foreign name @foreignName. The scraper is
+// restarted without the pair if closing its PairScraper does not stop the pair.
+func (s *Supervisor) RemovePair(foreignName string) error {
+	s.lock.Lock()
+	restart, err := s.removePair(foreignName)
+	s.lock.Unlock()
+	if restart {
+		log.Warnf("%s: restarting scraper without pair %s", s.exchange, foreignName)
+		s.replaceScraper()
+	}
+	return err
+}
+
+// removePair removes the pair with the foreign name @foreignName and closes its PairScraper.
+// It returns true if the pair is scraped until the scraper is replaced.
+func (s *Supervisor) removePair(foreignName string) (restart bool, err error) {
+	p, ok := s.pairs[foreignName]
+	if !ok {
+		return false, errors.New("pair not supervised: " + foreignName)
+	}
+	delete(s.pairs, foreignName)
+	if s.tradePairs[p.tradePair] == p {
+		delete(s.tradePairs, p.tradePair)
+	}
+	if p.scraper == nil {
+		return false, nil
+	}
+	return !stopsPair(p.scraper), p.scraper.Close()
+}
+
+// SyncPairs scrapes the pairs of @pairs which are not ignored and stops scraping all other
+// pairs. With @onePairPerSymbol only one pair is scraped per symbol, the pairs already scraped
+// are kept. If closing the PairScrapers of removed pairs does not stop them, the scraper is
+// restarted with the new pairs. It returns the pairs added and removed.
+func (s *Supervisor) SyncPairs(pairs []dia.Pair, onePairPerSymbol bool) (added []dia.Pair, removed []dia.Pair) {
+	s.lock.Lock()
+	restart := false
+	defer func() {
+		s.lock.Unlock()
+		if restart {
+			log.Warnf("%s: restarting scraper without %d removed pairs", s.exchange, len(removed))
+			s.replaceScraper()
+		}
+	}()
+
+	selected := selectPairs(pairs, s.pairs, onePairPerSymbol)
+	for foreignName, p := range s.pairs {
+		if _, ok := selected[foreignName]; ok {
+			continue
+		}
+		log.Println("Removing pair:", p.health.Pair.Symbol, foreignName, "on exchange", s.exchange)
+		r, err := s.removePair(foreignName)
+		if err != nil {
+			log.Warnf("%s: closing pair %s: %v", s.exchange, foreignName, err)
+		}
+		restart = restart || r
+		removed = append(removed, p.health.Pair)
+	}
+	for _, pair := range pairs {
+		if _, ok := selected[pair.ForeignName]; !ok {
+			continue
+		}
+		if _, ok := s.pairs[pair.ForeignName]; ok {
+			continue
+		}
+		log.Println("Adding pair:", pair.Symbol, pair.ForeignName, "on exchange", s.exchange)
+		if err := s.addPair(pair); err != nil {
+			log.Println(err)
+			continue
+		}
+		added = append(added, pair)
+	}
+	return
+}
+
+// selectPairs returns the pairs of @pairs to scrape by foreign name. With @onePairPerSymbol
+// the pairs of @scraped take precedence over other pairs of their symbol.
+func selectPairs(pairs []dia.Pair, scraped map[string]*supervisedPair, onePairPerSymbol bool) map[string]dia.Pair {
+	symbols := make(map[string]string)
+	if onePairPerSymbol {
+		for _, pair := range pairs {
+			if _, ok := scraped[pair.ForeignName]; !ok || pair.Ignore {
+				continue
+			}
+			if _, ok := symbols[pair.Symbol]; !ok {
+				symbols[pair.Symbol] = pair.ForeignName
+			}
+		}
+	}
+	selected := make(map[string]dia.Pair)
+	for _, pair := range pairs {
+		if pair.Ignore {
+			continue
+		}
+		if onePairPerSymbol {
+			if foreignName, ok := symbols[pair.Symbol]; ok && foreignName != pair.ForeignName {
+				continue
+			}
+			symbols[pair.Symbol] = pair.ForeignName
+		}
+		selected[pair.ForeignName] = pair
+	}
+	return selected
+}
+
+// Run passes the trades of the scraper to @handle and supervises the pairs. It only returns
+// once the restarts allowed by the policy are exhausted.
+func (s *Supervisor) Run(handle func(*dia.Trade)) error {
+	ticker := time.NewTicker(superviseInterval)
+	defer ticker.Stop()
+	for {
+		s.lock.RLock()
+		trades := s.scraper.Channel()
+		s.lock.RUnlock()
+
+		select {
+		case t, ok := <-trades:
+			if !ok {
+				err := s.restartScraper(time.Now(), errors.New("trades channel closed"))
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			s.record(t, time.Now())
+			handle(t)
+		case <-ticker.C:
+			if err := s.check(time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// record marks the pair of @t as healthy. The pair of @t is its foreign name or the foreign
+// name as normalized by the scraper.
+func (s *Supervisor) record(t *dia.Trade, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastTrade = now
+	s.scraperRestarts = 0
+	p, ok := s.pairs[t.Pair]
+	if !ok {
+		p, ok = s.tradePairs[t.Pair]
+	}
+	if ok {
+		p.active = now
+		p.health.LastTrade = t.Time
+		p.health.Status = PairHealthy
+		p.health.Restarts = 0
+		p.health.Error = ""
+	}
+}
+
+// check restarts the pairs without trades within the pair timeout and the pairs with errors,
+// and the scraper if there were no trades within the scraper timeout. An error is returned
+// once the scraper restarts are exhausted.
+func (s *Supervisor) check(now time.Time) error {
+	s.lock.Lock()
+	var restart []*supervisedPair
+	replace := false
+	for _, p := range s.pairs {
+		var err error
+		if p.scraper == nil {
+			err = errors.New("not scraped")
+		} else if err = p.scraper.Error(); err == nil && now.Sub(p.active) > s.policy.PairTimeout {
+			err = fmt.Errorf("no trades since %v", p.active)
+		}
+		if err == nil {
+			continue
+		}
+		p.health.Error = err.Error()
+		if p.health.Restarts >= s.policy.MaxPairRestarts {
+			p.health.Status = PairFailed
+			continue
+		}
+		restart = append(restart, p)
+		replace = replace || (p.scraper != nil && !stopsPair(p.scraper))
+	}
+	s.saveLastTradeTimes()
+
+	active := s.started
+	if s.lastTrade.After(active) {
+		active = s.lastTrade
+	}
+	timedOut := now.Sub(active) > s.policy.ScraperTimeout
+	if !timedOut && !replace {
+		for _, p := range restart {
+			s.restartPair(p, now)
+		}
+	}
+	s.lock.Unlock()
+
+	if timedOut {
+		return s.restartScraper(now, fmt.Errorf("no trades since %v", active))
+	}
+	if replace {
+		// the pairs keep being scraped after closing their PairScrapers, so they are
+		// restarted along with all other pairs on a new scraper
+		log.Warnf("%s: restarting scraper for %d pairs", s.exchange, len(restart))
+		s.replaceScraper()
+		s.lock.Lock()
+		for _, p := range restart {
+			p.active = now
+			p.health.Status = PairRestarted
+			p.health.Restarts++
+		}
+		s.lock.Unlock()
+	}
+	return nil
+}
+
+// restartPair closes the PairScraper of @p and scrapes its pair again. The PairScraper must
+// stop scraping its pair when closed.
+func (s *Supervisor) restartPair(p *supervisedPair, now time.Time) {
+	log.Warnf("%s: restarting pair %s: %s", s.exchange, p.health.Pair.ForeignName, p.health.Error)
+	if p.scraper != nil {
+		if err := p.scraper.Close(); err != nil {
+			log.Warnf("%s: closing pair %s: %v", s.exchange, p.health.Pair.ForeignName, err)
+		}
+	}
+	ps, err := s.scraper.ScrapePair(p.health.Pair)
+	if err != nil {
+		p.health.Error = err.Error()
+		ps = nil
+	}
+	p.scraper = ps
+	p.active = now
+	p.health.Status = PairRestarted
+	p.health.Restarts++
+}
+
+// restartScraper replaces the APIScraper and scrapes all pairs again. An error is returned
+// instead if the scraper restarts are exhausted. The lock must not be held.
+func (s *Supervisor) restartScraper(now time.Time, reason error) error {
+	s.lock.Lock()
+	if s.scraperRestarts >= s.policy.MaxScraperRestarts {
+		s.lock.Unlock()
+		return fmt.Errorf("%s: scraper failed after %d restarts: %v", s.exchange, s.scraperRestarts, reason)
+	}
+	s.scraperRestarts++
+	log.Warnf("%s: restarting scraper (%d/%d): %v", s.exchange, s.scraperRestarts, s.policy.MaxScraperRestarts, reason)
+	s.lock.Unlock()
+
+	s.replaceScraper()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.started = now
+	for _, p := range s.pairs {
+		p.active = now
+		p.health.Status = PairRestarted
+		p.health.Restarts = 0
+	}
+	return nil
+}
+
+// replaceScraper closes the APIScraper, replaces it by a new one and scrapes all pairs on it.
+// The old scraper is closed without holding the lock, as closing may take a while. The lock
+// must not be held.
+func (s *Supervisor) replaceScraper() {
+	s.restartLock.Lock()
+	defer s.restartLock.Unlock()
+
+	s.lock.RLock()
+	old := s.scraper
+	s.lock.RUnlock()
+	closed := make(chan error, 1)
+	go func() {
+		closed <- old.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			log.Warnf("%s: closing scraper: %v", s.exchange, err)
+		}
+	case <-time.After(scraperCloseTimeout):
+		log.Warnf("%s: closing scraper timed out", s.exchange)
+	}
+	scraper := s.newScraper()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.scraper = scraper
+	for _, p := range s.pairs {
+		ps, err := scraper.ScrapePair(p.health.Pair)
+		if err != nil {
+			p.health.Error = err.Error()
+			ps = nil
+		}
+		p.scraper = ps
+	}
+}
+
+// saveLastTradeTimes stores the latest trade time of the symbols with new trades.
+func (s *Supervisor) saveLastTradeTimes() {
+	if s.datastore == nil {
+		return
+	}
+	updated := make(map[string]bool)
+	latest := make(map[string]time.Time)
+	for _, p := range s.pairs {
+		symbol := p.health.Pair.Symbol
+		if p.health.LastTrade.After(p.saved) {
+			p.saved = p.health.LastTrade
+			updated[symbol] = true
+		}
+		if p.saved.After(latest[symbol]) {
+			latest[symbol] = p.saved
+		}
+	}
+	for symbol := range updated {
+		if err := s.datastore.SetLastTradeTimeForExchange(symbol, s.exchange, latest[symbol]); err != nil {
+			log.Errorln("SetLastTradeTimeForExchange", err)
+		}
+	}
+}
+
+// Health returns the health of all pairs ordered by foreign name.
+func (s *Supervisor) Health() []PairHealth {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	health := make([]PairHealth, 0, len(s.pairs))
+	for _, p := range s.pairs {
+		health = append(health, p.health)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].Pair.ForeignName < health[j].Pair.ForeignName
+	})
+	return health
+}
+
+// ServeHTTP writes the health of all pairs as JSON.
+func (s *Supervisor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Health()); err != nil {
+		log.Errorln("health", err)
+	}
+}
@@ -0,0 +1,264 @@
+package scrapers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+type fakeScraper struct {
+	trades chan *dia.Trade
+	pairs  map[string]int
+	// stoppable makes the PairScrapers stop their pair when closed
+	stoppable bool
+	// normalized are the normalized foreign names of pairs, which are kept if missing
+	normalized map[string]string
+}
+
+func (s *fakeScraper) Close() error {
+	return nil
+}
+
+func (s *fakeScraper) ScrapePair(pair dia.Pair) (PairScraper, error) {
+	s.pairs[pair.ForeignName]++
+	if s.stoppable {
+		return &fakeStoppablePairScraper{fakePairScraper{pair: pair}}, nil
+	}
+	return &fakePairScraper{pair: pair}, nil
+}
+
+func (s *fakeScraper) FetchAvailablePairs() ([]dia.Pair, error) {
+	return nil, nil
+}
+
+func (s *fakeScraper) NormalizePair(pair dia.Pair) (dia.Pair, error) {
+	if name, ok := s.normalized[pair.ForeignName]; ok {
+		pair.ForeignName = name
+	}
+	return pair, nil
+}
+
+func (s *fakeScraper) Channel() chan *dia.Trade {
+	return s.trades
+}
+
+type fakePairScraper struct {
+	pair dia.Pair
+}
+
+func (ps *fakePairScraper) Close() error {
+	return nil
+}
+
+func (ps *fakePairScraper) Error() error {
+	return nil
+}
+
+func (ps *fakePairScraper) Pair() dia.Pair {
+	return ps.pair
+}
+
+type fakeStoppablePairScraper struct {
+	fakePairScraper
+}
+
+func (ps *fakeStoppablePairScraper) stopsPair() {}
+
+type fakeLastTradeTimeStore map[string]time.Time
+
+func (st fakeLastTradeTimeStore) SetLastTradeTimeForExchange(symbol string, exchange string, t time.Time) error {
+	st[symbol+"_"+exchange] = t
+	return nil
+}
+
+func TestSupervisor(t *testing.T) {
+	var created []*fakeScraper
+	newScraper := func() APIScraper {
+		s := &fakeScraper{trades: make(chan *dia.Trade), pairs: make(map[string]int), stoppable: true}
+		created = append(created, s)
+		return s
+	}
+	store := make(fakeLastTradeTimeStore)
+	s := NewSupervisor(dia.BinanceExchange, SupervisorPolicy{
+		PairTimeout:        10 * time.Minute,
+		ScraperTimeout:     30 * time.Minute,
+		MaxPairRestarts:    1,
+		MaxScraperRestarts: 1,
+	}, newScraper, store)
+	for _, pair := range []dia.Pair{{Symbol: "BTC", ForeignName: "BTCUSDT"}, {Symbol: "ETH", ForeignName: "ETHUSDT"}} {
+		if err := s.AddPair(pair); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t0 := s.started
+	tradeTime := time.Unix(1600000000, 0)
+	s.record(&dia.Trade{Symbol: "BTC", Pair: "BTCUSDT", Time: tradeTime}, t0.Add(time.Minute))
+
+	tables := []struct {
+		after    time.Duration
+		statuses []string
+		restarts []int
+		scrapers int
+	}{
+		{11 * time.Minute, []string{PairHealthy, PairRestarted}, []int{0, 1}, 1},
+		{22 * time.Minute, []string{PairRestarted, PairFailed}, []int{1, 1}, 1},
+		// no trades within the scraper timeout
+		{32 * time.Minute, []string{PairRestarted, PairRestarted}, []int{0, 0}, 2},
+	}
+	for i, table := range tables {
+		if err := s.check(t0.Add(table.after)); err != nil {
+			t.Fatalf("Check %d failed: %v", i, err)
+		}
+		for j, health := range s.Health() {
+			if health.Status != table.statuses[j] || health.Restarts != table.restarts[j] {
+				t.Errorf("Health %d of check %d was incorrect, got: %s/%d, want: %s/%d.", j, i, health.Status, health.Restarts, table.statuses[j], table.restarts[j])
+			}
+		}
+		if len(created) != table.scrapers {
+			t.Errorf("Scrapers of check %d were incorrect, got: %d, want: %d.", i, len(created), table.scrapers)
+		}
+	}
+	if created[1].pairs["BTCUSDT"] != 1 || created[1].pairs["ETHUSDT"] != 1 {
+		t.Errorf("Pairs of the restarted scraper were incorrect, got: %v.", created[1].pairs)
+	}
+	if saved := store["BTC_"+dia.BinanceExchange]; !saved.Equal(tradeTime) {
+		t.Errorf("Last trade time was incorrect, got: %v, want: %v.", saved, tradeTime)
+	}
+
+	// the scraper restarts are exhausted
+	if err := s.check(t0.Add(63 * time.Minute)); err == nil {
+		t.Errorf("Check passed after the scraper restarts were exhausted.")
+	}
+}
+
+func TestSupervisorRestartsScraperForPairs(t *testing.T) {
+	var created []*fakeScraper
+	s := NewSupervisor(dia.KrakenExchange, SupervisorPolicy{
+		PairTimeout:        10 * time.Minute,
+		ScraperTimeout:     30 * time.Minute,
+		MaxPairRestarts:    1,
+		MaxScraperRestarts: 1,
+	}, func() APIScraper {
+		s := &fakeScraper{trades: make(chan *dia.Trade), pairs: make(map[string]int)}
+		created = append(created, s)
+		return s
+	}, make(fakeLastTradeTimeStore))
+	for _, pair := range []dia.Pair{{Symbol: "BTC", ForeignName: "XXBTZUSD"}, {Symbol: "ETH", ForeignName: "XETHZUSD"}} {
+		if err := s.AddPair(pair); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t0 := s.started
+	s.record(&dia.Trade{Symbol: "BTC", Pair: "XXBTZUSD", Time: t0}, t0.Add(time.Minute))
+
+	// the pairs are scraped until the scraper is closed, so the failing pair restarts the scraper
+	if err := s.check(t0.Add(11 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{PairRestarted, PairHealthy}
+	restarts := []int{1, 0}
+	for i, health := range s.Health() {
+		if health.Status != statuses[i] || health.Restarts != restarts[i] {
+			t.Errorf("Health %d was incorrect, got: %s/%d, want: %s/%d.", i, health.Status, health.Restarts, statuses[i], restarts[i])
+		}
+	}
+	if len(created) != 2 || created[1].pairs["XXBTZUSD"] != 1 || created[1].pairs["XETHZUSD"] != 1 {
+		t.Errorf("Scrapers were incorrect, got: %d scrapers.", len(created))
+	}
+	if s.scraperRestarts != 0 {
+		t.Errorf("Scraper restarts were incorrect, got: %d, want: %d.", s.scraperRestarts, 0)
+	}
+}
+
+func TestSupervisorNormalizedTradePairs(t *testing.T) {
+	var created []*fakeScraper
+	store := make(fakeLastTradeTimeStore)
+	s := NewSupervisor(dia.KrakenExchange, SupervisorPolicy{
+		PairTimeout:        10 * time.Minute,
+		ScraperTimeout:     30 * time.Minute,
+		MaxPairRestarts:    1,
+		MaxScraperRestarts: 1,
+	}, func() APIScraper {
+		s := &fakeScraper{trades: make(chan *dia.Trade), pairs: make(map[string]int), stoppable: true, normalized: map[string]string{"XXBTZEUR": "BTCEUR"}}
+		created = append(created, s)
+		return s
+	}, store)
+	if err := s.AddPair(dia.Pair{Symbol: "BTC", ForeignName: "XXBTZEUR"}); err != nil {
+		t.Fatal(err)
+	}
+	t0 := s.started
+	tradeTime := time.Unix(1600000000, 0)
+	// trades are stored with the normalized pair
+	s.record(&dia.Trade{Symbol: "BTC", Pair: "BTCEUR", Time: tradeTime}, t0.Add(5*time.Minute))
+
+	if err := s.check(t0.Add(11 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	health := s.Health()
+	if len(health) != 1 || health[0].Status != PairHealthy || health[0].Restarts != 0 || !health[0].LastTrade.Equal(tradeTime) {
+		t.Errorf("Health was incorrect, got: %v.", health)
+	}
+	if created[0].pairs["XXBTZEUR"] != 1 {
+		t.Errorf("Pair was restarted, got: %v.", created[0].pairs)
+	}
+	if saved := store["BTC_"+dia.KrakenExchange]; !saved.Equal(tradeTime) {
+		t.Errorf("Last trade time was incorrect, got: %v, want: %v.", saved, tradeTime)
+	}
+
+	s.RemovePair("XXBTZEUR")
+	if len(s.tradePairs) != 0 {
+		t.Errorf("Trade pairs were incorrect after removal, got: %v.", s.tradePairs)
+	}
+}
+
+func TestSupervisorSyncPairs(t *testing.T) {
+	var created []*fakeScraper
+	s := NewSupervisor(dia.BinanceExchange, SupervisorPolicy{}, func() APIScraper {
+		s := &fakeScraper{trades: make(chan *dia.Trade), pairs: make(map[string]int)}
+		created = append(created, s)
+		return s
+	}, nil)
+
+	tables := []struct {
+		pairs    []dia.Pair
+		scraped  []string
+		scrapers int
+	}{
+		{
+			[]dia.Pair{{Symbol: "BTC", ForeignName: "BTCUSDT"}, {Symbol: "BTC", ForeignName: "BTCEUR"}, {Symbol: "ETH", ForeignName: "ETHUSDT", Ignore: true}},
+			[]string{"BTCUSDT"},
+			1,
+		},
+		// the scraped pair of a symbol is kept
+		{
+			[]dia.Pair{{Symbol: "BTC", ForeignName: "BTCEUR"}, {Symbol: "BTC", ForeignName: "BTCUSDT"}, {Symbol: "ETH", ForeignName: "ETHUSDT"}},
+			[]string{"BTCUSDT", "ETHUSDT"},
+			1,
+		},
+		// closing the removed pairs does not stop them, so the scraper is restarted
+		{
+			[]dia.Pair{{Symbol: "BTC", ForeignName: "BTCEUR"}, {Symbol: "BTC", ForeignName: "BTCUSDT", Ignore: true}},
+			[]string{"BTCEUR"},
+			2,
+		},
+	}
+	for i, table := range tables {
+		s.SyncPairs(table.pairs, true)
+		health := s.Health()
+		var scraped []string
+		for _, h := range health {
+			scraped = append(scraped, h.Pair.ForeignName)
+		}
+		if strings.Join(scraped, ",") != strings.Join(table.scraped, ",") {
+			t.Errorf("Pairs of sync %d were incorrect, got: %v, want: %v.", i, scraped, table.scraped)
+		}
+		if len(created) != table.scrapers {
+			t.Errorf("Scrapers of sync %d were incorrect, got: %d, want: %d.", i, len(created), table.scrapers)
+		}
+	}
+	if pairs := created[len(created)-1].pairs; len(pairs) != 1 || pairs["BTCEUR"] != 1 {
+		t.Errorf("Pairs of the restarted scraper were incorrect, got: %v.", pairs)
+	}
+}
@@ -3,6 +3,7 @@ package scrapers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"math"
 	"math/big"
@@ -79,6 +80,8 @@ type UniswapScraper struct {
 	pairScrapers map[string]*UniswapPairScraper
 	exchangeName string
 	chanTrades   chan *dia.Trade
+	// pair addresses by foreign name for fetching past trades
+	pairAddresses pairAddresses
 }
 
 // NewUniswapScraper returns a new UniswapScraper for the given pair
@@ -351,7 +354,7 @@ func (s *UniswapScraper) normalizeUniswapSwap(swap uniswapcontract.UniswapV2Pair
 	amount1Out, _ := new(big.Float).Quo(big.NewFloat(0).SetInt(swap.Amount1Out), new(big.Float).SetFloat64(math.Pow10(decimals1))).Float64()
 
 	normalizedSwap = UniswapSwap{
-		ID:         swap.Raw.TxHash.Hex(),
+		ID:         swap.Raw.TxHash.Hex() + "-" + fmt.Sprint(swap.Raw.Index),
 		Timestamp:  time.Now().Unix(),
 		Pair:       pair,
 		Amount0In:  amount0In,
@@ -563,6 +566,91 @@ func getSwapData(swap UniswapSwap) (price float64, volume float64, err error) {
 	return
 }
 
+// FetchTrades returns the swaps of @pair in [@from, @to) filtered from the logs of all pair
+// contracts with the foreign name of @pair.
+func (s *UniswapScraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	addresses, err := s.pairAddresses.get(pair.ForeignName, func() (map[string][]common.Address, error) {
+		uniPairs, err := s.GetAllPairs()
+		if err != nil {
+			return nil, err
+		}
+		addresses := make(map[string][]common.Address)
+		for _, uniPair := range uniPairs {
+			addresses[uniPair.ForeignName] = append(addresses[uniPair.ForeignName], uniPair.Address)
+		}
+		return addresses, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reversePairs == nil {
+		reversePairs, err = getReverseTokensFromConfig("uniswap/reverse_tokens")
+		if err != nil {
+			log.Error("error getting tokens for which pairs should be reversed: ", err)
+		}
+	}
+	start, end, err := blockRange(s.RestClient, from, to)
+	if err != nil {
+		return nil, err
+	}
+	times := newBlockTimes(s.RestClient)
+
+	var trades []*dia.Trade
+	for _, address := range addresses {
+		pairFiltererContract, err := uniswapcontract.NewUniswapV2PairFilterer(address, s.RestClient)
+		if err != nil {
+			return nil, err
+		}
+		err = forBlockRanges(start, end, func(start uint64, end uint64) error {
+			iter, err := pairFiltererContract.FilterSwap(&bind.FilterOpts{Start: start, End: &end}, nil, nil)
+			if err != nil {
+				return err
+			}
+			defer iter.Close()
+			for iter.Next() {
+				swap, err := s.normalizeUniswapSwap(*iter.Event)
+				if err != nil {
+					return err
+				}
+				timestamp, err := times.at(iter.Event.Raw.BlockNumber)
+				if err != nil {
+					return err
+				}
+				price, volume, err := getSwapData(swap)
+				if err != nil {
+					log.Error("error getting swap data: ", err)
+					continue
+				}
+				t := &dia.Trade{
+					Symbol:         pair.Symbol,
+					Pair:           pair.ForeignName,
+					Price:          price,
+					Volume:         volume,
+					Time:           timestamp,
+					ForeignTradeID: swap.ID,
+					Source:         s.exchangeName,
+				}
+				// If we need quotation of a base token, reverse pair
+				if reversePairs != nil && utils.Contains(reversePairs, swap.Pair.Token1.Address.Hex()) {
+					tSwapped, err := dia.SwapTrade(*t)
+					if err == nil {
+						t = &tSwapped
+					}
+				}
+				if price > 0 {
+					trades = append(trades, t)
+				}
+			}
+			return iter.Error()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortTrades(trades)
+	return trades, nil
+}
+
 func (s *UniswapScraper) cleanup(err error) {
 	s.errorLock.Lock()
 	defer s.errorLock.Unlock()
@@ -2,6 +2,7 @@ package scrapers
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
 	"strings"
@@ -24,6 +25,10 @@ var (
 	UniswapV3FactoryContractAddress = "0x1F98431c8aD98523631AE4a59f267346ea31F984"
 )
 
+// uniswapV3StartBlock is the block the factory contract was created in
+// https://etherscan.io/tx/0x1e20cd6d47d7021ae7e437792823517eeadd835df09dde17ab45afd7a5df4603
+const uniswapV3StartBlock = 12369621
+
 type UniswapV3Swap struct {
 	ID        string
 	Timestamp int64
@@ -51,6 +56,8 @@ type UniswapV3Scraper struct {
 
 	exchangeName string
 	chanTrades   chan *dia.Trade
+	// pool addresses by foreign name for fetching past trades
+	pairAddresses pairAddresses
 }
 
 // NewUniswapV3Scraper returns a new UniswapV3Scraper
@@ -231,7 +238,7 @@ func (s *UniswapV3Scraper) normalizeUniswapSwap(swap UniswapV3Pair.UniswapV3Pair
 	amount1, _ := new(big.Float).Quo(big.NewFloat(0).SetInt(swap.Amount1), new(big.Float).SetFloat64(math.Pow10(decimals1))).Float64()
 
 	normalizedSwap = UniswapV3Swap{
-		ID:        swap.Raw.TxHash.Hex(),
+		ID:        swap.Raw.TxHash.Hex() + "-" + fmt.Sprint(swap.Raw.Index),
 		Timestamp: time.Now().Unix(),
 		Pair:      pair,
 		Amount0:   amount0,
@@ -399,10 +406,7 @@ func (s *UniswapV3Scraper) getAllPairs() (pairs []UniswapPair, err error) {
 		log.Error(err)
 	}
 
-	var startBlock uint64
-	startBlock = 12369621
-
-	poolCreated, err := contract.FilterPoolCreated(&bind.FilterOpts{Start: startBlock}, []common.Address{}, []common.Address{}, []*big.Int{})
+	poolCreated, err := contract.FilterPoolCreated(&bind.FilterOpts{Start: uniswapV3StartBlock}, []common.Address{}, []common.Address{}, []*big.Int{})
 	if err != nil {
 		return nil, err
 	}
@@ -417,6 +421,102 @@ func (s *UniswapV3Scraper) getAllPairs() (pairs []UniswapPair, err error) {
 
 }
 
+// FetchTrades returns the swaps of @pair in [@from, @to) filtered from the logs of all pools
+// with the foreign name of @pair.
+func (s *UniswapV3Scraper) FetchTrades(pair dia.Pair, from time.Time, to time.Time) ([]*dia.Trade, error) {
+	addresses, err := s.pairAddresses.get(pair.ForeignName, s.getPoolAddresses)
+	if err != nil {
+		return nil, err
+	}
+	if reversePairs == nil {
+		reversePairs, err = getReverseTokensFromConfig("reverse_tokens")
+		if err != nil {
+			log.Error("error getting tokens for which pairs should be reversed: ", err)
+		}
+	}
+	start, end, err := blockRange(s.RestClient, from, to)
+	if err != nil {
+		return nil, err
+	}
+	times := newBlockTimes(s.RestClient)
+
+	var trades []*dia.Trade
+	for _, address := range addresses {
+		pairFiltererContract, err := UniswapV3Pair.NewUniswapV3PairFilterer(address, s.RestClient)
+		if err != nil {
+			return nil, err
+		}
+		err = forBlockRanges(start, end, func(start uint64, end uint64) error {
+			iter, err := pairFiltererContract.FilterSwap(&bind.FilterOpts{Start: start, End: &end}, nil, nil)
+			if err != nil {
+				return err
+			}
+			defer iter.Close()
+			for iter.Next() {
+				swap, err := s.normalizeUniswapSwap(*iter.Event)
+				if err != nil {
+					return err
+				}
+				timestamp, err := times.at(iter.Event.Raw.BlockNumber)
+				if err != nil {
+					return err
+				}
+				swap.Pair.normalizeUniPair()
+				price, volume := s.getSwapData(swap)
+				t := &dia.Trade{
+					Symbol:         swap.Pair.Token0.Symbol,
+					Pair:           pair.ForeignName,
+					Price:          price,
+					Volume:         volume,
+					Time:           timestamp,
+					ForeignTradeID: swap.ID,
+					Source:         s.exchangeName,
+				}
+				// If we need quotation of a base token, reverse pair
+				if reversePairs != nil && utils.Contains(reversePairs, strings.ToLower(swap.Pair.Token1.Address.Hex())) {
+					tSwapped, err := dia.SwapTrade(*t)
+					if err == nil {
+						t = &tSwapped
+					}
+				}
+				if price > 0 {
+					trades = append(trades, t)
+				}
+			}
+			return iter.Error()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortTrades(trades)
+	return trades, nil
+}
+
+// getPoolAddresses returns the addresses of all pools by foreign name. Unlike getAllPairs it
+// does not subscribe to the pools.
+func (s *UniswapV3Scraper) getPoolAddresses() (map[string][]common.Address, error) {
+	contract, err := uniswapcontractv3.NewUniswapV3Filterer(common.HexToAddress(UniswapV3FactoryContractAddress), s.RestClient)
+	if err != nil {
+		return nil, err
+	}
+	poolCreated, err := contract.FilterPoolCreated(&bind.FilterOpts{Start: uniswapV3StartBlock}, []common.Address{}, []common.Address{}, []*big.Int{})
+	if err != nil {
+		return nil, err
+	}
+	defer poolCreated.Close()
+	addresses := make(map[string][]common.Address)
+	for poolCreated.Next() {
+		pair, err := s.GetPairData(poolCreated.Event)
+		if err != nil {
+			continue
+		}
+		pair.normalizeUniPair()
+		addresses[pair.ForeignName] = append(addresses[pair.ForeignName], pair.Address)
+	}
+	return addresses, poolCreated.Error()
+}
+
 func (s *UniswapV3Scraper) cleanup(err error) {
 	s.errorLock.Lock()
 	defer s.errorLock.Unlock()
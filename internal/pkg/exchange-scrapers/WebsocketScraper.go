@@ -0,0 +1,526 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	ws "github.com/gorilla/websocket"
+)
+
+// Defaults of the WebsocketOptions
+const (
+	wsPingInterval = 30 * time.Second
+	wsReadTimeout  = 2 * time.Minute
+	wsWriteTimeout = 10 * time.Second
+	wsMinBackoff   = time.Second
+	wsMaxBackoff   = 2 * time.Minute
+)
+
+// WebsocketProtocol supplies the exchange specific parts of a WebsocketScraper. Messages
+// returned as []byte are sent as they are, all others are encoded as JSON.
+type WebsocketProtocol interface {
+	// URL returns the endpoint dialed on each connection.
+	URL() (string, error)
+	// Subscribe returns the messages subscribing to the trades of @pairs.
+	Subscribe(pairs []dia.Pair) ([]interface{}, error)
+	// Unsubscribe returns the messages unsubscribing from the trades of @pair.
+	Unsubscribe(pair dia.Pair) ([]interface{}, error)
+	// Parse parses a message of type @messageType received from the exchange.
+	Parse(messageType int, data []byte) (WebsocketMessage, error)
+	// Heartbeat returns the message keeping the connection alive, or nil if the exchange
+	// answers websocket pings.
+	Heartbeat() interface{}
+}
+
+// tradePairNormalizer is implemented by protocols whose trades are stored with another pair
+// name than the foreign name they are subscribed with.
+type tradePairNormalizer interface {
+	// tradePair returns the pair name of the trades of @pair.
+	tradePair(pair dia.Pair) string
+}
+
+// WebsocketMessage is a message received from an exchange.
+type WebsocketMessage struct {
+	// Trades have the foreign name of their pair as Pair. Symbol and Source are set by the
+	// scraper, trades of pairs without active PairScraper are dropped.
+	Trades []*dia.Trade
+	// Diffs are applied to the order books of an order book scraper.
+	Diffs []OrderBookDiff
+	// Sequence is the number of the message in Stream, it increases by one per message.
+	// Sequences are not checked if it is 0.
+	Stream   string
+	Sequence int64
+	// Reply is sent back to the exchange if it is not nil, e.g. a pong.
+	Reply interface{}
+}
+
+// WebsocketOptions configure the connection of a WebsocketScraper. Zero values are replaced
+// by defaults.
+type WebsocketOptions struct {
+	// PingInterval is the interval of heartbeats.
+	PingInterval time.Duration
+	// ReadTimeout is the time without messages after which the scraper reconnects.
+	ReadTimeout time.Duration
+	// MinBackoff and MaxBackoff bound the exponential backoff between reconnects.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// WebsocketScraper implements the websocket connection of exchange scrapers. It dials the
+// exchange, keeps the connection alive with heartbeats, reconnects with exponential backoff
+// and resubscribes to all active pairs after reconnecting.
+type WebsocketScraper struct {
+	protocol     WebsocketProtocol
+	options      WebsocketOptions
+	exchangeName string
+	// signaling channels for session finishing
+	shutdown     chan nothing
+	shutdownDone chan nothing
+	// lock guards conn, pairScrapers, error and closed
+	lock         sync.RWMutex
+	conn         *ws.Conn
+	pairScrapers map[string]*WebsocketPairScraper
+	error        error
+	closed       bool
+	// writeLock serializes the messages written to conn
+	writeLock  sync.Mutex
+	sequences  sequenceTracker
+	chanTrades chan *dia.Trade
+	// books are the order books maintained from the diffs of the exchange, nil if only trades
+	// are scraped
+	books *orderBooks
+}
+
+// NewWebsocketScraper returns a WebsocketScraper for @exchange speaking @protocol. The
+// instance connects in the background as soon as it is created.
+func NewWebsocketScraper(exchange dia.Exchange, protocol WebsocketProtocol, options WebsocketOptions) *WebsocketScraper {
+	return newWebsocketScraper(exchange, protocol, options, nil)
+}
+
+func newWebsocketScraper(exchange dia.Exchange, protocol WebsocketProtocol, options WebsocketOptions, books *orderBooks) *WebsocketScraper {
+	if options.PingInterval == 0 {
+		options.PingInterval = wsPingInterval
+	}
+	if options.ReadTimeout == 0 {
+		options.ReadTimeout = wsReadTimeout
+	}
+	if options.MinBackoff == 0 {
+		options.MinBackoff = wsMinBackoff
+	}
+	if options.MaxBackoff == 0 {
+		options.MaxBackoff = wsMaxBackoff
+	}
+	s := &WebsocketScraper{
+		protocol:     protocol,
+		options:      options,
+		exchangeName: exchange.Name,
+		shutdown:     make(chan nothing),
+		shutdownDone: make(chan nothing),
+		pairScrapers: make(map[string]*WebsocketPairScraper),
+		sequences:    make(sequenceTracker),
+		chanTrades:   make(chan *dia.Trade),
+		books:        books,
+	}
+	go s.mainLoop()
+	return s
+}
+
+// runs in a goroutine until s is closed
+func (s *WebsocketScraper) mainLoop() {
+	b := backoff{min: s.options.MinBackoff, max: s.options.MaxBackoff}
+	for {
+		forwarded, err := s.session()
+		// sessions forwarding trades or diffs were subscribed successfully
+		if forwarded {
+			b.reset()
+		}
+		delay := b.next()
+		select {
+		case <-s.shutdown:
+			log.Printf("%s: shutting down", s.exchangeName)
+			s.cleanup(nil)
+			return
+		default:
+		}
+		log.Warnf("%s: reconnecting in %v after: %v", s.exchangeName, delay, err)
+		select {
+		case <-s.shutdown:
+			log.Printf("%s: shutting down", s.exchangeName)
+			s.cleanup(nil)
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// session connects to the exchange, subscribes to all active pairs and forwards trades until
+// the connection fails. It returns true if trades or order book diffs were forwarded.
+func (s *WebsocketScraper) session() (forwarded bool, err error) {
+	url, err := s.protocol.URL()
+	if err != nil {
+		return false, err
+	}
+	conn, _, err := ws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	pairs, err := s.connect(conn)
+	if err != nil {
+		return false, err
+	}
+	defer s.disconnect()
+
+	if len(pairs) > 0 {
+		messages, err := s.protocol.Subscribe(pairs)
+		if err != nil {
+			return false, err
+		}
+		if err := s.send(conn, messages...); err != nil {
+			return false, err
+		}
+	}
+	log.Infof("%s: subscribed to %d pairs", s.exchangeName, len(pairs))
+
+	done := make(chan nothing)
+	defer close(done)
+	go s.heartbeat(conn, done)
+
+	conn.SetReadDeadline(time.Now().Add(s.options.ReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(s.options.ReadTimeout))
+	})
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return forwarded, err
+		}
+		conn.SetReadDeadline(time.Now().Add(s.options.ReadTimeout))
+
+		message, err := s.protocol.Parse(messageType, data)
+		if err != nil {
+			log.Errorf("%s: parsing message %s: %v", s.exchangeName, data, err)
+			continue
+		}
+		if message.Reply != nil {
+			if err := s.send(conn, message.Reply); err != nil {
+				return forwarded, err
+			}
+		}
+		if missing := s.sequences.check(message.Stream, message.Sequence); missing > 0 {
+			log.Warnf("%s: %d messages of stream %s missing before sequence %d", s.exchangeName, missing, message.Stream, message.Sequence)
+		}
+		for _, t := range message.Trades {
+			ok, err := s.forward(t)
+			if err != nil {
+				return forwarded, err
+			}
+			forwarded = forwarded || ok
+		}
+		if s.books == nil {
+			continue
+		}
+		for _, diff := range message.Diffs {
+			forwarded = true
+			if s.books.apply(diff) {
+				s.fetchSnapshot(diff.Pair)
+			}
+		}
+	}
+}
+
+// fetchSnapshot fetches the order book of @foreignName in the background if the protocol
+// publishes snapshots separately from diffs.
+func (s *WebsocketScraper) fetchSnapshot(foreignName string) {
+	snapshotter, ok := s.protocol.(OrderBookSnapshotter)
+	if !ok || !s.books.startFetch(foreignName) {
+		return
+	}
+	go func() {
+		defer s.books.finishFetch(foreignName)
+		snapshot, err := snapshotter.Snapshot(foreignName)
+		if err != nil {
+			log.Errorf("%s: fetching order book of %s: %v", s.exchangeName, foreignName, err)
+			return
+		}
+		snapshot.Pair = foreignName
+		snapshot.Snapshot = true
+		s.books.apply(snapshot)
+	}()
+}
+
+// connect sets @conn as connection of s and returns the active pairs to subscribe to. Pairs
+// added from then on are subscribed by ScrapePair.
+func (s *WebsocketScraper) connect(conn *ws.Conn) ([]dia.Pair, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil, errors.New(s.exchangeName + ": Connect on closed scraper")
+	}
+	s.conn = conn
+	// diffs missed while disconnected are recovered from new snapshots
+	if s.books != nil {
+		s.books.reset()
+	}
+	pairs := make([]dia.Pair, 0, len(s.pairScrapers))
+	for _, ps := range s.pairScrapers {
+		pairs = append(pairs, ps.pair)
+	}
+	return pairs, nil
+}
+
+func (s *WebsocketScraper) disconnect() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.conn = nil
+}
+
+// heartbeat sends heartbeats on @conn until @done is closed. The connection is closed if a
+// heartbeat fails, which ends its session.
+func (s *WebsocketScraper) heartbeat(conn *ws.Conn, done chan nothing) {
+	ticker := time.NewTicker(s.options.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			var err error
+			if message := s.protocol.Heartbeat(); message != nil {
+				err = s.send(conn, message)
+			} else {
+				err = conn.WriteControl(ws.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			}
+			if err != nil {
+				log.Warnf("%s: heartbeat: %v", s.exchangeName, err)
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// send writes @messages to @conn.
+func (s *WebsocketScraper) send(conn *ws.Conn, messages ...interface{}) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	for _, message := range messages {
+		data, ok := message.([]byte)
+		if !ok {
+			var err error
+			if data, err = json.Marshal(message); err != nil {
+				return err
+			}
+		}
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteMessage(ws.TextMessage, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forward sends @t to the trades channel if its pair is scraped. An error is returned if s is
+// closed while waiting for the channel.
+func (s *WebsocketScraper) forward(t *dia.Trade) (bool, error) {
+	s.lock.RLock()
+	ps, ok := s.pairScrapers[t.Pair]
+	s.lock.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	if t.Symbol == "" {
+		t.Symbol = ps.pair.Symbol
+	}
+	if n, ok := s.protocol.(tradePairNormalizer); ok {
+		t.Pair = n.tradePair(ps.pair)
+	}
+	t.Source = s.exchangeName
+	select {
+	case s.chanTrades <- t:
+		return true, nil
+	case <-s.shutdown:
+		return false, errors.New(s.exchangeName + ": closed")
+	}
+}
+
+func (s *WebsocketScraper) cleanup(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err != nil {
+		s.error = err
+	}
+	close(s.shutdownDone)
+}
+
+// Close closes any existing API connections, as well as channels of
+// PairScrapers from calls to ScrapePair
+func (s *WebsocketScraper) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return errors.New(s.exchangeName + ": Already closed")
+	}
+	s.closed = true
+	close(s.shutdown)
+	// closing the connection ends the running session
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.lock.Unlock()
+
+	<-s.shutdownDone
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.error
+}
+
+// ScrapePair returns a PairScraper that can be used to get trades for a single pair from
+// this APIScraper. The pair is subscribed again after each reconnect until the PairScraper
+// is closed.
+func (s *WebsocketScraper) ScrapePair(pair dia.Pair) (PairScraper, error) {
+	s.lock.Lock()
+	if s.error != nil {
+		s.lock.Unlock()
+		return nil, s.error
+	}
+	if s.closed {
+		s.lock.Unlock()
+		return nil, errors.New(s.exchangeName + ": Call ScrapePair on closed scraper")
+	}
+	if ps, ok := s.pairScrapers[pair.ForeignName]; ok {
+		s.lock.Unlock()
+		return ps, nil
+	}
+	ps := &WebsocketPairScraper{
+		parent: s,
+		pair:   pair,
+	}
+	s.pairScrapers[pair.ForeignName] = ps
+	conn := s.conn
+	s.lock.Unlock()
+
+	// pairs added while disconnected are subscribed on connecting
+	if conn != nil {
+		messages, err := s.protocol.Subscribe([]dia.Pair{pair})
+		if err != nil {
+			return nil, err
+		}
+		if err := s.send(conn, messages...); err != nil {
+			log.Warnf("%s: subscribing to %s: %v", s.exchangeName, pair.ForeignName, err)
+		}
+	}
+	return ps, nil
+}
+
+// pairCount returns the number of pairs scraped by s.
+func (s *WebsocketScraper) pairCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.pairScrapers)
+}
+
+// scrapes returns true if s scrapes the pair with @foreignName.
+func (s *WebsocketScraper) scrapes(foreignName string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	_, ok := s.pairScrapers[foreignName]
+	return ok
+}
+
+// Channel returns a channel that can be used to receive trades
+func (s *WebsocketScraper) Channel() chan *dia.Trade {
+	return s.chanTrades
+}
+
+// WebsocketPairScraper implements PairScraper for the pairs of a WebsocketScraper
+type WebsocketPairScraper struct {
+	parent *WebsocketScraper
+	pair   dia.Pair
+	closed bool
+}
+
+// Close stops listening for trades of the pair associated with ps
+func (ps *WebsocketPairScraper) Close() error {
+	s := ps.parent
+	s.lock.Lock()
+	if ps.closed {
+		s.lock.Unlock()
+		return errors.New(s.exchangeName + ": PairScraper already closed")
+	}
+	ps.closed = true
+	delete(s.pairScrapers, ps.pair.ForeignName)
+	conn := s.conn
+	s.lock.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	messages, err := s.protocol.Unsubscribe(ps.pair)
+	if err != nil {
+		return err
+	}
+	return s.send(conn, messages...)
+}
+
+// stopsPair marks that Close unsubscribes the pair of ps, see pairStopper.
+func (ps *WebsocketPairScraper) stopsPair() {}
+
+// Error returns an error when the channel Channel() is closed
+// and nil otherwise
+func (ps *WebsocketPairScraper) Error() error {
+	s := ps.parent
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.error
+}
+
+// Pair returns the pair this scraper is subscribed to
+func (ps *WebsocketPairScraper) Pair() dia.Pair {
+	return ps.pair
+}
+
+// backoff doubles the delay between @min and @max on each call of next.
+type backoff struct {
+	min   time.Duration
+	max   time.Duration
+	delay time.Duration
+}
+
+func (b *backoff) next() time.Duration {
+	if b.delay == 0 {
+		b.delay = b.min
+	} else if b.delay *= 2; b.delay > b.max {
+		b.delay = b.max
+	}
+	return b.delay
+}
+
+func (b *backoff) reset() {
+	b.delay = 0
+}
+
+// sequenceTracker keeps the last sequence number of each stream. It is kept across reconnects,
+// so that messages missed while disconnected are detected as well.
+type sequenceTracker map[string]int64
+
+// check records @sequence of @stream and returns the number of messages missing before it.
+// Messages repeated by the exchange, e.g. the last trade sent on subscribing, are ignored.
+func (st sequenceTracker) check(stream string, sequence int64) int64 {
+	if sequence == 0 {
+		return 0
+	}
+	last, ok := st[stream]
+	if ok && sequence <= last {
+		return 0
+	}
+	st[stream] = sequence
+	if !ok {
+		return 0
+	}
+	return sequence - last - 1
+}
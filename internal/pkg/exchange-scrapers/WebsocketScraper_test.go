@@ -0,0 +1,140 @@
+package scrapers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	ws "github.com/gorilla/websocket"
+)
+
+// testProtocol speaks "subscribe:PAIR" and "PAIR,price,sequence" text messages.
+type testProtocol struct {
+	url string
+}
+
+func (p *testProtocol) URL() (string, error) {
+	return p.url, nil
+}
+
+func (p *testProtocol) Subscribe(pairs []dia.Pair) ([]interface{}, error) {
+	var messages []interface{}
+	for _, pair := range pairs {
+		messages = append(messages, []byte("subscribe:"+pair.ForeignName))
+	}
+	return messages, nil
+}
+
+func (p *testProtocol) Unsubscribe(pair dia.Pair) ([]interface{}, error) {
+	return []interface{}{[]byte("unsubscribe:" + pair.ForeignName)}, nil
+}
+
+func (p *testProtocol) Parse(messageType int, data []byte) (WebsocketMessage, error) {
+	fields := strings.Split(string(data), ",")
+	if len(fields) != 3 {
+		return WebsocketMessage{}, errors.New("invalid message")
+	}
+	price, _ := strconv.ParseFloat(fields[1], 64)
+	sequence, _ := strconv.ParseInt(fields[2], 10, 64)
+	return WebsocketMessage{
+		Trades:   []*dia.Trade{{Pair: fields[0], Price: price}},
+		Stream:   fields[0],
+		Sequence: sequence,
+	}, nil
+}
+
+func (p *testProtocol) Heartbeat() interface{} {
+	return nil
+}
+
+func TestWebsocketScraperReconnect(t *testing.T) {
+	subscriptions := make(chan string, 10)
+	var upgrader ws.Upgrader
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n := int(atomic.AddInt32(&connections, 1))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		subscriptions <- string(data)
+		// the first connection drops after one trade
+		conn.WriteMessage(ws.TextMessage, []byte("BTC_USD,"+strconv.Itoa(n)+","+strconv.Itoa(n)))
+		if n == 1 {
+			return
+		}
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	p := &testProtocol{url: "ws" + strings.TrimPrefix(server.URL, "http")}
+	s := NewWebsocketScraper(dia.Exchange{Name: "Test"}, p, WebsocketOptions{MinBackoff: 10 * time.Millisecond})
+	if _, err := s.ScrapePair(dia.Pair{Symbol: "BTC", ForeignName: "BTC_USD"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		select {
+		case trade := <-s.Channel():
+			if trade.Price != float64(i) || trade.Symbol != "BTC" || trade.Source != "Test" {
+				t.Errorf("Trade %d was incorrect, got: %v.", i, trade)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Trade %d was not received.", i)
+		}
+		if subscription := <-subscriptions; subscription != "subscribe:BTC_USD" {
+			t.Errorf("Subscription %d was incorrect, got: %s, want: subscribe:BTC_USD.", i, subscription)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := backoff{min: time.Second, max: 5 * time.Second}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if delay := b.next(); delay != want {
+			t.Errorf("Delay %d was incorrect, got: %v, want: %v.", i, delay, want)
+		}
+	}
+	b.reset()
+	if delay := b.next(); delay != time.Second {
+		t.Errorf("Delay after reset was incorrect, got: %v, want: %v.", delay, time.Second)
+	}
+}
+
+func TestSequenceTracker(t *testing.T) {
+	st := make(sequenceTracker)
+	tables := []struct {
+		stream   string
+		sequence int64
+		missing  int64
+	}{
+		{"BTC-USD", 10, 0},
+		{"BTC-USD", 11, 0},
+		{"ETH-USD", 3, 0},
+		{"BTC-USD", 14, 2},
+		{"BTC-USD", 14, 0},
+		{"BTC-USD", 12, 0},
+		{"BTC-USD", 15, 0},
+		{"BTC-USD", 0, 0},
+		{"ETH-USD", 5, 1},
+	}
+	for i, table := range tables {
+		if missing := st.check(table.stream, table.sequence); missing != table.missing {
+			t.Errorf("Missing messages %d were incorrect, got: %d, want: %d.", i, missing, table.missing)
+		}
+	}
+}
@@ -9,12 +9,27 @@ import (
 	models "github.com/diadata-org/diadata/pkg/model"
 )
 
-// Filter defines a filter's methods processing trades from the tradesBlockService
+// Filter defines a filter's methods processing trades from the tradesBlockService.
+// Filters implemented outside of this package are made available through RegisterFilter.
 type Filter interface {
-	compute(trade dia.Trade)
-	finalCompute(t time.Time) float64
-	filterPointForBlock() *dia.FilterPoint
-	save(ds models.Datastore) error
+	// Compute processes a trade of the current block.
+	Compute(trade dia.Trade)
+	// FinalCompute computes the filter value at the end @t of the current block.
+	FinalCompute(t time.Time) float64
+	// FilterPointForBlock returns the filter point to be published in the filters block, if any.
+	FilterPointForBlock() *dia.FilterPoint
+	// Save writes the filter value to the datastore.
+	Save(ds models.Datastore) error
+}
+
+// StatefulFilter is a Filter whose internal state can be snapshotted, so that it continues
+// seamlessly after a restart of the filtersBlockService.
+type StatefulFilter interface {
+	Filter
+	// State returns a snapshot of the internal state of the filter.
+	State() FilterState
+	// Restore sets the internal state of the filter to @state.
+	Restore(state FilterState)
 }
 
 // RemoveOutliers Cleans a data set it accordance to the acceptable range within interquartile range.
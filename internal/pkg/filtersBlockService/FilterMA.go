@@ -30,7 +30,7 @@ func NewFilterMA(symbol string, exchange string, currentTime time.Time, param in
 	}
 	return s
 }
-func (s *FilterMA) finalCompute(t time.Time) float64 {
+func (s *FilterMA) FinalCompute(t time.Time) float64 {
 	if s.lastTrade == nil {
 		return 0.0
 	} else {
@@ -49,7 +49,7 @@ func (s *FilterMA) finalCompute(t time.Time) float64 {
 	return s.value
 }
 
-func (s *FilterMA) filterPointForBlock() *dia.FilterPoint {
+func (s *FilterMA) FilterPointForBlock() *dia.FilterPoint {
 	if s.exchange != "" {
 		return nil
 	} else {
@@ -84,7 +84,7 @@ func (s *FilterMA) fill(t time.Time, price float64) {
 	s.currentTime = t
 }
 
-func (s *FilterMA) compute(trade dia.Trade) {
+func (s *FilterMA) Compute(trade dia.Trade) {
 	s.modified = true
 	if s.lastTrade != nil {
 		if trade.Time.Before(s.currentTime) {
@@ -98,10 +98,20 @@ func (s *FilterMA) compute(trade dia.Trade) {
 	s.lastTrade = &trade
 }
 
-func (s *FilterMA) save(ds models.Datastore) error {
+// Save stores the value of the filter. Only the MA120 is written to the price ZSET and, over
+// all exchanges, published as price in USD. MAs of other windows are stored as filters.
+func (s *FilterMA) Save(ds models.Datastore) error {
 	log.Infof("save called on symbol %s on exchange %s", s.symbol, s.exchange)
 	if s.modified {
 		s.modified = false
+		name := "MA" + strconv.Itoa(s.param)
+		if name != dia.FilterKing {
+			err := ds.SetFilter(name, s.symbol, s.exchange, s.value, s.currentTime)
+			if err != nil {
+				log.Errorln("FilterMA: Error:", err)
+			}
+			return err
+		}
 		err := ds.SetPriceZSET(s.symbol, s.exchange, s.value, s.currentTime)
 		if err != nil {
 			log.Errorln("FilterMA: Error:", err)
@@ -117,3 +127,22 @@ func (s *FilterMA) save(ds models.Datastore) error {
 		return nil
 	}
 }
+
+func (s *FilterMA) State() FilterState {
+	return FilterState{
+		Name:           "MA" + strconv.Itoa(s.param),
+		CurrentTime:    s.currentTime,
+		PreviousPrices: append([]float64{}, s.previousPrices...),
+		LastTrade:      s.lastTrade,
+		Value:          s.value,
+		Modified:       s.modified,
+	}
+}
+
+func (s *FilterMA) Restore(state FilterState) {
+	s.currentTime = state.CurrentTime
+	s.previousPrices = append([]float64{}, state.PreviousPrices...)
+	s.lastTrade = state.LastTrade
+	s.value = state.Value
+	s.modified = state.Modified
+}
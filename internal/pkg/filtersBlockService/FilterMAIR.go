@@ -21,6 +21,7 @@ type FilterMAIR struct {
 	value          float64
 	filterName     string
 	modified       bool
+	outlierRule    OutlierRule
 }
 
 //NewFilterMAIR creates a FilterMAIR
@@ -32,6 +33,7 @@ func NewFilterMAIR(symbol string, exchange string, currentTime time.Time, memory
 		currentTime:    currentTime,
 		memory:         memory,
 		filterName:     "MAIR" + strconv.Itoa(memory),
+		outlierRule:    removeOutliers,
 	}
 	return s
 }
@@ -43,18 +45,18 @@ func (s *FilterMAIR) processDataPoint(price float64) {
 	}
 	s.previousPrices = append([]float64{price}, s.previousPrices...)
 }
-func (s *FilterMAIR) finalCompute(t time.Time) float64 {
+func (s *FilterMAIR) FinalCompute(t time.Time) float64 {
 	if s.lastTrade == nil {
 		return 0.0
 	}
 	// Add the last trade again to compensate for the delay since measurement to EOB
 	// adopted behaviour from FilterMA
 	s.processDataPoint(s.lastTrade.EstimatedUSDPrice)
-	cleanPrices := removeOutliers(s.previousPrices)
+	cleanPrices := s.outlierRule(s.previousPrices)
 	s.value = computeMean(cleanPrices)
 	return s.value
 }
-func (s *FilterMAIR) filterPointForBlock() *dia.FilterPoint {
+func (s *FilterMAIR) FilterPointForBlock() *dia.FilterPoint {
 	if s.exchange != "" || s.filterName != dia.FilterKing {
 		return nil
 	}
@@ -83,7 +85,7 @@ func (s *FilterMAIR) fill(t time.Time, price float64) {
 	}
 	s.currentTime = t
 }
-func (s *FilterMAIR) compute(trade dia.Trade) {
+func (s *FilterMAIR) Compute(trade dia.Trade) {
 	s.modified = true
 	if s.lastTrade != nil {
 		if trade.Time.Before(s.currentTime) {
@@ -95,7 +97,7 @@ func (s *FilterMAIR) compute(trade dia.Trade) {
 	s.lastTrade = &trade
 }
 
-func (s *FilterMAIR) save(ds models.Datastore) error {
+func (s *FilterMAIR) Save(ds models.Datastore) error {
 	if s.modified {
 		s.modified = false
 		err := ds.SetFilter(s.filterName, s.symbol, s.exchange, s.value, s.currentTime)
@@ -107,3 +109,22 @@ func (s *FilterMAIR) save(ds models.Datastore) error {
 		return nil
 	}
 }
+
+func (s *FilterMAIR) State() FilterState {
+	return FilterState{
+		Name:           s.filterName,
+		CurrentTime:    s.currentTime,
+		PreviousPrices: append([]float64{}, s.previousPrices...),
+		LastTrade:      s.lastTrade,
+		Value:          s.value,
+		Modified:       s.modified,
+	}
+}
+
+func (s *FilterMAIR) Restore(state FilterState) {
+	s.currentTime = state.CurrentTime
+	s.previousPrices = append([]float64{}, state.PreviousPrices...)
+	s.lastTrade = state.LastTrade
+	s.value = state.Value
+	s.modified = state.Modified
+}
@@ -2,6 +2,7 @@ package filters
 
 import (
 	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
 	"testing"
 	"time"
 )
@@ -17,12 +18,12 @@ func TestFilterMa(t *testing.T) {
 	p := firstPrice
 	i := 0
 	for i <= steps {
-		f.compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
+		f.Compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
 		d = d.Add(time.Second)
 		i += 1
 	}
-	f.finalCompute(d)
-	v := f.filterPointForBlock()
+	f.FinalCompute(d)
+	v := f.FilterPointForBlock()
 	if v.Value != p {
 		t.Errorf("error should be stable %v", v)
 	}
@@ -30,13 +31,13 @@ func TestFilterMa(t *testing.T) {
 	priceIncrements := 1.0
 	i = 0
 	for i <= steps {
-		f.compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
+		f.Compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
 		p = p + priceIncrements
 		d = d.Add(time.Second)
 		i += 1
 	}
-	f.finalCompute(d)
-	v = f.filterPointForBlock()
+	f.FinalCompute(d)
+	v = f.FilterPointForBlock()
 	if v.Value != 53.25 { //TODO formulas
 		t.Errorf("error should be, %v", v)
 	}
@@ -53,12 +54,12 @@ func TestFilterMa2(t *testing.T) {
 	p := firstPrice
 	i := 0
 	for i <= steps {
-		f.compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
+		f.Compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
 		d = d.Add(time.Second)
 		d = d.Add(time.Second)
 		i += 1
 	}
-	v := f.finalCompute(d)
+	v := f.FinalCompute(d)
 	if v != p {
 		t.Errorf("error should be stable %v", v)
 	}
@@ -66,13 +67,13 @@ func TestFilterMa2(t *testing.T) {
 	priceIncrements := 1.0
 	i = 0
 	for i <= steps {
-		f.compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
+		f.Compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
 		p = p + priceIncrements
 		d = d.Add(time.Second)
 		d = d.Add(time.Second)
 		i += 1
 	}
-	v = f.finalCompute(d)
+	v = f.FinalCompute(d)
 	if v != 56.4 { //TODO formulas
 		t.Errorf("error shouldnt be 57.0 %v", v)
 	}
@@ -90,12 +91,41 @@ func TestFilterMaIgnore(t *testing.T) {
 	p := firstPrice
 	priceIncrements := 1.0
 	for i := 0; i <= steps; i++ {
-		f.compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
+		f.Compute(dia.Trade{EstimatedUSDPrice: p, Time: d})
 		d = d.Add(-time.Second)
 		p += priceIncrements
 	}
-	v := f.finalCompute(d)
+	v := f.FinalCompute(d)
 	if v != firstPrice {
 		t.Errorf("error should be initial value:%f got:%f", firstPrice, v)
 	}
 }
+
+func TestFilterMaSave(t *testing.T) {
+	ds := models.NewMemoryDataStore()
+	d := time.Date(2016, time.August, 15, 0, 0, 0, 0, time.UTC)
+
+	ma60 := NewFilterMA("XRP", "", d, 60)
+	ma60.Compute(dia.Trade{EstimatedUSDPrice: 60, Time: d})
+	ma60.FinalCompute(d.Add(time.Second))
+	if err := ma60.Save(ds); err != nil {
+		t.Fatal(err)
+	}
+	if price, err := ds.GetPriceUSD("XRP"); err == nil {
+		t.Errorf("MA60 published price %v.", price)
+	}
+	points, err := ds.GetFilterPoints("MA60", "", "XRP", "", d, d.Add(time.Minute))
+	if err != nil || len(points.DataPoints[0].Series[0].Values) != 1 {
+		t.Errorf("MA60 was not stored as filter, got: %v, %v.", points, err)
+	}
+
+	ma120 := NewFilterMA("XRP", "", d, 120)
+	ma120.Compute(dia.Trade{EstimatedUSDPrice: 120, Time: d})
+	ma120.FinalCompute(d.Add(time.Second))
+	if err := ma120.Save(ds); err != nil {
+		t.Fatal(err)
+	}
+	if price, err := ds.GetPriceUSD("XRP"); err != nil || price != 120 {
+		t.Errorf("Price of MA120 was incorrect, got: %v, %v, want: %v.", price, err, 120)
+	}
+}
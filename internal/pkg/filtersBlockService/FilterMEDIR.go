@@ -21,6 +21,7 @@ type FilterMEDIR struct {
 	value          float64
 	filterName     string
 	modified       bool
+	outlierRule    OutlierRule
 }
 
 //NewFilterMEDIR creates a FilterMEDIR
@@ -32,6 +33,7 @@ func NewFilterMEDIR(symbol string, exchange string, currentTime time.Time, memor
 		currentTime:    currentTime,
 		memory:         memory,
 		filterName:     "MEDIR" + strconv.Itoa(memory),
+		outlierRule:    removeOutliers,
 	}
 	return s
 }
@@ -43,16 +45,16 @@ func (s *FilterMEDIR) processDataPoint(price float64) {
 	}
 	s.previousPrices = append([]float64{price}, s.previousPrices...)
 }
-func (s *FilterMEDIR) finalCompute(t time.Time) float64 {
+func (s *FilterMEDIR) FinalCompute(t time.Time) float64 {
 	if s.lastTrade == nil {
 		return 0.0
 	}
-	cleanPrices := removeOutliers(s.previousPrices)
+	cleanPrices := s.outlierRule(s.previousPrices)
 	s.value = computeMedian(cleanPrices)
 	s.previousPrices = []float64{}
 	return s.value
 }
-func (s *FilterMEDIR) filterPointForBlock() *dia.FilterPoint {
+func (s *FilterMEDIR) FilterPointForBlock() *dia.FilterPoint {
 	if s.exchange != "" || s.filterName != dia.FilterKing {
 		return nil
 	}
@@ -64,7 +66,7 @@ func (s *FilterMEDIR) filterPointForBlock() *dia.FilterPoint {
 	}
 }
 
-func (s *FilterMEDIR) compute(trade dia.Trade) {
+func (s *FilterMEDIR) Compute(trade dia.Trade) {
 	s.modified = true
 	if s.lastTrade != nil {
 		if trade.Time.Before(s.currentTime) {
@@ -77,7 +79,7 @@ func (s *FilterMEDIR) compute(trade dia.Trade) {
 	s.lastTrade = &trade
 }
 
-func (s *FilterMEDIR) save(ds models.Datastore) error {
+func (s *FilterMEDIR) Save(ds models.Datastore) error {
 	if s.modified {
 		s.modified = false
 		err := ds.SetFilter(s.filterName, s.symbol, s.exchange, s.value, s.currentTime)
@@ -89,3 +91,22 @@ func (s *FilterMEDIR) save(ds models.Datastore) error {
 		return nil
 	}
 }
+
+func (s *FilterMEDIR) State() FilterState {
+	return FilterState{
+		Name:           s.filterName,
+		CurrentTime:    s.currentTime,
+		PreviousPrices: append([]float64{}, s.previousPrices...),
+		LastTrade:      s.lastTrade,
+		Value:          s.value,
+		Modified:       s.modified,
+	}
+}
+
+func (s *FilterMEDIR) Restore(state FilterState) {
+	s.currentTime = state.CurrentTime
+	s.previousPrices = append([]float64{}, state.PreviousPrices...)
+	s.lastTrade = state.LastTrade
+	s.value = state.Value
+	s.modified = state.Modified
+}
@@ -23,15 +23,15 @@ func NewFilterTLT(symbol string, exchange string) *FilterTLT {
 	return s
 }
 
-func (s *FilterTLT) filterPointForBlock() *dia.FilterPoint {
+func (s *FilterTLT) FilterPointForBlock() *dia.FilterPoint {
 	return nil
 }
 
-func (s *FilterTLT) compute(trade dia.Trade) {
+func (s *FilterTLT) Compute(trade dia.Trade) {
 	s.lastTradeTime = trade.Time
 }
 
-func (s *FilterTLT) save(ds models.Datastore) error {
+func (s *FilterTLT) Save(ds models.Datastore) error {
 	err := ds.SetLastTradeTimeForExchange(s.symbol, s.exchange, s.lastTradeTime)
 	if err != nil {
 		log.Errorln("FilterTLT Error:", err)
@@ -39,6 +39,17 @@ func (s *FilterTLT) save(ds models.Datastore) error {
 	return err
 }
 
-func (s *FilterTLT) finalCompute(time time.Time) float64 {
+func (s *FilterTLT) FinalCompute(time time.Time) float64 {
 	return 0.0
 }
+
+func (s *FilterTLT) State() FilterState {
+	return FilterState{
+		Name:        "TLT",
+		CurrentTime: s.lastTradeTime,
+	}
+}
+
+func (s *FilterTLT) Restore(state FilterState) {
+	s.lastTradeTime = state.CurrentTime
+}
@@ -31,25 +31,40 @@ func NewFilterVOL(symbol string, exchange string, memory int) *FilterVOL {
 	return s
 }
 
-func (s *FilterVOL) finalCompute(time time.Time) float64 {
+func (s *FilterVOL) FinalCompute(time time.Time) float64 {
 	s.value = s.volumeUSD
 	s.volumeUSD = 0.0
 	return s.value
 }
 
-func (s *FilterVOL) filterPointForBlock() *dia.FilterPoint {
+func (s *FilterVOL) FilterPointForBlock() *dia.FilterPoint {
 	return nil
 }
 
-func (s *FilterVOL) compute(trade dia.Trade) {
+func (s *FilterVOL) Compute(trade dia.Trade) {
 	s.volumeUSD += trade.EstimatedUSDPrice * math.Abs(trade.Volume)
 	s.currentTime = trade.Time
 }
 
-func (s *FilterVOL) save(ds models.Datastore) error {
+func (s *FilterVOL) Save(ds models.Datastore) error {
 	err := ds.SetFilter(s.filterName, s.symbol, s.exchange, s.value, s.currentTime)
 	if err != nil {
 		log.Errorln("FilterVOL Error:", err)
 	}
 	return err
 }
+
+func (s *FilterVOL) State() FilterState {
+	return FilterState{
+		Name:        s.filterName,
+		CurrentTime: s.currentTime,
+		Value:       s.value,
+		VolumeUSD:   s.volumeUSD,
+	}
+}
+
+func (s *FilterVOL) Restore(state FilterState) {
+	s.currentTime = state.CurrentTime
+	s.value = state.Value
+	s.volumeUSD = state.VolumeUSD
+}
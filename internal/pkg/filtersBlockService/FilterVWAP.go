@@ -0,0 +1,206 @@
+// FilterVWAP implements a volume weighted average price over the trades of a time window.
+// FilterVWAPIR additionally eliminates price outliers using interquartile range before weighting.
+// FilterVWAPD additionally weights the trades of each pair by the order book depth of the pair.
+// see: https://en.wikipedia.org/wiki/Volume-weighted_average_price
+package filters
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// FilterVWAP contains the configuration parameters of the filter
+type FilterVWAP struct {
+	symbol      string
+	exchange    string
+	currentTime time.Time
+	// trades are the trades of the last @memory seconds
+	trades     []dia.Trade
+	memory     int
+	value      float64
+	filterName string
+	modified   bool
+	// outlierRule is nil for a plain VWAP
+	outlierRule OutlierRule
+	// depths are nil unless trades are weighted by order book depth
+	depths *depthWeights
+}
+
+// NewFilterVWAP creates a FilterVWAP over a window of @memory seconds
+func NewFilterVWAP(symbol string, exchange string, currentTime time.Time, memory int) *FilterVWAP {
+	s := &FilterVWAP{
+		symbol:      symbol,
+		exchange:    exchange,
+		currentTime: currentTime,
+		trades:      []dia.Trade{},
+		memory:      memory,
+		filterName:  "VWAP" + strconv.Itoa(memory),
+	}
+	return s
+}
+
+// NewFilterVWAPIR creates a FilterVWAP over a window of @memory seconds, which removes outliers
+// using interquartile range
+func NewFilterVWAPIR(symbol string, exchange string, currentTime time.Time, memory int) *FilterVWAP {
+	s := NewFilterVWAP(symbol, exchange, currentTime, memory)
+	s.filterName = "VWAPIR" + strconv.Itoa(memory)
+	s.outlierRule = removeOutliers
+	return s
+}
+
+// NewFilterVWAPD creates a FilterVWAP over a window of @memory seconds, which weights the volume
+// of trades by the order book depth of their pair. Pairs without depth metrics are ignored,
+// unless no pair of the symbol or no pair traded in the window has metrics. The depths are empty until set by the
+// FiltersBlockService.
+func NewFilterVWAPD(symbol string, exchange string, currentTime time.Time, memory int) *FilterVWAP {
+	s := NewFilterVWAP(symbol, exchange, currentTime, memory)
+	s.filterName = "VWAPD" + strconv.Itoa(memory)
+	s.depths = newDepthWeights(nil)
+	return s
+}
+
+// setDepths makes depth weighted filters read their weights from @depths.
+func (s *FilterVWAP) setDepths(depths *depthWeights) {
+	if s.depths != nil {
+		s.depths = depths
+	}
+}
+
+func (s *FilterVWAP) Compute(trade dia.Trade) {
+	if trade.Time.Before(s.currentTime.Add(-time.Duration(s.memory) * time.Second)) {
+		log.Errorln("FilterVWAP: Ignoring Trade out of window ", s.currentTime, trade.Time)
+		return
+	}
+	s.modified = true
+	s.trades = append(s.trades, trade)
+	if trade.Time.After(s.currentTime) {
+		s.currentTime = trade.Time
+	}
+}
+
+// FinalCompute computes the VWAP of the trades in the window ending at @t. If there are
+// no trades in the window, the previous value is kept.
+func (s *FilterVWAP) FinalCompute(t time.Time) float64 {
+	windowStart := t.Add(-time.Duration(s.memory) * time.Second)
+	trades := s.trades[:0]
+	for _, trade := range s.trades {
+		if trade.Time.After(windowStart) {
+			trades = append(trades, trade)
+		}
+	}
+	s.trades = trades
+	s.currentTime = t
+	if len(s.trades) == 0 {
+		return s.value
+	}
+	// The value changes as trades leave the window, even for blocks without trades
+	s.modified = true
+
+	lowerBound, upperBound := math.Inf(-1), math.Inf(1)
+	if s.outlierRule != nil {
+		prices := make([]float64, len(s.trades))
+		for i, trade := range s.trades {
+			prices[i] = trade.EstimatedUSDPrice
+		}
+		sort.Float64s(prices)
+		cleanPrices := s.outlierRule(prices)
+		if len(cleanPrices) > 0 {
+			lowerBound, upperBound = cleanPrices[0], cleanPrices[len(cleanPrices)-1]
+		}
+	}
+
+	var depths map[string]float64
+	if s.depths != nil {
+		depths = s.depths.get(s.symbol, t)
+	}
+
+	value, ok := weightedAverage(s.trades, lowerBound, upperBound, depths)
+	if !ok && len(depths) > 0 {
+		// None of the pairs traded in the window has metrics
+		value, ok = weightedAverage(s.trades, lowerBound, upperBound, nil)
+	}
+	if ok {
+		s.value = value
+	}
+	return s.value
+}
+
+// weightedAverage returns the average price of the @trades within the bounds, weighted by
+// volume and, unless @depths is empty, by the depth of their pair. Trades of pairs without
+// depth are ignored. ok is false if no trade is weighted.
+func weightedAverage(trades []dia.Trade, lowerBound, upperBound float64, depths map[string]float64) (value float64, ok bool) {
+	var priceVolume, volume float64
+	var cleanPrices []float64
+	for _, trade := range trades {
+		if trade.EstimatedUSDPrice < lowerBound || trade.EstimatedUSDPrice > upperBound {
+			continue
+		}
+		weight := math.Abs(trade.Volume)
+		if len(depths) > 0 {
+			depth, ok := depths[string(trade.PartitionKey())]
+			if !ok {
+				continue
+			}
+			weight *= depth
+		}
+		priceVolume += trade.EstimatedUSDPrice * weight
+		volume += weight
+		cleanPrices = append(cleanPrices, trade.EstimatedUSDPrice)
+	}
+	if len(cleanPrices) == 0 {
+		return 0, false
+	}
+	if volume > 0 {
+		return priceVolume / volume, true
+	}
+	// Without volume information all trades are weighted equally
+	return computeMean(cleanPrices), true
+}
+
+func (s *FilterVWAP) FilterPointForBlock() *dia.FilterPoint {
+	if s.value == 0 {
+		return nil
+	}
+	return &dia.FilterPoint{
+		Symbol:   s.symbol,
+		Exchange: s.exchange,
+		Value:    s.value,
+		Name:     s.filterName,
+		Time:     s.currentTime,
+	}
+}
+
+func (s *FilterVWAP) Save(ds models.Datastore) error {
+	if s.modified {
+		s.modified = false
+		err := ds.SetFilter(s.filterName, s.symbol, s.exchange, s.value, s.currentTime)
+		if err != nil {
+			log.Errorln("FilterVWAP: Error:", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FilterVWAP) State() FilterState {
+	return FilterState{
+		Name:        s.filterName,
+		CurrentTime: s.currentTime,
+		Trades:      append([]dia.Trade{}, s.trades...),
+		Value:       s.value,
+		Modified:    s.modified,
+	}
+}
+
+func (s *FilterVWAP) Restore(state FilterState) {
+	s.currentTime = state.CurrentTime
+	s.trades = append([]dia.Trade{}, state.Trades...)
+	s.value = state.Value
+	s.modified = state.Modified
+}
@@ -0,0 +1,134 @@
+package filters
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+)
+
+func TestFilterVWAP(t *testing.T) {
+	d := time.Date(2016, time.August, 15, 0, 0, 0, 0, time.UTC)
+	trades := []dia.Trade{
+		{EstimatedUSDPrice: 10, Volume: 1, Time: d.Add(10 * time.Second)},
+		{EstimatedUSDPrice: 11, Volume: -3, Time: d.Add(20 * time.Second)},
+		{EstimatedUSDPrice: 12, Volume: 2, Time: d.Add(30 * time.Second)},
+		{EstimatedUSDPrice: 10, Volume: 1, Time: d.Add(40 * time.Second)},
+		{EstimatedUSDPrice: 11, Volume: 1, Time: d.Add(50 * time.Second)},
+		{EstimatedUSDPrice: 12, Volume: 1, Time: d.Add(60 * time.Second)},
+		{EstimatedUSDPrice: 100, Volume: 0.5, Time: d.Add(70 * time.Second)},
+	}
+
+	cases := []struct {
+		filter *FilterVWAP
+		name   string
+		value  float64
+	}{
+		{NewFilterVWAP("XRP", "", d, 120), "VWAP120", (10 + 33 + 24 + 10 + 11 + 12 + 50) / 9.5},
+		{NewFilterVWAPIR("XRP", "", d, 120), "VWAPIR120", (10 + 33 + 24 + 10 + 11 + 12) / 9.0},
+	}
+	for _, c := range cases {
+		for _, trade := range trades {
+			c.filter.Compute(trade)
+		}
+		v := c.filter.FinalCompute(d.Add(120 * time.Second))
+		if math.Abs(v-c.value) > 1e-9 {
+			t.Errorf("%s was incorrect, got: %v, want: %v.", c.name, v, c.value)
+		}
+		fp := c.filter.FilterPointForBlock()
+		if fp == nil || fp.Name != c.name || fp.Value != v {
+			t.Errorf("Filter point of %s was incorrect, got: %v.", c.name, fp)
+		}
+	}
+}
+
+func TestFilterVWAPWindow(t *testing.T) {
+	d := time.Date(2016, time.August, 15, 0, 0, 0, 0, time.UTC)
+	f := NewFilterVWAP("XRP", dia.BinanceExchange, d, 60)
+	f.Compute(dia.Trade{EstimatedUSDPrice: 10, Volume: 1, Time: d.Add(10 * time.Second)})
+	f.Compute(dia.Trade{EstimatedUSDPrice: 20, Volume: 1, Time: d.Add(50 * time.Second)})
+
+	if v := f.FinalCompute(d.Add(60 * time.Second)); v != 15 {
+		t.Errorf("VWAP was incorrect, got: %v, want: 15.", v)
+	}
+	// the first trade leaves the window
+	if v := f.FinalCompute(d.Add(90 * time.Second)); v != 20 {
+		t.Errorf("VWAP was incorrect, got: %v, want: 20.", v)
+	}
+	// the value is kept once the window is empty
+	if v := f.FinalCompute(d.Add(180 * time.Second)); v != 20 {
+		t.Errorf("VWAP was incorrect, got: %v, want: 20.", v)
+	}
+	if fp := f.FilterPointForBlock(); fp == nil || fp.Exchange != dia.BinanceExchange {
+		t.Errorf("Filter point was incorrect, got: %v.", fp)
+	}
+}
+
+func TestFilterVWAPD(t *testing.T) {
+	d := time.Date(2016, time.August, 15, 0, 0, 0, 0, time.UTC)
+	ds := models.NewMemoryDataStore()
+	for _, m := range []dia.OrderBookMetrics{
+		{Symbol: "BTC", Exchange: dia.BinanceExchange, Pair: "BTCUSDT", BidDepthUSD: 2, AskDepthUSD: 1, Time: d.Add(-2 * time.Hour)},
+		{Symbol: "BTC", Exchange: dia.BinanceExchange, Pair: "BTCUSDT", BidDepthUSD: 2, AskDepthUSD: 2, Time: d.Add(-time.Minute)},
+		{Symbol: "BTC", Exchange: dia.KrakenExchange, Pair: "XBTUSD", BidDepthUSD: 1, AskDepthUSD: 0, Time: d.Add(-time.Minute)},
+	} {
+		ds.SaveOrderBookMetricsInflux(m)
+	}
+
+	f := NewFilterVWAPD("BTC", "", d, 120)
+	f.setDepths(newDepthWeights(ds))
+	f.Compute(dia.Trade{Source: dia.BinanceExchange, Pair: "BTCUSDT", EstimatedUSDPrice: 10, Volume: 1, Time: d.Add(10 * time.Second)})
+	f.Compute(dia.Trade{Source: dia.KrakenExchange, Pair: "XBTUSD", EstimatedUSDPrice: 20, Volume: -2, Time: d.Add(20 * time.Second)})
+	// pairs without metrics are ignored
+	f.Compute(dia.Trade{Source: dia.BitfinexExchange, Pair: "BTCUSD", EstimatedUSDPrice: 100, Volume: 1, Time: d.Add(30 * time.Second)})
+
+	// weights are 1*4 and 2*1
+	want := (10*4 + 20*2) / 6.0
+	if v := f.FinalCompute(d.Add(60 * time.Second)); math.Abs(v-want) > 1e-9 {
+		t.Errorf("VWAPD was incorrect, got: %v, want: %v.", v, want)
+	}
+	if fp := f.FilterPointForBlock(); fp == nil || fp.Name != "VWAPD120" {
+		t.Errorf("Filter point was incorrect, got: %v.", fp)
+	}
+
+	// without metrics trades are weighted by volume only
+	f = NewFilterVWAPD("ETH", "", d, 120)
+	f.setDepths(newDepthWeights(ds))
+	f.Compute(dia.Trade{Source: dia.BinanceExchange, Pair: "ETHUSDT", EstimatedUSDPrice: 10, Volume: 1, Time: d.Add(10 * time.Second)})
+	f.Compute(dia.Trade{Source: dia.KrakenExchange, Pair: "ETHUSD", EstimatedUSDPrice: 20, Volume: 3, Time: d.Add(20 * time.Second)})
+	if v := f.FinalCompute(d.Add(60 * time.Second)); v != 17.5 {
+		t.Errorf("VWAPD without metrics was incorrect, got: %v, want: 17.5.", v)
+	}
+
+	// if no pair traded in the window has metrics, trades are weighted by volume only
+	f = NewFilterVWAPD("BTC", "", d, 120)
+	f.setDepths(newDepthWeights(ds))
+	f.Compute(dia.Trade{Source: dia.BitfinexExchange, Pair: "BTCUSD", EstimatedUSDPrice: 10, Volume: 3, Time: d.Add(10 * time.Second)})
+	f.Compute(dia.Trade{Source: dia.BitfinexExchange, Pair: "BTCUSD", EstimatedUSDPrice: 30, Volume: -1, Time: d.Add(20 * time.Second)})
+	if v := f.FinalCompute(d.Add(60 * time.Second)); v != 15 {
+		t.Errorf("VWAPD without metrics in the window was incorrect, got: %v, want: 15.", v)
+	}
+	if fp := f.FilterPointForBlock(); fp == nil || fp.Value != 15 {
+		t.Errorf("Filter point was incorrect, got: %v.", fp)
+	}
+}
+
+func TestFiltersBlockServiceDepths(t *testing.T) {
+	config := FiltersConfig{Filters: []FilterConfig{{Type: "VWAPD", FilterParams: FilterParams{Window: 120}}}}
+	datastores := []models.Datastore{models.NewMemoryDataStore(), models.NewMemoryDataStore()}
+	var services []*FiltersBlockService
+	for _, ds := range datastores {
+		s := newFiltersBlockService(nil, ds, nil, config, time.Now)
+		s.createFilters("BTC", "", time.Now())
+		services = append(services, s)
+	}
+	// each service weights its filters by the depths of its own datastore
+	for i, s := range services {
+		f, ok := s.filters["BTC"][0].(*FilterVWAP)
+		if !ok || f.depths != s.depths || s.depths.datastore != datastores[i] {
+			t.Errorf("Depths of service %d were incorrect.", i)
+		}
+	}
+}
@@ -2,11 +2,13 @@ package filters
 
 import (
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/cnf/structhash"
 	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/signingHelper"
 	models "github.com/diadata-org/diadata/pkg/model"
 	log "github.com/sirupsen/logrus"
 )
@@ -17,10 +19,26 @@ const (
 
 type nothing struct{}
 
+// Clock returns the current time. Replays inject a clock following the replayed blocks,
+// so that filter values do not depend on the time of the replay.
+type Clock func() time.Time
+
+// filterKey is a symbol and exchange the filters are computed for.
+type filterKey struct {
+	symbol   string
+	exchange string
+}
+
+// tradesBlockAtOffset is a trades block along with its kafka offset, -1 if unknown.
+type tradesBlockAtOffset struct {
+	tradesBlock *dia.TradesBlock
+	offset      int64
+}
+
 type FiltersBlockService struct {
 	shutdown             chan nothing
 	shutdownDone         chan nothing
-	chanTradesBlock      chan *dia.TradesBlock
+	chanTradesBlock      chan tradesBlockAtOffset
 	chanFiltersBlock     chan *dia.FiltersBlock
 	errorLock            sync.RWMutex
 	error                error
@@ -28,34 +46,81 @@ type FiltersBlockService struct {
 	started              bool
 	currentTime          time.Time
 	filters              map[string][]Filter
+	filterKeys           []filterKey
 	lastLog              time.Time
 	calculationValues    []int
 	previousBlockFilters []dia.FilterPoint
-	datastore            models.Datastore
+	config               FiltersConfig
+	clock                Clock
+	persistState         bool
+	checkpoint           checkpoint
+	chainLock            sync.Mutex
+	// previousBlockHash is the hash of the last filters block, which the next block is chained to.
+	previousBlockHash string
+	// signer signs filters blocks. Blocks are published unsigned if nil.
+	signer    *signingHelper.Signer
+	datastore models.Datastore
+	// depths are the order book depths weighting the trades of depth weighted filters.
+	depths *depthWeights
 }
 
+// NewFiltersBlockService returns a FiltersBlockService computing the filters of DefaultFiltersConfig.
 func NewFiltersBlockService(previousBlockFilters []dia.FilterPoint, datastore models.Datastore, chanFiltersBlock chan *dia.FiltersBlock) *FiltersBlockService {
+	return NewFiltersBlockServiceWithConfig(previousBlockFilters, datastore, chanFiltersBlock, DefaultFiltersConfig())
+}
+
+// NewFiltersBlockServiceWithConfig returns a FiltersBlockService computing the filters of @config.
+func NewFiltersBlockServiceWithConfig(previousBlockFilters []dia.FilterPoint, datastore models.Datastore, chanFiltersBlock chan *dia.FiltersBlock, config FiltersConfig) *FiltersBlockService {
+	return NewFiltersBlockServiceWithClock(previousBlockFilters, datastore, chanFiltersBlock, config, time.Now)
+}
+
+// NewFiltersBlockServiceWithClock returns a FiltersBlockService computing the filters of @config,
+// which reads the current time from @clock.
+func NewFiltersBlockServiceWithClock(previousBlockFilters []dia.FilterPoint, datastore models.Datastore, chanFiltersBlock chan *dia.FiltersBlock, config FiltersConfig, clock Clock) *FiltersBlockService {
+	s := newFiltersBlockService(previousBlockFilters, datastore, chanFiltersBlock, config, clock)
+	go s.mainLoop()
+	return s
+}
+
+// newFiltersBlockService returns a FiltersBlockService without starting its main loop.
+func newFiltersBlockService(previousBlockFilters []dia.FilterPoint, datastore models.Datastore, chanFiltersBlock chan *dia.FiltersBlock, config FiltersConfig, clock Clock) *FiltersBlockService {
 	s := &FiltersBlockService{
 		shutdown:             make(chan nothing),
 		shutdownDone:         make(chan nothing),
-		chanTradesBlock:      make(chan *dia.TradesBlock),
+		chanTradesBlock:      make(chan tradesBlockAtOffset),
 		chanFiltersBlock:     chanFiltersBlock,
 		error:                nil,
 		started:              false,
 		filters:              make(map[string][]Filter),
-		lastLog:              time.Now(),
+		lastLog:              clock(),
 		calculationValues:    make([]int, 0),
 		previousBlockFilters: previousBlockFilters,
+		config:               config,
+		clock:                clock,
 		datastore:            datastore,
+		depths:               newDepthWeights(datastore),
 	}
 	s.calculationValues = append(s.calculationValues, dia.BlockSizeSeconds)
-
-	go s.mainLoop()
 	return s
 }
 
+// ChainTo chains the next filters block to the block with @previousBlockHash, e.g. the last
+// block published before a restart. Filters blocks are signed by @signer unless it is nil.
+func (s *FiltersBlockService) ChainTo(previousBlockHash string, signer *signingHelper.Signer) {
+	s.chainLock.Lock()
+	defer s.chainLock.Unlock()
+	s.previousBlockHash = previousBlockHash
+	s.signer = signer
+}
+
 func (s *FiltersBlockService) ProcessTradesBlock(tradesBlock *dia.TradesBlock) {
-	s.chanTradesBlock <- tradesBlock
+	s.ProcessTradesBlockAtOffset(tradesBlock, -1)
+}
+
+// ProcessTradesBlockAtOffset processes @tradesBlock read at kafka @offset. The offset is stored
+// along with the filters state of persistent services.
+func (s *FiltersBlockService) ProcessTradesBlockAtOffset(tradesBlock *dia.TradesBlock, offset int64) {
+	s.chanTradesBlock <- tradesBlockAtOffset{tradesBlock: tradesBlock, offset: offset}
 	log.Info("ProcessTradesBlock finito")
 }
 
@@ -79,26 +144,27 @@ func (s *FiltersBlockService) cleanup(err error) {
 	close(s.shutdownDone) // signal that shutdown is complete
 }
 
-func addMissingPoints(previousBlockFilters []dia.FilterPoint, newFilters []dia.FilterPoint) []dia.FilterPoint {
+func addMissingPoints(previousBlockFilters []dia.FilterPoint, newFilters []dia.FilterPoint, now time.Time) []dia.FilterPoint {
 	log.Debug("previousBlockFilters", previousBlockFilters)
 	log.Debug("newFilters:", newFilters)
 	missingPoints := 0
 	result := newFilters
 	newFiltersMap := make(map[string]*dia.FilterPoint)
 	for _, filter := range newFilters {
-		newFiltersMap[filter.Name+filter.Symbol] = &filter
+		newFiltersMap[filter.Name+filter.Symbol+filter.Exchange] = &filter
 	}
 
 	for _, filter := range previousBlockFilters {
 
-		d := time.Now().Sub(filter.Time)
+		d := now.Sub(filter.Time)
 		log.Info("filter:", filter, " age:", d)
 
 		if d > time.Hour*24 {
-			_, ok := newFiltersMap[filter.Name+filter.Symbol]
+			_, ok := newFiltersMap[filter.Name+filter.Symbol+filter.Exchange]
 			if !ok {
+				filter.CarriedForward = true
 				result = append(result, filter)
-				log.Debug("Adding", filter.Name+filter.Symbol)
+				log.Debug("Adding", filter.Name+filter.Symbol+filter.Exchange)
 				missingPoints++
 			}
 		} else {
@@ -115,48 +181,56 @@ func addMissingPoints(previousBlockFilters []dia.FilterPoint, newFilters []dia.F
 func (s *FiltersBlockService) createFilters(symbol string, exchange string, BeginTime time.Time) {
 	_, ok := s.filters[symbol+exchange]
 	if !ok {
-		s.filters[symbol+exchange] = []Filter{
-			// Prices are written into redis in MA filter
-			NewFilterMA(symbol, exchange, BeginTime, dia.BlockSizeSeconds),
-			NewFilterTLT(symbol, exchange),
-			NewFilterVOL(symbol, exchange, dia.BlockSizeSeconds),
-			NewFilterMAIR(symbol, exchange, BeginTime, dia.BlockSizeSeconds),
-			NewFilterMEDIR(symbol, exchange, BeginTime, dia.BlockSizeSeconds),
+		filters := s.config.createFilters(symbol, exchange, BeginTime)
+		for _, f := range filters {
+			if f, ok := f.(depthWeightedFilter); ok {
+				f.setDepths(s.depths)
+			}
 		}
+		s.filters[symbol+exchange] = filters
+		s.filterKeys = append(s.filterKeys, filterKey{symbol: symbol, exchange: exchange})
 	}
 }
 
 func (s *FiltersBlockService) computeFilters(t dia.Trade, key string) {
 	for _, f := range s.filters[key] {
-		f.compute(t)
+		f.Compute(t)
 	}
 }
 
 // processTradesBlock is the 'main' function in the sense that all mathematical
-// computations are done here.
-func (s *FiltersBlockService) processTradesBlock(tb *dia.TradesBlock) {
+// computations are done here. It returns the filters block computed from @tb.
+func (s *FiltersBlockService) processTradesBlock(tb *dia.TradesBlock) *dia.FiltersBlock {
 
 	log.Infoln("processTradesBlock starting")
 
+	// tradedKeys are the filter keys with trades in this block. Filters of all other
+	// keys carry their values forward from previous blocks.
+	tradedKeys := make(map[string]struct{})
 	for _, trade := range tb.TradesBlockData.Trades {
 		s.createFilters(trade.Symbol, "", tb.TradesBlockData.BeginTime)
 		s.createFilters(trade.Symbol, trade.Source, tb.TradesBlockData.BeginTime)
 		s.computeFilters(trade, trade.Symbol)
 		s.computeFilters(trade, trade.Symbol+trade.Source)
+		tradedKeys[trade.Symbol] = struct{}{}
+		tradedKeys[trade.Symbol+trade.Source] = struct{}{}
 	}
 
 	resultFilters := []dia.FilterPoint{}
-	for _, filters := range s.filters {
+	for key, filters := range s.filters {
+		_, traded := tradedKeys[key]
 		for _, f := range filters {
-			f.finalCompute(tb.TradesBlockData.EndTime)
-			fp := f.filterPointForBlock()
+			f.FinalCompute(tb.TradesBlockData.EndTime)
+			fp := f.FilterPointForBlock()
 			if fp != nil {
+				fp.CarriedForward = !traded
 				resultFilters = append(resultFilters, *fp)
 			}
 		}
 	}
 
-	resultFilters = addMissingPoints(s.previousBlockFilters, resultFilters)
+	resultFilters = addMissingPoints(s.previousBlockFilters, resultFilters, s.clock())
+	sortFilterPoints(resultFilters)
 
 	s.previousBlockFilters = resultFilters
 
@@ -170,12 +244,25 @@ func (s *FiltersBlockService) processTradesBlock(tb *dia.TradesBlock) {
 		},
 	}
 
+	s.chainLock.Lock()
+	fb.FiltersBlockData.PreviousBlockHash = s.previousBlockHash
 	hash, err := structhash.Hash(fb.FiltersBlockData, 1)
 	if err != nil {
 		log.Printf("error on hash")
 		hash = "hashError"
 	}
 	fb.BlockHash = hash
+	if s.signer != nil {
+		err = s.signer.SignFiltersBlock(fb)
+		if err != nil {
+			log.Errorln("error signing filters block", hash, err)
+		}
+	}
+	// Empty blocks are not published, so they must not become part of the chain
+	if len(resultFilters) != 0 {
+		s.previousBlockHash = hash
+	}
+	s.chainLock.Unlock()
 	log.Printf("Generating Filters block %v (size:%v)", hash, fb.FiltersBlockData.FiltersNumber)
 
 	if len(resultFilters) != 0 && s.chanFiltersBlock != nil {
@@ -183,10 +270,11 @@ func (s *FiltersBlockService) processTradesBlock(tb *dia.TradesBlock) {
 	}
 	for _, filters := range s.filters {
 		for _, f := range filters {
-			f.save(s.datastore)
+			f.Save(s.datastore)
 		}
 	}
 	s.datastore.Flush()
+	return fb
 	// c, err := s.datastore.GetCoins()
 	// if err == nil {
 	// for i, v := range c.Coins {
@@ -196,6 +284,20 @@ func (s *FiltersBlockService) processTradesBlock(tb *dia.TradesBlock) {
 	// }
 }
 
+// sortFilterPoints sorts @points by name, symbol and exchange, so that the hash of a
+// filters block does not depend on the iteration order of the filters map.
+func sortFilterPoints(points []dia.FilterPoint) {
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Name != points[j].Name {
+			return points[i].Name < points[j].Name
+		}
+		if points[i].Symbol != points[j].Symbol {
+			return points[i].Symbol < points[j].Symbol
+		}
+		return points[i].Exchange < points[j].Exchange
+	})
+}
+
 // runs in a goroutine until s is closed
 func (s *FiltersBlockService) mainLoop() {
 	for {
@@ -203,11 +305,17 @@ func (s *FiltersBlockService) mainLoop() {
 		select {
 		case <-s.shutdown:
 			log.Println("Filters shutting down")
+			if s.persistState {
+				s.saveCheckpoint()
+			}
 			s.cleanup(nil)
 			return
 		case tb, ok := <-s.chanTradesBlock:
 			log.Info("receive tradesBlock for further processing ok: ", ok)
-			s.processTradesBlock(tb)
+			s.processTradesBlock(tb.tradesBlock)
+			if s.persistState {
+				s.checkpointBlock(tb.offset, tb.tradesBlock.TradesBlockData.EndTime)
+			}
 		}
 	}
 }
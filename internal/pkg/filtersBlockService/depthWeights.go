@@ -0,0 +1,74 @@
+package filters
+
+import (
+	"sync"
+	"time"
+
+	models "github.com/diadata-org/diadata/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// depthWindow is the age up to which order book metrics are used as weights.
+	depthWindow = time.Hour
+	// depthRefresh is the interval the weights of a symbol are reloaded in.
+	depthRefresh = 5 * time.Minute
+)
+
+// depthWeights caches the order book depths of the pairs of symbols in USD, by which filters
+// weight the trades of the pairs.
+type depthWeights struct {
+	lock      sync.Mutex
+	datastore models.Datastore
+	symbols   map[string]symbolDepths
+}
+
+// symbolDepths maps the partition keys of the pairs of a symbol to their depths.
+type symbolDepths struct {
+	loaded time.Time
+	depths map[string]float64
+}
+
+// newDepthWeights returns the depthWeights loaded from @datastore. They are empty if
+// @datastore is nil.
+func newDepthWeights(datastore models.Datastore) *depthWeights {
+	return &depthWeights{
+		datastore: datastore,
+		symbols:   make(map[string]symbolDepths),
+	}
+}
+
+// depthWeightedFilter is implemented by filters weighting trades by order book depth. The
+// FiltersBlockService sets the depths of its datastore on creating them.
+type depthWeightedFilter interface {
+	setDepths(depths *depthWeights)
+}
+
+// get returns the latest depths of the pairs of @symbol at @t, which are empty without metrics.
+// They are reloaded after depthRefresh of block time, so that replays use the same weights.
+func (dw *depthWeights) get(symbol string, t time.Time) map[string]float64 {
+	dw.lock.Lock()
+	defer dw.lock.Unlock()
+	if dw.datastore == nil {
+		return nil
+	}
+	sd, ok := dw.symbols[symbol]
+	if ok && !t.Before(sd.loaded) && t.Sub(sd.loaded) < depthRefresh {
+		return sd.depths
+	}
+	metrics, err := dw.datastore.GetOrderBookMetricsInflux(symbol, "", t.Add(-depthWindow), t)
+	if err != nil {
+		log.Errorln("depthWeights: loading metrics of", symbol, err)
+		return sd.depths
+	}
+	depths := make(map[string]float64)
+	// metrics are in descending order of time
+	for _, m := range metrics {
+		key := m.Exchange + ":" + m.Pair
+		if _, ok := depths[key]; !ok {
+			depths[key] = m.BidDepthUSD + m.AskDepthUSD
+		}
+	}
+	dw.symbols[symbol] = symbolDepths{loaded: t, depths: depths}
+	return depths
+}
@@ -0,0 +1,248 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	log "github.com/sirupsen/logrus"
+	"github.com/tkanos/gonfig"
+)
+
+// OutlierRule removes outliers from a sorted or unsorted sample of prices.
+type OutlierRule func(samples []float64) []float64
+
+// FilterParams are the parameters a filter is created with.
+type FilterParams struct {
+	// Window is the memory of the filter in seconds.
+	Window int
+	// OutlierRule is the name of a rule in outlierRules. It is ignored by filters
+	// without outlier removal.
+	OutlierRule string
+}
+
+// FilterFactory creates a filter of @symbol on @exchange, where @exchange is empty for
+// filters over all exchanges. @beginTime is the begin time of the first block.
+type FilterFactory func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error)
+
+// FilterConfig enables a registered filter type along with its parameters.
+type FilterConfig struct {
+	Type string
+	FilterParams
+	// Overrides are parameters for single symbols. Unset fields keep the values above.
+	Overrides map[string]FilterParams
+}
+
+// FiltersConfig lists the filters computed for each symbol and exchange.
+type FiltersConfig struct {
+	Filters []FilterConfig
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[string]FilterFactory)
+
+	outlierRules = map[string]OutlierRule{
+		"":     removeOutliers,
+		"iqr":  removeOutliers,
+		"none": func(samples []float64) []float64 { return samples },
+	}
+)
+
+func init() {
+	RegisterFilter("MA", func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error) {
+		if err := checkWindow(params); err != nil {
+			return nil, err
+		}
+		return NewFilterMA(symbol, exchange, beginTime, params.Window), nil
+	})
+	RegisterFilter("TLT", func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error) {
+		return NewFilterTLT(symbol, exchange), nil
+	})
+	RegisterFilter("VOL", func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error) {
+		if err := checkWindow(params); err != nil {
+			return nil, err
+		}
+		return NewFilterVOL(symbol, exchange, params.Window), nil
+	})
+	RegisterFilter("MAIR", func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error) {
+		rule, err := getOutlierRule(params.OutlierRule)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkWindow(params); err != nil {
+			return nil, err
+		}
+		f := NewFilterMAIR(symbol, exchange, beginTime, params.Window)
+		f.outlierRule = rule
+		return f, nil
+	})
+	RegisterFilter("MEDIR", func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error) {
+		rule, err := getOutlierRule(params.OutlierRule)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkWindow(params); err != nil {
+			return nil, err
+		}
+		f := NewFilterMEDIR(symbol, exchange, beginTime, params.Window)
+		f.outlierRule = rule
+		return f, nil
+	})
+	RegisterFilter("VWAP", func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error) {
+		if err := checkWindow(params); err != nil {
+			return nil, err
+		}
+		return NewFilterVWAP(symbol, exchange, beginTime, params.Window), nil
+	})
+	RegisterFilter("VWAPD", func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error) {
+		if err := checkWindow(params); err != nil {
+			return nil, err
+		}
+		return NewFilterVWAPD(symbol, exchange, beginTime, params.Window), nil
+	})
+	RegisterFilter("VWAPIR", func(symbol string, exchange string, beginTime time.Time, params FilterParams) (Filter, error) {
+		rule, err := getOutlierRule(params.OutlierRule)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkWindow(params); err != nil {
+			return nil, err
+		}
+		f := NewFilterVWAPIR(symbol, exchange, beginTime, params.Window)
+		f.outlierRule = rule
+		return f, nil
+	})
+}
+
+// RegisterFilter makes filters created by @factory available in the configuration under @filterType.
+// It panics if @filterType is registered twice.
+func RegisterFilter(filterType string, factory FilterFactory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[filterType]; ok {
+		panic("filter type registered twice: " + filterType)
+	}
+	registry[filterType] = factory
+}
+
+func getFilterFactory(filterType string) (FilterFactory, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	factory, ok := registry[filterType]
+	return factory, ok
+}
+
+// checkWindow returns an error if the window of @params is not positive.
+func checkWindow(params FilterParams) error {
+	if params.Window <= 0 {
+		return fmt.Errorf("invalid window %d", params.Window)
+	}
+	return nil
+}
+
+func getOutlierRule(name string) (OutlierRule, error) {
+	rule, ok := outlierRules[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown outlier rule %s", name)
+	}
+	return rule, nil
+}
+
+// DefaultFiltersConfig returns the filters computed if no configuration is given.
+func DefaultFiltersConfig() FiltersConfig {
+	return FiltersConfig{
+		Filters: []FilterConfig{
+			// Prices are written into redis in MA filter
+			{Type: "MA", FilterParams: FilterParams{Window: dia.BlockSizeSeconds}},
+			{Type: "TLT"},
+			{Type: "VOL", FilterParams: FilterParams{Window: dia.BlockSizeSeconds}},
+			{Type: "MAIR", FilterParams: FilterParams{Window: dia.BlockSizeSeconds}},
+			{Type: "MEDIR", FilterParams: FilterParams{Window: dia.BlockSizeSeconds}},
+			{Type: "VWAP", FilterParams: FilterParams{Window: dia.BlockSizeSeconds}},
+			{Type: "VWAPIR", FilterParams: FilterParams{Window: dia.BlockSizeSeconds}},
+		},
+	}
+}
+
+// LoadFiltersConfig reads a filters configuration from the json file @file and validates it.
+func LoadFiltersConfig(file string) (FiltersConfig, error) {
+	var config FiltersConfig
+	err := gonfig.GetConf(file, &config)
+	if err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+// Validate checks that all filters in @c can be created, i.e. that their types are known and
+// their parameters valid, and that no two filters of a symbol share type and window.
+func (c FiltersConfig) Validate() error {
+	if len(c.Filters) == 0 {
+		return errors.New("no filters configured")
+	}
+	// the empty symbol stands for the symbols without overrides
+	symbols := map[string]bool{"": true}
+	for i, fc := range c.Filters {
+		factory, ok := getFilterFactory(fc.Type)
+		if !ok {
+			return fmt.Errorf("filter %d: unknown filter type %s", i, fc.Type)
+		}
+		if _, err := factory("", "", time.Time{}, fc.FilterParams); err != nil {
+			return fmt.Errorf("filter %d (%s): %v", i, fc.Type, err)
+		}
+		for symbol := range fc.Overrides {
+			if _, err := factory(symbol, "", time.Time{}, fc.paramsFor(symbol)); err != nil {
+				return fmt.Errorf("filter %d (%s): override for %s: %v", i, fc.Type, symbol, err)
+			}
+			symbols[symbol] = true
+		}
+	}
+	for symbol := range symbols {
+		seen := make(map[string]int)
+		for i, fc := range c.Filters {
+			key := fc.Type + "/" + strconv.Itoa(fc.paramsFor(symbol).Window)
+			if j, ok := seen[key]; ok {
+				return fmt.Errorf("filter %d (%s): same type and window %d as filter %d for symbol %q", i, fc.Type, fc.paramsFor(symbol).Window, j, symbol)
+			}
+			seen[key] = i
+		}
+	}
+	return nil
+}
+
+// paramsFor returns the parameters of the filter for @symbol.
+func (fc FilterConfig) paramsFor(symbol string) FilterParams {
+	params := fc.FilterParams
+	if override, ok := fc.Overrides[symbol]; ok {
+		if override.Window != 0 {
+			params.Window = override.Window
+		}
+		if override.OutlierRule != "" {
+			params.OutlierRule = override.OutlierRule
+		}
+	}
+	return params
+}
+
+// createFilters creates all configured filters of @symbol on @exchange.
+func (c FiltersConfig) createFilters(symbol string, exchange string, beginTime time.Time) []Filter {
+	var filters []Filter
+	for _, fc := range c.Filters {
+		factory, ok := getFilterFactory(fc.Type)
+		if !ok {
+			log.Errorln("createFilters: unknown filter type", fc.Type)
+			continue
+		}
+		f, err := factory(symbol, exchange, beginTime, fc.paramsFor(symbol))
+		if err != nil {
+			log.Errorf("createFilters: %s for %s on %s: %v", fc.Type, symbol, exchange, err)
+			continue
+		}
+		filters = append(filters, f)
+	}
+	return filters
+}
@@ -0,0 +1,68 @@
+package filters
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFiltersConfig(t *testing.T) {
+	config := FiltersConfig{
+		Filters: []FilterConfig{
+			{Type: "MA", FilterParams: FilterParams{Window: 120}},
+			{
+				Type:         "MEDIR",
+				FilterParams: FilterParams{Window: 3600, OutlierRule: "iqr"},
+				Overrides:    map[string]FilterParams{"BTC": {Window: 1800}},
+			},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultFiltersConfig().Validate(); err != nil {
+		t.Errorf("Default config did not pass validation: %v.", err)
+	}
+
+	tables := []struct {
+		symbol string
+		window int
+	}{
+		{"ETH", 3600},
+		{"BTC", 1800},
+	}
+	for _, table := range tables {
+		filters := config.createFilters(table.symbol, "", time.Now())
+		if len(filters) != 2 {
+			t.Fatalf("Number of filters for %s was incorrect, got: %v, want: 2.", table.symbol, len(filters))
+		}
+		medir, ok := filters[1].(*FilterMEDIR)
+		if !ok {
+			t.Fatalf("Second filter for %s was %T, want *FilterMEDIR.", table.symbol, filters[1])
+		}
+		if medir.memory != table.window {
+			t.Errorf("Window of MEDIR for %s was incorrect, got: %v, want: %v.", table.symbol, medir.memory, table.window)
+		}
+	}
+
+	invalid := []FiltersConfig{
+		{},
+		{Filters: []FilterConfig{{Type: "unknown"}}},
+		{Filters: []FilterConfig{{Type: "MAIR", FilterParams: FilterParams{OutlierRule: "unknown"}}}},
+		{Filters: []FilterConfig{{Type: "MAIR", Overrides: map[string]FilterParams{"BTC": {OutlierRule: "unknown"}}}}},
+		{Filters: []FilterConfig{{Type: "MA"}}},
+		{Filters: []FilterConfig{{Type: "VWAP", FilterParams: FilterParams{Window: -60}}}},
+		{Filters: []FilterConfig{{Type: "MA", FilterParams: FilterParams{Window: 120}, Overrides: map[string]FilterParams{"BTC": {Window: -1}}}}},
+		{Filters: []FilterConfig{{Type: "MA", FilterParams: FilterParams{Window: 120}}, {Type: "MA", FilterParams: FilterParams{Window: 120}}}},
+		{Filters: []FilterConfig{{Type: "TLT"}, {Type: "TLT"}}},
+		// MA60 for BTC collides with its override to 60 of the first filter
+		{Filters: []FilterConfig{
+			{Type: "MA", FilterParams: FilterParams{Window: 120}, Overrides: map[string]FilterParams{"BTC": {Window: 60}}},
+			{Type: "MA", FilterParams: FilterParams{Window: 60}, Overrides: map[string]FilterParams{"ETH": {Window: 30}}},
+		}},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Config %+v passed validation.", c)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package filters
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cnf/structhash"
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// Replay recomputes filter values from historic trades. Trades are grouped into
+// TradesBlocks as in the tradesBlockService and blocks are processed synchronously,
+// with the clock of the filters following the end time of the replayed blocks.
+// Replaying the same trades therefore yields the same filter values and block hashes.
+type Replay struct {
+	service      *FiltersBlockService
+	currentBlock *dia.TradesBlock
+	lastHash     string
+	now          time.Time
+	// FiltersBlocks are the hashes of the filters blocks computed so far.
+	FiltersBlocks []string
+}
+
+// NewReplay returns a Replay computing the filters of @config, which are saved to @datastore.
+func NewReplay(datastore models.Datastore, config FiltersConfig) *Replay {
+	r := &Replay{}
+	r.service = newFiltersBlockService(nil, datastore, nil, config, r.clock)
+	return r
+}
+
+func (r *Replay) clock() time.Time {
+	return r.now
+}
+
+// AddTrade adds @t to the current block. Trades must be added in ascending order of time,
+// the current block is processed as soon as a trade of a later block is added.
+func (r *Replay) AddTrade(t dia.Trade) {
+	if r.currentBlock != nil && t.Time.Before(r.currentBlock.TradesBlockData.BeginTime) {
+		log.Errorln("Replay: ignoring trade out of order", t)
+		return
+	}
+	if r.currentBlock != nil && !t.Time.Before(r.currentBlock.TradesBlockData.EndTime) {
+		r.Flush()
+	}
+	if r.currentBlock == nil {
+		beginTime := replayBlockBeginTime(t.Time)
+		r.currentBlock = &dia.TradesBlock{
+			TradesBlockData: dia.TradesBlockData{
+				Trades:    []dia.Trade{},
+				BeginTime: beginTime,
+				EndTime:   beginTime.Add(time.Duration(dia.BlockSizeSeconds) * time.Second),
+			},
+		}
+	}
+	r.currentBlock.TradesBlockData.Trades = append(r.currentBlock.TradesBlockData.Trades, t)
+}
+
+// Flush processes the current block. It must be called after the last trade was added.
+func (r *Replay) Flush() {
+	if r.currentBlock == nil {
+		return
+	}
+	block := r.currentBlock
+	r.currentBlock = nil
+	block.TradesBlockData.PreviousBlockHash = r.lastHash
+	finaliseReplayBlock(block)
+	r.lastHash = block.BlockHash
+
+	log.Infoln("Replay: processing block", block.BlockHash, "with", block.TradesBlockData.TradesNumber, "trades", block.TradesBlockData.BeginTime)
+	r.now = block.TradesBlockData.EndTime
+	fb := r.service.processTradesBlock(block)
+	r.FiltersBlocks = append(r.FiltersBlocks, fb.BlockHash)
+}
+
+// replayBlockBeginTime returns the begin time of the block containing @t.
+func replayBlockBeginTime(t time.Time) time.Time {
+	return time.Unix((t.Unix()/dia.BlockSizeSeconds)*dia.BlockSizeSeconds, 0)
+}
+
+// finaliseReplayBlock sorts the trades of @block and sets its hash the same way the
+// tradesBlockService does.
+func finaliseReplayBlock(block *dia.TradesBlock) {
+	sort.SliceStable(block.TradesBlockData.Trades, func(i, j int) bool {
+		return block.TradesBlockData.Trades[i].Time.Before(block.TradesBlockData.Trades[j].Time)
+	})
+	block.TradesBlockData.TradesNumber = len(block.TradesBlockData.Trades)
+	hash, err := structhash.Hash(block.TradesBlockData, 1)
+	if err != nil {
+		log.Printf("error on hash")
+		hash = "hashError"
+	}
+	block.BlockHash = hash
+}
+
+// FilterPointDiff is a filter value which differs between two sets of filter points.
+// Values missing in one of the sets are NaN.
+type FilterPointDiff struct {
+	Name     string
+	Symbol   string
+	Exchange string
+	Time     time.Time
+	Current  float64
+	Replayed float64
+}
+
+// DiffFilterPoints returns the filter values of @current and @replayed which differ by more
+// than @tolerance relative to the current value, ordered as @current followed by values only
+// in @replayed.
+func DiffFilterPoints(current []dia.FilterPoint, replayed []dia.FilterPoint, tolerance float64) []FilterPointDiff {
+	key := func(fp dia.FilterPoint) string {
+		return fp.Name + "/" + fp.Symbol + "/" + fp.Exchange + "/" + fp.Time.UTC().Format(time.RFC3339Nano)
+	}
+	replayedValues := make(map[string]float64)
+	for _, fp := range replayed {
+		replayedValues[key(fp)] = fp.Value
+	}
+
+	diffs := []FilterPointDiff{}
+	seen := make(map[string]bool)
+	for _, fp := range current {
+		k := key(fp)
+		seen[k] = true
+		value, ok := replayedValues[k]
+		if !ok {
+			value = math.NaN()
+		}
+		if ok && math.Abs(value-fp.Value) <= tolerance*math.Abs(fp.Value) {
+			continue
+		}
+		diffs = append(diffs, FilterPointDiff{fp.Name, fp.Symbol, fp.Exchange, fp.Time, fp.Value, value})
+	}
+	for _, fp := range replayed {
+		if !seen[key(fp)] {
+			diffs = append(diffs, FilterPointDiff{fp.Name, fp.Symbol, fp.Exchange, fp.Time, math.NaN(), fp.Value})
+		}
+	}
+	return diffs
+}
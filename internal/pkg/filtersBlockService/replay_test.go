@@ -0,0 +1,68 @@
+package filters
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+)
+
+func TestReplay(t *testing.T) {
+	d := time.Unix(1539475200, 0)
+	trades := []dia.Trade{
+		{Symbol: "BTC", Source: dia.BinanceExchange, EstimatedUSDPrice: 6000, Volume: 1, Time: d.Add(10 * time.Second)},
+		{Symbol: "BTC", Source: dia.KrakenExchange, EstimatedUSDPrice: 6010, Volume: 2, Time: d.Add(20 * time.Second)},
+		{Symbol: "ETH", Source: dia.BinanceExchange, EstimatedUSDPrice: 200, Volume: 3, Time: d.Add(30 * time.Second)},
+		{Symbol: "BTC", Source: dia.BinanceExchange, EstimatedUSDPrice: 6020, Volume: 1, Time: d.Add(130 * time.Second)},
+		{Symbol: "BTC", Source: dia.BinanceExchange, EstimatedUSDPrice: 6030, Volume: 1, Time: d.Add(400 * time.Second)},
+	}
+
+	replay := func() []string {
+		r := NewReplay(models.NewMemoryDataStore(), DefaultFiltersConfig())
+		for _, trade := range trades {
+			r.AddTrade(trade)
+		}
+		r.Flush()
+		return r.FiltersBlocks
+	}
+	first := replay()
+	if len(first) != 3 {
+		t.Fatalf("Number of replayed blocks was incorrect, got: %v, want: 3.", len(first))
+	}
+	second := replay()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("Hash of block %d differs between replays, got: %v, want: %v.", i, second[i], first[i])
+		}
+	}
+}
+
+func TestDiffFilterPoints(t *testing.T) {
+	d := time.Unix(1539475200, 0)
+	current := []dia.FilterPoint{
+		{Name: "MA120", Symbol: "BTC", Value: 6000, Time: d},
+		{Name: "MA120", Symbol: "ETH", Value: 200, Time: d},
+		{Name: "MA120", Symbol: "XRP", Value: 0.3, Time: d},
+	}
+	replayed := []dia.FilterPoint{
+		{Name: "MA120", Symbol: "BTC", Value: 6000, Time: d},
+		{Name: "MA120", Symbol: "ETH", Value: 201, Time: d},
+		{Name: "MA120", Symbol: "LTC", Value: 50, Time: d},
+	}
+
+	diffs := DiffFilterPoints(current, replayed, 1e-9)
+	if len(diffs) != 3 {
+		t.Fatalf("Number of diffs was incorrect, got: %v, want: 3.", len(diffs))
+	}
+	if diffs[0].Symbol != "ETH" || diffs[0].Replayed != 201 {
+		t.Errorf("Diff of ETH was incorrect, got: %+v.", diffs[0])
+	}
+	if diffs[1].Symbol != "XRP" || !math.IsNaN(diffs[1].Replayed) {
+		t.Errorf("Diff of XRP was incorrect, got: %+v.", diffs[1])
+	}
+	if diffs[2].Symbol != "LTC" || !math.IsNaN(diffs[2].Current) {
+		t.Errorf("Diff of LTC was incorrect, got: %+v.", diffs[2])
+	}
+}
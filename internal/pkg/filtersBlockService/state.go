@@ -0,0 +1,162 @@
+package filters
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+// FilterState is a snapshot of the internal state of a filter. Filters only set the
+// fields they make use of.
+type FilterState struct {
+	// Name identifies the filter among the filters of a symbol and exchange.
+	Name           string
+	CurrentTime    time.Time
+	PreviousPrices []float64   `json:",omitempty"`
+	Trades         []dia.Trade `json:",omitempty"`
+	LastTrade      *dia.Trade  `json:",omitempty"`
+	Value          float64
+	VolumeUSD      float64 `json:",omitempty"`
+	Modified       bool
+}
+
+// filterKeyState is the state of the filters of @Symbol on @Exchange.
+type filterKeyState struct {
+	Symbol   string
+	Exchange string
+	States   []FilterState
+}
+
+// serviceState is the state of a FiltersBlockService after processing a trades block.
+type serviceState struct {
+	// Offset is the kafka offset of the last processed trades block, -1 if unknown.
+	Offset               int64
+	EndTime              time.Time
+	PreviousBlockFilters []dia.FilterPoint
+	Filters              []filterKeyState
+}
+
+// checkpoint keeps track of the trades blocks processed since the state was last saved.
+type checkpoint struct {
+	// interval is the minimal time between two saved states.
+	interval time.Duration
+	savedAt  time.Time
+	pending  bool
+	offset   int64
+	endTime  time.Time
+}
+
+// NewPersistentFiltersBlockService returns a FiltersBlockService which stores the state of its
+// filters in @datastore at most every @checkpointInterval and on Close. If there is a stored
+// state, the filters are restored from it and @offset is the kafka offset of the last trades
+// block processed before, so that consumption can continue right after it. Trades blocks
+// processed after the last checkpoint are thus processed again after a crash. Without stored
+// state, the service starts from @previousBlockFilters and @offset is -1.
+func NewPersistentFiltersBlockService(previousBlockFilters []dia.FilterPoint, datastore models.Datastore, chanFiltersBlock chan *dia.FiltersBlock, config FiltersConfig, checkpointInterval time.Duration) (s *FiltersBlockService, offset int64) {
+	s = newFiltersBlockService(previousBlockFilters, datastore, chanFiltersBlock, config, time.Now)
+	s.persistState = true
+	s.checkpoint.interval = checkpointInterval
+	offset = -1
+
+	data, err := datastore.GetFiltersState()
+	if err == nil {
+		var state serviceState
+		err = json.Unmarshal(data, &state)
+		if err == nil {
+			s.restoreState(state)
+			offset = state.Offset
+			log.Infof("restored filters state of %d keys at offset %d (%v)", len(state.Filters), state.Offset, state.EndTime)
+		} else {
+			log.Errorln("NewPersistentFiltersBlockService: unmarshalling filters state", err)
+		}
+	} else if err != redis.Nil {
+		log.Errorln("NewPersistentFiltersBlockService: GetFiltersState", err)
+	}
+
+	go s.mainLoop()
+	return s, offset
+}
+
+// state returns the state of @s after the trades block ending at @endTime at kafka @offset.
+func (s *FiltersBlockService) state(offset int64, endTime time.Time) serviceState {
+	state := serviceState{
+		Offset:               offset,
+		EndTime:              endTime,
+		PreviousBlockFilters: s.previousBlockFilters,
+	}
+	for _, key := range s.filterKeys {
+		keyState := filterKeyState{Symbol: key.symbol, Exchange: key.exchange}
+		for _, f := range s.filters[key.symbol+key.exchange] {
+			if sf, ok := f.(StatefulFilter); ok {
+				keyState.States = append(keyState.States, sf.State())
+			}
+		}
+		state.Filters = append(state.Filters, keyState)
+	}
+	return state
+}
+
+// restoreState recreates the filters of @state and restores their internal states. Filters
+// which have been added to the configuration since the state was saved start empty.
+func (s *FiltersBlockService) restoreState(state serviceState) {
+	s.previousBlockFilters = state.PreviousBlockFilters
+	for _, keyState := range state.Filters {
+		s.createFilters(keyState.Symbol, keyState.Exchange, state.EndTime)
+		states := make(map[string]FilterState)
+		for _, fs := range keyState.States {
+			states[fs.Name] = fs
+		}
+		for _, f := range s.filters[keyState.Symbol+keyState.Exchange] {
+			sf, ok := f.(StatefulFilter)
+			if !ok {
+				continue
+			}
+			if fs, ok := states[sf.State().Name]; ok {
+				sf.Restore(fs)
+			}
+		}
+	}
+}
+
+// saveState writes the state of @s after the trades block ending at @endTime at kafka @offset
+// to the datastore.
+func (s *FiltersBlockService) saveState(offset int64, endTime time.Time) error {
+	data, err := json.Marshal(s.state(offset, endTime))
+	if err != nil {
+		log.Errorln("saveState: marshalling filters state", err)
+		return err
+	}
+	err = s.datastore.SetFiltersState(data)
+	if err != nil {
+		log.Errorln("saveState: SetFiltersState", err)
+	}
+	return err
+}
+
+// checkpointBlock records that the trades block ending at @endTime at kafka @offset has been
+// processed and saves the state if the last checkpoint is older than the checkpoint interval.
+func (s *FiltersBlockService) checkpointBlock(offset int64, endTime time.Time) {
+	s.checkpoint.pending = true
+	s.checkpoint.offset = offset
+	s.checkpoint.endTime = endTime
+	if s.clock().Sub(s.checkpoint.savedAt) >= s.checkpoint.interval {
+		s.saveCheckpoint()
+	}
+}
+
+// saveCheckpoint saves the state after the last processed trades block, if not saved yet.
+// Failed checkpoints are retried with the next trades block.
+func (s *FiltersBlockService) saveCheckpoint() {
+	if !s.checkpoint.pending {
+		return
+	}
+	if s.saveState(s.checkpoint.offset, s.checkpoint.endTime) != nil {
+		return
+	}
+	s.checkpoint.pending = false
+	s.checkpoint.savedAt = s.clock()
+}
@@ -0,0 +1,114 @@
+package filters
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+)
+
+func TestRestoreState(t *testing.T) {
+	d := time.Unix(1539475200, 0)
+	clock := func() time.Time { return d }
+	block := func(begin time.Time, prices ...float64) *dia.TradesBlock {
+		tb := &dia.TradesBlock{TradesBlockData: dia.TradesBlockData{
+			BeginTime: begin,
+			EndTime:   begin.Add(dia.BlockSizeSeconds * time.Second),
+		}}
+		for i, p := range prices {
+			tb.TradesBlockData.Trades = append(tb.TradesBlockData.Trades, dia.Trade{
+				Symbol:            "BTC",
+				Source:            dia.BinanceExchange,
+				EstimatedUSDPrice: p,
+				Volume:            1,
+				Time:              begin.Add(time.Duration(10*(i+1)) * time.Second),
+			})
+		}
+		return tb
+	}
+
+	ds := models.NewMemoryDataStore()
+	s := newFiltersBlockService(nil, ds, nil, DefaultFiltersConfig(), clock)
+	s.processTradesBlock(block(d, 6000, 6100, 6050))
+	s.saveState(7, d.Add(dia.BlockSizeSeconds*time.Second))
+
+	data, err := ds.GetFiltersState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state serviceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	restored := newFiltersBlockService(nil, ds, nil, DefaultFiltersConfig(), clock)
+	restored.restoreState(state)
+
+	next := block(d.Add(dia.BlockSizeSeconds*time.Second), 6200, 5900)
+	want := s.processTradesBlock(next)
+	got := restored.processTradesBlock(next)
+	gotPoints, wantPoints := got.FiltersBlockData.FilterPoints, want.FiltersBlockData.FilterPoints
+	if len(gotPoints) != len(wantPoints) {
+		t.Fatalf("Filters block after restore was incorrect, got: %v, want: %v.", gotPoints, wantPoints)
+	}
+	for i := range wantPoints {
+		if gotPoints[i].Name != wantPoints[i].Name || gotPoints[i].Value != wantPoints[i].Value {
+			t.Errorf("Filter point after restore was incorrect, got: %v, want: %v.", gotPoints[i], wantPoints[i])
+		}
+	}
+
+	persistent, offset := NewPersistentFiltersBlockService(nil, ds, nil, DefaultFiltersConfig(), time.Minute)
+	defer persistent.Close()
+	if offset != 7 {
+		t.Errorf("Offset of restored state was incorrect, got: %v, want: 7.", offset)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	d := time.Unix(1539475200, 0)
+	now := d
+	ds := models.NewMemoryDataStore()
+	s := newFiltersBlockService(nil, ds, nil, DefaultFiltersConfig(), func() time.Time { return now })
+	s.persistState = true
+	s.checkpoint.interval = time.Minute
+	savedOffset := func() int64 {
+		data, err := ds.GetFiltersState()
+		if err != nil {
+			return -1
+		}
+		var state serviceState
+		if err := json.Unmarshal(data, &state); err != nil {
+			t.Fatal(err)
+		}
+		return state.Offset
+	}
+
+	tables := []struct {
+		elapsed time.Duration
+		offset  int64
+		saved   int64
+	}{
+		{0, 1, 1},
+		{20 * time.Second, 2, 1},
+		{50 * time.Second, 3, 1},
+		{61 * time.Second, 4, 4},
+		{90 * time.Second, 5, 4},
+	}
+	for _, table := range tables {
+		now = d.Add(table.elapsed)
+		s.checkpointBlock(table.offset, now)
+		if saved := savedOffset(); saved != table.saved {
+			t.Errorf("Saved offset after %v was incorrect, got: %d, want: %d.", table.elapsed, saved, table.saved)
+		}
+	}
+
+	// the pending state is saved on shutdown
+	go s.mainLoop()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if saved := savedOffset(); saved != 5 {
+		t.Errorf("Saved offset after Close was incorrect, got: %d, want: %d.", saved, 5)
+	}
+}
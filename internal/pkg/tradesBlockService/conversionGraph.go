@@ -0,0 +1,147 @@
+package tradesBlockService
+
+import (
+	"math"
+	"time"
+
+	models "github.com/diadata-org/diadata/pkg/model"
+)
+
+const (
+	// maxConversionHops is the maximal number of trade pairs on a route to a USD price.
+	maxConversionHops = 3
+	// rateHalfLife is the age after which the confidence in a trade pair rate is halved.
+	rateHalfLife = 10 * time.Minute
+	// hopConfidence is the confidence factor applied for each trade pair on a route.
+	hopConfidence = 0.95
+	// minConversionConfidence is the confidence below which conversions are rejected.
+	minConversionConfidence = 0.5
+	// usdPriceCacheDuration is the time USD prices from the datastore are cached for.
+	usdPriceCacheDuration = 30 * time.Second
+)
+
+// conversionEdge is the rate of an asset in units of another asset as seen in the latest trade.
+type conversionEdge struct {
+	rate float64
+	time time.Time
+}
+
+type cachedUSDPrice struct {
+	price     float64
+	found     bool
+	fetchedAt time.Time
+}
+
+// conversion is the price of an asset in units of the last asset on route.
+type conversion struct {
+	price      float64
+	route      []string
+	confidence float64
+}
+
+// conversionGraph finds USD prices of assets without a filter price of their own by routing
+// through the rates of recent trades to assets with a filter price, e.g. XYZ->WETH->USD.
+type conversionGraph struct {
+	// edges[a][b] is the price of a in units of b.
+	edges     map[string]map[string]conversionEdge
+	usdPrices map[string]cachedUSDPrice
+	datastore models.Datastore
+}
+
+func newConversionGraph(datastore models.Datastore) *conversionGraph {
+	return &conversionGraph{
+		edges:     make(map[string]map[string]conversionEdge),
+		usdPrices: make(map[string]cachedUSDPrice),
+		datastore: datastore,
+	}
+}
+
+// addRate records that one unit of @quoteToken traded for @price units of @baseToken at @t.
+func (g *conversionGraph) addRate(quoteToken, baseToken string, price float64, t time.Time) {
+	if price <= 0 || quoteToken == baseToken {
+		return
+	}
+	g.setEdge(quoteToken, baseToken, conversionEdge{rate: price, time: t})
+	g.setEdge(baseToken, quoteToken, conversionEdge{rate: 1 / price, time: t})
+}
+
+func (g *conversionGraph) setEdge(from, to string, edge conversionEdge) {
+	if _, ok := g.edges[from]; !ok {
+		g.edges[from] = make(map[string]conversionEdge)
+	}
+	if previous, ok := g.edges[from][to]; ok && previous.time.After(edge.time) {
+		return
+	}
+	g.edges[from][to] = edge
+}
+
+// usdPrice returns the USD price of @asset at time @t along the route with the highest confidence.
+// The route starts with @asset and ends with USD.
+func (g *conversionGraph) usdPrice(asset string, t time.Time) (price float64, route []string, confidence float64, ok bool) {
+	var best conversion
+	visited := map[string]conversion{
+		asset: {price: 1, route: []string{asset}, confidence: 1},
+	}
+	frontier := []string{asset}
+	for hops := 0; hops <= maxConversionHops && len(frontier) > 0; hops++ {
+		var next []string
+		for _, node := range frontier {
+			current := visited[node]
+			if current.confidence <= best.confidence {
+				continue
+			}
+			if p, found := g.lookupUSDPrice(node); found {
+				best = conversion{
+					price:      current.price * p,
+					route:      append(append([]string{}, current.route...), "USD"),
+					confidence: current.confidence,
+				}
+				continue
+			}
+			for to, edge := range g.edges[node] {
+				c := current.confidence * hopConfidence * rateConfidence(edge, t)
+				if previous, seen := visited[to]; seen && previous.confidence >= c {
+					continue
+				}
+				visited[to] = conversion{
+					price:      current.price * edge.rate,
+					route:      append(append([]string{}, current.route...), to),
+					confidence: c,
+				}
+				next = append(next, to)
+			}
+		}
+		frontier = next
+	}
+	if best.confidence < minConversionConfidence {
+		return 0, nil, best.confidence, false
+	}
+	return best.price, best.route, best.confidence, true
+}
+
+// rateConfidence decays exponentially with the age of @edge with respect to @t.
+func rateConfidence(edge conversionEdge, t time.Time) float64 {
+	age := t.Sub(edge.time)
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(rateHalfLife))
+}
+
+// lookupUSDPrice returns the filter price of @asset, which is cached for usdPriceCacheDuration.
+func (g *conversionGraph) lookupUSDPrice(asset string) (float64, bool) {
+	if asset == "USD" {
+		return 1, true
+	}
+	cached, ok := g.usdPrices[asset]
+	if !ok || time.Since(cached.fetchedAt) > usdPriceCacheDuration {
+		price, err := g.datastore.GetPriceUSD(asset)
+		cached = cachedUSDPrice{
+			price:     price,
+			found:     err == nil && price > 0,
+			fetchedAt: time.Now(),
+		}
+		g.usdPrices[asset] = cached
+	}
+	return cached.price, cached.found
+}
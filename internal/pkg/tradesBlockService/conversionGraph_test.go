@@ -0,0 +1,51 @@
+package tradesBlockService
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	models "github.com/diadata-org/diadata/pkg/model"
+)
+
+func TestConversionGraph(t *testing.T) {
+	ds := models.NewMemoryDataStore()
+	ds.SetPriceUSD("WETH", 400)
+	ds.SetPriceUSD("BTC", 10000)
+
+	t0 := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	g := newConversionGraph(ds)
+	g.addRate("UNI", "WETH", 0.01, t0)
+	g.addRate("SUSHI", "UNI", 0.5, t0.Add(-rateHalfLife))
+	g.addRate("YFI", "XYZ", 2, t0)
+
+	tables := []struct {
+		asset string
+		price float64
+		route []string
+		ok    bool
+	}{
+		{"WETH", 400, []string{"WETH", "USD"}, true},
+		{"UNI", 4, []string{"UNI", "WETH", "USD"}, true},
+		{"SUSHI", 2, nil, false},
+		{"XYZ", 0, nil, false},
+	}
+	for _, table := range tables {
+		price, route, confidence, ok := g.usdPrice(table.asset, t0)
+		if ok != table.ok {
+			t.Errorf("Conversion of %s was incorrect, got ok: %v with confidence %v, want: %v.", table.asset, ok, confidence, table.ok)
+			continue
+		}
+		if ok && (math.Abs(price-table.price) > 1e-9 || !reflect.DeepEqual(route, table.route)) {
+			t.Errorf("Conversion of %s was incorrect, got: %v via %v, want: %v via %v.", table.asset, price, route, table.price, table.route)
+		}
+	}
+
+	// SUSHI -> UNI -> WETH -> USD has confidence 0.5*0.95^2 below the minimum, a fresh rate is accepted.
+	g.addRate("SUSHI", "UNI", 0.5, t0)
+	price, route, confidence, ok := g.usdPrice("SUSHI", t0)
+	if !ok || math.Abs(price-2) > 1e-9 || math.Abs(confidence-hopConfidence*hopConfidence) > 1e-9 {
+		t.Errorf("Conversion of SUSHI was incorrect, got: %v via %v with confidence %v.", price, route, confidence)
+	}
+}
@@ -0,0 +1,86 @@
+package tradesBlockService
+
+import (
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+const (
+	// dedupWindow is the time ForeignTradeIDs are remembered, relative to the latest trade.
+	dedupWindow = time.Hour
+)
+
+// dedupExchanges are the exchanges whose ForeignTradeIDs identify a single trade of a pair.
+// Trades of other exchanges are never deduplicated, as their IDs may be shared by distinct
+// trades, e.g. LBank hashes the time a trade was received.
+var dedupExchanges = map[string]bool{
+	dia.BalancerExchange:  true,
+	dia.BinanceExchange:   true,
+	dia.BitBayExchange:    true,
+	dia.BitfinexExchange:  true,
+	dia.BitMaxExchange:    true,
+	dia.BittrexExchange:   true,
+	dia.CoinBaseExchange:  true,
+	dia.CurveFIExchange:   true,
+	dia.GateIOExchange:    true,
+	dia.HitBTCExchange:    true,
+	dia.HuobiExchange:     true,
+	dia.OKExExchange:      true,
+	dia.PanCakeSwap:       true,
+	dia.QuoineExchange:    true,
+	dia.STEXExchange:      true,
+	dia.SushiSwapExchange: true,
+	dia.UniswapExchange:   true,
+	dia.UniswapExchangeV3: true,
+	dia.ZBExchange:        true,
+}
+
+type dedupEntry struct {
+	key  string
+	time time.Time
+}
+
+// tradeDeduplicator detects trades delivered more than once by their ForeignTradeID, e.g.
+// trades read again after a consumer restart or resent after a scraper reconnect.
+type tradeDeduplicator struct {
+	window time.Duration
+	seen   map[string]struct{}
+	// entries are the keys in seen in order of arrival, so that old keys can be evicted.
+	entries    []dedupEntry
+	latestTime time.Time
+}
+
+func newTradeDeduplicator(window time.Duration) *tradeDeduplicator {
+	return &tradeDeduplicator{
+		window: window,
+		seen:   make(map[string]struct{}),
+	}
+}
+
+// isDuplicate returns true if a trade with the ForeignTradeID of @t on the same exchange
+// and pair has been seen within the window. Trades without ForeignTradeID or of exchanges
+// not in dedupExchanges are never duplicates.
+func (d *tradeDeduplicator) isDuplicate(t dia.Trade) bool {
+	if t.ForeignTradeID == "" || !dedupExchanges[t.Source] {
+		return false
+	}
+	key := t.Source + "/" + t.Pair + "/" + t.ForeignTradeID
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	d.seen[key] = struct{}{}
+	d.entries = append(d.entries, dedupEntry{key: key, time: t.Time})
+
+	if t.Time.After(d.latestTime) {
+		d.latestTime = t.Time
+	}
+	horizon := d.latestTime.Add(-d.window)
+	evicted := 0
+	for evicted < len(d.entries) && d.entries[evicted].time.Before(horizon) {
+		delete(d.seen, d.entries[evicted].key)
+		evicted++
+	}
+	d.entries = d.entries[evicted:]
+	return false
+}
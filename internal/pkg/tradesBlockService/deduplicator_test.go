@@ -0,0 +1,39 @@
+package tradesBlockService
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+func TestTradeDeduplicator(t *testing.T) {
+	t0 := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	d := newTradeDeduplicator(time.Hour)
+
+	tables := []struct {
+		trade     dia.Trade
+		duplicate bool
+	}{
+		{dia.Trade{Source: dia.BinanceExchange, Pair: "BTCUSDT", ForeignTradeID: "1", Time: t0}, false},
+		{dia.Trade{Source: dia.BinanceExchange, Pair: "BTCUSDT", ForeignTradeID: "1", Time: t0}, true},
+		{dia.Trade{Source: dia.BinanceExchange, Pair: "ETHUSDT", ForeignTradeID: "1", Time: t0}, false},
+		{dia.Trade{Source: dia.KrakenExchange, Pair: "BTCUSDT", ForeignTradeID: "1", Time: t0}, false},
+		{dia.Trade{Source: dia.BinanceExchange, Pair: "BTCUSDT", Time: t0}, false},
+		{dia.Trade{Source: dia.BinanceExchange, Pair: "BTCUSDT", Time: t0}, false},
+		// distinct trades of exchanges without unique IDs are kept
+		{dia.Trade{Source: dia.KrakenExchange, Pair: "BTCUSDT", ForeignTradeID: "1", Price: 10000, Time: t0}, false},
+		{dia.Trade{Source: dia.LBankExchange, Pair: "BTCUSDT", ForeignTradeID: "a", Price: 10000, Time: t0}, false},
+		{dia.Trade{Source: dia.LBankExchange, Pair: "BTCUSDT", ForeignTradeID: "a", Price: 10001, Time: t0}, false},
+		// moves the window past the first trades, which are forgotten
+		{dia.Trade{Source: dia.BinanceExchange, Pair: "BTCUSDT", ForeignTradeID: "2", Time: t0.Add(2 * time.Hour)}, false},
+		{dia.Trade{Source: dia.BinanceExchange, Pair: "BTCUSDT", ForeignTradeID: "1", Time: t0}, false},
+		{dia.Trade{Source: dia.BinanceExchange, Pair: "BTCUSDT", ForeignTradeID: "2", Time: t0.Add(2 * time.Hour)}, true},
+	}
+	for i, table := range tables {
+		duplicate := d.isDuplicate(table.trade)
+		if duplicate != table.duplicate {
+			t.Errorf("Duplicate detection of trade %d was incorrect, got: %v, want: %v.", i, duplicate, table.duplicate)
+		}
+	}
+}
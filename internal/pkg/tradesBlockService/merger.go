@@ -0,0 +1,118 @@
+package tradesBlockService
+
+import (
+	"sort"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/signingHelper"
+	log "github.com/sirupsen/logrus"
+)
+
+// BlockMerger assembles trades blocks from the shards published by the instances of a sharded
+// tradesBlockService, each of which consumes a subset of the partitions of the trades topic.
+// Merged blocks are published in order of time, chained and signed like the blocks of a single
+// TradesBlockService.
+type BlockMerger struct {
+	NumShards int
+	// Timeout is the time a block waits for missing shards after its first shard arrived. The
+	// block is published with the trades of the shards received when it expires.
+	Timeout time.Duration
+	// pending are the blocks waiting for shards in ascending order of time.
+	pending []*mergingBlock
+	// mergedUntil is the end time of the last merged block.
+	mergedUntil time.Time
+	chain       blockChain
+}
+
+type mergingBlock struct {
+	block     *dia.TradesBlock
+	shards    map[int]bool
+	firstSeen time.Time
+}
+
+// NewBlockMerger returns a BlockMerger of blocks split into @numShards shards.
+func NewBlockMerger(numShards int, timeout time.Duration) *BlockMerger {
+	return &BlockMerger{
+		NumShards: numShards,
+		Timeout:   timeout,
+	}
+}
+
+// ChainTo chains the next merged block to the block with @previousBlockHash. Merged blocks are
+// signed by @signer unless it is nil.
+func (m *BlockMerger) ChainTo(previousBlockHash string, signer *signingHelper.Signer) {
+	m.chain.chainTo(previousBlockHash, signer)
+}
+
+// Add adds the shard @b received at @now and returns the blocks merged in ascending order of time.
+func (m *BlockMerger) Add(b *dia.TradesBlockShard, now time.Time) []*dia.TradesBlock {
+	if b.NumShards != m.NumShards {
+		log.Errorf("ignoring shard %d of %d shards, expected %d shards", b.Shard, b.NumShards, m.NumShards)
+		return m.release(now)
+	}
+	if b.BeginTime.Before(m.mergedUntil) {
+		log.Warnf("ignoring shard %d of block beginTime %v, the block is already merged", b.Shard, b.BeginTime)
+		return m.release(now)
+	}
+
+	i := sort.Search(len(m.pending), func(i int) bool {
+		return !m.pending[i].block.TradesBlockData.BeginTime.Before(b.BeginTime)
+	})
+	if i == len(m.pending) || !m.pending[i].block.TradesBlockData.BeginTime.Equal(b.BeginTime) {
+		mb := &mergingBlock{
+			block: &dia.TradesBlock{
+				TradesBlockData: dia.TradesBlockData{
+					Trades:    []dia.Trade{},
+					BeginTime: b.BeginTime,
+					EndTime:   b.EndTime,
+				},
+			},
+			shards:    make(map[int]bool),
+			firstSeen: now,
+		}
+		m.pending = append(m.pending, nil)
+		copy(m.pending[i+1:], m.pending[i:])
+		m.pending[i] = mb
+	}
+
+	mb := m.pending[i]
+	if mb.shards[b.Shard] {
+		// shards are delivered at least once
+		log.Infof("ignoring duplicate shard %d of block beginTime %v", b.Shard, b.BeginTime)
+		return m.release(now)
+	}
+	mb.shards[b.Shard] = true
+	mb.block.TradesBlockData.Trades = append(mb.block.TradesBlockData.Trades, b.Trades...)
+	return m.release(now)
+}
+
+// Expire returns the blocks merged because their shards have not arrived within Timeout.
+func (m *BlockMerger) Expire(now time.Time) []*dia.TradesBlock {
+	return m.release(now)
+}
+
+// Pending returns the number of blocks waiting for shards.
+func (m *BlockMerger) Pending() int {
+	return len(m.pending)
+}
+
+// release merges the pending blocks in order of time, up to the first block which is
+// neither complete nor expired.
+func (m *BlockMerger) release(now time.Time) []*dia.TradesBlock {
+	var merged []*dia.TradesBlock
+	for len(m.pending) > 0 {
+		mb := m.pending[0]
+		if len(mb.shards) < m.NumShards {
+			if now.Sub(mb.firstSeen) < m.Timeout {
+				break
+			}
+			log.Warnf("merging block beginTime %v with %d of %d shards", mb.block.TradesBlockData.BeginTime, len(mb.shards), m.NumShards)
+		}
+		m.pending = m.pending[1:]
+		m.chain.seal(mb.block)
+		m.mergedUntil = mb.block.TradesBlockData.EndTime
+		merged = append(merged, mb.block)
+	}
+	return merged
+}
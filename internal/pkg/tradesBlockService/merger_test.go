@@ -0,0 +1,69 @@
+package tradesBlockService
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+func TestBlockMerger(t *testing.T) {
+	t0 := time.Unix(1000*120, 0)
+	now := t0.Add(130 * time.Second)
+	shard := func(i int, begin time.Time, prices ...float64) *dia.TradesBlockShard {
+		b := &dia.TradesBlockShard{Shard: i, NumShards: 2, BeginTime: begin, EndTime: begin.Add(120 * time.Second)}
+		for j, price := range prices {
+			b.Trades = append(b.Trades, dia.Trade{Symbol: "BTC", Price: price, Time: begin.Add(time.Duration(j+i) * time.Second)})
+		}
+		return b
+	}
+	t1 := t0.Add(120 * time.Second)
+
+	m := NewBlockMerger(2, time.Minute)
+	if merged := m.Add(shard(0, t1, 3), now); len(merged) != 0 {
+		t.Errorf("Incomplete block was merged, got: %d blocks.", len(merged))
+	}
+	m.Add(shard(1, t1, 4), now)
+	m.Add(shard(0, t0, 1, 2), now)
+	// the completed later block waits for the earlier block
+	if m.Pending() != 2 {
+		t.Errorf("Pending blocks were incorrect, got: %d, want: 2.", m.Pending())
+	}
+	m.Add(shard(0, t0, 1, 2), now)
+	merged := m.Add(shard(1, t0, 5), now)
+
+	tables := []struct {
+		beginTime    time.Time
+		tradesNumber int
+	}{
+		{t0, 3},
+		{t1, 2},
+	}
+	if len(merged) != len(tables) {
+		t.Fatalf("Merged blocks were incorrect, got: %d blocks, want: %d.", len(merged), len(tables))
+	}
+	for i, table := range tables {
+		data := merged[i].TradesBlockData
+		if !data.BeginTime.Equal(table.beginTime) || data.TradesNumber != table.tradesNumber {
+			t.Errorf("Merged block %d was incorrect, got: %d trades beginning at %v, want: %d trades beginning at %v.",
+				i, data.TradesNumber, data.BeginTime, table.tradesNumber, table.beginTime)
+		}
+	}
+	if merged[1].TradesBlockData.PreviousBlockHash != merged[0].BlockHash {
+		t.Errorf("Merged blocks were not chained.")
+	}
+
+	// shards of merged blocks are dropped, incomplete blocks are merged on expiry
+	t2 := t1.Add(120 * time.Second)
+	if merged := m.Add(shard(0, t0, 6), now); len(merged) != 0 || m.Pending() != 0 {
+		t.Errorf("Shard of merged block was added.")
+	}
+	m.Add(shard(1, t2, 7), now)
+	if merged := m.Expire(now.Add(30 * time.Second)); len(merged) != 0 {
+		t.Errorf("Block was merged before its timeout.")
+	}
+	merged = m.Expire(now.Add(time.Minute))
+	if len(merged) != 1 || merged[0].TradesBlockData.TradesNumber != 1 {
+		t.Errorf("Expired block was incorrect, got: %v.", merged)
+	}
+}
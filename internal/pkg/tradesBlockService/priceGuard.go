@@ -0,0 +1,132 @@
+package tradesBlockService
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// referenceCacheDuration is the time span around a trade for which a looked up
+	// reference price is reused for subsequent trades of the same symbol.
+	referenceCacheDuration = time.Minute
+)
+
+// referencePrice is a price a trade's estimated USD price is compared with.
+type referencePrice struct {
+	source string
+	price  float64
+	time   time.Time
+	// lookupTime is the trade time the reference price was looked up for.
+	lookupTime time.Time
+}
+
+// PriceGuard checks the estimated USD price of trades against the latest foreign quotations
+// and the last MA120 value valid at the time of the trade.
+type PriceGuard struct {
+	// MaxDeviation is the maximal relative deviation from a reference price, e.g. 0.2 for 20%.
+	MaxDeviation float64
+	// MaxReferenceAge is the maximal age of a reference price with respect to the trade time.
+	MaxReferenceAge time.Duration
+	// ForeignSources are the sources of foreign quotations used as reference, e.g. Coingecko.
+	ForeignSources []string
+	datastore      models.Datastore
+	cache          map[string]referencePrice
+}
+
+// NewPriceGuard returns a PriceGuard comparing trades with foreign quotations from @foreignSources
+// and DIA's own MA120 values.
+func NewPriceGuard(datastore models.Datastore, maxDeviation float64, maxReferenceAge time.Duration, foreignSources []string) *PriceGuard {
+	return &PriceGuard{
+		MaxDeviation:    maxDeviation,
+		MaxReferenceAge: maxReferenceAge,
+		ForeignSources:  foreignSources,
+		datastore:       datastore,
+		cache:           make(map[string]referencePrice),
+	}
+}
+
+// Check returns false along with the reason if the estimated USD price of @t deviates by more
+// than MaxDeviation from any of the reference prices valid at the time of @t.
+// Trades without reference prices pass the check.
+func (g *PriceGuard) Check(t dia.Trade) (reason string, ok bool) {
+	if t.EstimatedUSDPrice <= 0 {
+		return "non-positive estimated USD price", false
+	}
+	for _, source := range g.ForeignSources {
+		if reason, ok = g.checkReference(t, source); !ok {
+			return
+		}
+	}
+	return g.checkReference(t, dia.FilterKing)
+}
+
+func (g *PriceGuard) checkReference(t dia.Trade, source string) (string, bool) {
+	ref, found := g.reference(t, source)
+	if !found {
+		return "", true
+	}
+	deviation := math.Abs(t.EstimatedUSDPrice-ref.price) / ref.price
+	if deviation > g.MaxDeviation {
+		return fmt.Sprintf("price %v deviates by %.2f%% from %s price %v at %v",
+			t.EstimatedUSDPrice, deviation*100, ref.source, ref.price, ref.time), false
+	}
+	return "", true
+}
+
+// reference returns the price of @t.Symbol on @source valid at the time of @t.
+// @source is either the source of foreign quotations or dia.FilterKing.
+func (g *PriceGuard) reference(t dia.Trade, source string) (referencePrice, bool) {
+	key := source + "_" + t.Symbol
+	ref, ok := g.cache[key]
+	if !ok || math.Abs(t.Time.Sub(ref.lookupTime).Seconds()) > referenceCacheDuration.Seconds() {
+		ref = g.lookupReference(t, source)
+		g.cache[key] = ref
+	}
+	if ref.price <= 0 || ref.time.After(t.Time) || t.Time.Sub(ref.time) > g.MaxReferenceAge {
+		return ref, false
+	}
+	return ref, true
+}
+
+func (g *PriceGuard) lookupReference(t dia.Trade, source string) referencePrice {
+	ref := referencePrice{
+		source:     source,
+		lookupTime: t.Time,
+	}
+	if source != dia.FilterKing {
+		fq, err := g.datastore.GetForeignQuotationInflux(t.Symbol, source, t.Time)
+		if err != nil {
+			log.Errorf("PriceGuard: get foreign quotation of %s on %s: %v", t.Symbol, source, err)
+			return ref
+		}
+		ref.price = fq.Price
+		ref.time = fq.Time
+		return ref
+	}
+
+	points, err := g.datastore.GetFilterPoints(dia.FilterKing, "", t.Symbol, "", t.Time.Add(-g.MaxReferenceAge), t.Time)
+	if err != nil {
+		log.Errorf("PriceGuard: get %s of %s: %v", dia.FilterKing, t.Symbol, err)
+		return ref
+	}
+	// Filter points are in descending order, so the first one is the latest before the trade.
+	if len(points.DataPoints) > 0 && len(points.DataPoints[0].Series) > 0 && len(points.DataPoints[0].Series[0].Values) > 0 {
+		vals := points.DataPoints[0].Series[0].Values[0]
+		ref.time, err = time.Parse(time.RFC3339, vals[0].(string))
+		if err != nil {
+			log.Error(err)
+			return ref
+		}
+		ref.price, err = vals[4].(json.Number).Float64()
+		if err != nil {
+			log.Error(err)
+		}
+	}
+	return ref
+}
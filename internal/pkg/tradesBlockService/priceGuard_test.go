@@ -0,0 +1,39 @@
+package tradesBlockService
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+)
+
+func TestPriceGuard(t *testing.T) {
+	ds := models.NewMemoryDataStore()
+	t0 := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	ds.SaveForeignQuotationInflux(models.ForeignQuotation{Symbol: "BTC", Source: "Coingecko", Price: 100, Time: t0})
+	ds.SaveForeignQuotationInflux(models.ForeignQuotation{Symbol: "BTC", Source: "Coingecko", Price: 200, Time: t0.Add(2 * time.Hour)})
+	ds.SaveFilterInflux(dia.FilterKing, "BTC", "", 120, t0.Add(3*time.Hour))
+
+	tables := []struct {
+		price float64
+		time  time.Time
+		ok    bool
+	}{
+		{105, t0.Add(30 * time.Minute), true},
+		{150, t0.Add(40 * time.Minute), false},
+		// reference prices are looked up at the time of the trade
+		{195, t0.Add(150 * time.Minute), true},
+		{170, t0.Add(200 * time.Minute), false},
+		// no reference price younger than one hour
+		{1000, t0.Add(6 * time.Hour), true},
+	}
+	for _, table := range tables {
+		g := NewPriceGuard(ds, 0.2, time.Hour, []string{"Coingecko"})
+		trade := dia.Trade{Symbol: "BTC", EstimatedUSDPrice: table.price, Time: table.time}
+		reason, ok := g.Check(trade)
+		if ok != table.ok {
+			t.Errorf("Check of price %v at %v was incorrect, got: %v (%s), want: %v.", table.price, table.time, ok, reason, table.ok)
+		}
+	}
+}
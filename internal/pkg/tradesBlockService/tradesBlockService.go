@@ -2,6 +2,7 @@ package tradesBlockService
 
 import (
 	"errors"
+	"expvar"
 	"math"
 	"sort"
 	"sync"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/cnf/structhash"
 	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/signingHelper"
 	models "github.com/diadata-org/diadata/pkg/model"
 	log "github.com/sirupsen/logrus"
 )
@@ -27,31 +29,135 @@ var (
 	tol = float64(0.1)
 )
 
+// Late trades are counted per exchange in expvar maps, which are served as JSON at /debug/vars
+// by the http server of the process. Unlike LateTradeStats, they are shared by all services
+// of the process.
+var (
+	lateTradesMetric        = expvar.NewMap("lateTrades")
+	lateTradesVolumeMetric  = expvar.NewMap("lateTradesVolumeUSD")
+	lateTradesDroppedMetric = expvar.NewMap("lateTradesDropped")
+)
+
+const (
+	// lateTradesBufferSize is the number of late trades buffered before they are
+	// dropped from the late trades channel.
+	lateTradesBufferSize = 1000
+	// tickInterval is the interval at which blocks are checked for finalisation in ticker mode.
+	tickInterval = time.Second
+)
+
+// LateTradeStats accounts for trades which arrived after their block had been finalised.
+type LateTradeStats struct {
+	NumTrades int64
+	VolumeUSD float64
+	// MaxDelay is the largest distance between a late trade and the end of the last finalised block.
+	MaxDelay time.Duration
+}
+
 type TradesBlockService struct {
 	pair            string
 	shutdown        chan nothing
 	shutdownDone    chan nothing
 	chanTrades      chan *dia.Trade
 	chanTradesBlock chan *dia.TradesBlock
+	chanLateTrades  chan *dia.Trade
 	errorLock       sync.RWMutex
 	error           error
 	closed          bool
 	started         bool
 	BlockDuration   int64
-	currentBlock    *dia.TradesBlock
-	datastore       models.Datastore
+	// GracePeriod is the time blocks are kept open for trades arriving out of order.
+	// A block is finalised once the watermark, i.e. the latest trade time minus
+	// GracePeriod, has passed its end time.
+	GracePeriod time.Duration
+	// FinaliseOnTick is set if blocks are finalised by wall clock time rather than by incoming
+	// trades. In this mode a block is published for each interval, even if it has no trades.
+	FinaliseOnTick bool
+	// openBlocks are the blocks not yet finalised in ascending order of time.
+	openBlocks []*dia.TradesBlock
+	// finalisedUntil is the end time of the last finalised block.
+	finalisedUntil  time.Time
+	latestTradeTime time.Time
+	statsLock       sync.RWMutex
+	lateTradeStats  map[string]LateTradeStats
+	// priceGuard quarantines trades deviating from reference prices. It is disabled if nil.
+	priceGuard *PriceGuard
+	// conversionGraph converts prices of base tokens to USD, if necessary through other assets.
+	conversionGraph *conversionGraph
+	// deduplicator drops trades delivered more than once.
+	deduplicator *tradeDeduplicator
+	chain        blockChain
+	datastore    models.Datastore
+}
+
+// blockChain chains and signs finalised trades blocks.
+type blockChain struct {
+	lock sync.Mutex
+	// previousBlockHash is the hash of the last finalised block, which the next block is chained to.
+	previousBlockHash string
+	// signer signs finalised blocks. Blocks are published unsigned if nil.
+	signer *signingHelper.Signer
+}
+
+func (c *blockChain) chainTo(previousBlockHash string, signer *signingHelper.Signer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.previousBlockHash = previousBlockHash
+	c.signer = signer
+}
+
+// seal sorts the trades of @block by time, chains it to the previous block, and sets its hash
+// and signature.
+func (c *blockChain) seal(block *dia.TradesBlock) {
+	sort.Slice(block.TradesBlockData.Trades, func(i, j int) bool {
+		return block.TradesBlockData.Trades[i].Time.Before(block.TradesBlockData.Trades[j].Time)
+	})
+
+	block.TradesBlockData.TradesNumber = len(block.TradesBlockData.Trades)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	block.TradesBlockData.PreviousBlockHash = c.previousBlockHash
+	hash, err := structhash.Hash(block.TradesBlockData, 1)
+	if err != nil {
+		log.Printf("error on hash")
+		hash = "hashError"
+	}
+	block.BlockHash = hash
+	if c.signer != nil {
+		err = c.signer.SignTradesBlock(block)
+		if err != nil {
+			log.Errorln("error signing block", hash, err)
+		}
+	}
+	c.previousBlockHash = hash
+}
+
+func NewTradesBlockService(datastore models.Datastore, blockDuration int64, gracePeriod time.Duration, priceGuard *PriceGuard) *TradesBlockService {
+	return newTradesBlockService(datastore, blockDuration, gracePeriod, false, priceGuard)
 }
 
-func NewTradesBlockService(datastore models.Datastore, blockDuration int64) *TradesBlockService {
+// NewTradesBlockServiceWithTicker returns a TradesBlockService finalising blocks once the wall
+// clock minus @gracePeriod has passed their end time, including blocks without trades.
+func NewTradesBlockServiceWithTicker(datastore models.Datastore, blockDuration int64, gracePeriod time.Duration, priceGuard *PriceGuard) *TradesBlockService {
+	return newTradesBlockService(datastore, blockDuration, gracePeriod, true, priceGuard)
+}
+
+func newTradesBlockService(datastore models.Datastore, blockDuration int64, gracePeriod time.Duration, finaliseOnTick bool, priceGuard *PriceGuard) *TradesBlockService {
 	s := &TradesBlockService{
 		shutdown:        make(chan nothing),
 		shutdownDone:    make(chan nothing),
 		chanTrades:      make(chan *dia.Trade),
 		chanTradesBlock: make(chan *dia.TradesBlock),
+		chanLateTrades:  make(chan *dia.Trade, lateTradesBufferSize),
 		error:           nil,
 		started:         false,
-		currentBlock:    nil,
 		BlockDuration:   blockDuration,
+		GracePeriod:     gracePeriod,
+		FinaliseOnTick:  finaliseOnTick,
+		lateTradeStats:  make(map[string]LateTradeStats),
+		priceGuard:      priceGuard,
+		conversionGraph: newConversionGraph(datastore),
+		deduplicator:    newTradeDeduplicator(dedupWindow),
 		datastore:       datastore,
 	}
 	go s.mainLoop()
@@ -86,36 +192,152 @@ func (ps *TradesBlockService) Channel() chan *dia.TradesBlock {
 	return ps.chanTradesBlock
 }
 
-func (s *TradesBlockService) finaliseCurrentBlock() {
+// LateTradesChannel returns the trades which arrived after their block had been finalised.
+func (ps *TradesBlockService) LateTradesChannel() chan *dia.Trade {
+	return ps.chanLateTrades
+}
+
+// LateTradeStats returns the late trade statistics per exchange.
+func (ps *TradesBlockService) LateTradeStats() map[string]LateTradeStats {
+	ps.statsLock.RLock()
+	defer ps.statsLock.RUnlock()
+	stats := make(map[string]LateTradeStats, len(ps.lateTradeStats))
+	for source, stat := range ps.lateTradeStats {
+		stats[source] = stat
+	}
+	return stats
+}
+
+// ChainTo chains the next finalised block to the block with @previousBlockHash, e.g. the last
+// block published before a restart. Finalised blocks are signed by @signer unless it is nil.
+func (s *TradesBlockService) ChainTo(previousBlockHash string, signer *signingHelper.Signer) {
+	s.chain.chainTo(previousBlockHash, signer)
+}
 
-	sort.Slice(s.currentBlock.TradesBlockData.Trades, func(i, j int) bool {
-		return s.currentBlock.TradesBlockData.Trades[i].Time.Before(s.currentBlock.TradesBlockData.Trades[j].Time)
+func (s *TradesBlockService) finaliseBlock(block *dia.TradesBlock) {
+	s.chain.seal(block)
+	s.finalisedUntil = block.TradesBlockData.EndTime
+	s.chanTradesBlock <- block
+}
+
+// finaliseBlocksBefore finalises all open blocks ending before @watermark.
+// In ticker mode, empty blocks are finalised for intervals without trades.
+func (s *TradesBlockService) finaliseBlocksBefore(watermark time.Time) {
+	if !s.FinaliseOnTick {
+		for len(s.openBlocks) > 0 && s.openBlocks[0].TradesBlockData.EndTime.Before(watermark) {
+			block := s.openBlocks[0]
+			s.openBlocks = s.openBlocks[1:]
+			log.Info("finalising block beginTime:", block.TradesBlockData.BeginTime, " nb trades:", len(block.TradesBlockData.Trades))
+			s.finaliseBlock(block)
+		}
+		return
+	}
+
+	if s.finalisedUntil.IsZero() {
+		if len(s.openBlocks) > 0 {
+			s.finalisedUntil = s.openBlocks[0].TradesBlockData.BeginTime
+		} else {
+			s.finalisedUntil = s.blockBeginTime(watermark)
+		}
+	}
+	for {
+		beginTime := s.finalisedUntil
+		endTime := beginTime.Add(time.Duration(s.BlockDuration) * time.Second)
+		if !endTime.Before(watermark) {
+			return
+		}
+		var block *dia.TradesBlock
+		if len(s.openBlocks) > 0 && s.openBlocks[0].TradesBlockData.BeginTime.Equal(beginTime) {
+			block = s.openBlocks[0]
+			s.openBlocks = s.openBlocks[1:]
+		} else {
+			block = &dia.TradesBlock{
+				TradesBlockData: dia.TradesBlockData{
+					Trades:    []dia.Trade{},
+					EndTime:   endTime,
+					BeginTime: beginTime,
+				},
+			}
+		}
+		log.Info("finalising block beginTime:", block.TradesBlockData.BeginTime, " nb trades:", len(block.TradesBlockData.Trades))
+		s.finaliseBlock(block)
+	}
+}
+
+// blockForTrade returns the open block @t belongs to. The block is created if necessary.
+func (s *TradesBlockService) blockForTrade(t dia.Trade) *dia.TradesBlock {
+	beginTime := s.blockBeginTime(t.Time)
+	i := sort.Search(len(s.openBlocks), func(i int) bool {
+		return !s.openBlocks[i].TradesBlockData.BeginTime.Before(beginTime)
 	})
+	if i < len(s.openBlocks) && s.openBlocks[i].TradesBlockData.BeginTime.Equal(beginTime) {
+		return s.openBlocks[i]
+	}
 
-	hash, err := structhash.Hash(s.currentBlock.TradesBlockData, 1)
-	if err != nil {
-		log.Printf("error on hash")
-		hash = "hashError"
+	b := &dia.TradesBlock{
+		TradesBlockData: dia.TradesBlockData{
+			Trades:    []dia.Trade{},
+			EndTime:   beginTime.Add(time.Duration(s.BlockDuration) * time.Second),
+			BeginTime: beginTime,
+		},
+	}
+	log.Info("created new block beginTime:", b.TradesBlockData.BeginTime, " open blocks:", len(s.openBlocks)+1)
+	s.openBlocks = append(s.openBlocks, nil)
+	copy(s.openBlocks[i+1:], s.openBlocks[i:])
+	s.openBlocks[i] = b
+	s.datastore.Flush()
+	return b
+}
+
+// blockBeginTime returns the begin time of the block containing @t.
+func (s *TradesBlockService) blockBeginTime(t time.Time) time.Time {
+	return time.Unix((t.Unix()/s.BlockDuration)*s.BlockDuration, 0)
+}
+
+// handleLateTrade accounts for @t and forwards it to the late trades channel.
+func (s *TradesBlockService) handleLateTrade(t dia.Trade) {
+	s.statsLock.Lock()
+	stat := s.lateTradeStats[t.Source]
+	stat.NumTrades++
+	stat.VolumeUSD += math.Abs(t.Volume) * t.EstimatedUSDPrice
+	if delay := s.finalisedUntil.Sub(t.Time); delay > stat.MaxDelay {
+		stat.MaxDelay = delay
+	}
+	s.lateTradeStats[t.Source] = stat
+	s.statsLock.Unlock()
+	lateTradesMetric.Add(t.Source, 1)
+	lateTradesVolumeMetric.AddFloat(t.Source, math.Abs(t.Volume)*t.EstimatedUSDPrice)
+
+	select {
+	case s.chanLateTrades <- &t:
+	default:
+		lateTradesDroppedMetric.Add(t.Source, 1)
+		log.Warnf("late trades channel full, dropping late trade %v", t)
 	}
-	s.currentBlock.BlockHash = hash
-	s.currentBlock.TradesBlockData.TradesNumber = len(s.currentBlock.TradesBlockData.Trades)
-	s.chanTradesBlock <- s.currentBlock
 }
 
 func (s *TradesBlockService) process(t dia.Trade) {
 
+	if s.deduplicator.isDuplicate(t) {
+		log.Infof("ignoring duplicate trade %s on %s", t.ForeignTradeID, t.Source)
+		return
+	}
+
 	var ignoreTrade bool
 	baseToken := t.BaseToken()
 	if baseToken != "USD" {
-		val, err := s.datastore.GetPriceUSD(baseToken)
-		if err != nil {
-			log.Error("Cant find base token ", baseToken, " in redis ", err, " ignoring ", t)
+		val, route, confidence, ok := s.conversionGraph.usdPrice(baseToken, t.Time)
+		if !ok {
+			log.Error("Cant find a route from base token ", baseToken, " to USD (confidence ", confidence, ") ignoring ", t)
 			ignoreTrade = true
 		} else {
 			t.EstimatedUSDPrice = t.Price * val
+			t.ConversionRoute = route
+			t.ConversionConfidence = confidence
 		}
 	} else {
 		t.EstimatedUSDPrice = t.Price
+		t.ConversionConfidence = 1
 	}
 
 	// // If estimated price for stablecoin diverges too much ignore trade
@@ -125,48 +347,53 @@ func (s *TradesBlockService) process(t dia.Trade) {
 			ignoreTrade = true
 		}
 	}
-	// Comment Philipp: We could make another check here. Store CG and/or CMC quotation in redis cache
-	// and compare with estimatedUSDPrice. If deviation is too large ignore trade. If we do so,
-	// we should already think about how to do it best with regards to historic values, as these are coming up.
+	// Compare with foreign quotations and our own MA120 valid at the time of the trade.
+	// Outliers are quarantined rather than stored with the trades.
+	if !ignoreTrade && s.priceGuard != nil {
+		if reason, ok := s.priceGuard.Check(t); !ok {
+			log.Warnf("quarantine trade %v: %s", t, reason)
+			err := s.datastore.SaveQuarantinedTradeInflux(&t, reason)
+			if err != nil {
+				log.Error("SaveQuarantinedTradeInflux ", err)
+			}
+			ignoreTrade = true
+		}
+	}
 
 	if !ignoreTrade {
 		s.datastore.SaveTradeInflux(&t)
+		s.conversionGraph.addRate(t.Symbol, baseToken, t.Price, t.Time)
 	}
 
-	if s.currentBlock != nil &&
-		s.currentBlock.TradesBlockData.BeginTime.After(t.Time) {
-		log.Debugf("ignore trade should be in previous block %v", t)
-		ignoreTrade = true
+	if ignoreTrade {
+		log.Debugf("ignore trade  %v", t)
+		return
 	}
 
-	if !ignoreTrade {
+	if t.Time.Before(s.finalisedUntil) {
+		log.Debugf("late trade, its block is already finalised %v", t)
+		s.handleLateTrade(t)
+		return
+	}
 
-		if s.currentBlock == nil || s.currentBlock.TradesBlockData.EndTime.Before(t.Time) {
-			if s.currentBlock != nil {
-				s.finaliseCurrentBlock()
-			}
+	block := s.blockForTrade(t)
+	block.TradesBlockData.Trades = append(block.TradesBlockData.Trades, t)
 
-			b := &dia.TradesBlock{
-				TradesBlockData: dia.TradesBlockData{
-					Trades:    []dia.Trade{},
-					EndTime:   time.Unix((t.Time.Unix()/s.BlockDuration)*s.BlockDuration+s.BlockDuration, 0),
-					BeginTime: time.Unix((t.Time.Unix()/s.BlockDuration)*s.BlockDuration, 0),
-				},
-			}
-			if s.currentBlock != nil {
-				log.Info("created new block beginTime:", b.TradesBlockData.BeginTime, "previous block nb trades:", len(s.currentBlock.TradesBlockData.Trades))
-			}
-			s.currentBlock = b
-			s.datastore.Flush()
-		}
-		s.currentBlock.TradesBlockData.Trades = append(s.currentBlock.TradesBlockData.Trades, t)
-	} else {
-		log.Debugf("ignore trade  %v", t)
+	// In ticker mode blocks are finalised by mainLoop only
+	if !s.FinaliseOnTick && t.Time.After(s.latestTradeTime) {
+		s.latestTradeTime = t.Time
+		s.finaliseBlocksBefore(s.latestTradeTime.Add(-s.GracePeriod))
 	}
 }
 
 // runs in a goroutine until s is closed
 func (s *TradesBlockService) mainLoop() {
+	var tick <-chan time.Time
+	if s.FinaliseOnTick {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 	for {
 		select {
 		case <-s.shutdown:
@@ -175,6 +402,8 @@ func (s *TradesBlockService) mainLoop() {
 			return
 		case t := <-s.chanTrades:
 			s.process(*t)
+		case now := <-tick:
+			s.finaliseBlocksBefore(now.Add(-s.GracePeriod))
 		}
 	}
 }
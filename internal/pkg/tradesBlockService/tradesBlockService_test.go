@@ -0,0 +1,141 @@
+package tradesBlockService
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	models "github.com/diadata-org/diadata/pkg/model"
+)
+
+func TestLateTrades(t *testing.T) {
+	var blockDuration int64 = 120
+	s := NewTradesBlockService(models.NewMemoryDataStore(), blockDuration, 30*time.Second, nil)
+	defer s.Close()
+
+	lateTradesBefore := expvarInt(lateTradesMetric.Get(dia.BinanceExchange))
+	t0 := time.Unix(1000*blockDuration, 0)
+	offsets := []int64{10, 130, 100, 160, 50}
+	go func() {
+		for i, offset := range offsets {
+			s.ProcessTrade(&dia.Trade{
+				Symbol:         "BTC",
+				Pair:           "BTC-USD",
+				Price:          10000,
+				Volume:         1,
+				Time:           t0.Add(time.Duration(offset) * time.Second),
+				ForeignTradeID: string(rune('a' + i)),
+				Source:         dia.BinanceExchange,
+			})
+		}
+	}()
+
+	select {
+	case block := <-s.Channel():
+		if !block.TradesBlockData.BeginTime.Equal(t0) || block.TradesBlockData.TradesNumber != 2 {
+			t.Errorf("Finalised block was incorrect, got: %v trades beginning at %v, want: 2 trades beginning at %v.",
+				block.TradesBlockData.TradesNumber, block.TradesBlockData.BeginTime, t0)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No block was finalised.")
+	}
+
+	select {
+	case late := <-s.LateTradesChannel():
+		if !late.Time.Equal(t0.Add(50 * time.Second)) {
+			t.Errorf("Late trade was incorrect, got: %v.", late)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Late trade was not forwarded.")
+	}
+
+	stat := s.LateTradeStats()[dia.BinanceExchange]
+	if stat.NumTrades != 1 || stat.VolumeUSD != 10000 || stat.MaxDelay != 70*time.Second {
+		t.Errorf("Late trade stats were incorrect, got: %+v.", stat)
+	}
+	if lateTrades := expvarInt(lateTradesMetric.Get(dia.BinanceExchange)) - lateTradesBefore; lateTrades != 1 {
+		t.Errorf("Late trades metric was incorrect, got: %d, want: %d.", lateTrades, 1)
+	}
+}
+
+func expvarInt(v expvar.Var) int64 {
+	if i, ok := v.(*expvar.Int); ok {
+		return i.Value()
+	}
+	return 0
+}
+
+func TestFinaliseOnTick(t *testing.T) {
+	var blockDuration int64 = 120
+	s := &TradesBlockService{
+		chanTradesBlock: make(chan *dia.TradesBlock, 10),
+		BlockDuration:   blockDuration,
+		FinaliseOnTick:  true,
+		datastore:       models.NewMemoryDataStore(),
+	}
+
+	t0 := time.Unix(1000*blockDuration, 0)
+	block := s.blockForTrade(dia.Trade{Symbol: "BTC", Time: t0.Add(10 * time.Second)})
+	block.TradesBlockData.Trades = append(block.TradesBlockData.Trades, dia.Trade{Symbol: "BTC", Time: t0.Add(10 * time.Second)})
+
+	s.finaliseBlocksBefore(t0.Add(370 * time.Second))
+	close(s.chanTradesBlock)
+
+	tables := []struct {
+		beginTime    time.Time
+		tradesNumber int
+	}{
+		{t0, 1},
+		{t0.Add(120 * time.Second), 0},
+		{t0.Add(240 * time.Second), 0},
+	}
+	var i int
+	for b := range s.chanTradesBlock {
+		if i >= len(tables) {
+			t.Fatalf("Too many blocks finalised, got: %v.", b.TradesBlockData.BeginTime)
+		}
+		if !b.TradesBlockData.BeginTime.Equal(tables[i].beginTime) || b.TradesBlockData.TradesNumber != tables[i].tradesNumber {
+			t.Errorf("Block %v was incorrect, got: %v trades beginning at %v, want: %v trades beginning at %v.",
+				i, b.TradesBlockData.TradesNumber, b.TradesBlockData.BeginTime, tables[i].tradesNumber, tables[i].beginTime)
+		}
+		i++
+	}
+	if i != len(tables) {
+		t.Errorf("Number of finalised blocks was incorrect, got: %v, want: %v.", i, len(tables))
+	}
+	if len(s.openBlocks) != 0 || !s.finalisedUntil.Equal(t0.Add(360*time.Second)) {
+		t.Errorf("Service state after finalisation was incorrect, open blocks: %v, finalised until: %v.", len(s.openBlocks), s.finalisedUntil)
+	}
+}
+
+// TestFinaliseOnTickIgnoresTradeTime checks that trades ahead of the wall clock, e.g. of
+// another shard, do not finalise blocks in ticker mode.
+func TestFinaliseOnTickIgnoresTradeTime(t *testing.T) {
+	var blockDuration int64 = 120
+	ds := models.NewMemoryDataStore()
+	s := &TradesBlockService{
+		chanTradesBlock: make(chan *dia.TradesBlock, 10),
+		BlockDuration:   blockDuration,
+		GracePeriod:     30 * time.Second,
+		FinaliseOnTick:  true,
+		conversionGraph: newConversionGraph(ds),
+		deduplicator:    newTradeDeduplicator(dedupWindow),
+		datastore:       ds,
+	}
+
+	t0 := time.Unix(1000*blockDuration, 0)
+	for _, offset := range []int64{10, 1000} {
+		s.process(dia.Trade{
+			Symbol: "BTC",
+			Pair:   "BTC-USD",
+			Price:  10000,
+			Volume: 1,
+			Time:   t0.Add(time.Duration(offset) * time.Second),
+			Source: dia.BinanceExchange,
+		})
+	}
+	if len(s.chanTradesBlock) != 0 || len(s.openBlocks) != 2 {
+		t.Errorf("Blocks were finalised by trade time, finalised: %v, open: %v.", len(s.chanTradesBlock), len(s.openBlocks))
+	}
+}
@@ -0,0 +1,53 @@
+package dia
+
+import "time"
+
+// Scopes of API keys
+const (
+	// ScopeRead is required by requests to the read endpoints sent with an API key.
+	ScopeRead           = "read"
+	ScopeWriteSupply    = "write-supply"
+	ScopeIndexRebalance = "index-rebalance"
+	// ScopeAdmin grants all scopes and the management of API keys.
+	ScopeAdmin = "admin"
+)
+
+// APIKeyScopes are all scopes an API key can be granted.
+var APIKeyScopes = []string{ScopeRead, ScopeWriteSupply, ScopeIndexRebalance, ScopeAdmin}
+
+// APIKey is the key of a client of the REST API.
+type APIKey struct {
+	Key string
+	// SecretHash is the hex encoded SHA-256 hash of the secret of the key.
+	SecretHash string `json:"-"`
+	// Name is the client the key is issued to.
+	Name   string
+	Scopes []string
+	// RateLimit is the number of requests per minute allowed with the key, 0 for no limit.
+	RateLimit int
+	CreatedAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+// APIKeyUsage is the number of requests made with an API key on a day.
+type APIKeyUsage struct {
+	Key      string
+	Day      string
+	Requests int64
+}
+
+// HasScope returns true if the key is granted @scope.
+func (key *APIKey) HasScope(scope string) bool {
+	for _, s := range key.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked returns true if the key was revoked.
+func (key *APIKey) Revoked() bool {
+	return key.RevokedAt != nil
+}
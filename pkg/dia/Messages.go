@@ -229,6 +229,12 @@ type Trade struct {
 	ForeignTradeID    string
 	EstimatedUSDPrice float64 // will be filled by the TradeBlock Service
 	Source            string
+	// ConversionRoute is the chain of assets the base token was converted to USD through,
+	// e.g. [UNI WETH USD]. Will be filled by the TradeBlock Service.
+	ConversionRoute []string
+	// ConversionConfidence in [0,1] decreases with the number of hops and the age of the
+	// rates used for the conversion to USD. Will be filled by the TradeBlock Service.
+	ConversionConfidence float64
 }
 
 type ItinToken struct {
@@ -312,16 +318,36 @@ type TradesBlockData struct {
 	EndTime      time.Time
 	TradesNumber int
 	Trades       []Trade
+	// PreviousBlockHash is the BlockHash of the preceding trades block, empty for the
+	// first block published by a service.
+	PreviousBlockHash string
 }
 
 type TradesBlock struct {
 	BlockHash       string
 	TradesBlockData TradesBlockData
+	// Signature is the ed25519 signature of the block by the tradesBlockService, empty
+	// if the block is unsigned. See signingHelper.
+	Signature []byte
+}
+
+// TradesBlockShard is the part of a trades block with the trades of the partitions of the trades
+// topic consumed by one instance of a sharded tradesBlockService. The shards of a block are
+// merged into the TradesBlock.
+type TradesBlockShard struct {
+	Shard     int
+	NumShards int
+	BeginTime time.Time
+	EndTime   time.Time
+	Trades    []Trade
 }
 
 type FiltersBlock struct {
 	BlockHash        string
 	FiltersBlockData FiltersBlockData
+	// Signature is the ed25519 signature of the block by the filtersBlockService, empty
+	// if the block is unsigned. See signingHelper.
+	Signature []byte
 }
 
 type FiltersBlockData struct {
@@ -330,13 +356,21 @@ type FiltersBlockData struct {
 	EndTime         time.Time
 	FilterPoints    []FilterPoint
 	FiltersNumber   int
+	// PreviousBlockHash is the BlockHash of the preceding filters block, empty for the
+	// first block published by a service.
+	PreviousBlockHash string
 }
 
 type FilterPoint struct {
 	Symbol string
-	Value  float64
-	Name   string
-	Time   time.Time
+	// Exchange is empty for filters over all exchanges.
+	Exchange string
+	Value    float64
+	Name     string
+	Time     time.Time
+	// CarriedForward is set if there were no trades for the symbol in the block,
+	// so that Value is carried forward from previous blocks.
+	CarriedForward bool
 }
 
 type IndexBlock struct {
@@ -463,6 +497,19 @@ func (e *TradesBlock) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// MarshalBinary -
+func (e *TradesBlockShard) MarshalBinary() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// UnmarshalBinary -
+func (e *TradesBlockShard) UnmarshalBinary(data []byte) error {
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	return nil
+}
+
 // MarshalBinary -
 func (e *Supply) MarshalBinary() ([]byte, error) {
 	return json.Marshal(e)
@@ -575,6 +622,19 @@ func (e *IndexBlock) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// MarshalBinary -
+func (e *OptionOrderbookDatum) MarshalBinary() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// UnmarshalBinary -
+func (e *OptionOrderbookDatum) UnmarshalBinary(data []byte) error {
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	return nil
+}
+
 // MarshalBinary -
 func (e *SuppliesBlock) MarshalBinary() ([]byte, error) {
 	return json.Marshal(e)
@@ -0,0 +1,134 @@
+package dia
+
+import (
+	"sort"
+	"time"
+)
+
+// OrderBookDepthRange is the distance from the mid price, relative to it, within which the depth
+// of an order book is measured.
+const OrderBookDepthRange = 0.02
+
+// OrderBookLevel is the volume of the base asset offered at a price.
+type OrderBookLevel struct {
+	Price  float64
+	Volume float64
+}
+
+// OrderBook is the L2 order book of a pair on an exchange. Bids are sorted by decreasing,
+// asks by increasing price.
+type OrderBook struct {
+	Symbol   string
+	Pair     string
+	Exchange string
+	Bids     []OrderBookLevel
+	Asks     []OrderBookLevel
+	// Sequence is the update id of the exchange the book is at, 0 if updates are not numbered.
+	Sequence int64
+	Time     time.Time
+}
+
+// OrderBookMetrics describe the liquidity of an order book at a point in time.
+type OrderBookMetrics struct {
+	Symbol   string
+	Pair     string
+	Exchange string
+	BestBid  float64
+	BestAsk  float64
+	// Spread is the difference of the best ask and bid relative to the mid price.
+	Spread float64
+	// BidDepthUSD and AskDepthUSD are the values of the bids and asks within
+	// OrderBookDepthRange of the mid price.
+	BidDepthUSD float64
+	AskDepthUSD float64
+	Time        time.Time
+}
+
+// SetBid sets the volume of the bids at @price. The level is removed if @volume is 0.
+func (ob *OrderBook) SetBid(price float64, volume float64) {
+	ob.Bids = setLevel(ob.Bids, price, volume, func(p float64) bool { return p <= price })
+}
+
+// SetAsk sets the volume of the asks at @price. The level is removed if @volume is 0.
+func (ob *OrderBook) SetAsk(price float64, volume float64) {
+	ob.Asks = setLevel(ob.Asks, price, volume, func(p float64) bool { return p >= price })
+}
+
+// setLevel sets @price to @volume in @levels, where @after reports whether a price is at or
+// after @price in the order of @levels.
+func setLevel(levels []OrderBookLevel, price float64, volume float64, after func(float64) bool) []OrderBookLevel {
+	i := sort.Search(len(levels), func(i int) bool { return after(levels[i].Price) })
+	found := i < len(levels) && levels[i].Price == price
+	switch {
+	case volume == 0 && found:
+		return append(levels[:i], levels[i+1:]...)
+	case volume == 0:
+		return levels
+	case found:
+		levels[i].Volume = volume
+		return levels
+	}
+	levels = append(levels, OrderBookLevel{})
+	copy(levels[i+1:], levels[i:])
+	levels[i] = OrderBookLevel{Price: price, Volume: volume}
+	return levels
+}
+
+// Copy returns a deep copy of the order book.
+func (ob *OrderBook) Copy() OrderBook {
+	c := *ob
+	c.Bids = append([]OrderBookLevel{}, ob.Bids...)
+	c.Asks = append([]OrderBookLevel{}, ob.Asks...)
+	return c
+}
+
+// MidPrice returns the mean of the best bid and ask, 0 if a side is empty.
+func (ob *OrderBook) MidPrice() float64 {
+	if len(ob.Bids) == 0 || len(ob.Asks) == 0 {
+		return 0
+	}
+	return (ob.Bids[0].Price + ob.Asks[0].Price) / 2
+}
+
+// Depth returns the volumes of the bids and asks with prices within @fraction of the mid price.
+func (ob *OrderBook) Depth(fraction float64) (bidVolume float64, askVolume float64) {
+	mid := ob.MidPrice()
+	if mid == 0 {
+		return
+	}
+	for _, level := range ob.Bids {
+		if level.Price < mid*(1-fraction) {
+			break
+		}
+		bidVolume += level.Volume
+	}
+	for _, level := range ob.Asks {
+		if level.Price > mid*(1+fraction) {
+			break
+		}
+		askVolume += level.Volume
+	}
+	return
+}
+
+// Metrics returns the metrics of the order book, where @priceUSD is the price of the base asset
+// in USD.
+func (ob *OrderBook) Metrics(priceUSD float64) OrderBookMetrics {
+	metrics := OrderBookMetrics{
+		Symbol:   ob.Symbol,
+		Pair:     ob.Pair,
+		Exchange: ob.Exchange,
+		Time:     ob.Time,
+	}
+	mid := ob.MidPrice()
+	if mid == 0 {
+		return metrics
+	}
+	metrics.BestBid = ob.Bids[0].Price
+	metrics.BestAsk = ob.Asks[0].Price
+	metrics.Spread = (metrics.BestAsk - metrics.BestBid) / mid
+	bidVolume, askVolume := ob.Depth(OrderBookDepthRange)
+	metrics.BidDepthUSD = bidVolume * priceUSD
+	metrics.AskDepthUSD = askVolume * priceUSD
+	return metrics
+}
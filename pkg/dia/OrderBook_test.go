@@ -0,0 +1,65 @@
+package dia
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOrderBookSetLevels(t *testing.T) {
+	ob := &OrderBook{}
+	ob.SetBid(99, 1)
+	ob.SetBid(101, 2)
+	ob.SetBid(100, 3)
+	ob.SetBid(101, 4)
+	ob.SetBid(98, 0)
+	ob.SetAsk(103, 1)
+	ob.SetAsk(102, 2)
+	ob.SetAsk(104, 3)
+	ob.SetAsk(103, 0)
+
+	bids := []OrderBookLevel{{101, 4}, {100, 3}, {99, 1}}
+	asks := []OrderBookLevel{{102, 2}, {104, 3}}
+	if len(ob.Bids) != len(bids) || len(ob.Asks) != len(asks) {
+		t.Fatalf("Levels were incorrect, got: %v/%v, want: %v/%v.", ob.Bids, ob.Asks, bids, asks)
+	}
+	for i, level := range bids {
+		if ob.Bids[i] != level {
+			t.Errorf("Bid %d was incorrect, got: %v, want: %v.", i, ob.Bids[i], level)
+		}
+	}
+	for i, level := range asks {
+		if ob.Asks[i] != level {
+			t.Errorf("Ask %d was incorrect, got: %v, want: %v.", i, ob.Asks[i], level)
+		}
+	}
+}
+
+func TestOrderBookMetrics(t *testing.T) {
+	ob := &OrderBook{
+		Bids: []OrderBookLevel{{99, 1}, {98, 2}, {97, 4}},
+		Asks: []OrderBookLevel{{101, 1}, {102, 2}, {103, 4}},
+	}
+	metrics := ob.Metrics(10)
+	tables := []struct {
+		name  string
+		value float64
+		want  float64
+	}{
+		{"best bid", metrics.BestBid, 99},
+		{"best ask", metrics.BestAsk, 101},
+		{"spread", metrics.Spread, 0.02},
+		// 98 and 102 are exactly 2% from the mid price of 100
+		{"bid depth", metrics.BidDepthUSD, 30},
+		{"ask depth", metrics.AskDepthUSD, 30},
+	}
+	for _, table := range tables {
+		if math.Abs(table.value-table.want) > 1e-9 {
+			t.Errorf("Metric %s was incorrect, got: %v, want: %v.", table.name, table.value, table.want)
+		}
+	}
+
+	empty := (&OrderBook{Bids: ob.Bids}).Metrics(10)
+	if empty.Spread != 0 || empty.BidDepthUSD != 0 {
+		t.Errorf("Metrics of a one-sided book were incorrect, got: %v.", empty)
+	}
+}
@@ -42,6 +42,12 @@ func (t *Trade) BaseToken() string {
 	return strings.TrimPrefix(pair, strings.ToUpper(t.Symbol))
 }
 
+// PartitionKey returns the key trades are partitioned by in kafka, so that the trades of a pair
+// on an exchange are kept in order.
+func (t *Trade) PartitionKey() []byte {
+	return []byte(t.Source + ":" + t.Pair)
+}
+
 // SwapTrade swaps base and quote token of a trade and inverts the price accordingly
 func SwapTrade(t Trade) (Trade, error) {
 	if t.Price == 0 {
@@ -0,0 +1,109 @@
+package dia
+
+import (
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOREncMode encodes times with nanoseconds and time zone offset, as in JSON, so that the
+// JSON encoding of a message is the same after a round trip through CBOR. Signatures of blocks
+// are computed over the JSON encoding.
+var CBOREncMode, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+// The CBOR methods take precedence over MarshalBinary, which CBOR encoders would otherwise
+// use to embed the JSON encoding. The local plain types drop all methods of the messages.
+
+// MarshalCBOR -
+func (e *Trade) MarshalCBOR() ([]byte, error) {
+	type plain Trade
+	return CBOREncMode.Marshal((*plain)(e))
+}
+
+// UnmarshalCBOR -
+func (e *Trade) UnmarshalCBOR(data []byte) error {
+	type plain Trade
+	return cbor.Unmarshal(data, (*plain)(e))
+}
+
+// MarshalCBOR -
+func (e *TradesBlock) MarshalCBOR() ([]byte, error) {
+	type plain TradesBlock
+	return CBOREncMode.Marshal((*plain)(e))
+}
+
+// UnmarshalCBOR -
+func (e *TradesBlock) UnmarshalCBOR(data []byte) error {
+	type plain TradesBlock
+	return cbor.Unmarshal(data, (*plain)(e))
+}
+
+// MarshalCBOR -
+func (e *TradesBlockShard) MarshalCBOR() ([]byte, error) {
+	type plain TradesBlockShard
+	return CBOREncMode.Marshal((*plain)(e))
+}
+
+// UnmarshalCBOR -
+func (e *TradesBlockShard) UnmarshalCBOR(data []byte) error {
+	type plain TradesBlockShard
+	return cbor.Unmarshal(data, (*plain)(e))
+}
+
+// MarshalCBOR -
+func (e *FiltersBlock) MarshalCBOR() ([]byte, error) {
+	type plain FiltersBlock
+	return CBOREncMode.Marshal((*plain)(e))
+}
+
+// UnmarshalCBOR -
+func (e *FiltersBlock) UnmarshalCBOR(data []byte) error {
+	type plain FiltersBlock
+	return cbor.Unmarshal(data, (*plain)(e))
+}
+
+// MarshalCBOR -
+func (e *Supply) MarshalCBOR() ([]byte, error) {
+	type plain Supply
+	return CBOREncMode.Marshal((*plain)(e))
+}
+
+// UnmarshalCBOR -
+func (e *Supply) UnmarshalCBOR(data []byte) error {
+	type plain Supply
+	return cbor.Unmarshal(data, (*plain)(e))
+}
+
+// MarshalCBOR -
+func (e *SuppliesBlock) MarshalCBOR() ([]byte, error) {
+	type plain SuppliesBlock
+	return CBOREncMode.Marshal((*plain)(e))
+}
+
+// UnmarshalCBOR -
+func (e *SuppliesBlock) UnmarshalCBOR(data []byte) error {
+	type plain SuppliesBlock
+	return cbor.Unmarshal(data, (*plain)(e))
+}
+
+// MarshalCBOR -
+func (e *IndexBlock) MarshalCBOR() ([]byte, error) {
+	type plain IndexBlock
+	return CBOREncMode.Marshal((*plain)(e))
+}
+
+// UnmarshalCBOR -
+func (e *IndexBlock) UnmarshalCBOR(data []byte) error {
+	type plain IndexBlock
+	return cbor.Unmarshal(data, (*plain)(e))
+}
+
+// MarshalCBOR -
+func (e *OptionOrderbookDatum) MarshalCBOR() ([]byte, error) {
+	type plain OptionOrderbookDatum
+	return CBOREncMode.Marshal((*plain)(e))
+}
+
+// UnmarshalCBOR -
+func (e *OptionOrderbookDatum) UnmarshalCBOR(data []byte) error {
+	type plain OptionOrderbookDatum
+	return cbor.Unmarshal(data, (*plain)(e))
+}
@@ -7,9 +7,9 @@ import (
 	"io"
 	"net"
 	"os"
+	"reflect"
 	"time"
 
-	"github.com/diadata-org/diadata/pkg/dia"
 	"github.com/segmentio/kafka-go"
 	log "github.com/sirupsen/logrus"
 )
@@ -26,6 +26,12 @@ type KafkaMessageWithAHash interface {
 	Hash() string
 }
 
+// KafkaMessageWithAKey is written with its PartitionKey as message key. Messages with the same
+// key are written to the same partition of keyed topics.
+type KafkaMessageWithAKey interface {
+	PartitionKey() []byte
+}
+
 const (
 	TopicIndexBlock      = 0
 	TopicFiltersBlock    = 1
@@ -36,6 +42,8 @@ const (
 	TopicIndexBlockDaily = 11
 	retryDelay           = 2 * time.Second
 	TopicOptionOrderBook          = 13
+	TopicTradesLate      = 14
+	TopicTradesBlockShards = 15
 
 )
 
@@ -50,16 +58,11 @@ func GetTopic(topic int) string {
 }
 
 func getTopic(topic int) string {
-	topicMap := map[int]string{
-		1: "filtersBlock",
-		2: "trades",
-		3: "tradesBlock",
-	}
-	result, ok := topicMap[topic]
-	if !ok {
+	config, err := GetTopicConfig(topic)
+	if err != nil {
 		log.Error("getTopic cant fine topic", topic)
 	}
-	return result
+	return config.Name
 }
 
 func init() {
@@ -93,9 +96,14 @@ func init() {
 
 // WithRetryOnError
 func ReadOffset(topic int) (int64, error) {
+	return ReadPartitionOffset(topic, 0)
+}
+
+// ReadPartitionOffset returns the offset of the next message written to @partition of @topic.
+func ReadPartitionOffset(topic int, partition int) (int64, error) {
 	var err error
 	for _, ip := range KafkaConfig.KafkaUrl {
-		conn, err := kafka.DialLeader(context.Background(), "tcp", ip, getTopic(topic), 0)
+		conn, err := kafka.DialLeader(context.Background(), "tcp", ip, getTopic(topic), partition)
 		if err != nil {
 			log.Errorln("ReadOffset conn error: <", err, "> ", ip)
 		} else {
@@ -136,22 +144,28 @@ func ReadOffsetWithRetryOnError(topic int) (offset int64) {
 }
 
 func NewWriter(topic int) *kafka.Writer {
-	return kafka.NewWriter(kafka.WriterConfig{
+	createTopics()
+	w := kafka.NewWriter(kafka.WriterConfig{
 		Brokers:  KafkaConfig.KafkaUrl,
 		Topic:    getTopic(topic),
-		Balancer: &kafka.LeastBytes{},
+		Balancer: balancer(topic),
 		Async:    true,
 	})
+	writerTopics.Store(w, topic)
+	return w
 }
 
 func NewSyncWriter(topic int) *kafka.Writer {
-	return kafka.NewWriter(kafka.WriterConfig{
+	createTopics()
+	w := kafka.NewWriter(kafka.WriterConfig{
 		Brokers:    KafkaConfig.KafkaUrl,
 		Topic:      getTopic(topic),
-		Balancer:   &kafka.LeastBytes{},
+		Balancer:   balancer(topic),
 		Async:      false,
 		BatchBytes: 1e9, // 1GB
 	})
+	writerTopics.Store(w, topic)
+	return w
 }
 
 func NewReader(topic int) *kafka.Reader {
@@ -165,9 +179,20 @@ func NewReader(topic int) *kafka.Reader {
 	return r
 }
 
+// WriteMessage writes @m with the encoding of the topic of @w, see SetTopicEncoding.
+// Messages of a type other than the message type of the topic are rejected with ErrMessageType.
 func WriteMessage(w *kafka.Writer, m KafkaMessage) error {
+	if topic, ok := writerTopics.Load(w); ok {
+		if err := checkMessageType(topic.(int), m); err != nil {
+			log.Errorln("Skipping write of message ", err)
+			return err
+		}
+	}
 	key := []byte("helloKafka")
-	value, err := m.MarshalBinary()
+	if k, ok := m.(KafkaMessageWithAKey); ok {
+		key = k.PartitionKey()
+	}
+	value, err := Encode(m, writerEncoding(w))
 	if err == nil && value != nil {
 		err := w.WriteMessages(context.Background(),
 			kafka.Message{
@@ -216,6 +241,14 @@ func NewReaderNextMessage(topic int) *kafka.Reader {
 	return r
 }
 
+// NewReaderAtOffset returns a reader of @topic starting at @offset.
+func NewReaderAtOffset(topic int, offset int64) *kafka.Reader {
+	r := NewReader(topic)
+	r.SetOffset(offset)
+	log.Printf("Reading from offset %d on topic %s", offset, getTopic(topic))
+	return r
+}
+
 func IsTopicEmpty(topic int) bool {
 	log.Println("IsTopicEmpty: ", topic)
 	offset := ReadOffsetWithRetryOnError(topic)
@@ -253,12 +286,17 @@ func GetLastElement(topic int) (interface{}, error) {
 }
 
 func GetElements(topic int, offset int64, nbElements int) ([]interface{}, error) {
+	return GetPartitionElements(topic, 0, offset, nbElements)
+}
+
+// GetPartitionElements returns @nbElements messages of @partition of @topic, starting at @offset.
+func GetPartitionElements(topic int, partition int, offset int64, nbElements int) ([]interface{}, error) {
 
 	var result []interface{}
 
 	var maxOffset = offset + int64(nbElements)
 
-	conn, err := kafka.DialLeader(context.Background(), "tcp", KafkaConfig.KafkaUrl[0], getTopic(topic), 0)
+	conn, err := kafka.DialLeader(context.Background(), "tcp", KafkaConfig.KafkaUrl[0], getTopic(topic), partition)
 
 	if err != nil {
 		log.Errorln("kafka error:", err)
@@ -291,29 +329,13 @@ func GetElements(topic int, offset int64, nbElements int) ([]interface{}, error)
 			}
 			b2 := b[:z]
 
-			err = nil
-
-			switch topic {
-			case TopicFiltersBlock:
-				var e dia.FiltersBlock
-				err = e.UnmarshalBinary(b2)
-				if err == nil {
-					result = append(result, e)
-				}
-			case TopicTrades:
-				var e dia.Trade
-				err = e.UnmarshalBinary(b2)
-				if err == nil {
-					result = append(result, e)
-				}
-			case TopicTradesBlock:
-				var e dia.TradesBlock
-				err = e.UnmarshalBinary(b2)
-				if err == nil {
-					result = append(result, e)
-				}
-			default:
-				return nil, errors.New("Missing case unknown topic in switch... function GetElements / Kafka.go")
+			e, err := newMessage(topic)
+			if err != nil {
+				return nil, err
+			}
+			err = Decode(b2, e)
+			if err == nil {
+				result = append(result, reflect.ValueOf(e).Elem().Interface())
 			}
 
 			if err != nil {
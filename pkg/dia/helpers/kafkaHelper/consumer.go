@@ -0,0 +1,158 @@
+package kafkaHelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// StartPosition selects the first message read by a Consumer.
+type StartPosition int
+
+const (
+	// StartLatest skips all messages produced before the consumer started.
+	StartLatest StartPosition = iota
+	// StartEarliest reads all messages retained by kafka.
+	StartEarliest
+	// StartTimestamp reads the messages produced after ConsumerOptions.Timestamp.
+	StartTimestamp
+	// StartOffset reads the messages from ConsumerOptions.Offset, e.g. an offset stored along with
+	// the state of a service.
+	StartOffset
+)
+
+var startPositionNames = map[string]StartPosition{
+	"latest":    StartLatest,
+	"earliest":  StartEarliest,
+	"timestamp": StartTimestamp,
+	"offset":    StartOffset,
+}
+
+// ParseStartPosition returns the start position named @s, i.e. latest, earliest, timestamp or offset.
+func ParseStartPosition(s string) (StartPosition, error) {
+	p, ok := startPositionNames[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown start position %s", s)
+	}
+	return p, nil
+}
+
+// ConsumerOptions configure a Consumer.
+type ConsumerOptions struct {
+	// GroupID enables reading as a member of a consumer group. The offsets of a group are
+	// committed by Commit, and a restarted consumer continues after the last committed
+	// offset. Start only applies if the group has not committed an offset yet, and must
+	// be StartLatest or StartEarliest. The partitions of the topic are assigned to the
+	// members of the group.
+	GroupID   string
+	Start     StartPosition
+	Timestamp time.Time
+	Offset    int64
+	// Partition is the partition read by consumers without group.
+	Partition int
+}
+
+// Consumer reads messages of a topic with at-least-once semantics: messages which have been
+// fetched but not committed are read again after a restart.
+type Consumer struct {
+	topic  int
+	reader *kafka.Reader
+	group  bool
+}
+
+// NewConsumer returns a Consumer of @topic configured by @options.
+func NewConsumer(topic int, options ConsumerOptions) (*Consumer, error) {
+	createTopics()
+	config := kafka.ReaderConfig{
+		Brokers:  KafkaConfig.KafkaUrl,
+		Topic:    getTopic(topic),
+		MinBytes: 0,
+		MaxBytes: 10e6, // 10MB
+	}
+
+	if options.GroupID != "" {
+		switch options.Start {
+		case StartLatest:
+			config.StartOffset = kafka.LastOffset
+		case StartEarliest:
+			config.StartOffset = kafka.FirstOffset
+		default:
+			return nil, errors.New("consumer groups start at the latest or earliest offset")
+		}
+		config.GroupID = options.GroupID
+		// Offsets are only committed explicitly by Commit
+		config.CommitInterval = 0
+		log.Printf("Reading topic %s as member of group %s", getTopic(topic), options.GroupID)
+		return &Consumer{topic: topic, reader: kafka.NewReader(config), group: true}, nil
+	}
+
+	var offset int64
+	switch options.Start {
+	case StartLatest:
+		offset = kafka.LastOffset
+	case StartEarliest:
+		offset = kafka.FirstOffset
+	case StartTimestamp:
+		var err error
+		offset, err = readOffsetAt(topic, options.Partition, options.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+	case StartOffset:
+		offset = options.Offset
+	default:
+		return nil, fmt.Errorf("unknown start position %d", options.Start)
+	}
+	config.Partition = options.Partition
+	r := kafka.NewReader(config)
+	if err := r.SetOffset(offset); err != nil {
+		r.Close()
+		return nil, err
+	}
+	log.Printf("Reading partition %d of topic %s from offset %d", options.Partition, getTopic(topic), offset)
+	return &Consumer{topic: topic, reader: r}, nil
+}
+
+// readOffsetAt returns the offset of the first message of @partition of @topic produced at or after @t.
+func readOffsetAt(topic int, partition int, t time.Time) (int64, error) {
+	var err error
+	for _, ip := range KafkaConfig.KafkaUrl {
+		var conn *kafka.Conn
+		conn, err = kafka.DialLeader(context.Background(), "tcp", ip, getTopic(topic), partition)
+		if err != nil {
+			log.Errorln("readOffsetAt conn error: <", err, "> ", ip)
+			continue
+		}
+		var offset int64
+		offset, err = conn.ReadOffset(t)
+		conn.Close()
+		if err == nil {
+			return offset, nil
+		}
+		log.Errorln("readOffsetAt ReadOffset error: <", err, "> ")
+	}
+	return 0, err
+}
+
+// Fetch returns the next message. It is read again after a restart unless it is committed.
+func (c *Consumer) Fetch(ctx context.Context) (kafka.Message, error) {
+	return c.reader.FetchMessage(ctx)
+}
+
+// Commit marks all messages of @partition up to @offset as processed. Consumers without group
+// keep no committed offsets, so Commit is a no-op for them.
+func (c *Consumer) Commit(ctx context.Context, partition int, offset int64) error {
+	if !c.group {
+		return nil
+	}
+	return c.reader.CommitMessages(ctx, kafka.Message{Topic: getTopic(c.topic), Partition: partition, Offset: offset})
+}
+
+// Close closes the underlying reader.
+func (c *Consumer) Close() error {
+	return c.reader.Close()
+}
@@ -0,0 +1,153 @@
+package kafkaHelper
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// Encoding is the encoding of the payload of kafka messages.
+type Encoding byte
+
+const (
+	// EncodingLegacyJSON is plain JSON without envelope, as written by MarshalBinary.
+	// It stays the default until all consumers of a topic decode enveloped messages.
+	EncodingLegacyJSON Encoding = iota
+	// EncodingJSON is JSON in an envelope.
+	EncodingJSON
+	// EncodingCBOR is the compact binary encoding of RFC 7049.
+	EncodingCBOR
+)
+
+const (
+	// envelopeMagic starts every enveloped message. Legacy JSON messages start with '{'.
+	envelopeMagic byte = 0xd1
+	// SchemaVersion is the version of the dia message structs. It is increased with
+	// changes consumers of older versions cannot cope with, e.g. renamed fields.
+	SchemaVersion byte = 1
+	envelopeSize       = 3
+	// encodingsEnv lists the encodings of topics, e.g. "tradesBlock=cbor,filtersBlock=cbor".
+	encodingsEnv = "KAFKA_ENCODINGS"
+)
+
+var (
+	encodingNames = map[string]Encoding{
+		"legacy": EncodingLegacyJSON,
+		"json":   EncodingJSON,
+		"cbor":   EncodingCBOR,
+	}
+
+	encodingsLock  sync.RWMutex
+	topicEncodings = make(map[int]Encoding)
+	// writerTopics are the topics of the writers returned by NewWriter and NewSyncWriter.
+	writerTopics sync.Map
+)
+
+func init() {
+	if err := parseTopicEncodings(os.Getenv(encodingsEnv)); err != nil {
+		log.Errorf("%s: %v", encodingsEnv, err)
+	}
+}
+
+// parseTopicEncodings sets the encodings of topics listed as comma separated topic=encoding pairs.
+func parseTopicEncodings(s string) error {
+	if s == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid topic encoding %s", pair)
+		}
+		topic, ok := TopicByName(kv[0])
+		if !ok {
+			return fmt.Errorf("unknown topic %s", kv[0])
+		}
+		e, ok := encodingNames[kv[1]]
+		if !ok {
+			return fmt.Errorf("unknown encoding %s", kv[1])
+		}
+		SetTopicEncoding(topic, e)
+	}
+	return nil
+}
+
+// SetTopicEncoding sets the encoding of messages written to @topic.
+func SetTopicEncoding(topic int, e Encoding) {
+	encodingsLock.Lock()
+	defer encodingsLock.Unlock()
+	topicEncodings[topic] = e
+}
+
+// TopicEncoding returns the encoding of messages written to @topic, EncodingLegacyJSON by default.
+func TopicEncoding(topic int) Encoding {
+	encodingsLock.RLock()
+	defer encodingsLock.RUnlock()
+	return topicEncodings[topic]
+}
+
+// writerEncoding returns the encoding of the topic @w writes to.
+func writerEncoding(w *kafka.Writer) Encoding {
+	topic, ok := writerTopics.Load(w)
+	if !ok {
+		return EncodingLegacyJSON
+	}
+	return TopicEncoding(topic.(int))
+}
+
+// Encode encodes @m with @e, in an envelope unless @e is EncodingLegacyJSON.
+func Encode(m KafkaMessage, e Encoding) ([]byte, error) {
+	if e == EncodingLegacyJSON {
+		return m.MarshalBinary()
+	}
+	buf := bytes.NewBuffer([]byte{envelopeMagic, SchemaVersion, byte(e)})
+	var err error
+	switch e {
+	case EncodingJSON:
+		err = json.NewEncoder(buf).Encode(m)
+	case EncodingCBOR:
+		err = dia.CBOREncMode.NewEncoder(buf).Encode(m)
+	default:
+		err = fmt.Errorf("unknown encoding %d", e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes the payload @data of a kafka message into @v, which must be a pointer.
+// Messages without envelope are decoded as legacy JSON.
+func Decode(data []byte, v interface{}) error {
+	if len(data) == 0 || data[0] != envelopeMagic {
+		if u, ok := v.(encoding.BinaryUnmarshaler); ok {
+			return u.UnmarshalBinary(data)
+		}
+		return json.Unmarshal(data, v)
+	}
+	if len(data) < envelopeSize {
+		return errors.New("truncated envelope")
+	}
+	if data[1] > SchemaVersion {
+		return fmt.Errorf("unsupported schema version %d", data[1])
+	}
+	payload := data[envelopeSize:]
+	switch Encoding(data[2]) {
+	case EncodingJSON:
+		return json.Unmarshal(payload, v)
+	case EncodingCBOR:
+		return cbor.Unmarshal(payload, v)
+	default:
+		return fmt.Errorf("unknown encoding %d", data[2])
+	}
+}
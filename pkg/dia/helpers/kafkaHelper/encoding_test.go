@@ -0,0 +1,119 @@
+package kafkaHelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+// testTradesBlock returns a trades block of @n trades.
+func testTradesBlock(n int) *dia.TradesBlock {
+	d := time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)
+	b := &dia.TradesBlock{
+		BlockHash: "v1_4c1f2d3e",
+		TradesBlockData: dia.TradesBlockData{
+			BeginTime:         d,
+			EndTime:           d.Add(dia.BlockSizeSeconds * time.Second),
+			TradesNumber:      n,
+			PreviousBlockHash: "v1_9a8b7c6d",
+		},
+	}
+	for i := 0; i < n; i++ {
+		b.TradesBlockData.Trades = append(b.TradesBlockData.Trades, dia.Trade{
+			Symbol:               "BTC",
+			Pair:                 "BTCUSDT",
+			Price:                13000 + float64(i)/7,
+			Volume:               -0.0125 * float64(i%13),
+			Time:                 d.Add(time.Duration(i) * 123456789 * time.Nanosecond),
+			ForeignTradeID:       strconv.Itoa(100000 + i),
+			EstimatedUSDPrice:    13000.5 + float64(i)/7,
+			Source:               dia.BinanceExchange,
+			ConversionRoute:      []string{"USDT", "USD"},
+			ConversionConfidence: 0.95,
+		})
+	}
+	return b
+}
+
+func TestEncoding(t *testing.T) {
+	block := testTradesBlock(10)
+	want, _ := json.Marshal(block)
+
+	for _, e := range []Encoding{EncodingLegacyJSON, EncodingJSON, EncodingCBOR} {
+		data, err := Encode(block, e)
+		if err != nil {
+			t.Fatalf("Encoding %d failed: %v", e, err)
+		}
+		if e != EncodingLegacyJSON && (data[0] != envelopeMagic || data[2] != byte(e)) {
+			t.Errorf("Envelope of encoding %d was incorrect, got: %v.", e, data[:envelopeSize])
+		}
+		var decoded dia.TradesBlock
+		if err := Decode(data, &decoded); err != nil {
+			t.Fatalf("Decoding %d failed: %v", e, err)
+		}
+		// the json encoding must survive the round trip for signatures to stay valid
+		got, _ := json.Marshal(&decoded)
+		if !bytes.Equal(got, want) {
+			t.Errorf("Round trip of encoding %d was incorrect, got: %s, want: %s.", e, got, want)
+		}
+	}
+
+	if err := Decode([]byte{envelopeMagic, SchemaVersion + 1, byte(EncodingCBOR)}, &dia.TradesBlock{}); err == nil {
+		t.Errorf("Message of unknown schema version was decoded.")
+	}
+}
+
+func TestParseTopicEncodings(t *testing.T) {
+	defer SetTopicEncoding(TopicTradesBlock, EncodingLegacyJSON)
+	if err := parseTopicEncodings("tradesBlock=cbor"); err != nil {
+		t.Fatal(err)
+	}
+	if e := TopicEncoding(TopicTradesBlock); e != EncodingCBOR {
+		t.Errorf("Encoding of tradesBlock was incorrect, got: %v, want: %v.", e, EncodingCBOR)
+	}
+	if e := TopicEncoding(TopicFiltersBlock); e != EncodingLegacyJSON {
+		t.Errorf("Encoding of filtersBlock was incorrect, got: %v, want: %v.", e, EncodingLegacyJSON)
+	}
+	for _, s := range []string{"tradesBlock", "unknown=cbor", "tradesBlock=xml"} {
+		if err := parseTopicEncodings(s); err == nil {
+			t.Errorf("Topic encodings %s were parsed.", s)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, e Encoding) {
+	block := testTradesBlock(5000)
+	var size int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := Encode(block, e)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size = len(data)
+	}
+	b.ReportMetric(float64(size), "bytes/block")
+}
+
+func benchmarkDecode(b *testing.B, e Encoding) {
+	data, err := Encode(testTradesBlock(5000), e)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var block dia.TradesBlock
+		if err := Decode(data, &block); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeLegacyJSON(b *testing.B) { benchmarkEncode(b, EncodingLegacyJSON) }
+func BenchmarkEncodeCBOR(b *testing.B)       { benchmarkEncode(b, EncodingCBOR) }
+func BenchmarkDecodeLegacyJSON(b *testing.B) { benchmarkDecode(b, EncodingLegacyJSON) }
+func BenchmarkDecodeCBOR(b *testing.B)       { benchmarkDecode(b, EncodingCBOR) }
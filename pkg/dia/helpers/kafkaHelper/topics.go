@@ -0,0 +1,287 @@
+package kafkaHelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RetentionForever keeps the messages of a topic forever.
+	RetentionForever time.Duration = -1
+	// maxReplicationFactor bounds the replication factor of created topics by the number of brokers.
+	maxReplicationFactor = 3
+	// partitionsEnv lists the partition counts of topics, e.g. "trades=12".
+	partitionsEnv = "KAFKA_PARTITIONS"
+)
+
+// ErrMessageType is returned on writes of messages whose type does not match the topic.
+var ErrMessageType = errors.New("message type does not match topic")
+
+// TopicConfig declares a kafka topic.
+type TopicConfig struct {
+	Name string
+	// Message is the type of the messages of the topic. Messages are written as pointers to it.
+	Message    reflect.Type
+	Partitions int
+	// Keyed topics distribute messages to partitions by their PartitionKey, see KafkaMessageWithAKey.
+	Keyed bool
+	// Retention is the time messages are kept. The default of the broker applies if it is 0.
+	Retention time.Duration
+}
+
+var (
+	topicRegistry = map[int]TopicConfig{
+		TopicIndexBlock: {
+			Name:       "indexBlock",
+			Message:    reflect.TypeOf(dia.IndexBlock{}),
+			Partitions: 1,
+			Retention:  RetentionForever,
+		},
+		TopicFiltersBlock: {
+			Name:       "filtersBlock",
+			Message:    reflect.TypeOf(dia.FiltersBlock{}),
+			Partitions: 1,
+			Retention:  RetentionForever,
+		},
+		TopicTrades: {
+			Name:       "trades",
+			Message:    reflect.TypeOf(dia.Trade{}),
+			Partitions: 1,
+			Keyed:      true,
+			Retention:  7 * 24 * time.Hour,
+		},
+		TopicTradesBlock: {
+			Name:       "tradesBlock",
+			Message:    reflect.TypeOf(dia.TradesBlock{}),
+			Partitions: 1,
+			Retention:  RetentionForever,
+		},
+		TopicSuppliesBlock: {
+			Name:       "suppliesBlock",
+			Message:    reflect.TypeOf(dia.SuppliesBlock{}),
+			Partitions: 1,
+			Retention:  RetentionForever,
+		},
+		TopicIndexBlock2: {
+			Name:       "indexBlock2",
+			Message:    reflect.TypeOf(dia.IndexBlock{}),
+			Partitions: 1,
+			Retention:  RetentionForever,
+		},
+		TopicIndexBlockDaily: {
+			Name:       "indexBlockDaily",
+			Message:    reflect.TypeOf(dia.IndexBlock{}),
+			Partitions: 1,
+			Retention:  RetentionForever,
+		},
+		TopicOptionOrderBook: {
+			Name:       "optionOrderBook",
+			Message:    reflect.TypeOf(dia.OptionOrderbookDatum{}),
+			Partitions: 1,
+			Retention:  7 * 24 * time.Hour,
+		},
+		TopicTradesLate: {
+			Name:       "tradesLate",
+			Message:    reflect.TypeOf(dia.Trade{}),
+			Partitions: 1,
+			Retention:  7 * 24 * time.Hour,
+		},
+		TopicTradesBlockShards: {
+			Name:       "tradesBlockShards",
+			Message:    reflect.TypeOf(dia.TradesBlockShard{}),
+			Partitions: 1,
+			Retention:  7 * 24 * time.Hour,
+		},
+	}
+
+	createTopicsOnce sync.Once
+)
+
+func init() {
+	if err := parseTopicPartitions(os.Getenv(partitionsEnv)); err != nil {
+		log.Errorf("%s: %v", partitionsEnv, err)
+	}
+}
+
+// parseTopicPartitions sets the partition counts of topics listed as comma separated topic=count pairs.
+func parseTopicPartitions(s string) error {
+	if s == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid topic partitions %s", pair)
+		}
+		topic, ok := TopicByName(kv[0])
+		if !ok {
+			return fmt.Errorf("unknown topic %s", kv[0])
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of partitions %s", kv[1])
+		}
+		config := topicRegistry[topic]
+		config.Partitions = n
+		topicRegistry[topic] = config
+	}
+	return nil
+}
+
+// Topics returns all registered topics in ascending order.
+func Topics() []int {
+	topics := make([]int, 0, len(topicRegistry))
+	for topic := range topicRegistry {
+		topics = append(topics, topic)
+	}
+	sort.Ints(topics)
+	return topics
+}
+
+// GetTopicConfig returns the declaration of @topic.
+func GetTopicConfig(topic int) (TopicConfig, error) {
+	config, ok := topicRegistry[topic]
+	if !ok {
+		return TopicConfig{}, fmt.Errorf("unknown topic %d", topic)
+	}
+	return config, nil
+}
+
+// TopicByName returns the topic named @name.
+func TopicByName(name string) (int, bool) {
+	for topic, config := range topicRegistry {
+		if config.Name == name {
+			return topic, true
+		}
+	}
+	return 0, false
+}
+
+// checkMessageType returns ErrMessageType if @m is not of the message type of @topic.
+func checkMessageType(topic int, m KafkaMessage) error {
+	config, err := GetTopicConfig(topic)
+	if err != nil {
+		return err
+	}
+	t := reflect.TypeOf(m)
+	if t != config.Message && t != reflect.PtrTo(config.Message) {
+		return fmt.Errorf("%w: %T written to %s, want %v", ErrMessageType, m, config.Name, config.Message)
+	}
+	return nil
+}
+
+// balancer returns the balancer of writers to @topic.
+func balancer(topic int) kafka.Balancer {
+	if config, err := GetTopicConfig(topic); err == nil && config.Keyed {
+		return &kafka.Hash{}
+	}
+	return &kafka.LeastBytes{}
+}
+
+// newMessage returns a pointer to a new message of the type of @topic.
+func newMessage(topic int) (interface{}, error) {
+	config, err := GetTopicConfig(topic)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.New(config.Message).Interface(), nil
+}
+
+// kafkaTopicConfig returns the configuration of @config for creating it on the brokers.
+func kafkaTopicConfig(config TopicConfig) kafka.TopicConfig {
+	replicationFactor := len(KafkaConfig.KafkaUrl)
+	if replicationFactor > maxReplicationFactor {
+		replicationFactor = maxReplicationFactor
+	}
+	c := kafka.TopicConfig{
+		Topic:             config.Name,
+		NumPartitions:     config.Partitions,
+		ReplicationFactor: replicationFactor,
+	}
+	if config.Retention != 0 {
+		retention := int64(-1)
+		if config.Retention > 0 {
+			retention = int64(config.Retention / time.Millisecond)
+		}
+		c.ConfigEntries = append(c.ConfigEntries, kafka.ConfigEntry{
+			ConfigName:  "retention.ms",
+			ConfigValue: strconv.FormatInt(retention, 10),
+		})
+	}
+	return c
+}
+
+// CreateMissingTopics creates the registered topics which do not exist on the brokers.
+func CreateMissingTopics() error {
+	var err error
+	for _, ip := range KafkaConfig.KafkaUrl {
+		err = createMissingTopics(ip)
+		if err == nil {
+			return nil
+		}
+		// topics can only be created on the controller of the cluster
+		log.Errorln("CreateMissingTopics error: <", err, "> ", ip)
+	}
+	return err
+}
+
+func createMissingTopics(ip string) error {
+	conn, err := kafka.DialContext(context.Background(), "tcp", ip)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]int)
+	for _, p := range partitions {
+		existing[p.Topic]++
+	}
+
+	var missing []kafka.TopicConfig
+	for _, topic := range Topics() {
+		config := topicRegistry[topic]
+		n, ok := existing[config.Name]
+		if !ok {
+			missing = append(missing, kafkaTopicConfig(config))
+		} else if n != config.Partitions {
+			// partitions of existing topics are only added by the operators of the cluster
+			log.Warnf("topic %s has %d partitions, configured are %d", config.Name, n, config.Partitions)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	err = conn.CreateTopics(missing...)
+	if err != nil && err != kafka.TopicAlreadyExists {
+		return err
+	}
+	for _, c := range missing {
+		log.Printf("created topic %s with %d partitions", c.Topic, c.NumPartitions)
+	}
+	return nil
+}
+
+// createTopics creates the missing topics once per process, before the first writer or consumer is created.
+func createTopics() {
+	createTopicsOnce.Do(func() {
+		if err := CreateMissingTopics(); err != nil {
+			log.Errorln("could not create missing topics:", err)
+		}
+	})
+}
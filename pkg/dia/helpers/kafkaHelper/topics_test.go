@@ -0,0 +1,59 @@
+package kafkaHelper
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+func TestTopicRegistry(t *testing.T) {
+	messageType := reflect.TypeOf((*KafkaMessage)(nil)).Elem()
+	names := make(map[string]int)
+	for _, topic := range []int{TopicIndexBlock, TopicFiltersBlock, TopicTrades, TopicTradesBlock, TopicSuppliesBlock,
+		TopicIndexBlock2, TopicIndexBlockDaily, TopicOptionOrderBook, TopicTradesLate} {
+		config, err := GetTopicConfig(topic)
+		if err != nil {
+			t.Errorf("Topic %d is not registered.", topic)
+			continue
+		}
+		if other, ok := names[config.Name]; ok || config.Name == "" {
+			t.Errorf("Name of topic %d was invalid, got: %q, used by topic %d.", topic, config.Name, other)
+		}
+		names[config.Name] = topic
+		if !reflect.PtrTo(config.Message).Implements(messageType) {
+			t.Errorf("Messages of topic %s can not be written, got type: %v.", config.Name, config.Message)
+		}
+		if config.Partitions < 1 {
+			t.Errorf("Partitions of topic %s were incorrect, got: %d, want: >= 1.", config.Name, config.Partitions)
+		}
+		if named, ok := TopicByName(config.Name); !ok || named != topic {
+			t.Errorf("Topic named %s was incorrect, got: %d, want: %d.", config.Name, named, topic)
+		}
+	}
+}
+
+func TestCheckMessageType(t *testing.T) {
+	tables := []struct {
+		topic int
+		m     KafkaMessage
+		err   error
+	}{
+		{TopicTrades, &dia.Trade{}, nil},
+		{TopicTradesLate, &dia.Trade{}, nil},
+		{TopicTradesBlock, &dia.TradesBlock{}, nil},
+		{TopicSuppliesBlock, &dia.SuppliesBlock{}, nil},
+		{TopicTradesBlock, &dia.Trade{}, ErrMessageType},
+		{TopicFiltersBlock, &dia.TradesBlock{}, ErrMessageType},
+	}
+	for _, table := range tables {
+		err := checkMessageType(table.topic, table.m)
+		if !errors.Is(err, table.err) {
+			t.Errorf("Check of %T on topic %s was incorrect, got: %v, want: %v.", table.m, getTopic(table.topic), err, table.err)
+		}
+	}
+	if err := checkMessageType(-1, &dia.Trade{}); err == nil {
+		t.Errorf("Message on unknown topic passed the check.")
+	}
+}
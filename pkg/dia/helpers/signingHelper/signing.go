@@ -0,0 +1,133 @@
+// Package signingHelper signs trades and filters blocks with ed25519 keys and verifies
+// chains of signed blocks.
+//
+// A block is signed over its BlockHash and the SHA-256 digest of the JSON encoding of its
+// data, which, unlike the structhash based BlockHash, can be recomputed from blocks read
+// back from kafka. As the data contains the hash of the previous block, a valid chain of
+// signatures proves that no block has been altered, inserted or removed.
+package signingHelper
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+const (
+	// signingKeyEnv is the environment variable holding the hex encoded ed25519 seed of a signer.
+	signingKeyEnv = "BLOCK_SIGNING_KEY"
+)
+
+var (
+	// ErrUnsigned is returned when verifying a block without signature.
+	ErrUnsigned = errors.New("block is not signed")
+	// ErrInvalidSignature is returned when the signature of a block does not match its content.
+	ErrInvalidSignature = errors.New("invalid block signature")
+)
+
+// Signer signs blocks with an ed25519 private key.
+type Signer struct {
+	privateKey ed25519.PrivateKey
+}
+
+// NewSigner returns a Signer for the ed25519 private key derived from @seed.
+func NewSigner(seed []byte) (*Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("seed has %d bytes, want %d", len(seed), ed25519.SeedSize)
+	}
+	return &Signer{privateKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// NewSignerFromEnv returns a Signer for the hex encoded seed in BLOCK_SIGNING_KEY. It returns
+// nil without error if the variable is not set, in which case blocks are published unsigned.
+func NewSignerFromEnv() (*Signer, error) {
+	key := os.Getenv(signingKeyEnv)
+	if key == "" {
+		return nil, nil
+	}
+	seed, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", signingKeyEnv, err)
+	}
+	return NewSigner(seed)
+}
+
+// PublicKey returns the public key blocks signed by @s are verified with.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.privateKey.Public().(ed25519.PublicKey)
+}
+
+// ParsePublicKey decodes a hex encoded ed25519 public key.
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	b, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key has %d bytes, want %d", len(b), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// SignTradesBlock sets the signature of @b. The BlockHash of @b must be set.
+func (s *Signer) SignTradesBlock(b *dia.TradesBlock) error {
+	message, err := signedMessage(b.BlockHash, b.TradesBlockData)
+	if err != nil {
+		return err
+	}
+	b.Signature = ed25519.Sign(s.privateKey, message)
+	return nil
+}
+
+// SignFiltersBlock sets the signature of @b. The BlockHash of @b must be set.
+func (s *Signer) SignFiltersBlock(b *dia.FiltersBlock) error {
+	message, err := signedMessage(b.BlockHash, b.FiltersBlockData)
+	if err != nil {
+		return err
+	}
+	b.Signature = ed25519.Sign(s.privateKey, message)
+	return nil
+}
+
+// VerifyTradesBlock checks that @b has been signed by the owner of @publicKey.
+func VerifyTradesBlock(b *dia.TradesBlock, publicKey ed25519.PublicKey) error {
+	return verify(b.BlockHash, b.TradesBlockData, b.Signature, publicKey)
+}
+
+// VerifyFiltersBlock checks that @b has been signed by the owner of @publicKey.
+func VerifyFiltersBlock(b *dia.FiltersBlock, publicKey ed25519.PublicKey) error {
+	return verify(b.BlockHash, b.FiltersBlockData, b.Signature, publicKey)
+}
+
+func verify(blockHash string, data interface{}, signature []byte, publicKey ed25519.PublicKey) error {
+	if len(signature) == 0 {
+		return ErrUnsigned
+	}
+	message, err := signedMessage(blockHash, data)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, message, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signedMessage returns the message signed for a block with @blockHash and @data.
+func signedMessage(blockHash string, data interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(encoded)
+	message := make([]byte, 0, len(blockHash)+1+len(digest))
+	message = append(message, blockHash...)
+	message = append(message, 0)
+	return append(message, digest[:]...), nil
+}
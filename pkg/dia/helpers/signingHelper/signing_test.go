@@ -0,0 +1,63 @@
+package signingHelper
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+func TestChainVerifier(t *testing.T) {
+	signer, err := NewSigner(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	var chain []dia.TradesBlock
+	previousHash := ""
+	for i, hash := range []string{"v1_a", "v1_b", "v1_c"} {
+		b := dia.TradesBlock{
+			BlockHash: hash,
+			TradesBlockData: dia.TradesBlockData{
+				BeginTime:         d.Add(time.Duration(i) * 2 * time.Minute),
+				EndTime:           d.Add(time.Duration(i+1) * 2 * time.Minute),
+				Trades:            []dia.Trade{{Symbol: "BTC", Price: 13000 + float64(i), Volume: 1, Time: d}},
+				TradesNumber:      1,
+				PreviousBlockHash: previousHash,
+			},
+		}
+		if err := signer.SignTradesBlock(&b); err != nil {
+			t.Fatal(err)
+		}
+		// blocks are verified after the round trip through kafka
+		data, _ := b.MarshalBinary()
+		var received dia.TradesBlock
+		if err := received.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, received)
+		previousHash = hash
+	}
+
+	verifier := NewChainVerifier(signer.PublicKey())
+	for i := range chain {
+		if err := verifier.AddTradesBlock(&chain[i]); err != nil {
+			t.Errorf("Verification of block %d failed: %v.", i, err)
+		}
+	}
+
+	// a removed block breaks the chain
+	verifier = NewChainVerifier(signer.PublicKey())
+	verifier.AddTradesBlock(&chain[0])
+	if err := verifier.AddTradesBlock(&chain[2]); err == nil {
+		t.Errorf("Chain with removed block passed verification.")
+	}
+
+	// an altered trade invalidates the signature
+	chain[1].TradesBlockData.Trades[0].Price = 1
+	if err := VerifyTradesBlock(&chain[1], signer.PublicKey()); err != ErrInvalidSignature {
+		t.Errorf("Verification of altered block was incorrect, got: %v, want: %v.", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,50 @@
+package signingHelper
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+// ChainVerifier verifies a sequence of consecutive blocks. The first block added is
+// trusted to be the start of the range, every following block must be signed and
+// reference its predecessor.
+type ChainVerifier struct {
+	publicKey ed25519.PublicKey
+	lastHash  string
+	// NumBlocks is the number of blocks verified so far.
+	NumBlocks int
+}
+
+// NewChainVerifier returns a ChainVerifier for blocks signed with the key of @publicKey.
+func NewChainVerifier(publicKey ed25519.PublicKey) *ChainVerifier {
+	return &ChainVerifier{publicKey: publicKey}
+}
+
+// AddTradesBlock verifies @b as the successor of the previously added block.
+func (v *ChainVerifier) AddTradesBlock(b *dia.TradesBlock) error {
+	err := VerifyTradesBlock(b, v.publicKey)
+	if err != nil {
+		return fmt.Errorf("trades block %s: %v", b.BlockHash, err)
+	}
+	return v.link(b.BlockHash, b.TradesBlockData.PreviousBlockHash)
+}
+
+// AddFiltersBlock verifies @b as the successor of the previously added block.
+func (v *ChainVerifier) AddFiltersBlock(b *dia.FiltersBlock) error {
+	err := VerifyFiltersBlock(b, v.publicKey)
+	if err != nil {
+		return fmt.Errorf("filters block %s: %v", b.BlockHash, err)
+	}
+	return v.link(b.BlockHash, b.FiltersBlockData.PreviousBlockHash)
+}
+
+func (v *ChainVerifier) link(blockHash string, previousBlockHash string) error {
+	if v.NumBlocks > 0 && previousBlockHash != v.lastHash {
+		return fmt.Errorf("block %s references previous block %s, want %s", blockHash, previousBlockHash, v.lastHash)
+	}
+	v.lastHash = blockHash
+	v.NumBlocks++
+	return nil
+}
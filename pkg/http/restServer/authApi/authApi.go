@@ -0,0 +1,340 @@
+package authApi
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/http/restApi"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// IdentityKey is the claim of JWT tokens holding the API key, which the JWT middleware also
+// sets in the context of authenticated requests.
+const IdentityKey = "id"
+
+const (
+	// keyCacheTime is the time keys are cached for, after which revocations by other instances
+	// of the API take effect.
+	keyCacheTime = 30 * time.Second
+	keyLength    = 16
+	secretLength = 32
+	// usageDays is the number of days of usage returned if no times are set.
+	usageDays = 30
+	// maxUsageDays is the number of days usage is kept for.
+	maxUsageDays = 90
+)
+
+var (
+	errInvalidKey = errors.New("invalid API key")
+	errScope      = errors.New("API key is not granted the scope of the request")
+	errRateLimit  = errors.New("rate limit of API key exceeded")
+)
+
+// KeyStore stores API keys and counts the requests made with them.
+type KeyStore interface {
+	SetAPIKey(key dia.APIKey) error
+	GetAPIKey(key string) (dia.APIKey, error)
+	GetAPIKeys() ([]dia.APIKey, error)
+	RotateAPIKey(key string, secretHash string) error
+	RevokeAPIKey(key string) error
+	IncrementAPIKeyUsage(key string, t time.Time) (int64, error)
+	GetAPIKeyUsage(key string, starttime time.Time, endtime time.Time) ([]dia.APIKeyUsage, error)
+}
+
+// Env authenticates API keys, enforces their scopes and rate limits and manages them.
+type Env struct {
+	store KeyStore
+	// adminKey and adminSecret are the key of the config. It is granted all scopes, has no
+	// rate limit and is used to create the keys of clients.
+	adminKey    string
+	adminSecret string
+	lock        sync.Mutex
+	cache       map[string]cachedKey
+}
+
+type cachedKey struct {
+	key    dia.APIKey
+	loaded time.Time
+}
+
+// apiKeyRequest is the body of requests creating API keys.
+type apiKeyRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	RateLimit int      `json:"rateLimit"`
+}
+
+// apiKeySecret is returned on creating and rotating a key, the only time the secret is known.
+type apiKeySecret struct {
+	Key    string
+	Secret string
+}
+
+// NewEnv returns an Env with the keys of @store. @adminKey is accepted with @adminSecret if it
+// is not empty.
+func NewEnv(store KeyStore, adminKey string, adminSecret string) *Env {
+	return &Env{
+		store:       store,
+		adminKey:    adminKey,
+		adminSecret: adminSecret,
+		cache:       make(map[string]cachedKey),
+	}
+}
+
+// Authenticate returns the active API key @key if @secret is its secret.
+func (env *Env) Authenticate(key string, secret string) (dia.APIKey, error) {
+	apiKey, err := env.getKey(key)
+	if err != nil {
+		return dia.APIKey{}, err
+	}
+	if key == env.adminKey {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(env.adminSecret)) != 1 {
+			return dia.APIKey{}, errInvalidKey
+		}
+		return apiKey, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(apiKey.SecretHash)) != 1 {
+		return dia.APIKey{}, errInvalidKey
+	}
+	return apiKey, nil
+}
+
+// getKey returns the active API key @key. Keys are cached for keyCacheTime.
+func (env *Env) getKey(key string) (dia.APIKey, error) {
+	if key == "" {
+		return dia.APIKey{}, errInvalidKey
+	}
+	if key == env.adminKey {
+		return dia.APIKey{Key: key, Name: "admin", Scopes: []string{dia.ScopeAdmin}}, nil
+	}
+
+	env.lock.Lock()
+	cached, ok := env.cache[key]
+	env.lock.Unlock()
+	if !ok || time.Since(cached.loaded) > keyCacheTime {
+		apiKey, err := env.store.GetAPIKey(key)
+		if err != nil {
+			log.Warnln("GetAPIKey", key, err)
+			return dia.APIKey{}, errInvalidKey
+		}
+		cached = cachedKey{key: apiKey, loaded: time.Now()}
+		env.lock.Lock()
+		env.cache[key] = cached
+		env.lock.Unlock()
+	}
+	if cached.key.Revoked() {
+		return dia.APIKey{}, errInvalidKey
+	}
+	return cached.key, nil
+}
+
+func (env *Env) invalidate(key string) {
+	env.lock.Lock()
+	defer env.lock.Unlock()
+	delete(env.cache, key)
+}
+
+// RequireScope returns a middleware rejecting requests whose API key is not granted @scope or
+// exceeds its rate limit. The key is the identity set by the JWT middleware running before.
+func (env *Env) RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, err := env.getKey(c.GetString(IdentityKey))
+		if err != nil {
+			restApi.SendError(c, http.StatusUnauthorized, err)
+			c.Abort()
+			return
+		}
+		if !apiKey.HasScope(scope) {
+			restApi.SendError(c, http.StatusForbidden, errScope)
+			c.Abort()
+			return
+		}
+		if apiKey.Key != env.adminKey {
+			now := time.Now()
+			requests, err := env.store.IncrementAPIKeyUsage(apiKey.Key, now)
+			if err != nil {
+				// requests are not limited while usage can't be counted
+				log.Errorln("IncrementAPIKeyUsage", err)
+			} else if apiKey.RateLimit > 0 && requests > int64(apiKey.RateLimit) {
+				c.Header("Retry-After", strconv.Itoa(60-now.Second()))
+				restApi.SendError(c, http.StatusTooManyRequests, errRateLimit)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// LimitScope returns a middleware like RequireScope for requests with an API key, while
+// requests without key pass, e.g. to the public read endpoints.
+func (env *Env) LimitScope(scope string) gin.HandlerFunc {
+	requireScope := env.RequireScope(scope)
+	return func(c *gin.Context) {
+		if c.GetString(IdentityKey) == "" {
+			c.Next()
+			return
+		}
+		requireScope(c)
+	}
+}
+
+// CreateAPIKey creates an API key with the name, scopes and rate limit of the request body and
+// returns it with its secret.
+func (env *Env) CreateAPIKey(c *gin.Context) {
+	var request apiKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restApi.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := validateScopes(request.Scopes); err != nil {
+		restApi.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	if request.RateLimit < 0 {
+		restApi.SendError(c, http.StatusBadRequest, errors.New("rate limit must not be negative"))
+		return
+	}
+
+	key, err := randomHex(keyLength)
+	if err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	secret, err := randomHex(secretLength)
+	if err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	apiKey := dia.APIKey{
+		Key:        key,
+		SecretHash: hashSecret(secret),
+		Name:       request.Name,
+		Scopes:     request.Scopes,
+		RateLimit:  request.RateLimit,
+		CreatedAt:  time.Now(),
+	}
+	if err := env.store.SetAPIKey(apiKey); err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, apiKeySecret{Key: key, Secret: secret})
+}
+
+// GetAPIKeys returns all API keys without their secrets.
+func (env *Env) GetAPIKeys(c *gin.Context) {
+	apiKeys, err := env.store.GetAPIKeys()
+	if err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if apiKeys == nil {
+		apiKeys = []dia.APIKey{}
+	}
+	c.JSON(http.StatusOK, apiKeys)
+}
+
+// RotateAPIKey replaces the secret of the API key @key and returns the new secret. Tokens
+// issued for the old secret stay valid until they expire.
+func (env *Env) RotateAPIKey(c *gin.Context) {
+	key := c.Param("key")
+	secret, err := randomHex(secretLength)
+	if err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if err := env.store.RotateAPIKey(key, hashSecret(secret)); err != nil {
+		restApi.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	env.invalidate(key)
+	c.JSON(http.StatusOK, apiKeySecret{Key: key, Secret: secret})
+}
+
+// RevokeAPIKey revokes the API key @key. Requests made with it are rejected from then on.
+func (env *Env) RevokeAPIKey(c *gin.Context) {
+	key := c.Param("key")
+	if err := env.store.RevokeAPIKey(key); err != nil {
+		restApi.SendError(c, http.StatusNotFound, err)
+		return
+	}
+	env.invalidate(key)
+	c.Status(http.StatusNoContent)
+}
+
+// GetAPIKeyUsage returns the daily requests made with the API key @key between the unix times
+// starttime and endtime. If no times are set the last 30 days are used.
+func (env *Env) GetAPIKeyUsage(c *gin.Context) {
+	key := c.Param("key")
+	starttimeStr := c.Query("starttime")
+	endtimeStr := c.Query("endtime")
+
+	endtime := time.Now()
+	if endtimeStr != "" {
+		endtimeInt, err := strconv.ParseInt(endtimeStr, 10, 64)
+		if err != nil {
+			restApi.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		endtime = time.Unix(endtimeInt, 0)
+	}
+	starttime := endtime.AddDate(0, 0, -usageDays)
+	if starttimeStr != "" {
+		starttimeInt, err := strconv.ParseInt(starttimeStr, 10, 64)
+		if err != nil {
+			restApi.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		starttime = time.Unix(starttimeInt, 0)
+	}
+	if endtime.Sub(starttime) > maxUsageDays*24*time.Hour {
+		restApi.SendError(c, http.StatusBadRequest, errors.New("time range exceeds the days usage is kept for"))
+		return
+	}
+
+	usage, err := env.store.GetAPIKeyUsage(key, starttime, endtime)
+	if err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// validateScopes returns an error if @scopes is empty or contains unknown scopes.
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("no scopes")
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range dia.APIKeyScopes {
+			known = known || s == scope
+		}
+		if !known {
+			return errors.New("unknown scope " + scope)
+		}
+	}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(length int) (string, error) {
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,134 @@
+package authApi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/gin-gonic/gin"
+)
+
+// testStore keeps keys in memory and counts all requests in one window.
+type testStore struct {
+	keys     map[string]dia.APIKey
+	requests map[string]int64
+}
+
+func (s *testStore) SetAPIKey(key dia.APIKey) error {
+	s.keys[key.Key] = key
+	return nil
+}
+
+func (s *testStore) GetAPIKey(key string) (dia.APIKey, error) {
+	apiKey, ok := s.keys[key]
+	if !ok {
+		return dia.APIKey{}, errors.New("no key")
+	}
+	return apiKey, nil
+}
+
+func (s *testStore) GetAPIKeys() (keys []dia.APIKey, err error) {
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return
+}
+
+func (s *testStore) RotateAPIKey(key string, secretHash string) error {
+	apiKey := s.keys[key]
+	apiKey.SecretHash = secretHash
+	s.keys[key] = apiKey
+	return nil
+}
+
+func (s *testStore) RevokeAPIKey(key string) error {
+	apiKey := s.keys[key]
+	now := time.Now()
+	apiKey.RevokedAt = &now
+	s.keys[key] = apiKey
+	return nil
+}
+
+func (s *testStore) IncrementAPIKeyUsage(key string, t time.Time) (int64, error) {
+	s.requests[key]++
+	return s.requests[key], nil
+}
+
+func (s *testStore) GetAPIKeyUsage(key string, starttime time.Time, endtime time.Time) ([]dia.APIKeyUsage, error) {
+	return []dia.APIKeyUsage{{Key: key, Requests: s.requests[key]}}, nil
+}
+
+func newTestEnv() *Env {
+	store := &testStore{keys: make(map[string]dia.APIKey), requests: make(map[string]int64)}
+	store.SetAPIKey(dia.APIKey{Key: "reader", SecretHash: hashSecret("secret"), Scopes: []string{dia.ScopeRead}, RateLimit: 1})
+	store.SetAPIKey(dia.APIKey{Key: "supplier", SecretHash: hashSecret("secret"), Scopes: []string{dia.ScopeWriteSupply}})
+	return NewEnv(store, "admin", "adminSecret")
+}
+
+func TestAuthenticate(t *testing.T) {
+	env := newTestEnv()
+	env.store.RevokeAPIKey("supplier")
+
+	tables := []struct {
+		key    string
+		secret string
+		valid  bool
+	}{
+		{"reader", "secret", true},
+		{"reader", "wrong", false},
+		{"unknown", "secret", false},
+		{"supplier", "secret", false},
+		{"admin", "adminSecret", true},
+		{"admin", "secret", false},
+		{"", "", false},
+	}
+	for _, table := range tables {
+		_, err := env.Authenticate(table.key, table.secret)
+		if (err == nil) != table.valid {
+			t.Errorf("Authentication of %s with %s was incorrect, got: %v, want: %v.", table.key, table.secret, err == nil, table.valid)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	env := newTestEnv()
+	r := gin.New()
+	identity := func(c *gin.Context) {
+		c.Set(IdentityKey, c.Query("key"))
+	}
+	ok := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+	r.GET("/read", identity, env.RequireScope(dia.ScopeRead), ok)
+	r.GET("/supply", identity, env.RequireScope(dia.ScopeWriteSupply), ok)
+	r.GET("/public", identity, env.LimitScope(dia.ScopeRead), ok)
+
+	tables := []struct {
+		path string
+		want int
+	}{
+		{"/read?key=reader", http.StatusOK},
+		{"/supply?key=reader", http.StatusForbidden},
+		{"/supply?key=supplier", http.StatusOK},
+		{"/read?key=unknown", http.StatusUnauthorized},
+		{"/read?key=admin", http.StatusOK},
+		{"/public", http.StatusOK},
+		{"/public?key=supplier", http.StatusForbidden},
+		// the rate limit of 1 request per minute of the reader is exceeded
+		{"/read?key=reader", http.StatusTooManyRequests},
+		{"/public?key=reader", http.StatusTooManyRequests},
+		{"/read?key=admin", http.StatusOK},
+		{"/public", http.StatusOK},
+	}
+	for _, table := range tables {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, table.path, nil))
+		if w.Code != table.want {
+			t.Errorf("Status of %s was incorrect, got: %d, want: %d.", table.path, w.Code, table.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/diadata-org/diadata/internal/pkg/indexCalculationService"
@@ -101,6 +102,57 @@ func (env *Env) GetQuotation(c *gin.Context) {
 	}
 }
 
+// maxBatchSymbols is the maximal number of symbols of a batch of quotations.
+const maxBatchSymbols = 100
+
+// GetQuotations godoc
+// @Summary Get quotations, supplies and market caps of several symbols
+// @Param   symbols    query   string     true        "comma separated symbols"
+// @Param   timestamp  query   int        false       "unix time of historic values, latest values by default"
+// @Success 200 {object} []models.BatchQuotation "success, values which could not be retrieved are reported per symbol"
+// @Failure 400 {object} restApi.APIError "invalid parameters"
+// @Failure 500 {object} restApi.APIError "error"
+// @Router /v1/quotations [get]
+func (env *Env) GetQuotations(c *gin.Context) {
+	var symbols []string
+	for _, symbol := range strings.Split(c.Query("symbols"), ",") {
+		symbol = strings.TrimSpace(symbol)
+		if symbol == "" {
+			continue
+		}
+		if strings.ContainsAny(symbol, "'\\\"") {
+			restApi.SendError(c, http.StatusBadRequest, errors.New("invalid symbol "+symbol))
+			return
+		}
+		symbols = append(symbols, symbol)
+	}
+	if len(symbols) == 0 {
+		restApi.SendError(c, http.StatusBadRequest, errors.New("no symbols"))
+		return
+	}
+	if len(symbols) > maxBatchSymbols {
+		restApi.SendError(c, http.StatusBadRequest, fmt.Errorf("more than %d symbols", maxBatchSymbols))
+		return
+	}
+
+	var timestamp time.Time
+	if timestampStr := c.Query("timestamp"); timestampStr != "" {
+		timestampInt, err := strconv.ParseInt(timestampStr, 10, 64)
+		if err != nil {
+			restApi.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		timestamp = time.Unix(timestampInt, 0)
+	}
+
+	quotations, err := env.DataStore.GetBatchQuotations(symbols, timestamp)
+	if err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, quotations)
+}
+
 func (env *Env) GetPaxgQuotationOunces(c *gin.Context) {
 	q, err := env.DataStore.GetPaxgQuotationOunces()
 	if err != nil {
@@ -270,6 +322,44 @@ func (env *Env) GetVolume(c *gin.Context) {
 	c.JSON(http.StatusOK, v)
 }
 
+// GetOrderBookDepth returns the spread and the depth of the order books of @symbol on
+// @exchange, of all exchanges if @exchange is "all". If no times are set the last 24h are used.
+func (env *Env) GetOrderBookDepth(c *gin.Context) {
+	exchange := c.Param("exchange")
+	symbol := c.Param("symbol")
+	starttimeStr := c.Query("starttime")
+	endtimeStr := c.Query("endtime")
+
+	endtime := time.Now()
+	if endtimeStr != "" {
+		endtimeInt, err := strconv.ParseInt(endtimeStr, 10, 64)
+		if err != nil {
+			restApi.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		endtime = time.Unix(endtimeInt, 0)
+	}
+	starttime := endtime.AddDate(0, 0, -1)
+	if starttimeStr != "" {
+		starttimeInt, err := strconv.ParseInt(starttimeStr, 10, 64)
+		if err != nil {
+			restApi.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		starttime = time.Unix(starttimeInt, 0)
+	}
+	if exchange == "all" {
+		exchange = ""
+	}
+
+	metrics, err := env.DataStore.GetOrderBookMetricsInflux(symbol, exchange, starttime, endtime)
+	if err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, metrics)
+}
+
 // Get24hVolume if no times are set use the last 24h
 func (env *Env) Get24hVolume(c *gin.Context) {
 	exchange := c.Param("exchange")
@@ -504,6 +594,76 @@ func (env *Env) GetChartPointsAllExchanges(c *gin.Context) {
 	}
 }
 
+const (
+	// defaultCandles is the number of candles returned if no starttime is set.
+	defaultCandles = 500
+	// maxCandles is the maximal number of candles returned per request.
+	maxCandles = 10000
+)
+
+// GetCandles godoc
+// @Summary Get OHLCV candles of a symbol on an exchange
+// @Param   symbol     path    string     true        "Some symbol"
+// @Param   exchange   path    string     true        "Some exchange"
+// @Param   interval   query   string     false       "interval 1m 5m 15m 30m 1h 4h 1d 1w, 1h by default"
+// @Success 200 {object} []models.Candle "success"
+// @Failure 400 {object} restApi.APIError "invalid parameters"
+// @Failure 500 {object} restApi.APIError "error"
+// @Router /v1/candles/:exchange/:symbol [get]
+func (env *Env) GetCandles(c *gin.Context) {
+	env.getCandles(c, c.Param("exchange"))
+}
+
+// GetCandlesAllExchanges returns the OHLCV candles of the trades of a symbol on all exchanges.
+// @Param   interval   query   string     false       "interval 1m 5m 15m 30m 1h 4h 1d 1w, 1h by default"
+func (env *Env) GetCandlesAllExchanges(c *gin.Context) {
+	env.getCandles(c, "")
+}
+
+// getCandles returns the candles of @exchange between the unix times starttime and endtime.
+// If no times are set the last defaultCandles candles are returned.
+func (env *Env) getCandles(c *gin.Context, exchange string) {
+	symbol := c.Param("symbol")
+	interval := c.DefaultQuery("interval", "1h")
+	starttimeStr := c.Query("starttime")
+	endtimeStr := c.Query("endtime")
+
+	duration, ok := models.CandleIntervals[interval]
+	if !ok {
+		restApi.SendError(c, http.StatusBadRequest, errors.New("unknown interval "+interval))
+		return
+	}
+	endtime := time.Now()
+	if endtimeStr != "" {
+		endtimeInt, err := strconv.ParseInt(endtimeStr, 10, 64)
+		if err != nil {
+			restApi.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		endtime = time.Unix(endtimeInt, 0)
+	}
+	starttime := endtime.Add(-defaultCandles * duration)
+	if starttimeStr != "" {
+		starttimeInt, err := strconv.ParseInt(starttimeStr, 10, 64)
+		if err != nil {
+			restApi.SendError(c, http.StatusBadRequest, err)
+			return
+		}
+		starttime = time.Unix(starttimeInt, 0)
+	}
+	if endtime.Sub(starttime) > maxCandles*duration {
+		restApi.SendError(c, http.StatusBadRequest, fmt.Errorf("time range exceeds %d candles", maxCandles))
+		return
+	}
+
+	candles, err := env.DataStore.GetCandles(symbol, exchange, interval, starttime, endtime)
+	if err != nil {
+		restApi.SendError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, candles)
+}
+
 // GetAllSymbols returns all symbols available in our (redis) database.
 // Optional query parameter exchange returns only symbols available on this exchange.
 func (env *Env) GetAllSymbols(c *gin.Context) {
@@ -0,0 +1,275 @@
+package graphqlApi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// defaultListSize is the assumed length of lists whose length is not bound by their arguments.
+	defaultListSize = 100
+	// maxCost bounds the costs computed, so that they can't overflow.
+	maxCost = math.MaxInt32
+	// fieldQueryCost is the cost of the datastore query of the fields of queryFields.
+	fieldQueryCost = 10
+)
+
+// queryFields are the fields by type resolved by a datastore query for each value they are
+// selected on. Their queries are counted on top of their values, as they are not batched.
+var queryFields = map[string]map[string]bool{
+	"Asset": {
+		"exchanges":    true,
+		"filterPoints": true,
+		"trades":       true,
+		"supplies":     true,
+	},
+	"Exchange": {"symbols": true},
+	"NFTClass": {"price30Days": true},
+	"NFT":      {"trades": true},
+}
+
+// costWalker computes the cost of queries as the number of values they resolve, where the
+// fields below a list are counted once for each of its items.
+type costWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	defaults  map[string]ast.Value
+	// spreading holds the fragments being walked, whose cycles are rejected.
+	spreading map[string]bool
+}
+
+// queryCost returns the cost of the operation @operationName of @document with @variables.
+// The cost of the most expensive operation is returned if @operationName is empty.
+func queryCost(schema graphql.Schema, document *ast.Document, operationName string, variables map[string]interface{}) (int, error) {
+	w := &costWalker{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		spreading: make(map[string]bool),
+	}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			w.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	cost := 0
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName != "" && (operation.Name == nil || operation.Name.Value != operationName) {
+			continue
+		}
+		w.defaults = make(map[string]ast.Value)
+		for _, variable := range operation.VariableDefinitions {
+			if variable.DefaultValue != nil {
+				w.defaults[variable.Variable.Name.Value] = variable.DefaultValue
+			}
+		}
+		operationCost, err := w.selectionSetCost(operation.SelectionSet, schema.QueryType())
+		if err != nil {
+			return 0, err
+		}
+		if operationCost > cost {
+			cost = operationCost
+		}
+	}
+	return cost, nil
+}
+
+// selectionSetCost returns the cost of @set on values of @parent, which is nil if unknown.
+func (w *costWalker) selectionSetCost(set *ast.SelectionSet, parent graphql.Type) (int, error) {
+	if set == nil {
+		return 0, nil
+	}
+	cost := 0
+	for _, selection := range set.Selections {
+		var selectionCost int
+		var err error
+		switch s := selection.(type) {
+		case *ast.Field:
+			selectionCost, err = w.fieldCost(s, parent)
+		case *ast.InlineFragment:
+			selectionCost, err = w.selectionSetCost(s.SelectionSet, parent)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			fragment, ok := w.fragments[name]
+			if !ok {
+				return 0, fmt.Errorf("unknown fragment %s", name)
+			}
+			if w.spreading[name] {
+				return 0, fmt.Errorf("fragment %s spreads itself", name)
+			}
+			w.spreading[name] = true
+			selectionCost, err = w.selectionSetCost(fragment.SelectionSet, parent)
+			delete(w.spreading, name)
+		}
+		if err != nil {
+			return 0, err
+		}
+		cost = boundedSum(cost, selectionCost)
+	}
+	return cost, nil
+}
+
+// fieldCost returns the cost of @field on values of @parent. Fields unknown to the schema, as
+// those of introspection, are counted without multiplying their selections.
+func (w *costWalker) fieldCost(field *ast.Field, parent graphql.Type) (int, error) {
+	if field.Name.Value == "__typename" {
+		return 0, nil
+	}
+	var definition *graphql.FieldDefinition
+	fetchCost := 0
+	if object, ok := parent.(*graphql.Object); ok {
+		definition = object.Fields()[field.Name.Value]
+		if queryFields[object.Name()][field.Name.Value] {
+			fetchCost = fieldQueryCost
+		}
+	}
+	if definition == nil {
+		cost, err := w.selectionSetCost(field.SelectionSet, nil)
+		return boundedSum(1, cost), err
+	}
+	if err := w.checkTimeRange(field, definition); err != nil {
+		return 0, err
+	}
+
+	size := 1
+	fieldType := definition.Type
+	for {
+		if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+			fieldType = nonNull.OfType
+			continue
+		}
+		if list, ok := fieldType.(*graphql.List); ok {
+			size = boundedProduct(size, w.listSize(field))
+			fieldType = list.OfType
+			continue
+		}
+		break
+	}
+	cost, err := w.selectionSetCost(field.SelectionSet, fieldType)
+	return boundedSum(1+fetchCost, boundedProduct(size, cost)), err
+}
+
+// listSize returns the length of the list resolved by @field, which is its limit, or else the
+// length of its longest list argument, or else defaultListSize.
+func (w *costWalker) listSize(field *ast.Field) int {
+	size := -1
+	for _, argument := range field.Arguments {
+		value := w.resolve(argument.Value)
+		if argument.Name.Value == "limit" {
+			if limit, ok := intValue(value); ok {
+				if limit < 0 {
+					return 0
+				}
+				return limit
+			}
+		}
+		if length, ok := listLength(value); ok && length > size {
+			size = length
+		}
+	}
+	if size < 0 {
+		return defaultListSize
+	}
+	return size
+}
+
+// checkTimeRange returns an error if the time range of @field exceeds its maximum, so that
+// queries scanning long series are rejected before they are executed, see timeRange.
+func (w *costWalker) checkTimeRange(field *ast.Field, definition *graphql.FieldDefinition) error {
+	args := make(map[string]interface{})
+	ranged := false
+	for _, arg := range definition.Args {
+		if arg.DefaultValue != nil {
+			args[arg.Name()] = arg.DefaultValue
+		}
+		ranged = ranged || arg.Name() == "starttime"
+	}
+	if !ranged {
+		return nil
+	}
+	for _, argument := range field.Arguments {
+		value := w.resolve(argument.Value)
+		if v, ok := value.(*ast.StringValue); ok {
+			value = v.Value
+		}
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		args[argument.Name.Value] = s
+		if argument.Name.Value == "starttime" || argument.Name.Value == "endtime" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return fmt.Errorf("invalid %s %s", argument.Name.Value, s)
+			}
+			args[argument.Name.Value] = t
+		}
+	}
+	_, _, err := timeRange(args)
+	return err
+}
+
+// resolve returns the value of the variable @value, or @value if it is not a variable.
+func (w *costWalker) resolve(value ast.Value) interface{} {
+	variable, ok := value.(*ast.Variable)
+	if !ok {
+		return value
+	}
+	if v, ok := w.variables[variable.Name.Value]; ok {
+		return v
+	}
+	if v, ok := w.defaults[variable.Name.Value]; ok {
+		return v
+	}
+	return nil
+}
+
+// intValue returns the integer @value, which is a literal or the value of a variable.
+func intValue(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case *ast.IntValue:
+		i, err := strconv.Atoi(v.Value)
+		return i, err == nil
+	case int:
+		return v, true
+	case float64:
+		// numbers of decoded variables
+		return int(math.Max(math.Min(v, maxCost), -1)), true
+	}
+	return 0, false
+}
+
+// listLength returns the length of the list @value, which is a literal or the value of a
+// variable.
+func listLength(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case *ast.ListValue:
+		return len(v.Values), true
+	case []interface{}:
+		return len(v), true
+	}
+	return 0, false
+}
+
+func boundedSum(a, b int) int {
+	if a > maxCost-b {
+		return maxCost
+	}
+	return a + b
+}
+
+func boundedProduct(a, b int) int {
+	if a != 0 && b > maxCost/a {
+		return maxCost
+	}
+	return a * b
+}
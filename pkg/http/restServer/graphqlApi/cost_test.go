@@ -0,0 +1,122 @@
+package graphqlApi
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+func TestQueryCost(t *testing.T) {
+	schema, err := newSchema(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		query     string
+		variables map[string]interface{}
+		cost      int
+	}{
+		{`{ asset(symbol: "BTC") { symbol quotation { price } } }`, nil, 4},
+		{`{ asset(symbol: "BTC") { __typename symbol } }`, nil, 2},
+		// each asset costs its fields
+		{`{ assets(symbols: ["BTC", "ETH", "DIA"]) { symbol marketCap } }`, nil, 1 + 3*2},
+		{`query($s: [String!]!) { assets(symbols: $s) { symbol } }`, map[string]interface{}{"s": []interface{}{"BTC", "ETH"}}, 1 + 2},
+		// fields resolved by a query per value count the query
+		{`{ asset(symbol: "BTC") { trades(limit: 10) { price time } } }`, nil, 1 + 1 + fieldQueryCost + 10*2},
+		{`query($n: Int) { asset(symbol: "BTC") { trades(limit: $n) { price } } }`, map[string]interface{}{"n": float64(50)}, 1 + 1 + fieldQueryCost + 50},
+		{`query($n: Int = 20) { asset(symbol: "BTC") { trades(limit: $n) { price } } }`, nil, 1 + 1 + fieldQueryCost + 20},
+		{`{ nftClasses(limit: 5) { name price30Days } }`, nil, 1 + 5*(1+1+fieldQueryCost)},
+		// lists without limit count defaultListSize items, which they are truncated to
+		{`{ asset(symbol: "BTC") { filterPoints { value } } }`, nil, 1 + 1 + fieldQueryCost + defaultListSize},
+		{`{ asset(symbol: "BTC") { filterPoints(limit: 10) { value } } }`, nil, 1 + 1 + fieldQueryCost + 10},
+		{`{ cryptoIndex(name: "SCIFI") { value } }`, nil, 1 + defaultListSize},
+		{`{ exchanges { name symbols { symbol } } }`, nil, 1 + defaultListSize*(1+1+fieldQueryCost+defaultListSize)},
+		// aliases and fragments are counted where they are used
+		{`{ a: asset(symbol: "BTC") { ...f } b: asset(symbol: "ETH") { ...f } } fragment f on Asset { symbol supply { supply } }`, nil, 2 * 4},
+		{`{ asset(symbol: "BTC") { ... on Asset { name } } }`, nil, 2},
+		// of several operations the most expensive one
+		{`query a { asset(symbol: "BTC") { name } } query b { nftCategories }`, nil, 2},
+		{`{ __schema { types { name } } }`, nil, 3},
+		{`{ exchanges { symbols { trades(limit: 1000000000) { price } } } }`, nil, maxCost},
+	}
+	for _, table := range tables {
+		document, err := parser.Parse(parser.ParseParams{Source: table.query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cost, err := queryCost(schema, document, "", table.variables)
+		if err != nil {
+			t.Errorf("Cost of %s returned error %v.", table.query, err)
+			continue
+		}
+		if cost != table.cost {
+			t.Errorf("Cost of %s was incorrect, got: %d, want: %d.", table.query, cost, table.cost)
+		}
+	}
+
+	// ranges longer than their maximum are rejected, even if their results are truncated
+	ranges := []struct {
+		query     string
+		variables map[string]interface{}
+		valid     bool
+	}{
+		{`{ asset(symbol: "BTC") { filterPoints(starttime: "2015-01-01T00:00:00Z", limit: 1) { value } } }`, nil, false},
+		{`{ asset(symbol: "BTC") { filterPoints(starttime: "2021-01-01T00:00:00Z", endtime: "2021-01-05T00:00:00Z") { value } } }`, nil, true},
+		{`{ asset(symbol: "BTC") { filterPoints(starttime: "2015-01-01T00:00:00Z", endtime: "2021-01-01T00:00:00Z", scale: "1d") { value } } }`, nil, true},
+		{`query($s: DateTime) { asset(symbol: "BTC") { supplies(starttime: $s, limit: 1) { supply } } }`, map[string]interface{}{"s": "2015-01-01T00:00:00Z"}, false},
+		{`{ cryptoIndex(name: "SCIFI", starttime: "2021-01-01T00:00:00Z", endtime: "2021-01-20T00:00:00Z") { value } }`, nil, true},
+	}
+	for _, table := range ranges {
+		document, err := parser.Parse(parser.ParseParams{Source: table.query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := queryCost(schema, document, "", table.variables); (err == nil) != table.valid {
+			t.Errorf("Cost of %s was incorrect, got error: %v, want valid: %v.", table.query, err, table.valid)
+		}
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: `query a { asset(symbol: "BTC") { name } } query b { nftCategories }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, _ := queryCost(schema, document, "b", nil); cost != 1 {
+		t.Errorf("Cost of operation was incorrect, got: %d, want: %d.", cost, 1)
+	}
+
+	document, err = parser.Parse(parser.ParseParams{Source: `{ asset(symbol: "BTC") { ...f } } fragment f on Asset { exchanges { symbols { ...f } } }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queryCost(schema, document, "", nil); err == nil {
+		t.Error("Cost of fragment cycle returned no error.")
+	}
+}
+
+func TestLimited(t *testing.T) {
+	resolve := limited(func(p graphql.ResolveParams) (interface{}, error) {
+		return []int{1, 2, 3}, nil
+	})
+
+	tables := []struct {
+		limit  int
+		length int
+		valid  bool
+	}{
+		{2, 2, true},
+		{defaultListSize, 3, true},
+		{0, 0, false},
+	}
+	for _, table := range tables {
+		list, err := resolve(graphql.ResolveParams{Args: map[string]interface{}{"limit": table.limit}})
+		if (err == nil) != table.valid {
+			t.Errorf("Error of limit %d was incorrect, got: %v.", table.limit, err)
+			continue
+		}
+		if err == nil && len(list.([]int)) != table.length {
+			t.Errorf("Length of limit %d was incorrect, got: %d, want: %d.", table.limit, len(list.([]int)), table.length)
+		}
+	}
+}
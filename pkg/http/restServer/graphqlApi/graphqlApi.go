@@ -0,0 +1,102 @@
+package graphqlApi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	models "github.com/diadata-org/diadata/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxQueryCost is the maximal cost of queries, see queryCost.
+const maxQueryCost = 10000
+
+// Env resolves GraphQL queries from the datastores of the REST API.
+type Env struct {
+	datastore models.Datastore
+	schema    graphql.Schema
+}
+
+// request is a GraphQL request, sent as JSON body of POST or as query parameters of GET requests.
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// NewEnv returns the Env resolving queries from @datastore and @relDB.
+func NewEnv(datastore models.Datastore, relDB models.RelDatastore) (*Env, error) {
+	schema, err := newSchema(datastore, relDB)
+	if err != nil {
+		return nil, err
+	}
+	return &Env{
+		datastore: datastore,
+		schema:    schema,
+	}, nil
+}
+
+// Query executes the GraphQL query of the request. Queries whose cost exceeds maxQueryCost
+// are rejected. The values of the datastores are loaded in batches per request.
+func (env *Env) Query(c *gin.Context) {
+	var req request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				sendErrors(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		sendErrors(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Query == "" {
+		sendErrors(c, http.StatusBadRequest, errors.New("missing query"))
+		return
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		sendErrors(c, http.StatusBadRequest, err)
+		return
+	}
+	validation := graphql.ValidateDocument(&env.schema, document, nil)
+	if !validation.IsValid {
+		c.JSON(http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+	cost, err := queryCost(env.schema, document, req.OperationName, req.Variables)
+	if err != nil {
+		sendErrors(c, http.StatusBadRequest, err)
+		return
+	}
+	if cost > maxQueryCost {
+		sendErrors(c, http.StatusBadRequest, fmt.Errorf("query cost %d exceeds maximum of %d", cost, maxQueryCost))
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        env.schema,
+		AST:           document,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(c.Request.Context(), env.datastore),
+	})
+	if len(result.Errors) > 0 {
+		log.Warnln("GraphQL query returned errors:", result.Errors)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func sendErrors(c *gin.Context, code int, errs ...error) {
+	c.JSON(code, &graphql.Result{Errors: gqlerrors.FormatErrors(errs...)})
+}
@@ -0,0 +1,127 @@
+package graphqlApi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/diadata-org/diadata/pkg/model"
+)
+
+// batchLoader collects the keys requested by the resolvers of a query and fetches all keys
+// pending with one call once the first of their values is needed. The executor resolves the
+// fields of one level before their values, so that the keys of a level are fetched together.
+type batchLoader struct {
+	// fetch returns the values of @keys in their order.
+	fetch   func(keys []string) ([]interface{}, error)
+	lock    sync.Mutex
+	pending []string
+	results map[string]loadResult
+}
+
+type loadResult struct {
+	value interface{}
+	err   error
+}
+
+func newBatchLoader(fetch func(keys []string) ([]interface{}, error)) *batchLoader {
+	return &batchLoader{
+		fetch:   fetch,
+		results: make(map[string]loadResult),
+	}
+}
+
+// load requests the value of @key and returns the thunk returning it.
+func (l *batchLoader) load(key string) func() (interface{}, error) {
+	l.lock.Lock()
+	if _, ok := l.results[key]; !ok && !contains(l.pending, key) {
+		l.pending = append(l.pending, key)
+	}
+	l.lock.Unlock()
+
+	return func() (interface{}, error) {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if _, ok := l.results[key]; !ok {
+			l.flush()
+		}
+		result := l.results[key]
+		return result.value, result.err
+	}
+}
+
+// flush fetches the pending keys. The lock must be held.
+func (l *batchLoader) flush() {
+	keys := l.pending
+	l.pending = nil
+	values, err := l.fetch(keys)
+	if err == nil && len(values) != len(keys) {
+		err = errors.New("batch returned wrong number of values")
+	}
+	for i, key := range keys {
+		if err != nil {
+			l.results[key] = loadResult{err: err}
+		} else {
+			l.results[key] = loadResult{value: values[i]}
+		}
+	}
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+type loadersKey struct{}
+
+// loaders are the batch loaders of one request.
+type loaders struct {
+	// quotations loads the latest BatchQuotation of symbols.
+	quotations *batchLoader
+}
+
+// withLoaders returns @ctx with new loaders reading from @datastore.
+func withLoaders(ctx context.Context, datastore models.Datastore) context.Context {
+	l := &loaders{
+		quotations: newBatchLoader(func(symbols []string) ([]interface{}, error) {
+			quotations, err := datastore.GetBatchQuotations(symbols, time.Time{})
+			if err != nil {
+				return nil, err
+			}
+			values := make([]interface{}, len(quotations))
+			for i := range quotations {
+				values[i] = quotations[i]
+			}
+			return values, nil
+		}),
+	}
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+// loadQuotation returns the thunk of the latest BatchQuotation of @symbol, whose partial
+// failures are returned as error by @value.
+func loadQuotation(ctx context.Context, symbol string, value func(models.BatchQuotation) (interface{}, bool)) (interface{}, error) {
+	l, ok := ctx.Value(loadersKey{}).(*loaders)
+	if !ok {
+		return nil, errors.New("no loaders in context")
+	}
+	thunk := l.quotations.load(symbol)
+	return func() (interface{}, error) {
+		result, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		quotation := result.(models.BatchQuotation)
+		v, ok := value(quotation)
+		if !ok {
+			return nil, errors.New(strings.Join(quotation.Errors, "; "))
+		}
+		return v, nil
+	}, nil
+}
@@ -0,0 +1,121 @@
+package graphqlApi
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestBatchLoader(t *testing.T) {
+	var batches [][]string
+	loader := newBatchLoader(func(keys []string) ([]interface{}, error) {
+		batches = append(batches, keys)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = key + "!"
+		}
+		return values, nil
+	})
+
+	a := loader.load("a")
+	b := loader.load("b")
+	loader.load("a")
+	for _, thunk := range []func() (interface{}, error){b, a, b} {
+		if _, err := thunk(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := loader.load("c")
+	// loaded keys are not fetched again
+	if value, _ := loader.load("a")(); value != "a!" {
+		t.Errorf("Value was incorrect, got: %v, want: %v.", value, "a!")
+	}
+	if value, _ := c(); value != "c!" {
+		t.Errorf("Value was incorrect, got: %v, want: %v.", value, "c!")
+	}
+	if want := [][]string{{"a", "b"}, {"c"}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("Batches were incorrect, got: %v, want: %v.", batches, want)
+	}
+
+	failing := newBatchLoader(func(keys []string) ([]interface{}, error) {
+		return []interface{}{"x"}, nil
+	})
+	x := failing.load("x")
+	if _, err := failing.load("y")(); err == nil {
+		t.Error("Batch of wrong length returned no error.")
+	}
+	if _, err := x(); err == nil {
+		t.Error("Batch of wrong length returned no error.")
+	}
+}
+
+// TestBatchLoaderExecution checks that the executor fetches the keys of the items of lists
+// in one batch.
+func TestBatchLoaderExecution(t *testing.T) {
+	var batches [][]string
+	loader := newBatchLoader(func(keys []string) ([]interface{}, error) {
+		batches = append(batches, keys)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			if key == "unknown" {
+				values[i] = errors.New("unknown symbol")
+				continue
+			}
+			values[i] = len(key)
+		}
+		return values, nil
+	})
+	itemType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Item",
+		Fields: graphql.Fields{
+			"length": &graphql.Field{
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					thunk := loader.load(p.Source.(string))
+					return func() (interface{}, error) {
+						value, err := thunk()
+						if err, ok := value.(error); ok {
+							return nil, err
+						}
+						return value, err
+					}, nil
+				},
+			},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"items": &graphql.Field{
+					Type: graphql.NewList(itemType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return []string{"BTC", "DOGE", "unknown"}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: "{ items { length } other: items { length } }",
+		Context:       context.Background(),
+	})
+	if len(result.Errors) != 2 {
+		t.Errorf("Errors were incorrect, got: %v, want: 2 errors of unknown symbol.", result.Errors)
+	}
+	if want := [][]string{{"BTC", "DOGE", "unknown"}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("Batches were incorrect, got: %v, want: %v.", batches, want)
+	}
+	items := result.Data.(map[string]interface{})["items"].([]interface{})
+	if length := items[1].(map[string]interface{})["length"]; length != 4 {
+		t.Errorf("Length was incorrect, got: %v, want: %v.", length, 4)
+	}
+}
@@ -0,0 +1,739 @@
+package graphqlApi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers"
+	models "github.com/diadata-org/diadata/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	// defaultFilter is the filter of the filter points of assets if none is given.
+	defaultFilter = "MA120"
+	// defaultTrades is the number of trades of assets if no limit is given.
+	defaultTrades = 100
+	// defaultRange is the time range of series if no start is given.
+	defaultRange = 24 * time.Hour
+	// maxRange is the maximal time range of series without scale.
+	maxRange = 31 * 24 * time.Hour
+)
+
+// maxScaleRanges are the maximal time ranges of filter points by scale, so that queries scan a
+// bounded number of points even though their results are truncated to their limit.
+var maxScaleRanges = map[string]time.Duration{
+	"":    7 * 24 * time.Hour,
+	"5m":  31 * 24 * time.Hour,
+	"30m": 183 * 24 * time.Hour,
+	"1h":  366 * 24 * time.Hour,
+	"4h":  2 * 366 * 24 * time.Hour,
+	"1d":  10 * 366 * 24 * time.Hour,
+	"1w":  10 * 366 * 24 * time.Hour,
+}
+
+// asset is the source of the fields of assets, which are resolved from their symbol.
+type asset struct {
+	Symbol string
+}
+
+// exchange is the source of the fields of exchanges, which are resolved from their name.
+type exchange struct {
+	Name string
+}
+
+// newSchema returns the schema resolving queries from @datastore and @relDB.
+func newSchema(datastore models.Datastore, relDB models.RelDatastore) (graphql.Schema, error) {
+	// limitArgs bound the lists of fields not bound by their other arguments, whose cost
+	// is computed from the limit.
+	limitArgs := graphql.FieldConfigArgument{
+		"limit": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: defaultListSize,
+			Description:  "Maximal number of values returned.",
+		},
+	}
+	timeRangeArgs := withArgs(limitArgs, graphql.FieldConfigArgument{
+		"starttime": &graphql.ArgumentConfig{
+			Type:        graphql.DateTime,
+			Description: "Start of the range, by default 24 hours before its end. Ranges are at most 31 days, or for filter points 7 days without scale.",
+		},
+		"endtime": &graphql.ArgumentConfig{
+			Type:        graphql.DateTime,
+			Description: "End of the range, by default now.",
+		},
+	})
+
+	quotationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Quotation",
+		Fields: graphql.Fields{
+			"symbol":             &graphql.Field{Type: graphql.String},
+			"name":               &graphql.Field{Type: graphql.String},
+			"price":              &graphql.Field{Type: graphql.Float},
+			"priceYesterday":     &graphql.Field{Type: graphql.Float},
+			"volumeYesterdayUSD": &graphql.Field{Type: graphql.Float},
+			"source":             &graphql.Field{Type: graphql.String},
+			"time":               &graphql.Field{Type: graphql.DateTime},
+			"itin":               &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	supplyType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Supply",
+		Fields: graphql.Fields{
+			"symbol":            &graphql.Field{Type: graphql.String},
+			"name":              &graphql.Field{Type: graphql.String},
+			"supply":            &graphql.Field{Type: graphql.Float},
+			"circulatingSupply": &graphql.Field{Type: graphql.Float},
+			"source":            &graphql.Field{Type: graphql.String},
+			"time":              &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	filterPointType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "FilterPoint",
+		Fields: graphql.Fields{
+			"symbol":   &graphql.Field{Type: graphql.String},
+			"exchange": &graphql.Field{Type: graphql.String},
+			"name":     &graphql.Field{Type: graphql.String},
+			"value":    &graphql.Field{Type: graphql.Float},
+			"time":     &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	tradeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Trade",
+		Fields: graphql.Fields{
+			"symbol":            &graphql.Field{Type: graphql.String},
+			"pair":              &graphql.Field{Type: graphql.String},
+			"price":             &graphql.Field{Type: graphql.Float},
+			"volume":            &graphql.Field{Type: graphql.Float},
+			"time":              &graphql.Field{Type: graphql.DateTime},
+			"foreignTradeID":    &graphql.Field{Type: graphql.String},
+			"estimatedUSDPrice": &graphql.Field{Type: graphql.Float},
+			"source":            &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	var assetType *graphql.Object
+	exchangeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Exchange",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": &graphql.Field{Type: graphql.String},
+				"symbols": &graphql.Field{
+					Type: graphql.NewList(assetType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return assets(datastore.GetSymbolsByExchange(p.Source.(exchange).Name)), nil
+					},
+				},
+			}
+		}),
+	})
+
+	assetType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Asset",
+		Fields: graphql.Fields{
+			"symbol": &graphql.Field{Type: graphql.String},
+			"name": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return helpers.NameForSymbol(p.Source.(asset).Symbol), nil
+				},
+			},
+			"quotation": &graphql.Field{
+				Type: quotationType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadQuotation(p.Context, p.Source.(asset).Symbol, func(bq models.BatchQuotation) (interface{}, bool) {
+						return bq.Quotation, bq.Quotation != nil
+					})
+				},
+			},
+			"supply": &graphql.Field{
+				Type: supplyType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadQuotation(p.Context, p.Source.(asset).Symbol, func(bq models.BatchQuotation) (interface{}, bool) {
+						return bq.Supply, bq.Supply != nil
+					})
+				},
+			},
+			"marketCap": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Price times circulating supply.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadQuotation(p.Context, p.Source.(asset).Symbol, func(bq models.BatchQuotation) (interface{}, bool) {
+						return bq.MarketCap, bq.Quotation != nil && bq.Supply != nil
+					})
+				},
+			},
+			"exchanges": &graphql.Field{
+				Type: graphql.NewList(exchangeType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					names, err := datastore.GetExchangesForSymbol(p.Source.(asset).Symbol)
+					if err != nil {
+						return nil, err
+					}
+					return exchanges(names), nil
+				},
+			},
+			"filterPoints": &graphql.Field{
+				Type: graphql.NewList(filterPointType),
+				Args: withArgs(timeRangeArgs, graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{
+						Type:         graphql.String,
+						DefaultValue: defaultFilter,
+					},
+					"exchange": &graphql.ArgumentConfig{
+						Type:         graphql.String,
+						DefaultValue: "",
+						Description:  "Exchange of the filter, all exchanges if empty.",
+					},
+					"scale": &graphql.ArgumentConfig{
+						Type:         graphql.String,
+						DefaultValue: "",
+						Description:  "Scale of the rollups 5m 30m 1h 4h 1d 1w, no rollup if empty.",
+					},
+				}),
+				Resolve: limited(func(p graphql.ResolveParams) (interface{}, error) {
+					symbol := p.Source.(asset).Symbol
+					filter := p.Args["filter"].(string)
+					exchange := p.Args["exchange"].(string)
+					starttime, endtime, err := timeRange(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					points, err := datastore.GetFilterPoints(filter, exchange, symbol, p.Args["scale"].(string), starttime, endtime)
+					if err != nil {
+						return nil, err
+					}
+					return filterPoints(points, symbol, filter, exchange)
+				}),
+			},
+			"trades": &graphql.Field{
+				Type: graphql.NewList(tradeType),
+				Args: graphql.FieldConfigArgument{
+					"exchange": &graphql.ArgumentConfig{
+						Type:         graphql.String,
+						DefaultValue: "",
+						Description:  "Exchange of the trades, all exchanges if empty.",
+					},
+					"limit": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: defaultTrades,
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					symbol := p.Source.(asset).Symbol
+					limit := p.Args["limit"].(int)
+					if limit <= 0 {
+						return nil, errors.New("limit must be positive")
+					}
+					if exchange := p.Args["exchange"].(string); exchange != "" {
+						return datastore.GetLastTrades(symbol, exchange, limit)
+					}
+					return datastore.GetLastTradesAllExchanges(symbol, limit)
+				},
+			},
+			"supplies": &graphql.Field{
+				Type: graphql.NewList(supplyType),
+				Args: timeRangeArgs,
+				Resolve: limited(func(p graphql.ResolveParams) (interface{}, error) {
+					starttime, endtime, err := timeRange(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return datastore.GetSupplyInflux(p.Source.(asset).Symbol, starttime, endtime)
+				}),
+			},
+		},
+	})
+
+	interestRateType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "InterestRate",
+		Fields: graphql.Fields{
+			"symbol":          &graphql.Field{Type: graphql.String},
+			"value":           &graphql.Field{Type: graphql.Float},
+			"publicationTime": &graphql.Field{Type: graphql.DateTime},
+			"effectiveDate":   &graphql.Field{Type: graphql.DateTime},
+			"source":          &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	defiRateType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "DefiRate",
+		Fields: graphql.Fields{
+			"timestamp":     &graphql.Field{Type: graphql.DateTime},
+			"lendingRate":   &graphql.Field{Type: graphql.Float},
+			"borrowingRate": &graphql.Field{Type: graphql.Float},
+			"asset":         &graphql.Field{Type: graphql.String},
+			"protocol":      &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	defiProtocolType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "DefiProtocol",
+		Fields: graphql.Fields{
+			"name":                 &graphql.Field{Type: graphql.String},
+			"address":              &graphql.Field{Type: graphql.String},
+			"underlyingBlockchain": &graphql.Field{Type: graphql.String},
+			"token":                &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	defiStateType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "DefiProtocolState",
+		Fields: graphql.Fields{
+			"totalUSD":  &graphql.Field{Type: graphql.Float},
+			"totalETH":  &graphql.Field{Type: graphql.Float},
+			"timestamp": &graphql.Field{Type: graphql.DateTime},
+			"protocol":  &graphql.Field{Type: defiProtocolType},
+		},
+	})
+
+	farmingPoolType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "FarmingPool",
+		Fields: graphql.Fields{
+			"rate":         &graphql.Field{Type: graphql.Float},
+			"balance":      &graphql.Field{Type: graphql.Float},
+			"protocolName": &graphql.Field{Type: graphql.String},
+			"blockNumber":  &graphql.Field{Type: graphql.Int},
+			"poolID":       &graphql.Field{Type: graphql.String},
+			"timeStamp":    &graphql.Field{Type: graphql.DateTime},
+			"outputAsset":  &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"inputAsset":   &graphql.Field{Type: graphql.NewList(graphql.String)},
+		},
+	})
+
+	farmingPoolTypeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "FarmingPoolType",
+		Fields: graphql.Fields{
+			"protocolName": &graphql.Field{Type: graphql.String},
+			"inputAsset":   &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"poolID":       &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	nftClassType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NFTClass",
+		Fields: graphql.Fields{
+			"address":      &graphql.Field{Type: graphql.String},
+			"symbol":       &graphql.Field{Type: graphql.String},
+			"name":         &graphql.Field{Type: graphql.String},
+			"blockchain":   &graphql.Field{Type: graphql.String},
+			"contractType": &graphql.Field{Type: graphql.String},
+			"category":     &graphql.Field{Type: graphql.String},
+			"price30Days": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Average price of the NFTs of the class over the last 30 days.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return relDB.GetNFTPrice30Days(p.Source.(dia.NFTClass))
+				},
+			},
+		},
+	})
+
+	nftTradeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NFTTrade",
+		Fields: graphql.Fields{
+			"price": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Price in the smallest unit of the currency.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					price := p.Source.(dia.NFTTrade).Price
+					if price == nil {
+						return nil, nil
+					}
+					return price.String(), nil
+				},
+			},
+			"priceUSD":         &graphql.Field{Type: graphql.Float},
+			"fromAddress":      &graphql.Field{Type: graphql.String},
+			"toAddress":        &graphql.Field{Type: graphql.String},
+			"currencySymbol":   &graphql.Field{Type: graphql.String},
+			"currencyAddress":  &graphql.Field{Type: graphql.String},
+			"currencyDecimals": &graphql.Field{Type: graphql.Int},
+			"blockNumber":      &graphql.Field{Type: graphql.Int},
+			"timestamp":        &graphql.Field{Type: graphql.DateTime},
+			"txHash":           &graphql.Field{Type: graphql.String},
+			"exchange":         &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	nftType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NFT",
+		Fields: graphql.Fields{
+			"nftClass":       &graphql.Field{Type: nftClassType},
+			"tokenID":        &graphql.Field{Type: graphql.String},
+			"creationTime":   &graphql.Field{Type: graphql.DateTime},
+			"creatorAddress": &graphql.Field{Type: graphql.String},
+			"uri":            &graphql.Field{Type: graphql.String},
+			"trades": &graphql.Field{
+				Type: graphql.NewList(nftTradeType),
+				Args: limitArgs,
+				Resolve: limited(func(p graphql.ResolveParams) (interface{}, error) {
+					return relDB.GetNFTTrades(p.Source.(dia.NFT))
+				}),
+			},
+		},
+	})
+
+	cryptoIndexConstituentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CryptoIndexConstituent",
+		Fields: graphql.Fields{
+			"name":              &graphql.Field{Type: graphql.String},
+			"symbol":            &graphql.Field{Type: graphql.String},
+			"address":           &graphql.Field{Type: graphql.String},
+			"price":             &graphql.Field{Type: graphql.Float},
+			"priceYesterday":    &graphql.Field{Type: graphql.Float},
+			"priceYesterweek":   &graphql.Field{Type: graphql.Float},
+			"circulatingSupply": &graphql.Field{Type: graphql.Float},
+			"weight":            &graphql.Field{Type: graphql.Float},
+			"percentage":        &graphql.Field{Type: graphql.Float},
+			"cappingFactor":     &graphql.Field{Type: graphql.Float},
+			"numBaseTokens":     &graphql.Field{Type: graphql.Float},
+		},
+	})
+
+	cryptoIndexType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CryptoIndex",
+		Fields: graphql.Fields{
+			"name":              &graphql.Field{Type: graphql.String},
+			"value":             &graphql.Field{Type: graphql.Float},
+			"price":             &graphql.Field{Type: graphql.Float},
+			"price1h":           &graphql.Field{Type: graphql.Float},
+			"price24h":          &graphql.Field{Type: graphql.Float},
+			"price7d":           &graphql.Field{Type: graphql.Float},
+			"price14d":          &graphql.Field{Type: graphql.Float},
+			"price30d":          &graphql.Field{Type: graphql.Float},
+			"volume24hUSD":      &graphql.Field{Type: graphql.Float},
+			"circulatingSupply": &graphql.Field{Type: graphql.Float},
+			"divisor":           &graphql.Field{Type: graphql.Float},
+			"calculationTime":   &graphql.Field{Type: graphql.DateTime},
+			"constituents":      &graphql.Field{Type: graphql.NewList(cryptoIndexConstituentType)},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"asset": &graphql.Field{
+				Type: assetType,
+				Args: graphql.FieldConfigArgument{
+					"symbol": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return asset{Symbol: p.Args["symbol"].(string)}, nil
+				},
+			},
+			"assets": &graphql.Field{
+				Type: graphql.NewList(assetType),
+				Args: graphql.FieldConfigArgument{
+					"symbols": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var symbols []string
+					for _, symbol := range p.Args["symbols"].([]interface{}) {
+						symbols = append(symbols, symbol.(string))
+					}
+					return assets(symbols), nil
+				},
+			},
+			"exchanges": &graphql.Field{
+				Type: graphql.NewList(exchangeType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return exchanges(datastore.GetExchanges()), nil
+				},
+			},
+			"exchange": &graphql.Field{
+				Type: exchangeType,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return exchange{Name: p.Args["name"].(string)}, nil
+				},
+			},
+			"interestRate": &graphql.Field{
+				Type: interestRateType,
+				Args: graphql.FieldConfigArgument{
+					"symbol": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"date": &graphql.ArgumentConfig{
+						Type:         graphql.String,
+						DefaultValue: "",
+						Description:  "Date as yyyy-mm-dd, by default today.",
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return datastore.GetInterestRate(p.Args["symbol"].(string), p.Args["date"].(string))
+				},
+			},
+			"interestRates": &graphql.Field{
+				Type: graphql.NewList(interestRateType),
+				Args: withArgs(limitArgs, graphql.FieldConfigArgument{
+					"symbol":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"dateInit":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"dateFinal": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				}),
+				Resolve: limited(func(p graphql.ResolveParams) (interface{}, error) {
+					if err := checkDateRange(p.Args["dateInit"].(string), p.Args["dateFinal"].(string)); err != nil {
+						return nil, err
+					}
+					return datastore.GetInterestRateRange(p.Args["symbol"].(string), p.Args["dateInit"].(string), p.Args["dateFinal"].(string))
+				}),
+			},
+			"defiRates": &graphql.Field{
+				Type: graphql.NewList(defiRateType),
+				Args: withArgs(timeRangeArgs, graphql.FieldConfigArgument{
+					"protocol": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"asset":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				}),
+				Resolve: limited(func(p graphql.ResolveParams) (interface{}, error) {
+					starttime, endtime, err := timeRange(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return datastore.GetDefiRateInflux(starttime, endtime, p.Args["asset"].(string), p.Args["protocol"].(string))
+				}),
+			},
+			"defiStates": &graphql.Field{
+				Type: graphql.NewList(defiStateType),
+				Args: withArgs(timeRangeArgs, graphql.FieldConfigArgument{
+					"protocol": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				}),
+				Resolve: limited(func(p graphql.ResolveParams) (interface{}, error) {
+					starttime, endtime, err := timeRange(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return datastore.GetDefiStateInflux(starttime, endtime, p.Args["protocol"].(string))
+				}),
+			},
+			"farmingPools": &graphql.Field{
+				Type: graphql.NewList(farmingPoolTypeType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return datastore.GetFarmingPools()
+				},
+			},
+			"farmingPoolData": &graphql.Field{
+				Type: graphql.NewList(farmingPoolType),
+				Args: withArgs(timeRangeArgs, graphql.FieldConfigArgument{
+					"protocol": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"poolID":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				}),
+				Resolve: limited(func(p graphql.ResolveParams) (interface{}, error) {
+					starttime, endtime, err := timeRange(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return datastore.GetFarmingPoolData(starttime, endtime, p.Args["protocol"].(string), p.Args["poolID"].(string))
+				}),
+			},
+			"nftCategories": &graphql.Field{
+				Type: graphql.NewList(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return relDB.GetNFTCategories()
+				},
+			},
+			"nftClasses": &graphql.Field{
+				Type: graphql.NewList(nftClassType),
+				Args: graphql.FieldConfigArgument{
+					"limit":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					limit := p.Args["limit"].(int)
+					offset := p.Args["offset"].(int)
+					if limit <= 0 || offset < 0 {
+						return nil, errors.New("limit must be positive and offset not negative")
+					}
+					return relDB.GetNFTClasses(uint64(limit), uint64(offset))
+				},
+			},
+			"nftClass": &graphql.Field{
+				Type: nftClassType,
+				Args: graphql.FieldConfigArgument{
+					"address":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"blockchain": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					address := common.HexToAddress(p.Args["address"].(string)).Hex()
+					return relDB.GetNFTClass(address, p.Args["blockchain"].(string))
+				},
+			},
+			"nft": &graphql.Field{
+				Type: nftType,
+				Args: graphql.FieldConfigArgument{
+					"address":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"blockchain": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"tokenID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					address := common.HexToAddress(p.Args["address"].(string)).Hex()
+					return relDB.GetNFT(address, p.Args["blockchain"].(string), p.Args["tokenID"].(string))
+				},
+			},
+			"cryptoIndex": &graphql.Field{
+				Type: graphql.NewList(cryptoIndexType),
+				Args: withArgs(timeRangeArgs, graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				}),
+				Resolve: limited(func(p graphql.ResolveParams) (interface{}, error) {
+					starttime, endtime, err := timeRange(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return datastore.GetCryptoIndex(starttime, endtime, p.Args["name"].(string))
+				}),
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// withArgs returns the union of the arguments @args.
+func withArgs(args ...graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	union := graphql.FieldConfigArgument{}
+	for _, a := range args {
+		for name, arg := range a {
+			union[name] = arg
+		}
+	}
+	return union
+}
+
+// limited returns @resolve returning at most the number of values of the argument limit, so
+// that fields resolve no more values than their cost is computed from.
+func limited(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		limit, _ := p.Args["limit"].(int)
+		if limit <= 0 {
+			return nil, errors.New("limit must be positive")
+		}
+		list, err := resolve(p)
+		if err != nil {
+			return nil, err
+		}
+		if v := reflect.ValueOf(list); v.Kind() == reflect.Slice && v.Len() > limit {
+			return v.Slice(0, limit).Interface(), nil
+		}
+		return list, nil
+	}
+}
+
+// timeRange returns the range of the arguments starttime and endtime of a field. The range
+// ends now and starts defaultRange before its end if they are not given. An error is returned
+// if the range is longer than its maximum, see maxTimeRange.
+func timeRange(args map[string]interface{}) (time.Time, time.Time, error) {
+	endtime, ok := args["endtime"].(time.Time)
+	if !ok {
+		endtime = time.Now()
+	}
+	starttime, ok := args["starttime"].(time.Time)
+	if !ok {
+		starttime = endtime.Add(-defaultRange)
+	}
+	if max := maxTimeRange(args); endtime.Sub(starttime) > max {
+		return starttime, endtime, fmt.Errorf("time range exceeds maximum of %v", max)
+	}
+	return starttime, endtime, nil
+}
+
+// maxTimeRange returns the maximal time range of a field with the arguments @args, which is
+// given by the scale of fields with scale argument.
+func maxTimeRange(args map[string]interface{}) time.Duration {
+	scale, ok := args["scale"].(string)
+	if !ok {
+		return maxRange
+	}
+	if max, ok := maxScaleRanges[scale]; ok {
+		return max
+	}
+	return maxScaleRanges[""]
+}
+
+// checkDateRange returns an error if the range of the dates @dateInit and @dateFinal, given as
+// yyyy-mm-dd, is longer than maxRange.
+func checkDateRange(dateInit string, dateFinal string) error {
+	init, err := time.Parse("2006-01-02", dateInit)
+	if err != nil {
+		return err
+	}
+	final, err := time.Parse("2006-01-02", dateFinal)
+	if err != nil {
+		return err
+	}
+	if final.Sub(init) > maxRange {
+		return fmt.Errorf("time range exceeds maximum of %v", maxRange)
+	}
+	return nil
+}
+
+func assets(symbols []string) []asset {
+	result := make([]asset, len(symbols))
+	for i, symbol := range symbols {
+		result[i] = asset{Symbol: symbol}
+	}
+	return result
+}
+
+func exchanges(names []string) []exchange {
+	result := make([]exchange, len(names))
+	for i, name := range names {
+		result[i] = exchange{Name: name}
+	}
+	return result
+}
+
+// filterPoints returns the filter points of @symbol, @filter and @exchange in @points, whose
+// rows have a time and a value column as the filters table and its rollups.
+func filterPoints(points *models.Points, symbol string, filter string, exchange string) ([]dia.FilterPoint, error) {
+	result := []dia.FilterPoint{}
+	if points == nil {
+		return result, nil
+	}
+	for _, res := range points.DataPoints {
+		for _, series := range res.Series {
+			columns := make(map[string]int)
+			for i, column := range series.Columns {
+				columns[column] = i
+			}
+			timeColumn, ok := columns["time"]
+			if !ok {
+				return nil, errors.New("filter points without time")
+			}
+			valueColumn, ok := columns["value"]
+			if !ok {
+				return nil, errors.New("filter points without value")
+			}
+			for _, row := range series.Values {
+				timeStr, _ := row[timeColumn].(string)
+				t, err := time.Parse(time.RFC3339, timeStr)
+				if err != nil {
+					return nil, err
+				}
+				number, ok := row[valueColumn].(json.Number)
+				if !ok {
+					continue
+				}
+				value, err := number.Float64()
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, dia.FilterPoint{
+					Symbol:   symbol,
+					Exchange: exchange,
+					Name:     filter,
+					Value:    value,
+					Time:     t,
+				})
+			}
+		}
+	}
+	return result, nil
+}
@@ -20,8 +20,9 @@ var (
 // @hello
 // returns some kafka messages
 type resultApi struct {
-	Offset   int64         `json:"offset"`
-	Messages []interface{} `json:"messages"`
+	Partition int           `json:"partition"`
+	Offset    int64         `json:"offset"`
+	Messages  []interface{} `json:"messages"`
 }
 
 type RestApi struct {
@@ -49,7 +50,8 @@ func getOffset(c *gin.Context) int64 {
 
 func Process(c *gin.Context, topic int) {
 	elements, _ := strconv.Atoi(c.Query("elements"))
-	result, err := Apis[topic].Get(getOffset(c), elements)
+	partition, _ := strconv.Atoi(c.Query("partition"))
+	result, err := Apis[topic].Get(partition, getOffset(c), elements)
 	if err == nil {
 		c.JSON(http.StatusOK, result)
 	} else {
@@ -68,15 +70,15 @@ func Process(c *gin.Context, topic int) {
 // @Failure 404 {object} restApi.APIError "Can not find ID"
 // @Router /testapi/get-string-by-int/{some_id} [get]
 
-func (s *RestApi) Get(offset int64, elements int) (map[string]interface{}, error) {
+func (s *RestApi) Get(partition int, offset int64, elements int) (map[string]interface{}, error) {
 
 	if (elements == 0) || (elements > 100) {
 		elements = 100
 	}
 
-	result := &resultApi{}
+	result := &resultApi{Partition: partition}
 
-	maxOffset, err := kafkaHelper.ReadOffset(s.topic)
+	maxOffset, err := kafkaHelper.ReadPartitionOffset(s.topic, partition)
 
 	if err != nil {
 		return nil, err
@@ -98,7 +100,7 @@ func (s *RestApi) Get(offset int64, elements int) (map[string]interface{}, error
 	}
 	log.Printf("Get: maxOffset %v offset:%v nbElements:%v ", maxOffset, offset, nbElements)
 
-	element, err := kafkaHelper.GetElements(s.topic, offset, nbElements)
+	element, err := kafkaHelper.GetPartitionElements(s.topic, partition, offset, nbElements)
 
 	if err != nil {
 		return nil, err
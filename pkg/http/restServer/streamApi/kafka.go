@@ -0,0 +1,117 @@
+package streamApi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers"
+	"github.com/diadata-org/diadata/pkg/dia/helpers/kafkaHelper"
+	models "github.com/diadata-org/diadata/pkg/model"
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// ConsumeTopic publishes the events of all partitions of @topic to @h, starting at the messages
+// produced after the call. Trades are read from TopicTrades, filter points and quotations from
+// TopicFiltersBlock.
+func (h *Hub) ConsumeTopic(topic int) error {
+	config, err := kafkaHelper.GetTopicConfig(topic)
+	if err != nil {
+		return err
+	}
+	for partition := 0; partition < config.Partitions; partition++ {
+		c, err := kafkaHelper.NewConsumer(topic, kafkaHelper.ConsumerOptions{
+			Start:     kafkaHelper.StartLatest,
+			Partition: partition,
+		})
+		if err != nil {
+			return err
+		}
+		go h.consume(topic, c)
+	}
+	return nil
+}
+
+func (h *Hub) consume(topic int, c *kafkaHelper.Consumer) {
+	defer c.Close()
+	for {
+		m, err := c.Fetch(context.Background())
+		if err != nil {
+			log.Errorln("stream: fetch", err)
+			continue
+		}
+		events, err := eventsOf(topic, m)
+		if err != nil {
+			log.Errorf("stream: ignored message at offset %d: %v", m.Offset, err)
+			continue
+		}
+		for _, e := range events {
+			h.Publish(e)
+		}
+	}
+}
+
+// eventsOf returns the events derived from the message @m of @topic.
+func eventsOf(topic int, m kafka.Message) ([]Event, error) {
+	position := Position{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}
+	switch topic {
+	case kafkaHelper.TopicTrades:
+		var t dia.Trade
+		if err := kafkaHelper.Decode(m.Value, &t); err != nil {
+			return nil, err
+		}
+		return []Event{tradeEvent(t, position)}, nil
+	case kafkaHelper.TopicFiltersBlock:
+		var b dia.FiltersBlock
+		if err := kafkaHelper.Decode(m.Value, &b); err != nil {
+			return nil, err
+		}
+		return filtersBlockEvents(&b, position), nil
+	}
+	return nil, fmt.Errorf("no events for topic %s", kafkaHelper.GetTopic(topic))
+}
+
+func tradeEvent(t dia.Trade, position Position) Event {
+	return Event{
+		Type:     EventTrade,
+		Data:     t,
+		position: position,
+		symbol:   t.Symbol,
+		exchange: t.Source,
+	}
+}
+
+// filtersBlockEvents returns the filter points of @b, and a quotation for each point of
+// dia.FilterKing over all exchanges, the filter the quotations of the datastore are set from.
+func filtersBlockEvents(b *dia.FiltersBlock, position Position) []Event {
+	var events []Event
+	for _, fp := range b.FiltersBlockData.FilterPoints {
+		position.Index = len(events)
+		events = append(events, Event{
+			Type:     EventFilterPoint,
+			Data:     fp,
+			position: position,
+			symbol:   fp.Symbol,
+			exchange: fp.Exchange,
+			filter:   fp.Name,
+		})
+		if fp.Name != dia.FilterKing || fp.Exchange != "" {
+			continue
+		}
+		position.Index = len(events)
+		events = append(events, Event{
+			Type: EventQuotation,
+			Data: models.Quotation{
+				Symbol: fp.Symbol,
+				Name:   helpers.NameForSymbol(fp.Symbol),
+				Price:  fp.Value,
+				Source: dia.Diadata,
+				Time:   fp.Time,
+			},
+			position: position,
+			symbol:   fp.Symbol,
+		})
+	}
+	return events
+}
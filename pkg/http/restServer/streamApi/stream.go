@@ -0,0 +1,282 @@
+package streamApi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	EventTrade       = "trade"
+	EventFilterPoint = "filterPoint"
+	EventQuotation   = "quotation"
+	EventError       = "error"
+)
+
+// Position is the position of an event in the kafka message it is derived from. Index is the
+// index of the event among the events of the message, e.g. the filter points of a block.
+type Position struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Index     int
+}
+
+func (p Position) String() string {
+	return p.Topic + ":" + strconv.Itoa(p.Partition) + ":" + strconv.FormatInt(p.Offset, 10) + ":" + strconv.Itoa(p.Index)
+}
+
+// before returns true if @p is an earlier position than @q of the same partition.
+func (p Position) before(q Position) bool {
+	return p.Offset < q.Offset || (p.Offset == q.Offset && p.Index < q.Index)
+}
+
+// gapBefore returns true if events between @p and the later position @q of the same partition
+// are certainly missing.
+func (p Position) gapBefore(q Position) bool {
+	if q.Offset == p.Offset {
+		return q.Index > p.Index+1
+	}
+	return q.Offset > p.Offset+1 || q.Index > 0
+}
+
+// Event is pushed to the clients subscribed to its type, symbol, exchange and filter.
+type Event struct {
+	Type string `json:"type"`
+	// ID is the position of the kafka message of the event.
+	ID string `json:"id,omitempty"`
+	// Cursor resumes the stream of a client after the event, see Cursor.
+	Cursor string      `json:"cursor,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+
+	position Position
+	symbol   string
+	exchange string
+	filter   string
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// Cursor holds the positions of the last events delivered to a client per topic and partition.
+// Clients resume their stream after reconnecting by passing the cursor of the last event received.
+type Cursor map[partitionKey]Position
+
+// ParseCursor parses the comma separated positions @s of a cursor.
+func ParseCursor(s string) (Cursor, error) {
+	c := make(Cursor)
+	if s == "" {
+		return c, nil
+	}
+	for _, position := range strings.Split(s, ",") {
+		parts := strings.Split(position, ":")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid position %s", position)
+		}
+		partition, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid partition in position %s", position)
+		}
+		offset, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset in position %s", position)
+		}
+		index, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid index in position %s", position)
+		}
+		c[partitionKey{parts[0], partition}] = Position{parts[0], partition, offset, index}
+	}
+	return c, nil
+}
+
+func (c Cursor) String() string {
+	positions := make([]string, 0, len(c))
+	for _, p := range c {
+		positions = append(positions, p.String())
+	}
+	sort.Strings(positions)
+	return strings.Join(positions, ",")
+}
+
+// Advance moves the cursor to @p.
+func (c Cursor) Advance(p Position) {
+	c[partitionKey{p.Topic, p.Partition}] = p
+}
+
+// after returns true if @p comes after the cursor. Positions of partitions unknown to the
+// cursor come after it.
+func (c Cursor) after(p Position) bool {
+	q, ok := c[partitionKey{p.Topic, p.Partition}]
+	return !ok || q.before(p)
+}
+
+// Subscription selects the events pushed to a client. Empty sets select all values.
+type Subscription struct {
+	Types     []string `json:"types"`
+	Symbols   []string `json:"symbols"`
+	Exchanges []string `json:"exchanges"`
+	Filters   []string `json:"filters"`
+}
+
+// ParseSubscription returns the subscription to the comma separated lists of values.
+func ParseSubscription(types, symbols, exchanges, filters string) Subscription {
+	return Subscription{
+		Types:     splitList(types),
+		Symbols:   splitList(symbols),
+		Exchanges: splitList(exchanges),
+		Filters:   splitList(filters),
+	}
+}
+
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func contains(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns true if @e is selected by the subscription. Filters only apply to filter
+// points, exchanges do not apply to quotations.
+func (s Subscription) Matches(e Event) bool {
+	if !contains(s.Types, e.Type) || !contains(s.Symbols, e.symbol) {
+		return false
+	}
+	switch e.Type {
+	case EventTrade:
+		return contains(s.Exchanges, e.exchange)
+	case EventFilterPoint:
+		return contains(s.Exchanges, e.exchange) && contains(s.Filters, e.filter)
+	}
+	return true
+}
+
+// Subscriber receives the events of a Hub matching its subscription.
+type Subscriber struct {
+	// Events is closed when the subscriber is dropped because it does not keep up with the
+	// events, or when it unsubscribes.
+	Events       chan Event
+	subscription Subscription
+	lagging      bool
+}
+
+// Lagging returns true if the subscriber has been dropped because its queue was full. It is
+// only valid after Events has been closed.
+func (s *Subscriber) Lagging() bool {
+	return s.lagging
+}
+
+// Hub fans out events to subscribers. The latest events are kept for resuming the streams of
+// reconnecting clients.
+type Hub struct {
+	mu          sync.Mutex
+	buffer      []Event
+	bufferSize  int
+	queueSize   int
+	subscribers map[*Subscriber]struct{}
+}
+
+// NewHub returns a Hub keeping the latest @bufferSize events. Subscribers are dropped once
+// @queueSize events are waiting to be sent to them.
+func NewHub(bufferSize int, queueSize int) *Hub {
+	return &Hub{
+		bufferSize:  bufferSize,
+		queueSize:   queueSize,
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Publish pushes @e to all subscribers it matches.
+func (h *Hub) Publish(e Event) {
+	e.ID = e.position.String()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buffer = append(h.buffer, e)
+	if len(h.buffer) > h.bufferSize {
+		h.buffer = h.buffer[len(h.buffer)-h.bufferSize:]
+	}
+	for s := range h.subscribers {
+		if !s.subscription.Matches(e) {
+			continue
+		}
+		select {
+		case s.Events <- e:
+		default:
+			// dropped subscribers resume from their cursor after reconnecting
+			s.lagging = true
+			delete(h.subscribers, s)
+			close(s.Events)
+		}
+	}
+}
+
+// Subscribe registers a subscriber to @subscription. The buffered events after @resume which
+// match the subscription are returned for sending before the events of the subscriber. An
+// error is returned along with them if events after @resume are no longer buffered.
+func (h *Hub) Subscribe(subscription Subscription, resume Cursor) (*Subscriber, []Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []Event
+	var err error
+	if len(resume) > 0 {
+		oldest := make(Cursor)
+		for _, e := range h.buffer {
+			key := partitionKey{e.position.Topic, e.position.Partition}
+			if _, ok := oldest[key]; !ok {
+				oldest[key] = e.position
+			}
+			if resume.after(e.position) && subscription.Matches(e) {
+				replay = append(replay, e)
+			}
+		}
+		for key, p := range resume {
+			if first, ok := oldest[key]; ok && p.before(first) && p.gapBefore(first) {
+				err = fmt.Errorf("events after %s are no longer buffered", p)
+			}
+		}
+	}
+
+	s := &Subscriber{
+		Events:       make(chan Event, h.queueSize),
+		subscription: subscription,
+	}
+	h.subscribers[s] = struct{}{}
+	return s, replay, err
+}
+
+// Update replaces the subscription of @s.
+func (h *Hub) Update(s *Subscriber, subscription Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.subscription = subscription
+}
+
+// Unsubscribe removes @s from the hub and closes its events.
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.Events)
+	}
+}
@@ -0,0 +1,227 @@
+package streamApi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/http/restApi"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// writeWait is the time allowed for writing an event to a client.
+	writeWait = 10 * time.Second
+	// pingPeriod is the interval of pings keeping websocket connections alive.
+	pingPeriod = 30 * time.Second
+	// pongWait is the time allowed for the pong answering a ping.
+	pongWait = pingPeriod + writeWait
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// the stream is public like the rest of the API
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type Env struct {
+	Hub *Hub
+}
+
+// subscribe subscribes the client of @c to the events selected by the query parameters. The
+// stream resumes after the cursor @resume if it is not empty.
+func (env *Env) subscribe(c *gin.Context, resume string) (*Subscriber, []Event, error) {
+	cursor, err := ParseCursor(resume)
+	if err != nil {
+		return nil, nil, err
+	}
+	subscription := ParseSubscription(c.Query("types"), c.Query("symbols"), c.Query("exchanges"), c.Query("filters"))
+	s, replay, err := env.Hub.Subscribe(subscription, cursor)
+	if err != nil {
+		// the stream goes on with the buffered events
+		replay = append([]Event{{Type: EventError, Data: err.Error()}}, replay...)
+	}
+	return s, replay, nil
+}
+
+// lagging returns the error event sent to clients dropped for not keeping up with the events.
+func lagging(cursor Cursor) Event {
+	return Event{
+		Type:   EventError,
+		Data:   "client does not keep up with the events, resume with the cursor",
+		Cursor: cursor.String(),
+	}
+}
+
+// Websocket godoc
+// @Summary Stream trades, filter points and quotations
+// @Description Pushes events as they are produced. Clients can change their subscription by sending it as JSON.
+// @Description Each event carries the cursor for resuming the stream after a reconnect.
+// @Tags stream
+// @Param types query string false "comma separated event types: trade, filterPoint, quotation"
+// @Param symbols query string false "comma separated symbols"
+// @Param exchanges query string false "comma separated exchanges"
+// @Param filters query string false "comma separated filter names"
+// @Param resume query string false "cursor of the last event received"
+// @Router /stream/ws [get]
+func (env *Env) Websocket(c *gin.Context) {
+	s, replay, err := env.subscribe(c, c.Query("resume"))
+	if err != nil {
+		restApi.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	defer env.Hub.Unsubscribe(s)
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Errorln("stream: upgrade", err)
+		return
+	}
+	defer conn.Close()
+
+	// the reader handles subscription changes and pongs, and ends the stream on close
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var subscription Subscription
+			if err := json.Unmarshal(data, &subscription); err != nil {
+				log.Warnln("stream: invalid subscription", err)
+				continue
+			}
+			env.Hub.Update(s, subscription)
+		}
+	}()
+
+	cursor, _ := ParseCursor(c.Query("resume"))
+	send := func(e Event) error {
+		if e.Type != EventError {
+			cursor.Advance(e.position)
+			e.Cursor = cursor.String()
+		}
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteJSON(e)
+	}
+
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-s.Events:
+			if !ok {
+				if s.Lagging() {
+					send(lagging(cursor))
+					conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "lagging"), time.Now().Add(writeWait))
+				}
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// SSE godoc
+// @Summary Stream trades, filter points and quotations as server-sent events
+// @Description Server-sent events fallback of the websocket stream. The id of each event is the cursor for resuming the stream.
+// @Tags stream
+// @Param types query string false "comma separated event types: trade, filterPoint, quotation"
+// @Param symbols query string false "comma separated symbols"
+// @Param exchanges query string false "comma separated exchanges"
+// @Param filters query string false "comma separated filter names"
+// @Param resume query string false "cursor of the last event received, the Last-Event-ID header takes precedence"
+// @Router /stream/sse [get]
+func (env *Env) SSE(c *gin.Context) {
+	resume := c.GetHeader("Last-Event-ID")
+	if resume == "" {
+		resume = c.Query("resume")
+	}
+	s, replay, err := env.subscribe(c, resume)
+	if err != nil {
+		restApi.SendError(c, http.StatusBadRequest, err)
+		return
+	}
+	defer env.Hub.Unsubscribe(s)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	cursor, _ := ParseCursor(resume)
+	send := func(e Event) error {
+		if e.Type != EventError {
+			cursor.Advance(e.position)
+			e.Cursor = cursor.String()
+		}
+		data, err := json.Marshal(e.Data)
+		if err != nil {
+			return err
+		}
+		// events without cursor keep the last event id of the client
+		if e.Cursor != "" {
+			if _, err := fmt.Fprintf(c.Writer, "id: %s\n", e.Cursor); err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", e.Type, data)
+		c.Writer.Flush()
+		return err
+	}
+
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-s.Events:
+			if !ok {
+				if s.Lagging() {
+					send(lagging(cursor))
+				}
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			// comments keep proxies from closing idle streams
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package streamApi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+func trade(symbol, exchange string, offset int64) Event {
+	return tradeEvent(dia.Trade{Symbol: symbol, Source: exchange}, Position{Topic: "trades", Offset: offset})
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	b := &dia.FiltersBlock{FiltersBlockData: dia.FiltersBlockData{FilterPoints: []dia.FilterPoint{
+		{Symbol: "BTC", Name: dia.FilterKing, Value: 13000, Time: time.Unix(1000, 0)},
+		{Symbol: "BTC", Exchange: dia.BinanceExchange, Name: "MEDIR120", Value: 13001},
+	}}}
+	events := filtersBlockEvents(b, Position{Topic: "filtersBlock", Offset: 7})
+	if len(events) != 3 || events[1].Type != EventQuotation || events[2].position.Index != 2 {
+		t.Fatalf("Events of filters block were incorrect, got: %+v.", events)
+	}
+
+	tables := []struct {
+		subscription Subscription
+		event        Event
+		matches      bool
+	}{
+		{ParseSubscription("", "", "", ""), trade("BTC", dia.BinanceExchange, 1), true},
+		{ParseSubscription("trade", "ETH, BTC", "", ""), trade("BTC", dia.BinanceExchange, 1), true},
+		{ParseSubscription("", "ETH", "", ""), trade("BTC", dia.BinanceExchange, 1), false},
+		{ParseSubscription("", "", dia.KrakenExchange, ""), trade("BTC", dia.BinanceExchange, 1), false},
+		{ParseSubscription("filterPoint", "", "", ""), trade("BTC", dia.BinanceExchange, 1), false},
+		{ParseSubscription("", "BTC", "", dia.FilterKing), events[0], true},
+		{ParseSubscription("", "BTC", "", dia.FilterKing), events[2], false},
+		{ParseSubscription("", "", dia.BinanceExchange, ""), events[2], true},
+		// exchanges and filters do not apply to quotations
+		{ParseSubscription("quotation", "", dia.BinanceExchange, "MEDIR120"), events[1], true},
+	}
+	for i, table := range tables {
+		if matches := table.subscription.Matches(table.event); matches != table.matches {
+			t.Errorf("Match %d was incorrect, got: %v, want: %v.", i, matches, table.matches)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	c, err := ParseCursor("trades:1:20:0,filtersBlock:0:7:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(Position{Topic: "trades", Partition: 1, Offset: 21})
+	if s := c.String(); s != "filtersBlock:0:7:3,trades:1:21:0" {
+		t.Errorf("Cursor was incorrect, got: %s.", s)
+	}
+	for _, s := range []string{"trades:1:20", "trades:x:20:0", "trades:1:20:0,"} {
+		if _, err := ParseCursor(s); err == nil {
+			t.Errorf("Cursor %s was parsed.", s)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	h := NewHub(3, 2)
+	for offset := int64(0); offset < 4; offset++ {
+		h.Publish(trade("BTC", dia.BinanceExchange, offset))
+	}
+
+	// events 1 to 3 are buffered
+	cursor, _ := ParseCursor("trades:0:1:0")
+	s, replay, err := h.Subscribe(ParseSubscription("", "BTC", "", ""), cursor)
+	if err != nil || len(replay) != 2 || replay[0].ID != "trades:0:2:0" {
+		t.Errorf("Resume was incorrect, got: %v, %v.", replay, err)
+	}
+	cursor, _ = ParseCursor("trades:0:-1:0")
+	if _, _, err := h.Subscribe(ParseSubscription("", "ETH", "", ""), cursor); err == nil {
+		t.Errorf("Resume after evicted events passed.")
+	}
+
+	h.Publish(trade("ETH", dia.BinanceExchange, 4))
+	h.Publish(trade("BTC", dia.BinanceExchange, 5))
+	if e := <-s.Events; e.ID != "trades:0:5:0" {
+		t.Errorf("Event was incorrect, got: %v.", e)
+	}
+
+	// the queue of 2 events overflows with the third event
+	for offset := int64(6); offset < 9; offset++ {
+		h.Publish(trade("BTC", dia.BinanceExchange, offset))
+	}
+	n := 0
+	for range s.Events {
+		n++
+	}
+	if n != 2 || !s.Lagging() {
+		t.Errorf("Lagging subscriber was incorrect, got: %d events, lagging %v.", n, s.Lagging())
+	}
+}
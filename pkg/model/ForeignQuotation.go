@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
 	"time"
 
 	clientInfluxdb "github.com/influxdata/influxdb1-client/v2"
@@ -44,9 +43,16 @@ func (db *DB) SaveForeignQuotationInflux(fq ForeignQuotation) error {
 func (db *DB) GetForeignQuotationInflux(symbol, source string, timestamp time.Time) (ForeignQuotation, error) {
 	retval := ForeignQuotation{}
 
-	unixtime := timestamp.UnixNano()
-	q := fmt.Sprintf("SELECT price,priceYesterday,volumeYesterdayUSD,\"itin\",\"name\" FROM %s WHERE source='%s' and \"symbol\"='%s' and time<%d order by time desc limit 1", influxDbForeignQuotationTable, source, symbol, unixtime)
-	fmt.Println("query: ", q)
+	q, err := newInfluxSelect(influxDbForeignQuotationTable, "price", "priceYesterday", "volumeYesterdayUSD", "\"itin\"", "\"name\"").
+		where("source", source).
+		where("symbol", symbol).
+		before(timestamp).
+		orderDesc().
+		limitTo(1).
+		build()
+	if err != nil {
+		return retval, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		fmt.Println("Error querying influx")
@@ -96,11 +102,17 @@ func (db *DB) GetForeignPriceYesterday(symbol, source string) (float64, error) {
 	secondsFromYesterday := now.Hour()*60*60 + now.Minute()*60 + now.Second()
 	timeFinal := int(now.Unix()) - secondsFromYesterday - 1
 	timeInit := timeFinal - 24*60*60
-	unixtimeFinal := strconv.Itoa(timeFinal) + "000000000"
-	unixtimeInit := strconv.Itoa(timeInit) + "000000000"
 
 	// Make corresponding influx query
-	q := fmt.Sprintf("SELECT price FROM %s WHERE source='%s' and symbol='%s' and time>%s and time<%s", influxDbForeignQuotationTable, source, symbol, unixtimeInit, unixtimeFinal)
+	q, err := newInfluxSelect(influxDbForeignQuotationTable, "price").
+		where("source", source).
+		where("symbol", symbol).
+		after(time.Unix(int64(timeInit), 0)).
+		before(time.Unix(int64(timeFinal), 0)).
+		build()
+	if err != nil {
+		return 0, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		fmt.Println("Error querying influx")
@@ -133,7 +145,13 @@ func (db *DB) GetForeignPriceYesterday(symbol, source string) (float64, error) {
 // along with their ITIN.
 func (db *DB) GetForeignSymbolsInflux(source string) (symbols []SymbolShort, err error) {
 
-	q := fmt.Sprintf("SELECT symbol,source FROM %s WHERE time>now()-7d and source='%s'", influxDbForeignQuotationTable, source)
+	q, err := newInfluxSelect(influxDbForeignQuotationTable, "symbol", "source").
+		since(7*24*time.Hour).
+		where("source", source).
+		build()
+	if err != nil {
+		return
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		fmt.Println("Error querying influx")
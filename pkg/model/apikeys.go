@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+)
+
+const (
+	keyAPIKeyRate  = "apikeyRate_"
+	keyAPIKeyUsage = "apikeyUsage_"
+	// apiKeyUsageTTL is the time daily usage counters of API keys are kept in redis.
+	apiKeyUsageTTL = 90 * 24 * time.Hour
+	// time format of the days of usage counters
+	timeFormatAPIKeyUsage = "2006-01-02"
+)
+
+// SetAPIKey stores the new API key @key in postgres.
+func (rdb *RelDB) SetAPIKey(key dia.APIKey) error {
+	query := fmt.Sprintf("insert into %s (key,secret_hash,name,scopes,rate_limit,created_at) values ($1,$2,$3,$4,$5,$6)", apikeyTable)
+	_, err := rdb.postgresClient.Exec(context.Background(), query, key.Key, key.SecretHash, key.Name, key.Scopes, key.RateLimit, key.CreatedAt)
+	return err
+}
+
+// GetAPIKey returns the API key @key, including revoked keys.
+func (rdb *RelDB) GetAPIKey(key string) (apiKey dia.APIKey, err error) {
+	query := fmt.Sprintf("select key,secret_hash,name,scopes,rate_limit,created_at,rotated_at,revoked_at from %s where key=$1", apikeyTable)
+	err = rdb.postgresClient.QueryRow(context.Background(), query, key).Scan(
+		&apiKey.Key,
+		&apiKey.SecretHash,
+		&apiKey.Name,
+		&apiKey.Scopes,
+		&apiKey.RateLimit,
+		&apiKey.CreatedAt,
+		&apiKey.RotatedAt,
+		&apiKey.RevokedAt,
+	)
+	return
+}
+
+// GetAPIKeys returns all API keys, including revoked keys.
+func (rdb *RelDB) GetAPIKeys() (apiKeys []dia.APIKey, err error) {
+	query := fmt.Sprintf("select key,secret_hash,name,scopes,rate_limit,created_at,rotated_at,revoked_at from %s order by created_at", apikeyTable)
+	rows, err := rdb.postgresClient.Query(context.Background(), query)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var apiKey dia.APIKey
+		err = rows.Scan(
+			&apiKey.Key,
+			&apiKey.SecretHash,
+			&apiKey.Name,
+			&apiKey.Scopes,
+			&apiKey.RateLimit,
+			&apiKey.CreatedAt,
+			&apiKey.RotatedAt,
+			&apiKey.RevokedAt,
+		)
+		if err != nil {
+			return
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys, rows.Err()
+}
+
+// RotateAPIKey replaces the secret of the active API key @key by the secret with @secretHash.
+func (rdb *RelDB) RotateAPIKey(key string, secretHash string) error {
+	query := fmt.Sprintf("update %s set secret_hash=$1,rotated_at=$2 where key=$3 and revoked_at is null", apikeyTable)
+	tag, err := rdb.postgresClient.Exec(context.Background(), query, secretHash, time.Now(), key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("no active API key %s", key)
+	}
+	return nil
+}
+
+// RevokeAPIKey revokes the API key @key.
+func (rdb *RelDB) RevokeAPIKey(key string) error {
+	query := fmt.Sprintf("update %s set revoked_at=$1 where key=$2 and revoked_at is null", apikeyTable)
+	tag, err := rdb.postgresClient.Exec(context.Background(), query, time.Now(), key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("no active API key %s", key)
+	}
+	return nil
+}
+
+// IncrementAPIKeyUsage counts a request made with @key at @t in redis. It returns the number of
+// requests made with @key in the minute of @t.
+func (rdb *RelDB) IncrementAPIKeyUsage(key string, t time.Time) (int64, error) {
+	rateKey := keyAPIKeyRate + key + "_" + strconv.FormatInt(t.Unix()/60, 10)
+	usageKey := keyAPIKeyUsage + key + "_" + t.UTC().Format(timeFormatAPIKeyUsage)
+
+	pipe := rdb.redisClient.TxPipeline()
+	rate := pipe.Incr(rateKey)
+	pipe.Expire(rateKey, time.Minute)
+	pipe.Incr(usageKey)
+	pipe.Expire(usageKey, apiKeyUsageTTL)
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	return rate.Val(), nil
+}
+
+// GetAPIKeyUsage returns the daily number of requests made with @key between @starttime and
+// @endtime.
+func (rdb *RelDB) GetAPIKeyUsage(key string, starttime time.Time, endtime time.Time) ([]dia.APIKeyUsage, error) {
+	var days []string
+	var keys []string
+	for day := starttime.UTC(); !day.After(endtime.UTC()); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(timeFormatAPIKeyUsage))
+		keys = append(keys, keyAPIKeyUsage+key+"_"+day.Format(timeFormatAPIKeyUsage))
+	}
+	if len(keys) == 0 {
+		return []dia.APIKeyUsage{}, nil
+	}
+
+	values, err := rdb.redisClient.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	usage := make([]dia.APIKeyUsage, len(days))
+	for i, day := range days {
+		usage[i] = dia.APIKeyUsage{Key: key, Day: day}
+		// days without requests have no counter
+		if value, ok := values[i].(string); ok {
+			usage[i].Requests, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers"
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+// BatchQuotation is the quotation, supply and market cap of a symbol returned in a batch.
+// Values which could not be retrieved are nil and described in Errors.
+type BatchQuotation struct {
+	Symbol    string
+	Quotation *Quotation
+	Supply    *dia.Supply
+	// MarketCap is the price times the circulating supply, 0 if either is missing.
+	MarketCap float64
+	Errors    []string `json:",omitempty"`
+}
+
+// newBatchQuotation returns the BatchQuotation of @symbol from the results of the lookups of
+// its quotation and supply.
+func newBatchQuotation(symbol string, quotation *Quotation, quotationErr error, supply *dia.Supply, supplyErr error) BatchQuotation {
+	bq := BatchQuotation{Symbol: symbol}
+	if quotationErr != nil {
+		bq.Errors = append(bq.Errors, "quotation: "+quotationErr.Error())
+	} else {
+		bq.Quotation = quotation
+	}
+	if supplyErr != nil {
+		bq.Errors = append(bq.Errors, "supply: "+supplyErr.Error())
+	} else {
+		bq.Supply = supply
+	}
+	if bq.Quotation != nil && bq.Supply != nil {
+		bq.MarketCap = bq.Quotation.Price * bq.Supply.CirculatingSupply
+	}
+	return bq
+}
+
+// historicQuotation returns the quotation of the last @price at or before @timestamp, whose lookup
+// failed with @err.
+func historicQuotation(price Price, err error, timestamp time.Time) (*Quotation, error) {
+	if err != nil {
+		return nil, err
+	}
+	if price.Price == 0 {
+		return nil, fmt.Errorf("no price at %v", timestamp)
+	}
+	return &Quotation{
+		Symbol: price.Symbol,
+		Name:   price.Name,
+		Price:  price.Price,
+		Source: dia.Diadata,
+		Time:   price.Time,
+	}, nil
+}
+
+// GetBatchQuotations returns the quotations, supplies and market caps of @symbols in their
+// order. The latest values are read from redis in one pipeline if @timestamp is zero, the
+// values at @timestamp otherwise.
+func (db *DB) GetBatchQuotations(symbols []string, timestamp time.Time) ([]BatchQuotation, error) {
+	if timestamp.IsZero() {
+		return db.getLatestBatchQuotations(symbols)
+	}
+
+	supplies, err := db.getSuppliesBefore(symbols, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]BatchQuotation, len(symbols))
+	for i, symbol := range symbols {
+		price, err := db.getPriceAtOrBefore(symbol, dia.FilterKing, "", timestamp)
+		quotation, quotationErr := historicQuotation(price, err, timestamp)
+		supply, ok := supplies[symbol]
+		var supplyErr error
+		if !ok {
+			supplyErr = fmt.Errorf("no supply before %v", timestamp)
+		}
+		result[i] = newBatchQuotation(symbol, quotation, quotationErr, supply, supplyErr)
+	}
+	return result, nil
+}
+
+func (db *DB) getLatestBatchQuotations(symbols []string) ([]BatchQuotation, error) {
+	pipe := db.redisClient.Pipeline()
+	quotationCmds := make([]*redis.StringCmd, len(symbols))
+	supplyCmds := make([]*redis.StringCmd, len(symbols))
+	for i, symbol := range symbols {
+		quotationCmds[i] = pipe.Get(getKeyQuotation(symbol))
+		supplyCmds[i] = pipe.Get(getKeySupply(symbol))
+	}
+	// missing keys are reported per symbol
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	result := make([]BatchQuotation, len(symbols))
+	for i, symbol := range symbols {
+		quotation := &Quotation{}
+		quotationErr := quotationCmds[i].Scan(quotation)
+		if quotationErr == nil {
+			quotation.Name = helpers.NameForSymbol(symbol)
+		}
+		supply := &dia.Supply{}
+		supplyErr := supplyCmds[i].Scan(supply)
+		if supplyErr == redis.Nil {
+			// supplies set before they were cached, or hardcoded by GetSupply
+			supply, supplyErr = db.GetLatestSupply(symbol)
+		}
+		result[i] = newBatchQuotation(symbol, quotation, quotationErr, supply, supplyErr)
+	}
+	return result, nil
+}
+
+// getSuppliesBefore returns the latest supplies of @symbols before @timestamp by symbol.
+func (db *DB) getSuppliesBefore(symbols []string, timestamp time.Time) (map[string]*dia.Supply, error) {
+	supplies := make(map[string]*dia.Supply)
+	if len(symbols) == 0 {
+		return supplies, nil
+	}
+	q, err := newInfluxSelect(influxDbSupplyTable, "last(supply)", "last(circulatingsupply)", "last(source)", "last(\"name\")").
+		atOrBefore(timestamp).
+		whereAny("symbol", symbols).
+		groupByTags("symbol").
+		build()
+	if err != nil {
+		return nil, err
+	}
+	res, err := queryInfluxDB(db.influxClient, q)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return supplies, nil
+	}
+	for _, series := range res[0].Series {
+		if len(series.Values) == 0 || len(series.Values[0]) < 5 {
+			continue
+		}
+		row := series.Values[0]
+		supply, err := parseSupplyRow(series.Tags["symbol"], row)
+		if err != nil {
+			log.Errorln("getSuppliesBefore", err)
+			continue
+		}
+		supplies[supply.Symbol] = supply
+	}
+	return supplies, nil
+}
+
+// parseSupplyRow parses a row of time, supply, circulating supply, source and name.
+func parseSupplyRow(symbol string, row []interface{}) (*dia.Supply, error) {
+	supply := &dia.Supply{Symbol: symbol}
+	var err error
+	timeStr, ok := row[0].(string)
+	if !ok {
+		return nil, errors.New("invalid time of supply")
+	}
+	if supply.Time, err = time.Parse(time.RFC3339, timeStr); err != nil {
+		return nil, err
+	}
+	values := []*float64{&supply.Supply, &supply.CirculatingSupply}
+	for i, value := range values {
+		number, ok := row[i+1].(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid supply of %s", symbol)
+		}
+		if *value, err = number.Float64(); err != nil {
+			return nil, err
+		}
+	}
+	supply.Source, _ = row[3].(string)
+	supply.Name, _ = row[4].(string)
+	return supply, nil
+}
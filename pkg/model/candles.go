@@ -0,0 +1,179 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	clientInfluxdb "github.com/influxdata/influxdb1-client/v2"
+)
+
+// influxDbCandlesTable is the prefix of the candle rollups, which are written by the
+// continuous queries of scripts/influxdbCandles.sh to one measurement per interval.
+const influxDbCandlesTable = "a_year.candles_"
+
+// CandleIntervals are the supported intervals of candles.
+var CandleIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// Candle holds the open, high, low and close price in USD and the traded volume of a symbol
+// in the interval starting at Time. Exchange is empty for candles of all exchanges.
+type Candle struct {
+	Symbol   string
+	Exchange string
+	Time     time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64
+}
+
+// candleStart returns the start of the candle of @interval containing @t. Candles are aligned
+// to the unix epoch like the time buckets of influx.
+func candleStart(t time.Time, interval time.Duration) time.Time {
+	return time.Unix(0, t.UnixNano()-t.UnixNano()%int64(interval)).UTC()
+}
+
+// candlesFromTrades returns the candles of @interval of @trades, which are sorted by time.
+// Trades without estimated USD price are ignored.
+func candlesFromTrades(trades []dia.Trade, symbol string, exchange string, interval time.Duration) []Candle {
+	candles := []Candle{}
+	for _, t := range trades {
+		if t.EstimatedUSDPrice <= 0 {
+			continue
+		}
+		start := candleStart(t.Time, interval)
+		if len(candles) == 0 || !candles[len(candles)-1].Time.Equal(start) {
+			candles = append(candles, Candle{
+				Symbol:   symbol,
+				Exchange: exchange,
+				Time:     start,
+				Open:     t.EstimatedUSDPrice,
+				High:     t.EstimatedUSDPrice,
+				Low:      t.EstimatedUSDPrice,
+			})
+		}
+		c := &candles[len(candles)-1]
+		c.High = math.Max(c.High, t.EstimatedUSDPrice)
+		c.Low = math.Min(c.Low, t.EstimatedUSDPrice)
+		c.Close = t.EstimatedUSDPrice
+		c.Volume += math.Abs(t.Volume)
+	}
+	return candles
+}
+
+// GetCandles returns the candles of @interval of @symbol on @exchange between @starttime and
+// @endtime in ascending order. The candles of all exchanges are returned if @exchange is empty.
+// Candles are read from the rollups, those not rolled up yet are computed from the trades.
+func (db *DB) GetCandles(symbol string, exchange string, interval string, starttime time.Time, endtime time.Time) ([]Candle, error) {
+	duration, ok := CandleIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unknown candle interval %s", interval)
+	}
+	starttime = candleStart(starttime, duration)
+
+	// the rollup of all exchanges is the series without exchange tag
+	q, err := newInfluxSelect(influxDbCandlesTable+interval, "open", "high", "low", "close", "buyVolume", "sellVolume").
+		where("symbol", symbol).
+		where("exchange", exchange).
+		atOrAfter(starttime).
+		before(endtime).
+		orderAsc().
+		build()
+	if err != nil {
+		return nil, err
+	}
+	res, err := queryInfluxDB(db.influxClient, q)
+	if err != nil {
+		return nil, err
+	}
+	candles, err := parseCandles(res, symbol, exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	tailStart := starttime
+	if len(candles) > 0 {
+		tailStart = candles[len(candles)-1].Time.Add(duration)
+	}
+	if !tailStart.Before(endtime) {
+		return candles, nil
+	}
+	trades := newInfluxSelect(influxDbTradesTable, "estimatedUSDPrice AS price", "abs(volume) AS volume").
+		where("symbol", symbol)
+	if exchange != "" {
+		trades.where("exchange", exchange)
+	}
+	trades.whereRaw("estimatedUSDPrice>0").atOrAfter(tailStart).before(endtime)
+	q, err = newInfluxSubquerySelect(trades, "first(price)", "max(price)", "min(price)", "last(price)", "sum(volume)").
+		atOrAfter(tailStart).
+		before(endtime).
+		groupByTime(duration).
+		build()
+	if err != nil {
+		return nil, err
+	}
+	res, err = queryInfluxDB(db.influxClient, q)
+	if err != nil {
+		return nil, err
+	}
+	tail, err := parseCandles(res, symbol, exchange)
+	if err != nil {
+		return nil, err
+	}
+	return append(candles, tail...), nil
+}
+
+// parseCandles parses rows of time, open, high, low and close followed by volume columns,
+// whose absolute values are summed. Rows without open price are skipped.
+func parseCandles(res []clientInfluxdb.Result, symbol string, exchange string) ([]Candle, error) {
+	candles := []Candle{}
+	if len(res) == 0 || len(res[0].Series) == 0 {
+		return candles, nil
+	}
+	for _, row := range res[0].Series[0].Values {
+		if len(row) < 5 || row[1] == nil {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, row[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		c := Candle{Symbol: symbol, Exchange: exchange, Time: t}
+		values := []*float64{&c.Open, &c.High, &c.Low, &c.Close}
+		for i, value := range values {
+			number, ok := row[i+1].(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("invalid price %v", row[i+1])
+			}
+			if *value, err = number.Float64(); err != nil {
+				return nil, err
+			}
+		}
+		for _, v := range row[5:] {
+			// intervals without sells have no sell volume
+			number, ok := v.(json.Number)
+			if !ok {
+				continue
+			}
+			volume, err := number.Float64()
+			if err != nil {
+				return nil, err
+			}
+			c.Volume += math.Abs(volume)
+		}
+		candles = append(candles, c)
+	}
+	return candles, nil
+}
@@ -2,7 +2,6 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/diadata-org/diadata/pkg/dia/helpers"
@@ -15,7 +14,16 @@ func (db *DB) GetChartPoints7Days(symbol string) (r []Point, err error) {
 	table := influxDbFiltersTable
 	filter := "MA120"
 
-	q := fmt.Sprintf("SELECT time, value FROM %s WHERE time > now() - 7d and filter='%s' and exchange='' and symbol='%s' ORDER BY DESC", table, filter, symbol)
+	q, err := newInfluxSelect(table, "value").
+		since(7*24*time.Hour).
+		where("filter", filter).
+		where("exchange", "").
+		where("symbol", symbol).
+		orderDesc().
+		build()
+	if err != nil {
+		return
+	}
 
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
@@ -48,7 +56,6 @@ func (db *DB) GetChartPoints7Days(symbol string) (r []Point, err error) {
 
 // GetFilterPoints returns filter points from either a specific exchange or all exchanges
 func (db *DB) GetFilterPoints(filter string, exchange string, symbol string, scale string, starttime time.Time, endtime time.Time) (*Points, error) {
-	exchangeQuery := "and exchange='" + exchange + "' "
 	table := ""
 	//	5m 30m 1h 4h 1d 1w
 	if scale != "" {
@@ -62,8 +69,17 @@ func (db *DB) GetFilterPoints(filter string, exchange string, symbol string, sca
 		table = influxDbFiltersTable
 	}
 
-	q := fmt.Sprintf("SELECT time,exchange, filter, symbol, value FROM %s WHERE filter='%s' %sand symbol='%s' and time>%d and time<%d ORDER BY DESC",
-		table, filter, exchangeQuery, symbol, starttime.UnixNano(), endtime.UnixNano())
+	q, err := newInfluxSelect(table, "exchange", "filter", "symbol", "value").
+		where("filter", filter).
+		where("exchange", exchange).
+		where("symbol", symbol).
+		after(starttime).
+		before(endtime).
+		orderDesc().
+		build()
+	if err != nil {
+		return nil, err
+	}
 
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
@@ -78,14 +94,41 @@ func (db *DB) GetFilterPoints(filter string, exchange string, symbol string, sca
 }
 
 func (db *DB) GetLastPriceBefore(symbol string, filter string, exchange string, timestamp time.Time) (Price, error) {
-	exchangeQuery := "exchange='" + exchange + "'"
 	table := influxDbFiltersTable
-	// q := fmt.Sprintf("SELECT LAST(value) FROM %s WHERE filter='%s' AND symbol='%s' AND %s AND time < %d",
-	// 	table, filter, symbol, exchangeQuery, timestamp.UnixNano())
 
-	q := fmt.Sprintf("SELECT value FROM %s WHERE filter='%s' AND symbol='%s' AND %s AND time > %d ORDER BY ASC LIMIT 1",
-		table, filter, symbol, exchangeQuery, timestamp.UnixNano())
+	q, err := newInfluxSelect(table, "value").
+		where("filter", filter).
+		where("symbol", symbol).
+		where("exchange", exchange).
+		after(timestamp).
+		orderAsc().
+		limitTo(1).
+		build()
+	if err != nil {
+		return Price{}, err
+	}
+	return db.queryPrice(symbol, q)
+}
+
+// getPriceAtOrBefore returns the last value of @filter for @symbol at or before @timestamp.
+// The price is 0 if there is none.
+func (db *DB) getPriceAtOrBefore(symbol string, filter string, exchange string, timestamp time.Time) (Price, error) {
+	q, err := newInfluxSelect(influxDbFiltersTable, "value").
+		where("filter", filter).
+		where("symbol", symbol).
+		where("exchange", exchange).
+		atOrBefore(timestamp).
+		orderDesc().
+		limitTo(1).
+		build()
+	if err != nil {
+		return Price{}, err
+	}
+	return db.queryPrice(symbol, q)
+}
 
+// queryPrice returns the price of @symbol selected by the filter value query @q.
+func (db *DB) queryPrice(symbol string, q string) (Price, error) {
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		log.Errorln("GetLastFilterPointBefore", err)
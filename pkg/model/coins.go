@@ -22,67 +22,74 @@ func (db *DB) GetCoins() (*Coins, error) {
 			return &coins, err
 		}
 
-		coins.Coins = []Coin{}
-		coins.CompleteCoinList = []CoinSymbolAndName{}
-		coins.Change, _ = db.GetCurrencyChange()
+		coins = buildCoins(db, symbols)
+		err = db.redisClient.Set(key, &coins, timeOutRedisOneBlock).Err()
+		if err != nil {
+			log.Error("Error: on GetCoin setting cache\n", err)
+		}
+	}
+	return &coins, nil
+}
+
+// buildCoins computes the coin list for @symbols from the quotations, volumes and
+// supplies found in @ds.
+func buildCoins(ds Datastore, symbols []string) (coins Coins) {
+	coins.Coins = []Coin{}
+	coins.CompleteCoinList = []CoinSymbolAndName{}
+	coins.Change, _ = ds.GetCurrencyChange()
 
-		for _, symbol := range symbols {
+	for _, symbol := range symbols {
 
-			var c1 Coin
-			log.Debug("Adding symbol", symbol)
-			price, _ := db.GetQuotation(symbol)
-			itin, itinErr := db.GetItinBySymbol(symbol)
-			if price != nil {
-				volume, _ := db.GetVolume(symbol)
-				if volume != nil {
-					if *volume < 1.0 {
-						log.Warning("GetCoins: skipping ", symbol, "because <1.0 volume")
-						continue
-					}
-					c1.Price = price.Price
-					c1.Name = price.Name
-					c1.Symbol = price.Symbol
-					if price.PriceYesterday != nil {
-						c1.PriceYesterday = price.PriceYesterday
-					}
-					c1.Time = price.Time
-					c1.VolumeYesterdayUSD = volume
-					supply, err := db.GetLatestSupply(symbol)
-					if err != nil {
-						log.Error(err)
-						supply = nil
-					}
-					if supply != nil {
-						c1.CirculatingSupply = &supply.CirculatingSupply
-					}
-					if itinErr == nil {
-						c1.ITIN = itin.Itin
-					} else {
-						c1.ITIN = "undefined"
-					}
-					coins.Coins = append(coins.Coins, c1)
+		var c1 Coin
+		log.Debug("Adding symbol", symbol)
+		price, _ := ds.GetQuotation(symbol)
+		itin, itinErr := ds.GetItinBySymbol(symbol)
+		if price != nil {
+			volume, _ := ds.GetVolume(symbol)
+			if volume != nil {
+				if *volume < 1.0 {
+					log.Warning("GetCoins: skipping ", symbol, "because <1.0 volume")
+					continue
 				}
+				c1.Price = price.Price
+				c1.Name = price.Name
+				c1.Symbol = price.Symbol
+				if price.PriceYesterday != nil {
+					c1.PriceYesterday = price.PriceYesterday
+				}
+				c1.Time = price.Time
+				c1.VolumeYesterdayUSD = volume
+				supply, err := ds.GetLatestSupply(symbol)
+				if err != nil {
+					log.Error(err)
+					supply = nil
+				}
+				if supply != nil {
+					c1.CirculatingSupply = &supply.CirculatingSupply
+				}
+				if itinErr == nil {
+					c1.ITIN = itin.Itin
+				} else {
+					c1.ITIN = "undefined"
+				}
+				coins.Coins = append(coins.Coins, c1)
 			}
 		}
-		sort.Slice(coins.Coins, func(i, j int) bool {
-			if coins.Coins[i].CirculatingSupply == nil {
-				return false
-			}
-			if coins.Coins[j].CirculatingSupply == nil {
-				return true
-			}
-			return (*coins.Coins[i].CirculatingSupply * coins.Coins[i].Price) > (*coins.Coins[j].CirculatingSupply * coins.Coins[j].Price)
-		})
-		for _, coin := range coins.Coins {
-			coins.CompleteCoinList = append(coins.CompleteCoinList, CoinSymbolAndName{coin.Symbol, coin.Name})
-		}
-		if len(coins.Coins) > coinsPerPage {
-			coins.Coins = coins.Coins[:coinsPerPage]
+	}
+	sort.Slice(coins.Coins, func(i, j int) bool {
+		if coins.Coins[i].CirculatingSupply == nil {
+			return false
 		}
-		err = db.redisClient.Set(key, &coins, timeOutRedisOneBlock).Err()
-		if err != nil {
-			log.Error("Error: on GetCoin setting cache\n", err)
+		if coins.Coins[j].CirculatingSupply == nil {
+			return true
 		}
+		return (*coins.Coins[i].CirculatingSupply * coins.Coins[i].Price) > (*coins.Coins[j].CirculatingSupply * coins.Coins[j].Price)
+	})
+	for _, coin := range coins.Coins {
+		coins.CompleteCoinList = append(coins.CompleteCoinList, CoinSymbolAndName{coin.Symbol, coin.Name})
 	}
-	return &coins, nil
+	if len(coins.Coins) > coinsPerPage {
+		coins.Coins = coins.Coins[:coinsPerPage]
+	}
+	return
 }
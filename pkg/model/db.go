@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/diadata-org/diadata/pkg/dia"
@@ -22,6 +23,7 @@ type Datastore interface {
 	SetPriceEUR(symbol string, price float64) error
 	GetPriceUSD(symbol string) (float64, error)
 	GetQuotation(symbol string) (*Quotation, error)
+	GetBatchQuotations(symbols []string, timestamp time.Time) ([]BatchQuotation, error)
 	SetQuotation(quotation *Quotation) error
 	SetQuotationEUR(quotation *Quotation) error
 	GetLatestSupply(string) (*dia.Supply, error)
@@ -37,6 +39,11 @@ type Datastore interface {
 	SetLastTradeTimeForExchange(symbol string, exchange string, t time.Time) error
 	SaveTradeInflux(t *dia.Trade) error
 	GetTradeInflux(string, string, time.Time) (*dia.Trade, error)
+	SaveQuarantinedTradeInflux(t *dia.Trade, reason string) error
+	GetQuarantinedTradesInflux(symbol string, starttime time.Time, endtime time.Time) ([]QuarantinedTrade, error)
+	GetCandles(symbol string, exchange string, interval string, starttime time.Time, endtime time.Time) ([]Candle, error)
+	SaveOrderBookMetricsInflux(metrics dia.OrderBookMetrics) error
+	GetOrderBookMetricsInflux(symbol string, exchange string, starttime time.Time, endtime time.Time) ([]dia.OrderBookMetrics, error)
 	SaveFilterInflux(filter string, symbol string, exchange string, value float64, t time.Time) error
 	GetLastTrades(symbol string, exchange string, maxTrades int) ([]dia.Trade, error)
 	GetLastTradesAllExchanges(string, int) ([]dia.Trade, error)
@@ -49,6 +56,8 @@ type Datastore interface {
 	GetAvailablePairsForExchange(exchange string) ([]dia.Pair, error)
 	SetCurrencyChange(cc *Change) error
 	GetCurrencyChange() (*Change, error)
+	SetFiltersState(state []byte) error
+	GetFiltersState() ([]byte, error)
 	GetAllSymbols() []string
 	GetSymbolsByExchange(string) []string
 	GetCoins() (*Coins, error)
@@ -195,6 +204,28 @@ func NewDataStoreWithoutRedis() (*DB, error) {
 	return NewDataStoreWithOptions(false, true)
 }
 
+var (
+	sharedMemoryDB     *MemoryDB
+	sharedMemoryDBOnce sync.Once
+)
+
+// NewDataStoreFromConfig returns the datastore backend selected by the environment variable
+// DATASTORE_BACKEND. If it is set to "memory", all callers within the process share a single
+// in-memory datastore. Otherwise the redis and influx backed datastore is returned.
+func NewDataStoreFromConfig() (Datastore, error) {
+	switch os.Getenv("DATASTORE_BACKEND") {
+	case "memory":
+		sharedMemoryDBOnce.Do(func() {
+			sharedMemoryDB = NewMemoryDataStore()
+		})
+		return sharedMemoryDB, nil
+	case "", "redis":
+		return NewDataStore()
+	default:
+		return nil, fmt.Errorf("unknown datastore backend %s", os.Getenv("DATASTORE_BACKEND"))
+	}
+}
+
 func NewDataStoreWithOptions(withRedis bool, withInflux bool) (*DB, error) {
 	var ci clientInfluxdb.Client
 	var bp clientInfluxdb.BatchPoints
@@ -356,7 +387,15 @@ select sum(value) from filters where  symbol='BTC' and filter='VOL120' and time
 
 // Sum24HoursInflux returns the 24h  volume of @symbol on @exchange using the filter @filter.
 func (db *DB) Sum24HoursInflux(symbol string, exchange string, filter string) (*float64, error) {
-	q := fmt.Sprintf("SELECT SUM(value) FROM %s WHERE symbol='%s' and exchange='%s' and filter='%s' and time > now() - 1d", influxDbFiltersTable, symbol, exchange, filter)
+	q, err := newInfluxSelect(influxDbFiltersTable, "SUM(value)").
+		where("symbol", symbol).
+		where("exchange", exchange).
+		where("filter", filter).
+		since(24 * time.Hour).
+		build()
+	if err != nil {
+		return nil, err
+	}
 	var errorString string
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
@@ -406,12 +445,18 @@ func (db *DB) Sum24HoursExchange(exchange string) (float64, error) {
 // It uses the VOL filter from the filter services.
 func (db *DB) GetVolumeInflux(symbol string, starttime time.Time, endtime time.Time) (float64, error) {
 	var retval float64
-	var q string
 	filter := "VOL120"
+	query := newInfluxSelect(influxDbFiltersTable, "SUM(value)").
+		where("symbol", symbol).
+		where("filter", filter)
 	if starttime.IsZero() || endtime.IsZero() {
-		q = fmt.Sprintf("SELECT SUM(value) FROM %s WHERE symbol='%s' and filter='%s' and time > now() - 1d", influxDbFiltersTable, symbol, filter)
+		query.since(24 * time.Hour)
 	} else {
-		q = fmt.Sprintf("SELECT SUM(value) FROM %s WHERE symbol='%s' and filter='%s' and time > %d and time < %d", influxDbFiltersTable, symbol, filter, starttime.UnixNano(), endtime.UnixNano())
+		query.after(starttime).before(endtime)
+	}
+	q, err := query.build()
+	if err != nil {
+		return retval, err
 	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
@@ -460,11 +505,13 @@ func (db *DB) SaveTradeInflux(t *dia.Trade) error {
 
 func (db *DB) GetTradeInflux(symbol string, exchange string, timestamp time.Time) (*dia.Trade, error) {
 	retval := dia.Trade{}
-	var q string
+	query := newInfluxSelect(influxDbTradesTable, "*").where("symbol", symbol)
 	if exchange != "" {
-		q = fmt.Sprintf("SELECT * FROM %s WHERE symbol='%s' and echange='%s' and time < %d order by desc limit 1", influxDbTradesTable, symbol, exchange, timestamp.UnixNano())
-	} else {
-		q = fmt.Sprintf("SELECT * FROM %s WHERE symbol='%s' and time < %d order by desc limit 1", influxDbTradesTable, symbol, timestamp.UnixNano())
+		query.where("exchange", exchange)
+	}
+	q, err := query.before(timestamp).orderDesc().limitTo(1).build()
+	if err != nil {
+		return &retval, err
 	}
 
 	/// TODO
@@ -541,12 +588,13 @@ func (db *DB) SaveETHCVIInflux(cviValue float64, observationTime time.Time) erro
 
 func (db *DB) GetCVIInflux(starttime time.Time, endtime time.Time, symbol string) ([]dia.CviDataPoint, error) {
 	retval := []dia.CviDataPoint{}
-	var q string
+	table := influxDbCVITable
 	if symbol == "ETH" {
-		q = fmt.Sprintf("SELECT * FROM %s WHERE time > %d and time < %d", influxDbETHCVITable, starttime.UnixNano(), endtime.UnixNano())
-
-	} else {
-		q = fmt.Sprintf("SELECT * FROM %s WHERE time > %d and time < %d", influxDbCVITable, starttime.UnixNano(), endtime.UnixNano())
+		table = influxDbETHCVITable
+	}
+	q, err := newInfluxSelect(table, "*").after(starttime).before(endtime).build()
+	if err != nil {
+		return retval, err
 	}
 
 	res, err := queryInfluxDB(db.influxClient, q)
@@ -597,7 +645,12 @@ func (db *DB) SaveOptionOrderbookDatumInflux(t dia.OptionOrderbookDatum) error {
 
 func (db *DB) GetOptionOrderbookDataInflux(t dia.OptionMeta) (dia.OptionOrderbookDatum, error) {
 	retval := dia.OptionOrderbookDatum{}
-	q := fmt.Sprintf("SELECT LAST(askPrice), bidPrice, askSize, bidSize, observationTime FROM %s WHERE instrumentName ='%s'", influxDbOptionsTable, t.InstrumentName)
+	q, err := newInfluxSelect(influxDbOptionsTable, "LAST(askPrice)", "bidPrice", "askSize", "bidSize", "observationTime").
+		where("instrumentName", t.InstrumentName).
+		build()
+	if err != nil {
+		return retval, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 
 	if err != nil {
@@ -670,8 +723,10 @@ func (db *DB) SetFarmingPool(pool *FarmingPool) error {
 func (db *DB) GetFarmingPools() ([]FarmingPoolType, error) {
 	var retval []FarmingPoolType
 	// First get all protocols
-	qProtocol := fmt.Sprintf("SHOW TAG VALUES FROM %s with key=%s", influxDbPoolTable, "protocol")
-	fmt.Println("protocol query: ", qProtocol)
+	qProtocol, err := newInfluxTagValues(influxDbPoolTable, "protocol").build()
+	if err != nil {
+		return retval, err
+	}
 	resProtocols, err := queryInfluxDB(db.influxClient, qProtocol)
 	if err != nil {
 		return retval, err
@@ -681,7 +736,10 @@ func (db *DB) GetFarmingPools() ([]FarmingPoolType, error) {
 		for i := 0; i < len(resProtocols[0].Series[0].Values); i++ {
 			protocolName := resProtocols[0].Series[0].Values[i][1].(string)
 			// For each protocol, get available pools by ID
-			qPoolIDs := fmt.Sprintf("SHOW TAG VALUES FROM %s with key=%s where protocol='%s'", influxDbPoolTable, "poolID", protocolName)
+			qPoolIDs, err := newInfluxTagValues(influxDbPoolTable, "poolID").where("protocol", protocolName).build()
+			if err != nil {
+				return retval, err
+			}
 			resPoolIDs, err := queryInfluxDB(db.influxClient, qPoolIDs)
 			if err != nil {
 				return retval, err
@@ -692,7 +750,13 @@ func (db *DB) GetFarmingPools() ([]FarmingPoolType, error) {
 					poolType.ProtocolName = protocolName
 					poolType.PoolID = resPoolIDs[0].Series[0].Values[k][1].(string)
 					// Get input assets of pool
-					qAssets := fmt.Sprintf("SHOW TAG VALUES FROM %s with key=%s where protocol='%s' and poolID='%s'", influxDbPoolTable, "inputAssets", protocolName, poolType.PoolID)
+					qAssets, err := newInfluxTagValues(influxDbPoolTable, "inputAssets").
+						where("protocol", protocolName).
+						where("poolID", poolType.PoolID).
+						build()
+					if err != nil {
+						return retval, err
+					}
 					resAssets, err := queryInfluxDB(db.influxClient, qAssets)
 					if err != nil {
 						return retval, err
@@ -719,8 +783,16 @@ func (db *DB) GetFarmingPools() ([]FarmingPoolType, error) {
 // time, balance, blocknumber, inputAssets, outputAssets, poolID, protocol, rate
 func (db *DB) GetFarmingPoolData(starttime, endtime time.Time, protocol, poolID string) ([]FarmingPool, error) {
 	retval := []FarmingPool{}
-	influxQuery := "SELECT balance,blockNumber,\"inputAssets\",\"outputAssets\",\"poolID\",\"protocol\",rate FROM %s WHERE time > %d and time <= %d and protocol = '%s' and poolID='%s' order by desc"
-	q := fmt.Sprintf(influxQuery, influxDbPoolTable, starttime.UnixNano(), endtime.UnixNano(), protocol, poolID)
+	q, err := newInfluxSelect(influxDbPoolTable, "balance", "blockNumber", "\"inputAssets\"", "\"outputAssets\"", "\"poolID\"", "\"protocol\"", "rate").
+		after(starttime).
+		atOrBefore(endtime).
+		where("protocol", protocol).
+		where("poolID", poolID).
+		orderDesc().
+		build()
+	if err != nil {
+		return retval, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		return retval, err
@@ -794,11 +866,16 @@ func (db *DB) SetDefiRateInflux(rate *dia.DefiRate) error {
 
 func (db *DB) GetDefiRateInflux(starttime time.Time, endtime time.Time, asset string, protocol string) ([]dia.DefiRate, error) {
 	retval := []dia.DefiRate{}
-	influxQuery := "SELECT \"asset\",borrowRate,lendingRate,\"protocol\" FROM %s WHERE time > %d and time < %d and asset = '%s' and protocol = '%s'"
-	q := fmt.Sprintf(influxQuery, influxDbDefiRateTable, starttime.UnixNano(), endtime.UnixNano(), asset, protocol)
-	fmt.Println("influx query: ", q)
+	q, err := newInfluxSelect(influxDbDefiRateTable, "\"asset\"", "borrowRate", "lendingRate", "\"protocol\"").
+		after(starttime).
+		before(endtime).
+		where("asset", asset).
+		where("protocol", protocol).
+		build()
+	if err != nil {
+		return retval, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
-	fmt.Println("res, err: ", res, err)
 	if err != nil {
 		return retval, err
 	}
@@ -854,8 +931,14 @@ func (db *DB) SetDefiStateInflux(state *dia.DefiProtocolState) error {
 }
 
 func (db *DB) GetDefiStateInflux(starttime time.Time, endtime time.Time, protocol string) (retval []dia.DefiProtocolState, err error) {
-	influxQuery := "SELECT totalETH,totalUSD FROM %s WHERE time > %d and time < %d and protocol = '%s'"
-	q := fmt.Sprintf(influxQuery, influxDbDefiStateTable, starttime.UnixNano(), endtime.UnixNano(), protocol)
+	q, err := newInfluxSelect(influxDbDefiStateTable, "totalETH", "totalUSD").
+		after(starttime).
+		before(endtime).
+		where("protocol", protocol).
+		build()
+	if err != nil {
+		return retval, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		return retval, err
@@ -919,11 +1002,15 @@ func (db *DB) SaveSupplyInflux(supply *dia.Supply) error {
 
 func (db *DB) GetSupplyInflux(symbol string, starttime time.Time, endtime time.Time) ([]dia.Supply, error) {
 	retval := []dia.Supply{}
-	var q string
+	query := newInfluxSelect(influxDbSupplyTable, "supply", "circulatingsupply", "source", "\"name\"").where("symbol", symbol)
 	if starttime.IsZero() || endtime.IsZero() {
-		q = fmt.Sprintf("SELECT supply,circulatingsupply,source,\"name\" FROM %s WHERE \"symbol\" = '%s' ORDER BY time DESC LIMIT 1", influxDbSupplyTable, symbol)
+		query.orderDesc().limitTo(1)
 	} else {
-		q = fmt.Sprintf("SELECT supply,circulatingsupply,source,\"name\" FROM %s WHERE time > %d and time < %d and \"symbol\" = '%s'", influxDbSupplyTable, starttime.UnixNano(), endtime.UnixNano(), symbol)
+		query.after(starttime).before(endtime)
+	}
+	q, err := query.build()
+	if err != nil {
+		return retval, err
 	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
@@ -963,6 +1050,12 @@ func (db *DB) GetSupplyInflux(symbol string, starttime time.Time, endtime time.T
 }
 
 func (db *DB) SaveFilterInflux(filter string, symbol string, exchange string, value float64, t time.Time) error {
+	return db.SaveFilterInfluxTable(influxDbFiltersTable, filter, symbol, exchange, value, t)
+}
+
+// SaveFilterInfluxTable writes a filter value into the measurement @table, e.g. one returned
+// by FiltersReplayTable.
+func (db *DB) SaveFilterInfluxTable(table string, filter string, symbol string, exchange string, value float64, t time.Time) error {
 	// Create a point and add to batch
 	tags := map[string]string{"filter": filter, "symbol": symbol, "exchange": exchange}
 	fields := map[string]interface{}{
@@ -970,7 +1063,7 @@ func (db *DB) SaveFilterInflux(filter string, symbol string, exchange string, va
 		"ignore":       false,
 		"allExchanges": exchange == "",
 	}
-	pt, err := clientInfluxdb.NewPoint(influxDbFiltersTable, tags, fields, t)
+	pt, err := clientInfluxdb.NewPoint(table, tags, fields, t)
 	if err != nil {
 		log.Errorln("newPoint:", err)
 	} else {
@@ -1,7 +1,12 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	log "github.com/sirupsen/logrus"
 )
 
 func (db *DB) SetFilter(filter string, symbol string, exchange string, volume float64, t time.Time) error {
@@ -9,3 +14,62 @@ func (db *DB) SetFilter(filter string, symbol string, exchange string, volume fl
 	err := db.setZSETValue(getKeyFilterZSET(getKey(filter, symbol, exchange)), volume, t.Unix(), BiggestWindow)
 	return err
 }
+
+// FiltersTable returns the name of the measurement of live filter values.
+func FiltersTable() string {
+	return influxDbFiltersTable
+}
+
+// FiltersReplayTable returns the name of the measurement of filter values recomputed
+// by replay @version, so that replays do not overwrite the live filter values.
+func FiltersReplayTable(version int) string {
+	return fmt.Sprintf("%sReplayV%d", influxDbFiltersTable, version)
+}
+
+// GetFilterPointsTable returns all filter values in the measurement @table with
+// @starttime <= time < @endtime in ascending order.
+func (db *DB) GetFilterPointsTable(table string, starttime time.Time, endtime time.Time) ([]dia.FilterPoint, error) {
+	r := []dia.FilterPoint{}
+	q, err := newInfluxSelect(table, "exchange", "filter", "symbol", "value").
+		atOrAfter(starttime).
+		before(endtime).
+		build()
+	if err != nil {
+		return r, err
+	}
+	res, err := queryInfluxDB(db.influxClient, q)
+	if err != nil {
+		log.Errorln("GetFilterPointsTable", err)
+		return r, err
+	}
+	if len(res) == 0 || len(res[0].Series) == 0 {
+		return r, nil
+	}
+	for _, row := range res[0].Series[0].Values {
+		if len(row) < 5 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, row[0].(string))
+		if err != nil {
+			log.Errorln("GetFilterPointsTable: parsing time", err)
+			continue
+		}
+		value, err := row[4].(json.Number).Float64()
+		if err != nil {
+			log.Errorln("GetFilterPointsTable: parsing value", err)
+			continue
+		}
+		// Influx drops empty tags, so filters over all exchanges have no exchange
+		exchange, _ := row[1].(string)
+		name, _ := row[2].(string)
+		symbol, _ := row[3].(string)
+		r = append(r, dia.FilterPoint{
+			Symbol:   symbol,
+			Exchange: exchange,
+			Value:    value,
+			Name:     name,
+			Time:     t,
+		})
+	}
+	return r, nil
+}
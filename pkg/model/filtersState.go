@@ -0,0 +1,26 @@
+package models
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+const keyFiltersState = "dia_filtersState"
+
+// SetFiltersState stores the serialized state of the filtersBlockService. The key does not
+// expire, so that the state survives restarts of arbitrary length.
+func (db *DB) SetFiltersState(state []byte) error {
+	err := db.redisClient.Set(keyFiltersState, state, 0).Err()
+	if err != nil {
+		log.Errorln("Error: on SetFiltersState", err)
+	}
+	return err
+}
+
+// GetFiltersState returns the state stored by SetFiltersState, or redis.Nil if there is none.
+func (db *DB) GetFiltersState() ([]byte, error) {
+	state, err := db.redisClient.Get(keyFiltersState).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -2,13 +2,15 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
 	"time"
 
 	clientInfluxdb "github.com/influxdata/influxdb1-client/v2"
 	log "github.com/sirupsen/logrus"
 )
 
+// githubCommitFields are the fields of commits in the order they are parsed.
+var githubCommitFields = []string{"authorname", "authormail", "hash", "message", "numAdditions", "numDeletions", "numChangedFiles"}
+
 type GithubCommit struct {
 	User            string
 	Repository      string
@@ -60,7 +62,16 @@ func (db *DB) SetCommit(commit *GithubCommit) error {
 // GetCommitByDate returns the latest commit from @repository of github user @user before @date.
 func (db *DB) GetCommitByDate(user, repository string, date time.Time) (GithubCommit, error) {
 	var commit GithubCommit
-	q := fmt.Sprintf("select authorname,authormail,hash,message,numAdditions,numDeletions,numChangedFiles from %s where \"user\"='%s' and \"repository\"='%s' and time<%d order by desc limit 1", influxDbGithubCommitTable, user, repository, date.UnixNano())
+	q, err := newInfluxSelect(influxDbGithubCommitTable, githubCommitFields...).
+		where("user", user).
+		where("repository", repository).
+		before(date).
+		orderDesc().
+		limitTo(1).
+		build()
+	if err != nil {
+		return commit, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		return commit, err
@@ -104,7 +115,14 @@ func (db *DB) GetCommitByDate(user, repository string, date time.Time) (GithubCo
 // GetCommitByHash returns the commit from @repository of github user @user with hash @hash.
 func (db *DB) GetCommitByHash(user, repository, hash string) (GithubCommit, error) {
 	var commit GithubCommit
-	q := fmt.Sprintf("select authorname,authormail,hash,message,numAdditions,numDeletions,numChangedFiles from %s where \"user\"='%s' and \"repository\"='%s' and \"hash\"='%s'", influxDbGithubCommitTable, user, repository, hash)
+	q, err := newInfluxSelect(influxDbGithubCommitTable, githubCommitFields...).
+		where("user", user).
+		where("repository", repository).
+		where("hash", hash).
+		build()
+	if err != nil {
+		return commit, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		return commit, err
@@ -2,7 +2,6 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
 	"time"
 
@@ -68,7 +67,16 @@ func (e *CryptoIndex) UnmarshalBinary(data []byte) error {
 
 func (db *DB) GetCryptoIndex(starttime time.Time, endtime time.Time, name string) ([]CryptoIndex, error) {
 	var retval []CryptoIndex
-	q := fmt.Sprintf("SELECT constituents,\"name\",price,value,divisor from %s WHERE time > %d and time < %d and \"name\" = '%s' ORDER BY time DESC LIMIT 1", influxDbCryptoIndexTable, starttime.UnixNano(), endtime.UnixNano(), name)
+	q, err := newInfluxSelect(influxDbCryptoIndexTable, "constituents", "\"name\"", "price", "value", "divisor").
+		after(starttime).
+		before(endtime).
+		where("name", name).
+		orderDesc().
+		limitTo(1).
+		build()
+	if err != nil {
+		return retval, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		return retval, err
@@ -222,7 +230,16 @@ func (db *DB) SetCryptoIndex(index *CryptoIndex) error {
 
 func (db *DB) GetCryptoIndexConstituentPrice(symbol string, date time.Time) (float64, error) {
 	startdate := date.Add(-24 * time.Hour)
-	q := fmt.Sprintf("SELECT price from %s where time > %d and time <= %d and symbol = '%s' ORDER BY time DESC LIMIT 1", influxDbCryptoIndexConstituentsTable, startdate.UnixNano(), date.UnixNano(), symbol)
+	q, err := newInfluxSelect(influxDbCryptoIndexConstituentsTable, "price").
+		after(startdate).
+		atOrBefore(date).
+		where("symbol", symbol).
+		orderDesc().
+		limitTo(1).
+		build()
+	if err != nil {
+		return float64(0), err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		return float64(0), err
@@ -239,8 +256,17 @@ func (db *DB) GetCryptoIndexConstituents(starttime time.Time, endtime time.Time,
 //func (db *DB) GetCryptoIndexConstituents(starttime time.Time, endtime time.Time, symbol string) ([]CryptoIndexConstituent, error) {
 	var retval []CryptoIndexConstituent
 
-	q := fmt.Sprintf("SELECT address,cappingfactor,circulatingsupply,\"name\",percentage,price,symbol,weight,numbasetokens from %s WHERE time > %d and time < %d and symbol = '%s' and cryptoindex = '%s' ORDER BY time DESC LIMIT 1", influxDbCryptoIndexConstituentsTable, starttime.UnixNano(), endtime.UnixNano(), symbol, indexSymbol)
-	//q := fmt.Sprintf("SELECT address,cappingfactor,circulatingsupply,\"name\",percentage,price,symbol,weight,numbasetokens from %s WHERE time > %d and time < %d and symbol = '%s' ORDER BY time DESC LIMIT 1", influxDbCryptoIndexConstituentsTable, starttime.UnixNano(), endtime.UnixNano(), symbol)
+	q, err := newInfluxSelect(influxDbCryptoIndexConstituentsTable, "address", "cappingfactor", "circulatingsupply", "\"name\"", "percentage", "price", "symbol", "weight", "numbasetokens").
+		after(starttime).
+		before(endtime).
+		where("symbol", symbol).
+		where("cryptoindex", indexSymbol).
+		orderDesc().
+		limitTo(1).
+		build()
+	if err != nil {
+		return retval, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 
 	if err != nil {
@@ -342,6 +368,10 @@ func (db *DB) SetCryptoIndexConstituent(constituent *CryptoIndexConstituent, ind
 // WIP: Returns the amounts of constituents tokens needed to mint an index token
 // For now we hard-code amounts. TO DO: Set and Get data to and from influx/config
 func (db *DB) GetCryptoIndexMintAmounts(symbol string) ([]CryptoIndexMintAmount, error) {
+	return cryptoIndexMintAmounts(symbol)
+}
+
+func cryptoIndexMintAmounts(symbol string) ([]CryptoIndexMintAmount, error) {
 
 	constituents := []string{"SUSHI", "REN", "KP3R", "UTK", "AXS", "Yf-DAI", "DIA", "STAKE", "POLS", "PICKLE", "EASY", "IDLE", "SPICE"}
 	amounts := []uint64{102504643110709000, 907990711110561000, 206329281567188, 461546152853883000, 56696968122059100, 4185582958247, 26215696618443200, 3778532359289460, 38656197930994700, 972363917807713, 2038967220923070, 952603382004964, 16697065735724400}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bounds of the times influx can store, outside of which UnixNano is undefined.
+var (
+	influxMinTime = time.Unix(0, -1<<63+2).UTC()
+	influxMaxTime = time.Unix(0, 1<<63-2).UTC()
+)
+
+// influxQuery builds InfluxQL statements. Tag keys are quoted as identifiers and values as
+// string literals, so that values coming from requests can't change the statement. Fields,
+// measurements and raw conditions are taken as they are and must be constants of the code.
+type influxQuery struct {
+	statement  string
+	source     string
+	key        string
+	conditions []string
+	groupBy    []string
+	fill       string
+	order      string
+	limit      int
+	// lower and upper are the time bounds, used to check that the range is not empty.
+	lower *time.Time
+	upper *time.Time
+	err   error
+}
+
+// newInfluxSelect returns a query selecting @fields from @measurement.
+func newInfluxSelect(measurement string, fields ...string) *influxQuery {
+	return &influxQuery{
+		statement: "SELECT " + strings.Join(fields, ","),
+		source:    quoteInfluxMeasurement(measurement),
+	}
+}
+
+// newInfluxSubquerySelect returns a query selecting @fields from the results of @subquery.
+func newInfluxSubquerySelect(subquery *influxQuery, fields ...string) *influxQuery {
+	q := &influxQuery{statement: "SELECT " + strings.Join(fields, ",")}
+	source, err := subquery.build()
+	if err != nil {
+		q.err = err
+		return q
+	}
+	q.source = "(" + source + ")"
+	return q
+}
+
+// newInfluxTagValues returns a query of the values of the tag @key of @measurement.
+func newInfluxTagValues(measurement string, key string) *influxQuery {
+	return &influxQuery{
+		statement: "SHOW TAG VALUES",
+		source:    quoteInfluxMeasurement(measurement),
+		key:       quoteInfluxIdentifier(key),
+	}
+}
+
+// where restricts the query to points whose tag @tag is @value.
+func (q *influxQuery) where(tag string, value string) *influxQuery {
+	q.conditions = append(q.conditions, quoteInfluxIdentifier(tag)+"="+quoteInfluxString(value))
+	return q
+}
+
+// whereAny restricts the query to points whose tag @tag is one of @values.
+func (q *influxQuery) whereAny(tag string, values []string) *influxQuery {
+	if len(values) == 0 {
+		q.err = fmt.Errorf("no values of %s", tag)
+		return q
+	}
+	var conditions []string
+	for _, value := range values {
+		conditions = append(conditions, quoteInfluxIdentifier(tag)+"="+quoteInfluxString(value))
+	}
+	q.conditions = append(q.conditions, "("+strings.Join(conditions, " or ")+")")
+	return q
+}
+
+// whereRaw adds @condition as it is. It must not contain values of requests.
+func (q *influxQuery) whereRaw(condition string) *influxQuery {
+	q.conditions = append(q.conditions, condition)
+	return q
+}
+
+// since restricts the query to the last @d before now.
+func (q *influxQuery) since(d time.Duration) *influxQuery {
+	duration, err := influxDuration(d)
+	if err != nil {
+		q.err = err
+		return q
+	}
+	q.conditions = append(q.conditions, "time>now()-"+duration)
+	return q
+}
+
+// after restricts the query to points after @t. A zero @t sets no lower bound.
+func (q *influxQuery) after(t time.Time) *influxQuery {
+	if t.IsZero() {
+		return q
+	}
+	return q.timeBound(">", t, &q.lower)
+}
+
+// atOrAfter restricts the query to points at or after @t. A zero @t sets no lower bound.
+func (q *influxQuery) atOrAfter(t time.Time) *influxQuery {
+	if t.IsZero() {
+		return q
+	}
+	return q.timeBound(">=", t, &q.lower)
+}
+
+// before restricts the query to points before @t.
+func (q *influxQuery) before(t time.Time) *influxQuery {
+	return q.timeBound("<", t, &q.upper)
+}
+
+// atOrBefore restricts the query to points at or before @t.
+func (q *influxQuery) atOrBefore(t time.Time) *influxQuery {
+	return q.timeBound("<=", t, &q.upper)
+}
+
+func (q *influxQuery) timeBound(operator string, t time.Time, bound **time.Time) *influxQuery {
+	if t.Before(influxMinTime) || t.After(influxMaxTime) {
+		q.err = fmt.Errorf("time %v out of range", t)
+		return q
+	}
+	*bound = &t
+	q.conditions = append(q.conditions, "time"+operator+strconv.FormatInt(t.UnixNano(), 10))
+	return q
+}
+
+// groupByTags groups the results by the tags @tags.
+func (q *influxQuery) groupByTags(tags ...string) *influxQuery {
+	for _, tag := range tags {
+		q.groupBy = append(q.groupBy, quoteInfluxIdentifier(tag))
+	}
+	return q
+}
+
+// groupByTime groups the results in buckets of @d without empty buckets.
+func (q *influxQuery) groupByTime(d time.Duration) *influxQuery {
+	duration, err := influxDuration(d)
+	if err != nil {
+		q.err = err
+		return q
+	}
+	q.groupBy = append(q.groupBy, "time("+duration+")")
+	q.fill = "none"
+	return q
+}
+
+// orderAsc returns the points in ascending order of time.
+func (q *influxQuery) orderAsc() *influxQuery {
+	q.order = "ASC"
+	return q
+}
+
+// orderDesc returns the points in descending order of time.
+func (q *influxQuery) orderDesc() *influxQuery {
+	q.order = "DESC"
+	return q
+}
+
+// limitTo returns at most @n points. 0 means no limit, as in InfluxQL.
+func (q *influxQuery) limitTo(n int) *influxQuery {
+	if n < 0 {
+		q.err = fmt.Errorf("invalid limit %d", n)
+		return q
+	}
+	q.limit = n
+	return q
+}
+
+// build returns the statement of the query, or the first error of building it.
+func (q *influxQuery) build() (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	if q.lower != nil && q.upper != nil && q.upper.Before(*q.lower) {
+		return "", errors.New("end of time range is before its start")
+	}
+	var b strings.Builder
+	b.WriteString(q.statement)
+	b.WriteString(" FROM ")
+	b.WriteString(q.source)
+	if q.key != "" {
+		b.WriteString(" WITH KEY=" + q.key)
+	}
+	if len(q.conditions) > 0 {
+		b.WriteString(" WHERE " + strings.Join(q.conditions, " and "))
+	}
+	if len(q.groupBy) > 0 {
+		b.WriteString(" GROUP BY " + strings.Join(q.groupBy, ","))
+	}
+	if q.fill != "" {
+		b.WriteString(" fill(" + q.fill + ")")
+	}
+	if q.order != "" {
+		b.WriteString(" ORDER BY time " + q.order)
+	}
+	if q.limit > 0 {
+		b.WriteString(" LIMIT " + strconv.Itoa(q.limit))
+	}
+	return b.String(), nil
+}
+
+// quoteInfluxIdentifier returns @name as double quoted identifier.
+func quoteInfluxIdentifier(name string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(name) + `"`
+}
+
+// quoteInfluxString returns @value as single quoted string literal.
+func quoteInfluxString(value string) string {
+	return `'` + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`).Replace(value) + `'`
+}
+
+// quoteInfluxMeasurement quotes the measurement @measurement, which may be qualified by its
+// retention policy.
+func quoteInfluxMeasurement(measurement string) string {
+	parts := strings.SplitN(measurement, ".", 2)
+	for i := range parts {
+		parts[i] = quoteInfluxIdentifier(parts[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+// influxDuration returns @d as duration literal of whole seconds.
+func influxDuration(d time.Duration) (string, error) {
+	if d <= 0 || d%time.Second != 0 {
+		return "", fmt.Errorf("invalid duration %v", d)
+	}
+	return strconv.FormatInt(int64(d/time.Second), 10) + "s", nil
+}
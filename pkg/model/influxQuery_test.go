@@ -0,0 +1,144 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxQuery(t *testing.T) {
+	t0 := time.Unix(1600000000, 0)
+	t1 := t0.Add(time.Hour)
+
+	tables := []struct {
+		query *influxQuery
+		want  string
+	}{
+		{
+			newInfluxSelect(influxDbTradesTable, "*").where("symbol", "BTC").orderDesc().limitTo(10),
+			`SELECT * FROM "trades" WHERE "symbol"='BTC' ORDER BY time DESC LIMIT 10`,
+		},
+		{
+			newInfluxSelect(influxDbFiltersTable, "value").where("exchange", "").after(t0).atOrBefore(t1),
+			`SELECT value FROM "filters" WHERE "exchange"='' and time>1600000000000000000 and time<=1600003600000000000`,
+		},
+		{
+			newInfluxSelect(influxDbFiltersTable, "value").after(time.Time{}).before(t0),
+			`SELECT value FROM "filters" WHERE time<1600000000000000000`,
+		},
+		{
+			newInfluxSelect(influxDbCandlesTable+"1h", "open").since(24 * time.Hour).orderAsc(),
+			`SELECT open FROM "a_year"."candles_1h" WHERE time>now()-86400s ORDER BY time ASC`,
+		},
+		{
+			newInfluxSubquerySelect(newInfluxSelect(influxDbTradesTable, "abs(volume) AS volume").whereRaw("volume>0"), "sum(volume)").
+				groupByTime(time.Hour),
+			`SELECT sum(volume) FROM (SELECT abs(volume) AS volume FROM "trades" WHERE volume>0) GROUP BY time(3600s) fill(none)`,
+		},
+		{
+			newInfluxSelect(influxDbSupplyTable, "last(supply)").whereAny("symbol", []string{"BTC", "ETH"}).groupByTags("symbol"),
+			`SELECT last(supply) FROM "supplies" WHERE ("symbol"='BTC' or "symbol"='ETH') GROUP BY "symbol"`,
+		},
+		{
+			newInfluxTagValues(influxDbPoolTable, "poolID").where("protocol", "CVAULT"),
+			`SHOW TAG VALUES FROM "defiPools" WITH KEY="poolID" WHERE "protocol"='CVAULT'`,
+		},
+	}
+	for _, table := range tables {
+		q, err := table.query.build()
+		if err != nil {
+			t.Errorf("Query %s returned error %v.", table.want, err)
+			continue
+		}
+		if q != table.want {
+			t.Errorf("Query was incorrect, got: %s, want: %s.", q, table.want)
+		}
+	}
+}
+
+func TestInfluxQueryInjection(t *testing.T) {
+	t0 := time.Unix(1600000000, 0)
+	symbols := []string{
+		"BTC' OR 1=1",
+		"BTC' OR symbol='ETH",
+		`BTC\' OR 1=1 --`,
+		`BTC\`,
+		"BTC'; DROP MEASUREMENT trades; SELECT * FROM trades WHERE symbol='BTC",
+		"BTC'\nOR 1=1",
+	}
+	prefix := `SELECT * FROM "trades" WHERE "symbol"=`
+	suffix := " and time<1600000000000000000"
+	for _, symbol := range symbols {
+		q, err := newInfluxSelect(influxDbTradesTable, "*").where("symbol", symbol).before(t0).build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(q, prefix) {
+			t.Errorf("Query of %q was incorrect, got: %s, want prefix: %s.", symbol, q, prefix)
+			continue
+		}
+		// the symbol must be read back as one string literal followed by the rest of the query
+		value, n := scanInfluxString(q[len(prefix):])
+		if value != symbol || q[len(prefix)+n:] != suffix {
+			t.Errorf("Symbol %q changed the query, got literal %q followed by %q, want: %q followed by %q.", symbol, value, q[len(prefix)+n:], symbol, suffix)
+		}
+	}
+
+	q, err := newInfluxSelect(influxDbTradesTable, "*").where(`symbol" OR "a`, "BTC").build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT * FROM "trades" WHERE "symbol\" OR \"a"='BTC'`
+	if q != want {
+		t.Errorf("Query with crafted tag was incorrect, got: %s, want: %s.", q, want)
+	}
+}
+
+func TestInfluxQueryErrors(t *testing.T) {
+	t0 := time.Unix(1600000000, 0)
+	queries := []*influxQuery{
+		newInfluxSelect(influxDbTradesTable, "*").before(time.Time{}),
+		newInfluxSelect(influxDbTradesTable, "*").before(time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)),
+		newInfluxSelect(influxDbTradesTable, "*").after(t0).before(t0.Add(-time.Second)),
+		newInfluxSelect(influxDbTradesTable, "*").limitTo(-1),
+		newInfluxSelect(influxDbTradesTable, "*").whereAny("symbol", nil),
+		newInfluxSelect(influxDbTradesTable, "*").groupByTime(time.Millisecond),
+		newInfluxSubquerySelect(newInfluxSelect(influxDbTradesTable, "*").limitTo(-1), "*"),
+	}
+	for i, query := range queries {
+		if q, err := query.build(); err == nil {
+			t.Errorf("Query %d returned no error, got: %s.", i, q)
+		}
+	}
+}
+
+// scanInfluxString reads the single quoted string literal at the start of @s like the scanner
+// of InfluxQL. It returns the value of the literal and its length in @s.
+func scanInfluxString(s string) (string, int) {
+	if !strings.HasPrefix(s, "'") {
+		return "", 0
+	}
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			return value.String(), i + 1
+		case '\n':
+			// unescaped newlines end the literal with an error
+			return "", 0
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", 0
+			}
+			if s[i] == 'n' {
+				value.WriteByte('\n')
+			} else {
+				value.WriteByte(s[i])
+			}
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return "", 0
+}
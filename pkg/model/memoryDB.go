@@ -0,0 +1,1496 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/diadata-org/diadata/pkg/dia/helpers"
+	"github.com/go-redis/redis"
+	influxModels "github.com/influxdata/influxdb1-client/models"
+	clientInfluxdb "github.com/influxdata/influxdb1-client/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// MemoryDB is an in-memory implementation of Datastore. It keeps everything the
+// redis/influx backed DB persists in maps and slices guarded by a single lock, so that
+// services can run and be tested without external databases.
+// Missing keys are reported as redis.Nil, in order for callers to keep a single
+// not-found check regardless of the backend.
+type MemoryDB struct {
+	mu sync.RWMutex
+
+	// key-value entries, corresponding to the redis part of DB
+	quotations        map[string]Quotation
+	quotationsEUR     map[string]Quotation
+	zsets             map[string][]zsetEntry
+	lastTradeTimes    map[string]time.Time
+	availablePairs    map[string][]dia.Pair
+	currencyChange    *Change
+	filtersState      []byte
+	symbolRanks       map[string]int
+	optionMeta        map[string]map[string]dia.OptionMeta
+	interestRates     map[string][]InterestRate
+	itins             map[string]dia.ItinToken
+	defiProtocols     map[string]dia.DefiProtocol
+	latestSupplyNames map[string]struct{}
+
+	// time series, corresponding to the influx part of DB
+	trades              []dia.Trade
+	quarantinedTrades   []QuarantinedTrade
+	orderBookMetrics    []dia.OrderBookMetrics
+	filterPoints        []memoryFilterPoint
+	supplies            []dia.Supply
+	cvis                map[string][]dia.CviDataPoint
+	farmingPools        []FarmingPool
+	defiRates           []dia.DefiRate
+	defiStates          []dia.DefiProtocolState
+	foreignQuotations   []ForeignQuotation
+	cryptoIndices       []CryptoIndex
+	indexConstituents   []memoryIndexConstituent
+	githubCommits       []GithubCommit
+	stockQuotations     []StockQuotation
+	optionOrderbookData map[string]dia.OptionOrderbookDatum
+}
+
+// zsetEntry is a member of a sorted set. Entries are kept in ascending order of score.
+type zsetEntry struct {
+	score int64
+	value float64
+}
+
+type memoryFilterPoint struct {
+	filter   string
+	symbol   string
+	exchange string
+	value    float64
+	time     time.Time
+}
+
+type memoryIndexConstituent struct {
+	constituent CryptoIndexConstituent
+	indexSymbol string
+	time        time.Time
+}
+
+// NewMemoryDataStore returns an empty in-memory datastore.
+func NewMemoryDataStore() *MemoryDB {
+	return &MemoryDB{
+		quotations:          make(map[string]Quotation),
+		quotationsEUR:       make(map[string]Quotation),
+		zsets:               make(map[string][]zsetEntry),
+		lastTradeTimes:      make(map[string]time.Time),
+		availablePairs:      make(map[string][]dia.Pair),
+		symbolRanks:         make(map[string]int),
+		optionMeta:          make(map[string]map[string]dia.OptionMeta),
+		interestRates:       make(map[string][]InterestRate),
+		itins:               make(map[string]dia.ItinToken),
+		defiProtocols:       make(map[string]dia.DefiProtocol),
+		latestSupplyNames:   make(map[string]struct{}),
+		cvis:                make(map[string][]dia.CviDataPoint),
+		optionOrderbookData: make(map[string]dia.OptionOrderbookDatum),
+	}
+}
+
+// Flush is a no-op, as there is no batch to write.
+func (mdb *MemoryDB) Flush() error {
+	return nil
+}
+
+// ------------------------------------------------------------------------------
+// SORTED SETS
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) setZSETValue(key string, value float64, unixTime int64, maxWindow int64) {
+	entries := mdb.zsets[key]
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].score > unixTime })
+	entries = append(entries, zsetEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = zsetEntry{score: unixTime, value: value}
+	// purging old values
+	first := sort.Search(len(entries), func(i int) bool { return entries[i].score >= unixTime-maxWindow })
+	mdb.zsets[key] = entries[first:]
+}
+
+func (mdb *MemoryDB) getZSETValue(key string, atUnixTime int64) (float64, error) {
+	entries := mdb.zsets[key]
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].score > atUnixTime })
+	if i == 0 {
+		return 0, errors.New("getZSETValue no value found")
+	}
+	return entries[i-1].value, nil
+}
+
+func (mdb *MemoryDB) getZSETLastValue(key string) (float64, int64, error) {
+	entries := mdb.zsets[key]
+	if len(entries) == 0 {
+		return 0, 0, errors.New("getZSETLastValue no value found")
+	}
+	last := entries[len(entries)-1]
+	return last.value, last.score, nil
+}
+
+// zsetFilterKeys returns the components of all sorted set keys of @filter,
+// i.e. [symbol] or [symbol, exchange] for each key.
+func (mdb *MemoryDB) zsetFilterKeys(filter string) [][]string {
+	var result [][]string
+	prefix := "dia_" + filter + "_"
+	for key := range mdb.zsets {
+		if !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, "_ZSET") {
+			continue
+		}
+		filteredKey := strings.TrimSuffix(strings.TrimPrefix(key, prefix), "_ZSET")
+		result = append(result, strings.Split(filteredKey, "_"))
+	}
+	return result
+}
+
+// ------------------------------------------------------------------------------
+// QUOTATIONS AND PRICES
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SetPriceUSD(symbol string, price float64) error {
+	return mdb.SetQuotation(&Quotation{
+		Symbol: symbol,
+		Name:   helpers.NameForSymbol(symbol),
+		Price:  price,
+		Source: dia.Diadata,
+		Time:   time.Now(),
+	})
+}
+
+func (mdb *MemoryDB) SetPriceEUR(symbol string, price float64) error {
+	return mdb.SetQuotationEUR(&Quotation{
+		Symbol: symbol,
+		Name:   helpers.NameForSymbol(symbol),
+		Price:  price,
+		Source: dia.Diadata,
+		Time:   time.Now(),
+	})
+}
+
+func (mdb *MemoryDB) GetPriceUSD(symbol string) (float64, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	q, ok := mdb.quotations[symbol]
+	if !ok {
+		return 0.0, redis.Nil
+	}
+	return q.Price, nil
+}
+
+func (mdb *MemoryDB) GetQuotation(symbol string) (*Quotation, error) {
+	mdb.mu.RLock()
+	q, ok := mdb.quotations[symbol]
+	mdb.mu.RUnlock()
+	if !ok {
+		return nil, redis.Nil
+	}
+	value := q
+	value.Name = helpers.NameForSymbol(symbol)
+	v, err := mdb.GetPriceYesterday(symbol, "")
+	if err == nil {
+		value.PriceYesterday = &v
+	}
+	value.VolumeYesterdayUSD, _ = mdb.GetVolume(symbol)
+	itin, err := mdb.GetItinBySymbol(symbol)
+	if err != nil {
+		value.ITIN = "undefined"
+	} else {
+		value.ITIN = itin.Itin
+	}
+	return &value, nil
+}
+
+// GetBatchQuotations mirrors DB.GetBatchQuotations.
+func (mdb *MemoryDB) GetBatchQuotations(symbols []string, timestamp time.Time) ([]BatchQuotation, error) {
+	result := make([]BatchQuotation, len(symbols))
+	for i, symbol := range symbols {
+		var quotation *Quotation
+		var quotationErr error
+		var supply *dia.Supply
+		var supplyErr error
+		if timestamp.IsZero() {
+			quotation, quotationErr = mdb.GetQuotation(symbol)
+			supply, supplyErr = mdb.GetLatestSupply(symbol)
+		} else {
+			price, err := mdb.getPriceAtOrBefore(symbol, dia.FilterKing, "", timestamp)
+			quotation, quotationErr = historicQuotation(price, err, timestamp)
+			// the bounds of the range are exclusive
+			supplies, err := mdb.GetSupplyInflux(symbol, time.Unix(0, 0), timestamp.Add(time.Nanosecond))
+			if err != nil {
+				supplyErr = fmt.Errorf("no supply before %v", timestamp)
+			} else {
+				supply = &supplies[len(supplies)-1]
+			}
+		}
+		result[i] = newBatchQuotation(symbol, quotation, quotationErr, supply, supplyErr)
+	}
+	return result, nil
+}
+
+func (mdb *MemoryDB) SetQuotation(quotation *Quotation) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.quotations[quotation.Symbol] = *quotation
+	return nil
+}
+
+func (mdb *MemoryDB) SetQuotationEUR(quotation *Quotation) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.quotationsEUR[quotation.Symbol] = *quotation
+	return nil
+}
+
+func (mdb *MemoryDB) GetPaxgQuotationOunces() (*Quotation, error) {
+	return mdb.GetQuotation("PAXG")
+}
+
+func (mdb *MemoryDB) GetPaxgQuotationGrams() (*Quotation, error) {
+	q, err := mdb.GetQuotation("PAXG")
+	if err != nil {
+		return nil, err
+	}
+	q.Symbol = q.Symbol + "-gram"
+	q.Name = q.Name + "-gram"
+	q.Price = q.Price / 31.1034768
+	if q.PriceYesterday != nil {
+		*q.PriceYesterday = *q.PriceYesterday / 31.1034768
+	}
+	return q, nil
+}
+
+func (mdb *MemoryDB) SetPriceZSET(symbol string, exchange string, price float64, t time.Time) error {
+	mdb.SaveFilterInflux(dia.FilterKing, symbol, exchange, price, t)
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.setZSETValue(getKeyFilterZSET(getKey(dia.FilterKing, symbol, exchange)), price, time.Now().Unix(), Window30d)
+	return nil
+}
+
+// GetPrice returns the last MA120 value of @symbol on @exchange.
+func (mdb *MemoryDB) GetPrice(symbol string, exchange string) (float64, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	v, _, err := mdb.getZSETLastValue(getKeyFilterSymbolAndExchangeZSET(dia.FilterKing, symbol, exchange))
+	return v, err
+}
+
+// GetPriceYesterday returns the MA120 value of @symbol on @exchange 24 hours ago.
+func (mdb *MemoryDB) GetPriceYesterday(symbol string, exchange string) (float64, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	return mdb.getZSETValue(getKeyFilterZSET(getKey(dia.FilterKing, symbol, exchange)), time.Now().Unix()-WindowYesterday)
+}
+
+func (mdb *MemoryDB) GetChartPoints7Days(symbol string) (r []Point, err error) {
+	r = []Point{}
+	points := mdb.filterPointsWhere(func(fp memoryFilterPoint) bool {
+		return fp.filter == dia.FilterKing && fp.exchange == "" && fp.symbol == symbol && fp.time.After(time.Now().Add(-7*24*time.Hour))
+	})
+	for i := len(points) - 1; i >= 0; i-- {
+		r = append(r, Point{points[i].time.Unix(), points[i].value})
+	}
+	return
+}
+
+// ------------------------------------------------------------------------------
+// SYMBOLS, PAIRS AND EXCHANGES
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) GetPairs(exchange string) ([]dia.Pair, error) {
+	var result []dia.Pair
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, s := range mdb.zsetFilterKeys(dia.FilterKing) {
+		if len(s) == 2 {
+			result = append(result, dia.Pair{
+				Symbol:   s[0],
+				Exchange: s[1],
+			})
+		}
+	}
+	return result, nil
+}
+
+func (mdb *MemoryDB) GetSymbols(exchange string) ([]string, error) {
+	var result []string
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, s := range mdb.zsetFilterKeys(dia.FilterKing) {
+		if exchange == "" {
+			if len(s) == 1 {
+				result = append(result, s[0])
+			}
+		} else if len(s) == 2 && s[1] == exchange {
+			result = append(result, s[0])
+		}
+	}
+	return result, nil
+}
+
+func (mdb *MemoryDB) GetExchangesForSymbol(symbol string) ([]string, error) {
+	var result []string
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, s := range mdb.zsetFilterKeys(dia.FilterKing) {
+		if len(s) == 2 && s[0] == symbol {
+			result = append(result, s[1])
+		}
+	}
+	return result, nil
+}
+
+func (mdb *MemoryDB) GetSymbolExchangeDetails(symbol string, exchange string) (*SymbolExchangeDetails, error) {
+	result := &SymbolExchangeDetails{
+		Name: exchange,
+	}
+	v, err := mdb.GetPrice(symbol, exchange)
+	if err == nil {
+		result.Price = v
+	}
+	py, err2 := mdb.GetPriceYesterday(symbol, exchange)
+	if err2 == nil {
+		result.PriceYesterday = &py
+	}
+	result.VolumeYesterdayUSD, _ = mdb.Sum24HoursInflux(symbol, exchange, volumeKey)
+	result.Time, _ = mdb.GetLastTradeTimeForExchange(symbol, exchange)
+	result.LastTrades, _ = mdb.GetLastTrades(symbol, exchange, 10)
+	return result, err
+}
+
+func (mdb *MemoryDB) GetLastTradeTimeForExchange(symbol string, exchange string) (*time.Time, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	t, ok := mdb.lastTradeTimes[getKeyLastTradeTimeForExchange(symbol, exchange)]
+	if !ok {
+		return nil, redis.Nil
+	}
+	return &t, nil
+}
+
+func (mdb *MemoryDB) SetLastTradeTimeForExchange(symbol string, exchange string, t time.Time) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.lastTradeTimes[getKeyLastTradeTimeForExchange(symbol, exchange)] = t
+	return nil
+}
+
+func (mdb *MemoryDB) SetAvailablePairsForExchange(exchange string, pairs []dia.Pair) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.availablePairs[exchange] = append([]dia.Pair{}, pairs...)
+	return nil
+}
+
+func (mdb *MemoryDB) GetAvailablePairsForExchange(exchange string) ([]dia.Pair, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	pairs, ok := mdb.availablePairs[exchange]
+	if !ok {
+		return nil, redis.Nil
+	}
+	return append([]dia.Pair{}, pairs...), nil
+}
+
+func (mdb *MemoryDB) GetAllSymbols() []string {
+	r := make(map[string]struct{})
+	for _, e := range dia.Exchanges() {
+		for _, symbol := range mdb.GetSymbolsByExchange(e) {
+			r[symbol] = struct{}{}
+		}
+	}
+	s := []string{}
+	for symbol := range r {
+		s = append(s, symbol)
+	}
+	return s
+}
+
+func (mdb *MemoryDB) GetSymbolsByExchange(e string) []string {
+	r := make(map[string]struct{})
+	pairs, _ := mdb.GetAvailablePairsForExchange(e)
+	for _, p := range pairs {
+		r[p.Symbol] = struct{}{}
+	}
+	s := []string{}
+	for symbol := range r {
+		s = append(s, symbol)
+	}
+	return s
+}
+
+func (mdb *MemoryDB) GetExchanges() (allExchanges []string) {
+	for _, exchange := range dia.Exchanges() {
+		if exchange != dia.UnknownExchange {
+			allExchanges = append(allExchanges, exchange)
+		}
+	}
+	return
+}
+
+func (mdb *MemoryDB) SetCurrencyChange(cc *Change) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	change := *cc
+	mdb.currencyChange = &change
+	return nil
+}
+
+func (mdb *MemoryDB) GetCurrencyChange() (*Change, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	if mdb.currencyChange == nil {
+		return nil, redis.Nil
+	}
+	change := *mdb.currencyChange
+	return &change, nil
+}
+
+func (mdb *MemoryDB) SetFiltersState(state []byte) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.filtersState = append([]byte{}, state...)
+	return nil
+}
+
+func (mdb *MemoryDB) GetFiltersState() ([]byte, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	if mdb.filtersState == nil {
+		return nil, redis.Nil
+	}
+	return append([]byte{}, mdb.filtersState...), nil
+}
+
+func (mdb *MemoryDB) GetCoins() (*Coins, error) {
+	coins := buildCoins(mdb, mdb.GetAllSymbols())
+	return &coins, nil
+}
+
+func (mdb *MemoryDB) GetSymbolDetails(symbol string) (*SymbolDetails, error) {
+	r, err := buildSymbolDetails(mdb, symbol)
+	if err != nil {
+		return r, err
+	}
+	mdb.mu.RLock()
+	r.Rank = mdb.symbolRanks[symbol]
+	mdb.mu.RUnlock()
+	return r, nil
+}
+
+func (mdb *MemoryDB) UpdateSymbolDetails(symbol string, rank int) {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.symbolRanks[symbol] = rank
+}
+
+func (mdb *MemoryDB) GetConfigTogglePairDiscovery() (bool, error) {
+	return false, nil
+}
+
+func (mdb *MemoryDB) SetItinData(token dia.ItinToken) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.itins[token.Symbol] = token
+	return nil
+}
+
+func (mdb *MemoryDB) GetItinBySymbol(symbol string) (dia.ItinToken, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	token, ok := mdb.itins[symbol]
+	if !ok {
+		return dia.ItinToken{}, redis.Nil
+	}
+	return token, nil
+}
+
+// ------------------------------------------------------------------------------
+// TRADES
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SaveTradeInflux(t *dia.Trade) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	i := sort.Search(len(mdb.trades), func(i int) bool { return mdb.trades[i].Time.After(t.Time) })
+	mdb.trades = append(mdb.trades, dia.Trade{})
+	copy(mdb.trades[i+1:], mdb.trades[i:])
+	mdb.trades[i] = *t
+	return nil
+}
+
+// GetTradeInflux returns the last trade of @symbol on @exchange before @timestamp.
+// @exchange can be left blank in order to consider all exchanges.
+func (mdb *MemoryDB) GetTradeInflux(symbol string, exchange string, timestamp time.Time) (*dia.Trade, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.trades) - 1; i >= 0; i-- {
+		t := mdb.trades[i]
+		if t.Symbol == symbol && (exchange == "" || t.Source == exchange) && t.Time.Before(timestamp) {
+			return &t, nil
+		}
+	}
+	return &dia.Trade{}, errors.New("Error parsing Trade from Database")
+}
+
+func (mdb *MemoryDB) GetLastTrades(symbol string, exchange string, maxTrades int) ([]dia.Trade, error) {
+	return mdb.lastTradesWhere(maxTrades, func(t dia.Trade) bool {
+		return t.Symbol == symbol && t.Source == exchange
+	}), nil
+}
+
+func (mdb *MemoryDB) GetLastTradesAllExchanges(symbol string, maxTrades int) ([]dia.Trade, error) {
+	return mdb.lastTradesWhere(maxTrades, func(t dia.Trade) bool {
+		return t.Symbol == symbol
+	}), nil
+}
+
+// GetAllTrades returns at most @maxTrades trades with timestamp >= @t in ascending order.
+func (mdb *MemoryDB) GetAllTrades(t time.Time, maxTrades int) ([]dia.Trade, error) {
+	r := []dia.Trade{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	i := sort.Search(len(mdb.trades), func(i int) bool { return !mdb.trades[i].Time.Before(t) })
+	for ; i < len(mdb.trades) && len(r) < maxTrades; i++ {
+		r = append(r, mdb.trades[i])
+	}
+	return r, nil
+}
+
+// lastTradesWhere returns at most @maxTrades of the latest trades matching @match in descending order.
+func (mdb *MemoryDB) lastTradesWhere(maxTrades int, match func(dia.Trade) bool) []dia.Trade {
+	r := []dia.Trade{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.trades) - 1; i >= 0 && len(r) < maxTrades; i-- {
+		if match(mdb.trades[i]) {
+			r = append(r, mdb.trades[i])
+		}
+	}
+	return r
+}
+
+// GetCandles returns the candles of @interval of @symbol on @exchange between @starttime and
+// @endtime in ascending order. The candles of all exchanges are returned if @exchange is empty.
+func (mdb *MemoryDB) GetCandles(symbol string, exchange string, interval string, starttime time.Time, endtime time.Time) ([]Candle, error) {
+	duration, ok := CandleIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unknown candle interval %s", interval)
+	}
+	starttime = candleStart(starttime, duration)
+	var trades []dia.Trade
+	mdb.mu.RLock()
+	i := sort.Search(len(mdb.trades), func(i int) bool { return !mdb.trades[i].Time.Before(starttime) })
+	for ; i < len(mdb.trades) && mdb.trades[i].Time.Before(endtime); i++ {
+		t := mdb.trades[i]
+		if t.Symbol == symbol && (exchange == "" || t.Source == exchange) {
+			trades = append(trades, t)
+		}
+	}
+	mdb.mu.RUnlock()
+	return candlesFromTrades(trades, symbol, exchange, duration), nil
+}
+
+func (mdb *MemoryDB) SaveQuarantinedTradeInflux(t *dia.Trade, reason string) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.quarantinedTrades = append(mdb.quarantinedTrades, QuarantinedTrade{Trade: *t, Reason: reason})
+	sort.SliceStable(mdb.quarantinedTrades, func(i, j int) bool {
+		return mdb.quarantinedTrades[i].Trade.Time.Before(mdb.quarantinedTrades[j].Trade.Time)
+	})
+	return nil
+}
+
+// GetQuarantinedTradesInflux returns the quarantined trades of @symbol between @starttime and @endtime
+// in descending order.
+func (mdb *MemoryDB) GetQuarantinedTradesInflux(symbol string, starttime time.Time, endtime time.Time) ([]QuarantinedTrade, error) {
+	retval := []QuarantinedTrade{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.quarantinedTrades) - 1; i >= 0; i-- {
+		qt := mdb.quarantinedTrades[i]
+		if qt.Trade.Symbol == symbol && qt.Trade.Time.After(starttime) && qt.Trade.Time.Before(endtime) {
+			retval = append(retval, qt)
+		}
+	}
+	return retval, nil
+}
+
+func (mdb *MemoryDB) SaveOrderBookMetricsInflux(metrics dia.OrderBookMetrics) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.orderBookMetrics = append(mdb.orderBookMetrics, metrics)
+	sort.SliceStable(mdb.orderBookMetrics, func(i, j int) bool {
+		return mdb.orderBookMetrics[i].Time.Before(mdb.orderBookMetrics[j].Time)
+	})
+	return nil
+}
+
+// GetOrderBookMetricsInflux returns the order book metrics of @symbol on @exchange between
+// @starttime and @endtime in descending order, of all exchanges if @exchange is empty.
+func (mdb *MemoryDB) GetOrderBookMetricsInflux(symbol string, exchange string, starttime time.Time, endtime time.Time) ([]dia.OrderBookMetrics, error) {
+	retval := []dia.OrderBookMetrics{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.orderBookMetrics) - 1; i >= 0; i-- {
+		metrics := mdb.orderBookMetrics[i]
+		if metrics.Symbol == symbol && (exchange == "" || metrics.Exchange == exchange) && metrics.Time.After(starttime) && metrics.Time.Before(endtime) {
+			retval = append(retval, metrics)
+		}
+	}
+	return retval, nil
+}
+
+// ------------------------------------------------------------------------------
+// FILTERS AND VOLUMES
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SaveFilterInflux(filter string, symbol string, exchange string, value float64, t time.Time) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	fp := memoryFilterPoint{filter: filter, symbol: symbol, exchange: exchange, value: value, time: t}
+	i := sort.Search(len(mdb.filterPoints), func(i int) bool { return mdb.filterPoints[i].time.After(t) })
+	mdb.filterPoints = append(mdb.filterPoints, memoryFilterPoint{})
+	copy(mdb.filterPoints[i+1:], mdb.filterPoints[i:])
+	mdb.filterPoints[i] = fp
+	return nil
+}
+
+func (mdb *MemoryDB) SetFilter(filter string, symbol string, exchange string, value float64, t time.Time) error {
+	mdb.SaveFilterInflux(filter, symbol, exchange, value, t)
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.setZSETValue(getKeyFilterZSET(getKey(filter, symbol, exchange)), value, t.Unix(), BiggestWindow)
+	return nil
+}
+
+// filterPointsWhere returns all filter points matching @match in ascending order of time.
+func (mdb *MemoryDB) filterPointsWhere(match func(memoryFilterPoint) bool) []memoryFilterPoint {
+	var r []memoryFilterPoint
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, fp := range mdb.filterPoints {
+		if match(fp) {
+			r = append(r, fp)
+		}
+	}
+	return r
+}
+
+// GetFilterPoints returns filter points in the same shape as the influx query result of DB.
+// If @scale is set, points are averaged (VOL120 summed up) over buckets of the given size.
+func (mdb *MemoryDB) GetFilterPoints(filter string, exchange string, symbol string, scale string, starttime time.Time, endtime time.Time) (*Points, error) {
+	points := mdb.filterPointsWhere(func(fp memoryFilterPoint) bool {
+		return fp.filter == filter && fp.exchange == exchange && fp.symbol == symbol && fp.time.After(starttime) && fp.time.Before(endtime)
+	})
+	if scale != "" {
+		bucket, ok := filterScales[scale]
+		if !ok {
+			return &Points{}, errors.New("unknown scale " + scale)
+		}
+		points = aggregateFilterPoints(points, bucket, filter == volumeKey)
+	}
+	row := influxModels.Row{
+		Name:    influxDbFiltersTable,
+		Columns: []string{"time", "exchange", "filter", "symbol", "value"},
+	}
+	for i := len(points) - 1; i >= 0; i-- {
+		fp := points[i]
+		row.Values = append(row.Values, []interface{}{
+			fp.time.UTC().Format(time.RFC3339),
+			fp.exchange,
+			fp.filter,
+			fp.symbol,
+			json.Number(strconv.FormatFloat(fp.value, 'f', -1, 64)),
+		})
+	}
+	return &Points{
+		DataPoints: []clientInfluxdb.Result{{Series: []influxModels.Row{row}}},
+	}, nil
+}
+
+// filterScales are the bucket sizes of the continuous queries on the filters table.
+var filterScales = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// aggregateFilterPoints averages (or sums, if @sum is true) ascending @points over buckets of size @bucket.
+func aggregateFilterPoints(points []memoryFilterPoint, bucket time.Duration, sum bool) []memoryFilterPoint {
+	var result []memoryFilterPoint
+	var count int
+	for _, fp := range points {
+		fp.time = fp.time.Truncate(bucket)
+		if len(result) > 0 && result[len(result)-1].time.Equal(fp.time) {
+			result[len(result)-1].value += fp.value
+			count++
+			continue
+		}
+		if len(result) > 0 && !sum {
+			result[len(result)-1].value /= float64(count)
+		}
+		result = append(result, fp)
+		count = 1
+	}
+	if len(result) > 0 && !sum {
+		result[len(result)-1].value /= float64(count)
+	}
+	return result
+}
+
+// GetLastPriceBefore mirrors DB.GetLastPriceBefore, which returns the first filter
+// value after @timestamp.
+func (mdb *MemoryDB) GetLastPriceBefore(symbol string, filter string, exchange string, timestamp time.Time) (Price, error) {
+	price := Price{
+		Symbol: symbol,
+		Name:   helpers.NameForSymbol(symbol),
+	}
+	points := mdb.filterPointsWhere(func(fp memoryFilterPoint) bool {
+		return fp.filter == filter && fp.symbol == symbol && fp.exchange == exchange && fp.time.After(timestamp)
+	})
+	if len(points) > 0 {
+		price.Price = points[0].value
+		price.Time = points[0].time
+	} else {
+		log.Errorln("Empty response GetLastFilterPointBefore")
+	}
+	return price, nil
+}
+
+// getPriceAtOrBefore mirrors DB.getPriceAtOrBefore.
+func (mdb *MemoryDB) getPriceAtOrBefore(symbol string, filter string, exchange string, timestamp time.Time) (Price, error) {
+	price := Price{
+		Symbol: symbol,
+		Name:   helpers.NameForSymbol(symbol),
+	}
+	points := mdb.filterPointsWhere(func(fp memoryFilterPoint) bool {
+		return fp.filter == filter && fp.symbol == symbol && fp.exchange == exchange && !fp.time.After(timestamp)
+	})
+	for _, fp := range points {
+		if price.Time.IsZero() || fp.time.After(price.Time) {
+			price.Price = fp.value
+			price.Time = fp.time
+		}
+	}
+	return price, nil
+}
+
+func (mdb *MemoryDB) SetVolume(symbol string, exchange string, volume float64, t time.Time) error {
+	return mdb.SetFilter(volumeKey, symbol, exchange, volume, t)
+}
+
+func (mdb *MemoryDB) GetVolume(symbol string) (*float64, error) {
+	return mdb.Sum24HoursInflux(symbol, "", volumeKey)
+}
+
+// Sum24HoursInflux returns the 24h volume of @symbol on @exchange using the filter @filter.
+func (mdb *MemoryDB) Sum24HoursInflux(symbol string, exchange string, filter string) (*float64, error) {
+	points := mdb.filterPointsWhere(func(fp memoryFilterPoint) bool {
+		return fp.filter == filter && fp.symbol == symbol && fp.exchange == exchange && fp.time.After(time.Now().Add(-24*time.Hour))
+	})
+	if len(points) == 0 {
+		return nil, errors.New("Empty response in Sum24HoursInflux")
+	}
+	var result float64
+	for _, fp := range points {
+		result += fp.value
+	}
+	return &result, nil
+}
+
+func (mdb *MemoryDB) Sum24HoursExchange(exchange string) (float64, error) {
+	var TVL float64
+	for _, symbol := range mdb.GetSymbolsByExchange(exchange) {
+		volumeUSD, err := mdb.Sum24HoursInflux(symbol, exchange, volumeKey)
+		if err != nil {
+			continue
+		}
+		TVL += *volumeUSD
+	}
+	return TVL, nil
+}
+
+// GetVolumeInflux returns the trade volume of @symbol in the time range @starttime - @endtime.
+func (mdb *MemoryDB) GetVolumeInflux(symbol string, starttime time.Time, endtime time.Time) (float64, error) {
+	if starttime.IsZero() || endtime.IsZero() {
+		endtime = time.Now()
+		starttime = endtime.Add(-24 * time.Hour)
+	}
+	points := mdb.filterPointsWhere(func(fp memoryFilterPoint) bool {
+		return fp.filter == volumeKey && fp.symbol == symbol && fp.time.After(starttime) && fp.time.Before(endtime)
+	})
+	if len(points) == 0 {
+		return 0, errors.New("Error parsing Volume value from Database")
+	}
+	var retval float64
+	for _, fp := range points {
+		retval += fp.value
+	}
+	return retval, nil
+}
+
+// ------------------------------------------------------------------------------
+// SUPPLIES
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SymbolsWithASupply() ([]string, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	result := []string{}
+	for symbol := range mdb.latestSupplyNames {
+		result = append(result, symbol)
+	}
+	return result, nil
+}
+
+func (mdb *MemoryDB) GetLatestSupply(symbol string) (*dia.Supply, error) {
+	val, err := mdb.GetSupply(symbol, time.Time{}, time.Time{})
+	if err != nil {
+		return &dia.Supply{}, err
+	}
+	return &val[0], err
+}
+
+func (mdb *MemoryDB) GetSupply(symbol string, starttime, endtime time.Time) ([]dia.Supply, error) {
+	return mdb.GetSupplyInflux(symbol, starttime, endtime)
+}
+
+func (mdb *MemoryDB) SetSupply(supply *dia.Supply) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.latestSupplyNames[supply.Symbol] = struct{}{}
+	i := sort.Search(len(mdb.supplies), func(i int) bool { return mdb.supplies[i].Time.After(supply.Time) })
+	mdb.supplies = append(mdb.supplies, dia.Supply{})
+	copy(mdb.supplies[i+1:], mdb.supplies[i:])
+	mdb.supplies[i] = *supply
+	return nil
+}
+
+// GetSupplyInflux returns the supplies of @symbol in the given time range, or the latest
+// supply if one of the bounds is zero.
+func (mdb *MemoryDB) GetSupplyInflux(symbol string, starttime time.Time, endtime time.Time) ([]dia.Supply, error) {
+	retval := []dia.Supply{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	latest := starttime.IsZero() || endtime.IsZero()
+	for i := len(mdb.supplies) - 1; i >= 0; i-- {
+		s := mdb.supplies[i]
+		if s.Symbol != symbol {
+			continue
+		}
+		if latest {
+			retval = append(retval, s)
+			break
+		}
+		if s.Time.After(starttime) && s.Time.Before(endtime) {
+			retval = append([]dia.Supply{s}, retval...)
+		}
+	}
+	if len(retval) == 0 {
+		return retval, errors.New("Error parsing Supply value from Database")
+	}
+	return retval, nil
+}
+
+// ------------------------------------------------------------------------------
+// OPTIONS AND CVI
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SetOptionMeta(optionMeta *dia.OptionMeta) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	if _, ok := mdb.optionMeta[optionMeta.BaseCurrency]; !ok {
+		mdb.optionMeta[optionMeta.BaseCurrency] = make(map[string]dia.OptionMeta)
+	}
+	mdb.optionMeta[optionMeta.BaseCurrency][optionMeta.InstrumentName] = *optionMeta
+	return nil
+}
+
+func (mdb *MemoryDB) GetOptionMeta(baseCurrency string) ([]dia.OptionMeta, error) {
+	var result []dia.OptionMeta
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, om := range mdb.optionMeta[baseCurrency] {
+		result = append(result, om)
+	}
+	return result, nil
+}
+
+func (mdb *MemoryDB) SaveOptionOrderbookDatumInflux(t dia.OptionOrderbookDatum) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.optionOrderbookData[t.InstrumentName] = t
+	return nil
+}
+
+func (mdb *MemoryDB) GetOptionOrderbookDataInflux(t dia.OptionMeta) (dia.OptionOrderbookDatum, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	return mdb.optionOrderbookData[t.InstrumentName], nil
+}
+
+func (mdb *MemoryDB) SaveCVIInflux(cviValue float64, observationTime time.Time) error {
+	return mdb.saveCVI("", cviValue, observationTime)
+}
+
+func (mdb *MemoryDB) SaveETHCVIInflux(cviValue float64, observationTime time.Time) error {
+	return mdb.saveCVI("ETH", cviValue, observationTime)
+}
+
+func (mdb *MemoryDB) saveCVI(symbol string, cviValue float64, observationTime time.Time) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.cvis[symbol] = append(mdb.cvis[symbol], dia.CviDataPoint{Timestamp: observationTime, Value: cviValue})
+	sort.Slice(mdb.cvis[symbol], func(i, j int) bool {
+		return mdb.cvis[symbol][i].Timestamp.Before(mdb.cvis[symbol][j].Timestamp)
+	})
+	return nil
+}
+
+func (mdb *MemoryDB) GetCVIInflux(starttime time.Time, endtime time.Time, symbol string) ([]dia.CviDataPoint, error) {
+	retval := []dia.CviDataPoint{}
+	if symbol != "ETH" {
+		symbol = ""
+	}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, p := range mdb.cvis[symbol] {
+		if p.Timestamp.After(starttime) && p.Timestamp.Before(endtime) {
+			retval = append(retval, p)
+		}
+	}
+	if len(retval) == 0 {
+		return retval, errors.New("Error parsing CVI value from Database")
+	}
+	return retval, nil
+}
+
+// ------------------------------------------------------------------------------
+// INTEREST RATES
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SetInterestRate(ir *InterestRate) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	rates := mdb.interestRates[ir.Symbol]
+	for i := range rates {
+		if rates[i].EffectiveDate.Equal(ir.EffectiveDate) {
+			rates[i] = *ir
+			return nil
+		}
+	}
+	rates = append(rates, *ir)
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].EffectiveDate.Before(rates[j].EffectiveDate)
+	})
+	mdb.interestRates[ir.Symbol] = rates
+	return nil
+}
+
+// GetInterestRate returns the interest rate value for the last day up to @date with an entry,
+// going back at most 30 days. @date is a string in the format yyyy-mm-dd.
+func (mdb *MemoryDB) GetInterestRate(symbol, date string) (*InterestRate, error) {
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return &InterestRate{}, err
+	}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	rates := mdb.interestRates[symbol]
+	for i := len(rates) - 1; i >= 0; i-- {
+		if rates[i].EffectiveDate.Before(day.AddDate(0, 0, 1)) {
+			if rates[i].EffectiveDate.Before(day.AddDate(0, 0, -30)) {
+				break
+			}
+			ir := rates[i]
+			return &ir, nil
+		}
+	}
+	return &InterestRate{}, redis.Nil
+}
+
+// GetInterestRateRange returns the interest rate values with effective date between @dateInit and @dateFinal.
+func (mdb *MemoryDB) GetInterestRateRange(symbol, dateInit, dateFinal string) ([]*InterestRate, error) {
+	allValues := []*InterestRate{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, ir := range mdb.interestRates[symbol] {
+		day := ir.EffectiveDate.Format("2006-01-02")
+		if day >= dateInit && day <= dateFinal {
+			value := ir
+			allValues = append(allValues, &value)
+		}
+	}
+	return allValues, nil
+}
+
+func (mdb *MemoryDB) GetRatesMeta() (RatesMeta []InterestRateMeta, err error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for symbol, rates := range mdb.interestRates {
+		if len(rates) == 0 {
+			continue
+		}
+		RatesMeta = append(RatesMeta, InterestRateMeta{symbol, rates[0].EffectiveDate, rateDecimals(symbol), rates[0].Source})
+	}
+	return
+}
+
+// GetFirstDate returns the oldest effective date of the rate with symbol @symbol.
+func (mdb *MemoryDB) GetFirstDate(symbol string) (time.Time, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	rates := mdb.interestRates[symbol]
+	if len(rates) == 0 {
+		return time.Time{}, errors.New("database error")
+	}
+	return rates[0].EffectiveDate, nil
+}
+
+func (mdb *MemoryDB) GetCompoundedRate(symbol string, dateInit, date time.Time, daysPerYear int, rounding int) (*InterestRate, error) {
+	return getCompoundedRate(mdb, symbol, dateInit, date, daysPerYear, rounding)
+}
+
+func (mdb *MemoryDB) GetCompoundedIndex(symbol string, date time.Time, daysPerYear int, rounding int) (*InterestRate, error) {
+	return getCompoundedIndex(mdb, symbol, date, daysPerYear, rounding)
+}
+
+func (mdb *MemoryDB) GetCompoundedIndexRange(symbol string, dateInit, dateFinal time.Time, daysPerYear int, rounding int) ([]*InterestRate, error) {
+	return getCompoundedIndexRange(mdb, symbol, dateInit, dateFinal, daysPerYear, rounding)
+}
+
+func (mdb *MemoryDB) GetCompoundedAvg(symbol string, date time.Time, calDays, daysPerYear int, rounding int) (*InterestRate, error) {
+	return getCompoundedAvg(mdb, symbol, date, calDays, daysPerYear, rounding)
+}
+
+func (mdb *MemoryDB) GetCompoundedAvgRange(symbol string, dateInit, dateFinal time.Time, calDays, daysPerYear int, rounding int) ([]*InterestRate, error) {
+	return getCompoundedAvgRange(mdb, symbol, dateInit, dateFinal, calDays, daysPerYear, rounding)
+}
+
+func (mdb *MemoryDB) GetCompoundedAvgDIARange(symbol string, dateInit, dateFinal time.Time, calDays, daysPerYear int, rounding int) ([]*InterestRate, error) {
+	return getCompoundedAvgDIARange(mdb, symbol, dateInit, dateFinal, calDays, daysPerYear, rounding)
+}
+
+// ------------------------------------------------------------------------------
+// DEFI AND FARMING POOLS
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SetFarmingPool(pool *FarmingPool) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.farmingPools = append(mdb.farmingPools, *pool)
+	sort.SliceStable(mdb.farmingPools, func(i, j int) bool {
+		return mdb.farmingPools[i].TimeStamp.Before(mdb.farmingPools[j].TimeStamp)
+	})
+	return nil
+}
+
+// GetFarmingPools returns all pools, identified by protocol and pool ID.
+func (mdb *MemoryDB) GetFarmingPools() ([]FarmingPoolType, error) {
+	var retval []FarmingPoolType
+	seen := make(map[string]struct{})
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, pool := range mdb.farmingPools {
+		key := pool.ProtocolName + "_" + pool.PoolID
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		retval = append(retval, FarmingPoolType{
+			ProtocolName: pool.ProtocolName,
+			InputAsset:   pool.InputAsset,
+			PoolID:       pool.PoolID,
+		})
+	}
+	if len(retval) == 0 {
+		return retval, errors.New("Error parsing Defi Lending Rate from Database")
+	}
+	return retval, nil
+}
+
+// GetFarmingPoolData returns all farming pool states in the given time range in descending order.
+func (mdb *MemoryDB) GetFarmingPoolData(starttime, endtime time.Time, protocol, poolID string) ([]FarmingPool, error) {
+	retval := []FarmingPool{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.farmingPools) - 1; i >= 0; i-- {
+		pool := mdb.farmingPools[i]
+		if pool.ProtocolName == protocol && pool.PoolID == poolID && pool.TimeStamp.After(starttime) && !pool.TimeStamp.After(endtime) {
+			retval = append(retval, pool)
+		}
+	}
+	if len(retval) == 0 {
+		return retval, errors.New("Error parsing Defi Lending Rate from Database")
+	}
+	return retval, nil
+}
+
+func (mdb *MemoryDB) SetDefiProtocol(protocol dia.DefiProtocol) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.defiProtocols[protocol.Name] = protocol
+	return nil
+}
+
+func (mdb *MemoryDB) GetDefiProtocol(name string) (dia.DefiProtocol, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	protocol, ok := mdb.defiProtocols[name]
+	if !ok {
+		return dia.DefiProtocol{}, redis.Nil
+	}
+	return protocol, nil
+}
+
+func (mdb *MemoryDB) GetDefiProtocols() ([]dia.DefiProtocol, error) {
+	allProtocols := []dia.DefiProtocol{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, protocol := range mdb.defiProtocols {
+		allProtocols = append(allProtocols, protocol)
+	}
+	return allProtocols, nil
+}
+
+func (mdb *MemoryDB) SetDefiRateInflux(rate *dia.DefiRate) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.defiRates = append(mdb.defiRates, *rate)
+	sort.SliceStable(mdb.defiRates, func(i, j int) bool {
+		return mdb.defiRates[i].Timestamp.Before(mdb.defiRates[j].Timestamp)
+	})
+	return nil
+}
+
+func (mdb *MemoryDB) GetDefiRateInflux(starttime time.Time, endtime time.Time, asset string, protocol string) ([]dia.DefiRate, error) {
+	retval := []dia.DefiRate{}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, rate := range mdb.defiRates {
+		if rate.Asset == asset && rate.Protocol == protocol && rate.Timestamp.After(starttime) && rate.Timestamp.Before(endtime) {
+			retval = append(retval, rate)
+		}
+	}
+	if len(retval) == 0 {
+		return retval, errors.New("Error parsing Defi Lending Rate from Database")
+	}
+	return retval, nil
+}
+
+func (mdb *MemoryDB) SetDefiStateInflux(state *dia.DefiProtocolState) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.defiStates = append(mdb.defiStates, *state)
+	sort.SliceStable(mdb.defiStates, func(i, j int) bool {
+		return mdb.defiStates[i].Timestamp.Before(mdb.defiStates[j].Timestamp)
+	})
+	return nil
+}
+
+func (mdb *MemoryDB) GetDefiStateInflux(starttime time.Time, endtime time.Time, protocol string) ([]dia.DefiProtocolState, error) {
+	var retval []dia.DefiProtocolState
+	defiProtocol, err := mdb.GetDefiProtocol(protocol)
+	if err != nil {
+		return retval, err
+	}
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, state := range mdb.defiStates {
+		if state.Protocol.Name == protocol && state.Timestamp.After(starttime) && state.Timestamp.Before(endtime) {
+			state.Protocol = defiProtocol
+			retval = append(retval, state)
+		}
+	}
+	if len(retval) == 0 {
+		return retval, errors.New("Error parsing Defi Lending Rate from Database")
+	}
+	return retval, nil
+}
+
+// ------------------------------------------------------------------------------
+// FOREIGN AND STOCK QUOTATIONS
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SaveForeignQuotationInflux(fq ForeignQuotation) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.foreignQuotations = append(mdb.foreignQuotations, fq)
+	sort.SliceStable(mdb.foreignQuotations, func(i, j int) bool {
+		return mdb.foreignQuotations[i].Time.Before(mdb.foreignQuotations[j].Time)
+	})
+	return nil
+}
+
+// GetForeignQuotationInflux returns the last quotation of @symbol before @timestamp.
+func (mdb *MemoryDB) GetForeignQuotationInflux(symbol, source string, timestamp time.Time) (ForeignQuotation, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.foreignQuotations) - 1; i >= 0; i-- {
+		fq := mdb.foreignQuotations[i]
+		if fq.Symbol == symbol && fq.Source == source && fq.Time.Before(timestamp) {
+			return fq, nil
+		}
+	}
+	return ForeignQuotation{}, nil
+}
+
+// GetForeignPriceYesterday returns the average price of @symbol on @source from yesterday.
+func (mdb *MemoryDB) GetForeignPriceYesterday(symbol, source string) (float64, error) {
+	now := time.Now()
+	timeFinal := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(-time.Second)
+	timeInit := timeFinal.Add(-24 * time.Hour)
+	var price float64
+	var numPrices int
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, fq := range mdb.foreignQuotations {
+		if fq.Symbol == symbol && fq.Source == source && fq.Time.After(timeInit) && fq.Time.Before(timeFinal) {
+			price += fq.Price
+			numPrices++
+		}
+	}
+	if numPrices == 0 {
+		return 0, errors.New("No data available from yesterday")
+	}
+	return price / float64(numPrices), nil
+}
+
+// GetForeignSymbolsInflux returns all symbols quoted by @source in the last 7 days, along with their ITIN.
+func (mdb *MemoryDB) GetForeignSymbolsInflux(source string) (symbols []SymbolShort, err error) {
+	set := make(map[string]struct{})
+	symsUnique := []string{}
+	mdb.mu.RLock()
+	for _, fq := range mdb.foreignQuotations {
+		if fq.Source != source || fq.Time.Before(time.Now().AddDate(0, 0, -7)) {
+			continue
+		}
+		if _, ok := set[fq.Symbol]; !ok {
+			set[fq.Symbol] = struct{}{}
+			symsUnique = append(symsUnique, fq.Symbol)
+		}
+	}
+	mdb.mu.RUnlock()
+	for _, sym := range symsUnique {
+		symbol := SymbolShort{Symbol: sym}
+		itin, err := mdb.GetItinBySymbol(sym)
+		if err == nil {
+			symbol.ITIN = itin.Itin
+		}
+		symbols = append(symbols, symbol)
+	}
+	return
+}
+
+func (mdb *MemoryDB) SetStockQuotation(sq StockQuotation) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.stockQuotations = append(mdb.stockQuotations, sq)
+	sort.SliceStable(mdb.stockQuotations, func(i, j int) bool {
+		return mdb.stockQuotations[i].Time.Before(mdb.stockQuotations[j].Time)
+	})
+	return nil
+}
+
+// GetStockQuotation returns the last quotation of @symbol before @timestamp.
+func (mdb *MemoryDB) GetStockQuotation(symbol string, timestamp time.Time) (StockQuotation, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.stockQuotations) - 1; i >= 0; i-- {
+		sq := mdb.stockQuotations[i]
+		if sq.Symbol == symbol && sq.Time.Before(timestamp) {
+			return sq, nil
+		}
+	}
+	return StockQuotation{}, nil
+}
+
+// ------------------------------------------------------------------------------
+// CRYPTO INDEX
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SetCryptoIndex(index *CryptoIndex) error {
+	mdb.mu.Lock()
+	mdb.cryptoIndices = append(mdb.cryptoIndices, *index)
+	sort.SliceStable(mdb.cryptoIndices, func(i, j int) bool {
+		return mdb.cryptoIndices[i].CalculationTime.Before(mdb.cryptoIndices[j].CalculationTime)
+	})
+	mdb.mu.Unlock()
+	for _, constituent := range index.Constituents {
+		err := mdb.SetCryptoIndexConstituent(&constituent, index.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCryptoIndex returns the latest index @name in the given time range, along with
+// historic prices, supply and its constituents.
+func (mdb *MemoryDB) GetCryptoIndex(starttime time.Time, endtime time.Time, name string) ([]CryptoIndex, error) {
+	var retval []CryptoIndex
+	var found bool
+	var currentIndex CryptoIndex
+	mdb.mu.RLock()
+	for i := len(mdb.cryptoIndices) - 1; i >= 0; i-- {
+		index := mdb.cryptoIndices[i]
+		if index.Name == name && index.CalculationTime.After(starttime) && index.CalculationTime.Before(endtime) {
+			currentIndex = index
+			found = true
+			break
+		}
+	}
+	mdb.mu.RUnlock()
+	if !found {
+		return retval, nil
+	}
+
+	for _, lookback := range []struct {
+		price    *float64
+		duration time.Duration
+	}{
+		{&currentIndex.Price1h, time.Hour},
+		{&currentIndex.Price24h, 24 * time.Hour},
+		{&currentIndex.Price7d, 7 * 24 * time.Hour},
+		{&currentIndex.Price14d, 14 * 24 * time.Hour},
+		{&currentIndex.Price30d, 30 * 24 * time.Hour},
+	} {
+		trade, err := mdb.GetTradeInflux(currentIndex.Name, "", time.Now().Add(-lookback.duration))
+		if err == nil {
+			*lookback.price = trade.EstimatedUSDPrice
+		}
+	}
+	diaSupply, err := mdb.GetLatestSupply(currentIndex.Name)
+	if err == nil {
+		currentIndex.CirculatingSupply = diaSupply.CirculatingSupply
+	}
+
+	var constituents []CryptoIndexConstituent
+	for _, c := range currentIndex.Constituents {
+		curr, err := mdb.GetCryptoIndexConstituents(currentIndex.CalculationTime.Add(-24*time.Hour), endtime, c.Symbol, name)
+		if err != nil {
+			return retval, err
+		}
+		if len(curr) > 0 {
+			constituents = append(constituents, curr[0])
+		}
+	}
+	currentIndex.Constituents = constituents
+	retval = append(retval, currentIndex)
+	return retval, nil
+}
+
+func (mdb *MemoryDB) SetCryptoIndexConstituent(constituent *CryptoIndexConstituent, indexSymbol string) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.indexConstituents = append(mdb.indexConstituents, memoryIndexConstituent{
+		constituent: *constituent,
+		indexSymbol: indexSymbol,
+		time:        time.Now(),
+	})
+	return nil
+}
+
+func (mdb *MemoryDB) GetCryptoIndexConstituents(starttime time.Time, endtime time.Time, symbol string, indexSymbol string) ([]CryptoIndexConstituent, error) {
+	var retval []CryptoIndexConstituent
+	var found bool
+	var currentConstituent CryptoIndexConstituent
+	mdb.mu.RLock()
+	for i := len(mdb.indexConstituents) - 1; i >= 0; i-- {
+		c := mdb.indexConstituents[i]
+		if c.constituent.Symbol == symbol && c.indexSymbol == indexSymbol && c.time.After(starttime) && c.time.Before(endtime) {
+			currentConstituent = c.constituent
+			found = true
+			break
+		}
+	}
+	mdb.mu.RUnlock()
+	if !found {
+		return retval, nil
+	}
+	tmpsymbol := currentConstituent.Symbol
+	if currentConstituent.Symbol != "FTX Token" {
+		tmpsymbol = strings.ToUpper(tmpsymbol)
+	}
+	priceYesterday, _ := mdb.GetLastPriceBefore(tmpsymbol, "MAIR120", "", endtime.AddDate(0, 0, -1))
+	currentConstituent.PriceYesterday = priceYesterday.Price
+	priceYesterweek, _ := mdb.GetLastPriceBefore(tmpsymbol, "MAIR120", "", endtime.AddDate(0, 0, -7))
+	currentConstituent.PriceYesterweek = priceYesterweek.Price
+	retval = append(retval, currentConstituent)
+	return retval, nil
+}
+
+// GetCryptoIndexConstituentPrice returns the latest price of constituent @symbol in the 24 hours before @date.
+func (mdb *MemoryDB) GetCryptoIndexConstituentPrice(symbol string, date time.Time) (float64, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.indexConstituents) - 1; i >= 0; i-- {
+		c := mdb.indexConstituents[i]
+		if c.constituent.Symbol == symbol && c.time.After(date.Add(-24*time.Hour)) && !c.time.After(date) {
+			return c.constituent.Price, nil
+		}
+	}
+	return float64(0), nil
+}
+
+func (mdb *MemoryDB) GetCryptoIndexMintAmounts(symbol string) ([]CryptoIndexMintAmount, error) {
+	return cryptoIndexMintAmounts(symbol)
+}
+
+// ------------------------------------------------------------------------------
+// GITHUB
+// ------------------------------------------------------------------------------
+
+func (mdb *MemoryDB) SetCommit(commit *GithubCommit) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.githubCommits = append(mdb.githubCommits, *commit)
+	sort.SliceStable(mdb.githubCommits, func(i, j int) bool {
+		return mdb.githubCommits[i].Timestamp.Before(mdb.githubCommits[j].Timestamp)
+	})
+	return nil
+}
+
+// GetCommitByDate returns the latest commit from @repository of github user @user before @date.
+func (mdb *MemoryDB) GetCommitByDate(user, repository string, date time.Time) (GithubCommit, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for i := len(mdb.githubCommits) - 1; i >= 0; i-- {
+		commit := mdb.githubCommits[i]
+		if commit.User == user && commit.Repository == repository && commit.Timestamp.Before(date) {
+			return commit, nil
+		}
+	}
+	return GithubCommit{}, nil
+}
+
+// GetCommitByHash returns the commit from @repository of github user @user with hash @hash.
+func (mdb *MemoryDB) GetCommitByHash(user, repository, hash string) (GithubCommit, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	for _, commit := range mdb.githubCommits {
+		if commit.User == user && commit.Repository == repository && commit.Hash == hash {
+			return commit, nil
+		}
+	}
+	return GithubCommit{}, nil
+}
+
+func (mdb *MemoryDB) GetLatestCommit(user, repository string) (GithubCommit, error) {
+	return mdb.GetCommitByDate(user, repository, time.Now())
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	"github.com/go-redis/redis"
+)
+
+func TestMemoryDBNotFound(t *testing.T) {
+	mdb := NewMemoryDataStore()
+	if _, err := mdb.GetQuotation("BTC"); err != redis.Nil {
+		t.Errorf("GetQuotation on empty store returned %v, want redis.Nil.", err)
+	}
+	if _, err := mdb.GetAvailablePairsForExchange(dia.BinanceExchange); err != redis.Nil {
+		t.Errorf("GetAvailablePairsForExchange on empty store returned %v, want redis.Nil.", err)
+	}
+}
+
+func TestMemoryDBZSET(t *testing.T) {
+	mdb := NewMemoryDataStore()
+	key := getKeyFilterZSET(getKey(dia.FilterKing, "BTC", ""))
+	now := time.Now().Unix()
+
+	tables := []struct {
+		value    float64
+		unixTime int64
+	}{
+		{1, now - BiggestWindow - 10},
+		{3, now - 60},
+		{2, now - 120},
+		{4, now},
+	}
+	for _, table := range tables {
+		mdb.setZSETValue(key, table.value, table.unixTime, BiggestWindow)
+	}
+
+	value, unixTime, err := mdb.getZSETLastValue(key)
+	if err != nil || value != 4 || unixTime != now {
+		t.Errorf("Last value was incorrect, got: %v at %v, want: 4 at %v.", value, unixTime, now)
+	}
+	value, err = mdb.getZSETValue(key, now-90)
+	if err != nil || value != 2 {
+		t.Errorf("Value at %v was incorrect, got: %v, want: 2.", now-90, value)
+	}
+	if _, err = mdb.getZSETValue(key, now-BiggestWindow); err == nil {
+		t.Error("Value outside of the window was not purged.")
+	}
+}
+
+func TestMemoryDBTrades(t *testing.T) {
+	mdb := NewMemoryDataStore()
+	t0 := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		trade := &dia.Trade{
+			Symbol: "BTC",
+			Source: dia.BinanceExchange,
+			Price:  float64(i),
+			Time:   t0.Add(time.Duration(4-i) * time.Minute),
+		}
+		if err := mdb.SaveTradeInflux(trade); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trade, err := mdb.GetTradeInflux("BTC", "", t0.Add(150*time.Second))
+	if err != nil || trade.Price != 2 {
+		t.Errorf("Trade before timestamp was incorrect, got: %v, want price 2.", trade)
+	}
+	trades, _ := mdb.GetLastTrades("BTC", dia.BinanceExchange, 2)
+	if len(trades) != 2 || trades[0].Price != 0 || trades[1].Price != 1 {
+		t.Errorf("Last trades were incorrect, got: %v.", trades)
+	}
+	trades, _ = mdb.GetAllTrades(t0.Add(time.Minute), 10)
+	if len(trades) != 4 || trades[0].Price != 3 {
+		t.Errorf("Trades after %v were incorrect, got: %v.", t0.Add(time.Minute), trades)
+	}
+}
+
+func TestMemoryDBCandles(t *testing.T) {
+	mdb := NewMemoryDataStore()
+	t0 := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	trades := []dia.Trade{
+		{Source: dia.BinanceExchange, EstimatedUSDPrice: 10, Volume: 1, Time: t0.Add(10 * time.Second)},
+		{Source: dia.KrakenExchange, EstimatedUSDPrice: 12, Volume: -2, Time: t0.Add(20 * time.Second)},
+		{Source: dia.BinanceExchange, EstimatedUSDPrice: 9, Volume: 1, Time: t0.Add(30 * time.Second)},
+		{Source: dia.BinanceExchange, EstimatedUSDPrice: 11, Volume: -1, Time: t0.Add(40 * time.Second)},
+		// trades without USD price are ignored
+		{Source: dia.BinanceExchange, EstimatedUSDPrice: 0, Volume: 5, Time: t0.Add(50 * time.Second)},
+		{Source: dia.BinanceExchange, EstimatedUSDPrice: 13, Volume: 3, Time: t0.Add(5 * time.Minute)},
+	}
+	for i := range trades {
+		trades[i].Symbol = "BTC"
+		if err := mdb.SaveTradeInflux(&trades[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tables := []struct {
+		exchange string
+		interval string
+		want     []Candle
+	}{
+		{dia.BinanceExchange, "1m", []Candle{
+			{Time: t0, Open: 10, High: 11, Low: 9, Close: 11, Volume: 3},
+			{Time: t0.Add(5 * time.Minute), Open: 13, High: 13, Low: 13, Close: 13, Volume: 3},
+		}},
+		{"", "1h", []Candle{
+			{Time: t0, Open: 10, High: 13, Low: 9, Close: 13, Volume: 8},
+		}},
+	}
+	for _, table := range tables {
+		candles, err := mdb.GetCandles("BTC", table.exchange, table.interval, t0.Add(30*time.Second), t0.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(candles) != len(table.want) {
+			t.Errorf("Candles of %s on %q were incorrect, got: %v, want: %v.", table.interval, table.exchange, candles, table.want)
+			continue
+		}
+		for i, want := range table.want {
+			want.Symbol, want.Exchange = "BTC", table.exchange
+			if candles[i] != want {
+				t.Errorf("Candle %d of %s on %q was incorrect, got: %v, want: %v.", i, table.interval, table.exchange, candles[i], want)
+			}
+		}
+	}
+	if _, err := mdb.GetCandles("BTC", "", "2m", t0, t0.Add(time.Hour)); err == nil {
+		t.Errorf("Candles of unknown interval returned no error.")
+	}
+}
+
+func TestMemoryDBBatchQuotations(t *testing.T) {
+	mdb := NewMemoryDataStore()
+	t0 := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	mdb.SetQuotation(&Quotation{Symbol: "BTC", Price: 10000, Time: t0})
+	mdb.SetQuotation(&Quotation{Symbol: "ETH", Price: 400, Time: t0})
+	mdb.SetSupply(&dia.Supply{Symbol: "BTC", CirculatingSupply: 10, Time: t0.Add(-time.Hour)})
+	mdb.SetSupply(&dia.Supply{Symbol: "BTC", CirculatingSupply: 20, Time: t0})
+	mdb.SaveFilterInflux(dia.FilterKing, "BTC", "", 9000, t0.Add(-30*time.Minute))
+
+	tables := []struct {
+		symbol    string
+		timestamp time.Time
+		marketCap float64
+		errors    int
+	}{
+		{"BTC", time.Time{}, 200000, 0},
+		// ETH has no supply
+		{"ETH", time.Time{}, 0, 1},
+		{"XRP", time.Time{}, 0, 2},
+		// no price at or before the timestamp
+		{"BTC", t0.Add(-time.Hour), 0, 1},
+		{"BTC", t0.Add(-30 * time.Minute), 90000, 0},
+		{"BTC", t0, 180000, 0},
+	}
+	for _, table := range tables {
+		quotations, err := mdb.GetBatchQuotations([]string{table.symbol}, table.timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := quotations[0]
+		if q.Symbol != table.symbol || q.MarketCap != table.marketCap || len(q.Errors) != table.errors {
+			t.Errorf("Batch quotation of %s at %v was incorrect, got: %v with errors %v, want: %v with %d errors.", table.symbol, table.timestamp, q.MarketCap, q.Errors, table.marketCap, table.errors)
+		}
+	}
+
+	quotations, _ := mdb.GetBatchQuotations([]string{"ETH", "XRP", "BTC"}, time.Time{})
+	for i, symbol := range []string{"ETH", "XRP", "BTC"} {
+		if quotations[i].Symbol != symbol {
+			t.Errorf("Order of batch quotations was incorrect, got: %s, want: %s.", quotations[i].Symbol, symbol)
+		}
+	}
+}
+
+func TestAggregateFilterPoints(t *testing.T) {
+	t0 := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	points := []memoryFilterPoint{
+		{value: 1, time: t0},
+		{value: 3, time: t0.Add(2 * time.Minute)},
+		{value: 5, time: t0.Add(6 * time.Minute)},
+	}
+
+	tables := []struct {
+		sum    bool
+		values []float64
+	}{
+		{false, []float64{2, 5}},
+		{true, []float64{4, 5}},
+	}
+	for _, table := range tables {
+		result := aggregateFilterPoints(points, 5*time.Minute, table.sum)
+		if len(result) != len(table.values) {
+			t.Fatalf("Number of buckets was incorrect, got: %v, want: %v.", len(result), len(table.values))
+		}
+		for i := range result {
+			if result[i].value != table.values[i] {
+				t.Errorf("Bucket %v was incorrect, got: %v, want: %v.", i, result[i].value, table.values[i])
+			}
+		}
+	}
+}
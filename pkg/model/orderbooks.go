@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	clientInfluxdb "github.com/influxdata/influxdb1-client/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+const influxDbOrderBookMetricsTable = "orderbookMetrics"
+
+// SaveOrderBookMetricsInflux stores the depth metrics of an order book to an influx batch.
+func (db *DB) SaveOrderBookMetricsInflux(metrics dia.OrderBookMetrics) error {
+	tags := map[string]string{
+		"symbol":   metrics.Symbol,
+		"exchange": metrics.Exchange,
+		"pair":     metrics.Pair,
+	}
+	fields := map[string]interface{}{
+		"bestBid":     metrics.BestBid,
+		"bestAsk":     metrics.BestAsk,
+		"spread":      metrics.Spread,
+		"bidDepthUSD": metrics.BidDepthUSD,
+		"askDepthUSD": metrics.AskDepthUSD,
+	}
+
+	pt, err := clientInfluxdb.NewPoint(influxDbOrderBookMetricsTable, tags, fields, metrics.Time)
+	if err != nil {
+		log.Errorln("NewOrderBookMetricsInflux:", err)
+	} else {
+		db.addPoint(pt)
+	}
+	return err
+}
+
+// GetOrderBookMetricsInflux returns the order book metrics of @symbol on @exchange between
+// @starttime and @endtime in descending order. The metrics of all exchanges are returned if
+// @exchange is empty.
+func (db *DB) GetOrderBookMetricsInflux(symbol string, exchange string, starttime time.Time, endtime time.Time) ([]dia.OrderBookMetrics, error) {
+	retval := []dia.OrderBookMetrics{}
+	query := newInfluxSelect(influxDbOrderBookMetricsTable, "askDepthUSD", "bestAsk", "bestBid", "bidDepthUSD", "exchange", "pair", "spread").
+		where("symbol", symbol)
+	if exchange != "" {
+		query.where("exchange", exchange)
+	}
+	q, err := query.after(starttime).before(endtime).orderDesc().build()
+	if err != nil {
+		return retval, err
+	}
+	res, err := queryInfluxDB(db.influxClient, q)
+	if err != nil {
+		return retval, err
+	}
+
+	if len(res) > 0 && len(res[0].Series) > 0 {
+		for _, vals := range res[0].Series[0].Values {
+			metrics := dia.OrderBookMetrics{Symbol: symbol}
+			metrics.Time, err = time.Parse(time.RFC3339, vals[0].(string))
+			if err != nil {
+				return retval, err
+			}
+			metrics.AskDepthUSD, err = vals[1].(json.Number).Float64()
+			if err != nil {
+				return retval, err
+			}
+			metrics.BestAsk, err = vals[2].(json.Number).Float64()
+			if err != nil {
+				return retval, err
+			}
+			metrics.BestBid, err = vals[3].(json.Number).Float64()
+			if err != nil {
+				return retval, err
+			}
+			metrics.BidDepthUSD, err = vals[4].(json.Number).Float64()
+			if err != nil {
+				return retval, err
+			}
+			metrics.Exchange = vals[5].(string)
+			metrics.Pair = vals[6].(string)
+			metrics.Spread, err = vals[7].(json.Number).Float64()
+			if err != nil {
+				return retval, err
+			}
+			retval = append(retval, metrics)
+		}
+	}
+	return retval, nil
+}
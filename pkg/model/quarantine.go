@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/diadata-org/diadata/pkg/dia"
+	clientInfluxdb "github.com/influxdata/influxdb1-client/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+const influxDbTradesQuarantineTable = "tradesQuarantine"
+
+// QuarantinedTrade is a trade which failed a price sanity check, along with the reason.
+type QuarantinedTrade struct {
+	Trade  dia.Trade
+	Reason string
+}
+
+// SaveQuarantinedTradeInflux stores a trade rejected by a sanity check to an influx batch.
+func (db *DB) SaveQuarantinedTradeInflux(t *dia.Trade, reason string) error {
+	tags := map[string]string{
+		"symbol":   t.Symbol,
+		"exchange": t.Source,
+		"pair":     t.Pair,
+	}
+	fields := map[string]interface{}{
+		"price":             t.Price,
+		"volume":            t.Volume,
+		"estimatedUSDPrice": t.EstimatedUSDPrice,
+		"foreignTradeID":    t.ForeignTradeID,
+		"reason":            reason,
+	}
+
+	pt, err := clientInfluxdb.NewPoint(influxDbTradesQuarantineTable, tags, fields, t.Time)
+	if err != nil {
+		log.Errorln("NewQuarantinedTradeInflux:", err)
+	} else {
+		db.addPoint(pt)
+	}
+	return err
+}
+
+// GetQuarantinedTradesInflux returns the quarantined trades of @symbol between @starttime and @endtime
+// in descending order.
+func (db *DB) GetQuarantinedTradesInflux(symbol string, starttime time.Time, endtime time.Time) ([]QuarantinedTrade, error) {
+	retval := []QuarantinedTrade{}
+	q, err := newInfluxSelect(influxDbTradesQuarantineTable, "estimatedUSDPrice", "exchange", "foreignTradeID", "pair", "price", "reason", "volume").
+		where("symbol", symbol).
+		after(starttime).
+		before(endtime).
+		orderDesc().
+		build()
+	if err != nil {
+		return retval, err
+	}
+	res, err := queryInfluxDB(db.influxClient, q)
+	if err != nil {
+		return retval, err
+	}
+
+	if len(res) > 0 && len(res[0].Series) > 0 {
+		for _, vals := range res[0].Series[0].Values {
+			qt := QuarantinedTrade{}
+			qt.Trade.Symbol = symbol
+			qt.Trade.Time, err = time.Parse(time.RFC3339, vals[0].(string))
+			if err != nil {
+				return retval, err
+			}
+			qt.Trade.EstimatedUSDPrice, err = vals[1].(json.Number).Float64()
+			if err != nil {
+				return retval, err
+			}
+			qt.Trade.Source = vals[2].(string)
+			qt.Trade.ForeignTradeID = vals[3].(string)
+			qt.Trade.Pair = vals[4].(string)
+			qt.Trade.Price, err = vals[5].(json.Number).Float64()
+			if err != nil {
+				return retval, err
+			}
+			qt.Reason = vals[6].(string)
+			qt.Trade.Volume, err = vals[7].(json.Number).Float64()
+			if err != nil {
+				return retval, err
+			}
+			retval = append(retval, qt)
+		}
+	}
+	return retval, nil
+}
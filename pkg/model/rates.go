@@ -140,26 +140,7 @@ func (db *DB) GetRatesMeta() (RatesMeta []InterestRateMeta, err error) {
 		if err != nil {
 			return []InterestRateMeta{}, err
 		}
-		// Number of decimals
-		decimals := 0
-		switch symbol {
-		case "SONIA":
-			decimals = 4
-		case "SOFR":
-			decimals = 2
-		case "SAFR":
-			decimals = 8
-		case "SOFR30":
-			decimals = 5
-		case "SOFR90":
-			decimals = 5
-		case "SOFR180":
-			decimals = 5
-		case "ESTER":
-			decimals = 3
-		default:
-			decimals = 8
-		}
+		decimals := rateDecimals(symbol)
 		// Fill meta type
 		newEntry := InterestRateMeta{symbol, newdate, decimals, issuer}
 		RatesMeta = append(RatesMeta, newEntry)
@@ -167,6 +148,29 @@ func (db *DB) GetRatesMeta() (RatesMeta []InterestRateMeta, err error) {
 	return
 }
 
+// rateDecimals returns the number of decimals the rate @symbol is published with.
+func rateDecimals(symbol string) (decimals int) {
+	switch symbol {
+	case "SONIA":
+		decimals = 4
+	case "SOFR":
+		decimals = 2
+	case "SAFR":
+		decimals = 8
+	case "SOFR30":
+		decimals = 5
+	case "SOFR90":
+		decimals = 5
+	case "SOFR180":
+		decimals = 5
+	case "ESTER":
+		decimals = 3
+	default:
+		decimals = 8
+	}
+	return
+}
+
 // GetIssuer returns the issuing entity of the rate given by @symbol
 func (db *DB) GetIssuer(symbol string) (string, error) {
 	newdate, err := db.GetFirstDate(symbol)
@@ -208,9 +212,22 @@ func (db *DB) GetFirstDate(symbol string) (time.Time, error) {
 // Risk-free rates methods
 // ---------------------------------------------------------------------------------------
 
+// rateStore is the part of a datastore the risk-free rate computations depend on.
+// It allows all Datastore backends to share the computations below.
+type rateStore interface {
+	GetFirstDate(symbol string) (time.Time, error)
+	GetInterestRate(symbol, date string) (*InterestRate, error)
+	GetInterestRateRange(symbol, dateInit, dateFinal string) ([]*InterestRate, error)
+	GetCompoundedRate(symbol string, dateInit, date time.Time, daysPerYear int, rounding int) (*InterestRate, error)
+}
+
 // GetCompoundedRate returns the compounded rate for the period @dateInit to @date. It computes the rate for all
 // days for which an entry is present in the database. All other days are assumed to be holidays (or weekends).
 func (db *DB) GetCompoundedRate(symbol string, dateInit, date time.Time, daysPerYear int, rounding int) (*InterestRate, error) {
+	return getCompoundedRate(db, symbol, dateInit, date, daysPerYear, rounding)
+}
+
+func getCompoundedRate(db rateStore, symbol string, dateInit, date time.Time, daysPerYear int, rounding int) (*InterestRate, error) {
 
 	// Get first publication date for the rate with @symbol in order to check feasibility of dateInit
 	firstPublication, err := db.GetFirstDate(symbol)
@@ -280,6 +297,10 @@ func (db *DB) GetCompoundedRate(symbol string, dateInit, date time.Time, daysPer
 
 // GetCompoundedIndex returns the compounded index over the maximal period of existence of @symbol
 func (db *DB) GetCompoundedIndex(symbol string, date time.Time, daysPerYear int, rounding int) (*InterestRate, error) {
+	return getCompoundedIndex(db, symbol, date, daysPerYear, rounding)
+}
+
+func getCompoundedIndex(db rateStore, symbol string, date time.Time, daysPerYear int, rounding int) (*InterestRate, error) {
 	// Get initial date for the rate with @symbol
 	dateInit, err := db.GetFirstDate(symbol)
 	if err != nil {
@@ -290,6 +311,10 @@ func (db *DB) GetCompoundedIndex(symbol string, date time.Time, daysPerYear int,
 
 // GetCompoundedIndexRange returns the compounded average of the index @symbol over rolling @calDays calendar days.
 func (db *DB) GetCompoundedIndexRange(symbol string, dateInit, dateFinal time.Time, daysPerYear int, rounding int) (values []*InterestRate, err error) {
+	return getCompoundedIndexRange(db, symbol, dateInit, dateFinal, daysPerYear, rounding)
+}
+
+func getCompoundedIndexRange(db rateStore, symbol string, dateInit, dateFinal time.Time, daysPerYear int, rounding int) (values []*InterestRate, err error) {
 
 	// Get first publication date for the rate with @symbol in order to check feasibility of dateInit
 	firstPublication, err := db.GetFirstDate(symbol)
@@ -369,6 +394,10 @@ func (db *DB) GetCompoundedIndexRange(symbol string, dateInit, dateFinal time.Ti
 
 // GetCompoundedAvg returns the compounded average of the index @symbol over rolling @calDays calendar days.
 func (db *DB) GetCompoundedAvg(symbol string, date time.Time, calDays, daysPerYear int, rounding int) (*InterestRate, error) {
+	return getCompoundedAvg(db, symbol, date, calDays, daysPerYear, rounding)
+}
+
+func getCompoundedAvg(db rateStore, symbol string, date time.Time, calDays, daysPerYear int, rounding int) (*InterestRate, error) {
 
 	dateInit := date.AddDate(0, 0, -calDays)
 
@@ -433,6 +462,10 @@ func WeightedRates(intRates []*InterestRate, dateInit, dateFinal time.Time, holi
 
 // GetCompoundedAvgRange returns the compounded average of the index @symbol over rolling @calDays calendar days.
 func (db *DB) GetCompoundedAvgRange(symbol string, dateInit, dateFinal time.Time, calDays, daysPerYear int, rounding int) (values []*InterestRate, err error) {
+	return getCompoundedAvgRange(db, symbol, dateInit, dateFinal, calDays, daysPerYear, rounding)
+}
+
+func getCompoundedAvgRange(db rateStore, symbol string, dateInit, dateFinal time.Time, calDays, daysPerYear int, rounding int) (values []*InterestRate, err error) {
 
 	dateStart := dateInit.AddDate(0, 0, -calDays)
 
@@ -575,6 +608,10 @@ func StraightRates(intRates []*InterestRate) map[time.Time]float64 {
 
 // GetCompoundedAvgDIARange returns the compounded average DIA index of @symbol over rolling @calDays calendar days.
 func (db *DB) GetCompoundedAvgDIARange(symbol string, dateInit, dateFinal time.Time, calDays, daysPerYear int, rounding int) (values []*InterestRate, err error) {
+	return getCompoundedAvgDIARange(db, symbol, dateInit, dateFinal, calDays, daysPerYear, rounding)
+}
+
+func getCompoundedAvgDIARange(db rateStore, symbol string, dateInit, dateFinal time.Time, calDays, daysPerYear int, rounding int) (values []*InterestRate, err error) {
 
 	dateStart := dateInit.AddDate(0, 0, -calDays)
 
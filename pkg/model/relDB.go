@@ -56,11 +56,21 @@ type RelDatastore interface {
 	SetBlockData(dia.BlockData) error
 	GetBlockData(blockchain string, blocknumber int64) (dia.BlockData, error)
 	GetLastBlockBlockscraper(blockchain string) (int64, error)
+
+	// API keys
+	SetAPIKey(key dia.APIKey) error
+	GetAPIKey(key string) (dia.APIKey, error)
+	GetAPIKeys() ([]dia.APIKey, error)
+	RotateAPIKey(key string, secretHash string) error
+	RevokeAPIKey(key string) error
+	IncrementAPIKeyUsage(key string, t time.Time) (int64, error)
+	GetAPIKeyUsage(key string, starttime time.Time, endtime time.Time) ([]dia.APIKeyUsage, error)
 }
 
 const (
 	postgresKey = "postgres_credentials.txt"
 
+	apikeyTable      = "apikey"
 	blockchainTable  = "blockchain"
 	blockdataTable   = "blockdata"
 	nftcategoryTable = "nftcategory"
@@ -41,8 +41,15 @@ func (db *DB) SetStockQuotation(sq StockQuotation) error {
 func (db *DB) GetStockQuotation(symbol string, timestamp time.Time) (StockQuotation, error) {
 	retval := StockQuotation{}
 
-	unixtime := timestamp.UnixNano()
-	q := fmt.Sprintf("SELECT priceAsk,priceBid,sizeAsk,sizeBid,source,\"isin\",\"name\" FROM %s WHERE \"symbol\"='%s' and time<%d order by time desc limit 1", influxDbStockQuotationsTable, symbol, unixtime)
+	q, err := newInfluxSelect(influxDbStockQuotationsTable, "priceAsk", "priceBid", "sizeAsk", "sizeBid", "source", "\"isin\"", "\"name\"").
+		where("symbol", symbol).
+		before(timestamp).
+		orderDesc().
+		limitTo(1).
+		build()
+	if err != nil {
+		return retval, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		fmt.Println("Error querying influx")
@@ -131,11 +131,16 @@ func (db *DB) GetSymbolDetails(symbol string) (*SymbolDetails, error) {
 }
 
 func (db *DB) getSymbolDetails(symbol string) (*SymbolDetails, error) {
-	q, err := db.GetQuotation(symbol)
+	return buildSymbolDetails(db, symbol)
+}
+
+// buildSymbolDetails collects quotation, supply and per-exchange details of @symbol from @ds.
+func buildSymbolDetails(ds Datastore, symbol string) (*SymbolDetails, error) {
+	q, err := ds.GetQuotation(symbol)
 	if err != nil {
 		return nil, err
 	} else {
-		itin, err := db.GetItinBySymbol(q.Symbol)
+		itin, err := ds.GetItinBySymbol(q.Symbol)
 		if err != nil {
 			log.Error("Error retrieving ITIN:", err)
 			itin.Itin = "undefined"
@@ -152,15 +157,15 @@ func (db *DB) getSymbolDetails(symbol string) (*SymbolDetails, error) {
 			},
 			Exchanges: []SymbolExchangeDetails{},
 		}
-		r.Change, _ = db.GetCurrencyChange()
-		s, err := db.GetLatestSupply(symbol)
+		r.Change, _ = ds.GetCurrencyChange()
+		s, err := ds.GetLatestSupply(symbol)
 		if err == nil {
 			r.Coin.CirculatingSupply = &s.CirculatingSupply
 		}
-		exs, err := db.GetExchangesForSymbol(symbol)
+		exs, err := ds.GetExchangesForSymbol(symbol)
 		if err == nil {
 			for _, e := range exs {
-				s, err2 := db.GetSymbolExchangeDetails(symbol, e)
+				s, err2 := ds.GetSymbolExchangeDetails(symbol, e)
 				if err2 == nil {
 					if s.VolumeYesterdayUSD != nil {
 						r.Exchanges = append(r.Exchanges, *s)
@@ -170,7 +175,7 @@ func (db *DB) getSymbolDetails(symbol string) (*SymbolDetails, error) {
 				}
 			}
 		}
-		r.Gfx1, err = db.GetFilterPoints("MA120", "", symbol, "", time.Time{}, time.Now())
+		r.Gfx1, err = ds.GetFilterPoints("MA120", "", symbol, "", time.Time{}, time.Now())
 		if r.Gfx1 == nil || err != nil {
 			log.Error("Couldnt fetch points for ", symbol, err)
 		}
@@ -2,7 +2,6 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/diadata-org/diadata/pkg/dia"
@@ -75,11 +74,14 @@ func parseTrade(row []interface{}) *dia.Trade {
 	return nil
 }
 
-// GetAllTrades returns at most @maxTrades trades from influx with timestamp > @t. Only used by replayInflux option.
+// GetAllTrades returns at most @maxTrades trades from influx with timestamp >= @t. Only used by replayInflux option.
 func (db *DB) GetAllTrades(t time.Time, maxTrades int) ([]dia.Trade, error) {
 	r := []dia.Trade{}
 	// TO DO: Substitute select * with precise statment select estimatedUSDPrice, source,...
-	q := fmt.Sprintf("SELECT * FROM %s WHERE time > %d LIMIT %d", influxDbTradesTable, t.Unix()*1000000000, maxTrades)
+	q, err := newInfluxSelect(influxDbTradesTable, "*").atOrAfter(t).limitTo(maxTrades).build()
+	if err != nil {
+		return r, err
+	}
 	log.Debug(q)
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
@@ -101,7 +103,15 @@ func (db *DB) GetAllTrades(t time.Time, maxTrades int) ([]dia.Trade, error) {
 
 func (db *DB) GetLastTrades(symbol string, exchange string, maxTrades int) ([]dia.Trade, error) {
 	r := []dia.Trade{}
-	q := fmt.Sprintf("SELECT * FROM %s WHERE exchange='%s' and symbol='%s' ORDER BY DESC LIMIT %d", influxDbTradesTable, exchange, symbol, maxTrades)
+	q, err := newInfluxSelect(influxDbTradesTable, "*").
+		where("exchange", exchange).
+		where("symbol", symbol).
+		orderDesc().
+		limitTo(maxTrades).
+		build()
+	if err != nil {
+		return r, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		log.Errorln("GetLastTrades", err)
@@ -123,7 +133,14 @@ func (db *DB) GetLastTrades(symbol string, exchange string, maxTrades int) ([]di
 
 func (db *DB) GetLastTradesAllExchanges(symbol string, maxTrades int) ([]dia.Trade, error) {
 	r := []dia.Trade{}
-	q := fmt.Sprintf("SELECT * FROM %s WHERE symbol='%s' ORDER BY DESC LIMIT %d", influxDbTradesTable, symbol, maxTrades)
+	q, err := newInfluxSelect(influxDbTradesTable, "*").
+		where("symbol", symbol).
+		orderDesc().
+		limitTo(maxTrades).
+		build()
+	if err != nil {
+		return r, err
+	}
 	res, err := queryInfluxDB(db.influxClient, q)
 	if err != nil {
 		log.Errorln("GetLastTrades", err)
@@ -0,0 +1,35 @@
+# Prints the continuous queries rolling up the trades into the candles read by the
+# candles endpoints, e.g. ./influxdbCandles.sh | influx -database dia
+# Pass "backfill" to print the queries rolling up the trades of the past year instead.
+DB="dia"
+MODE=$1
+
+# interval and the time each bucket is recomputed for, so that late trades are included
+for a in "1m 10m" "5m 15m" "15m 30m" "30m 1h" "1h 2h" "4h 8h" "1d 2d" "1w 2w"
+	do
+	set -- $a
+	INTERVAL=$1
+	RESAMPLE=$2
+	TARGET="\"a_year\".\"candles_$INTERVAL\""
+	PRICE="SELECT first(\"estimatedUSDPrice\") AS open, max(\"estimatedUSDPrice\") AS high, min(\"estimatedUSDPrice\") AS low, last(\"estimatedUSDPrice\") AS close INTO $TARGET FROM \"trades\" WHERE \"estimatedUSDPrice\" > 0"
+	BUYS="SELECT sum(\"volume\") AS buyVolume INTO $TARGET FROM \"trades\" WHERE \"estimatedUSDPrice\" > 0 AND \"volume\" > 0"
+	SELLS="SELECT sum(\"volume\") AS sellVolume INTO $TARGET FROM \"trades\" WHERE \"estimatedUSDPrice\" > 0 AND \"volume\" < 0"
+	# candles of single exchanges and, without exchange tag, of all exchanges
+	for GROUP in "symbol, exchange" "symbol"
+		do
+		SUFFIX=""
+		if [ "$GROUP" = "symbol" ]; then
+			SUFFIX="_all"
+		fi
+		for q in "price:$PRICE" "buys:$BUYS" "sells:$SELLS"
+			do
+			NAME="${q%%:*}"
+			QUERY="${q#*:}"
+			if [ "$MODE" = "backfill" ]; then
+				echo "$QUERY AND time > now() - 52w GROUP BY time($INTERVAL), $GROUP"
+			else
+				echo "CREATE CONTINUOUS QUERY \"cq_candles_${NAME}_$INTERVAL$SUFFIX\" ON \"$DB\" RESAMPLE EVERY $INTERVAL FOR $RESAMPLE BEGIN $QUERY GROUP BY time($INTERVAL), $GROUP END"
+			fi
+		done
+	done
+done